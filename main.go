@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"image/color"
 	"log"
 	"os"
@@ -10,10 +11,13 @@ import (
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
 	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
+	"github.com/luo2pei4/ltool/pkg/config"
 	"github.com/luo2pei4/ltool/pkg/dblayer"
 	logger "github.com/luo2pei4/ltool/pkg/log"
+	"github.com/luo2pei4/ltool/pkg/utils"
 	"github.com/luo2pei4/ltool/view"
 )
 
@@ -40,31 +44,73 @@ func main() {
 	// init log
 	logger.InitLog("info", path.Join(u.HomeDir, "ltool.log"))
 
+	// load config
+	if err := config.Load(path.Join(u.HomeDir, "ltool.yaml")); err != nil {
+		logger.Errorf("load config failed, %v\n", err)
+		os.Exit(1)
+	}
+	knownHostsFile := config.Conf.SSH.KnownHostsFile
+	if knownHostsFile == "" {
+		knownHostsFile = path.Join(u.HomeDir, "ltool_known_hosts")
+	}
+	utils.SetKnownHosts(knownHostsFile, config.Conf.SSH.UserKnownHosts)
+
 	// init database layer
-	if err := dblayer.Init("sqlite", "./ltool.db"); err != nil {
+	if err := dblayer.Init(config.Conf.Database.DriverName(), config.Conf.Database.DataSource()); err != nil {
 		logger.Errorf("initialize database instance failed, %v\n", err)
+		// e.g. the database was migrated by a newer ltool
+		var tooNewErr *dblayer.SchemaTooNewError
+		if errors.As(err, &tooNewErr) {
+			showFatalError(err)
+		}
 		os.Exit(1)
 	}
 
 	a := app.NewWithID("lustre.gui.tool")
 	topWindow = a.NewWindow("ltool")
 	page := container.NewStack()
+	var current *view.Navi
 	setContent := func(navi view.Navi) {
+		current = &navi
 		v := navi.Content()
 		page.Objects = []fyne.CanvasObject{v.CreateView(topWindow)}
 		page.Refresh()
 	}
 
 	content := container.NewBorder(nil, nil, nil, nil, page)
-	split := container.NewHSplit(makeNav(setContent), content)
+	// the views load the nodes of the new cluster when recreated
+	reload := func() {
+		if current != nil {
+			setContent(*current)
+		}
+	}
+	switcher, restoreCluster := view.NewClusterSwitcher(topWindow, reload)
+	split := container.NewHSplit(makeNav(setContent, switcher), content)
 	split.Offset = 0.2
 	topWindow.SetContent(split)
 
 	topWindow.Resize(fyne.NewSize(1024, 768))
+	// the credentials are encrypted with the master passphrase
+	view.ShowUnlockDialog(topWindow, restoreCluster)
 	topWindow.ShowAndRun()
+
+	// close pooled ssh connections
+	utils.CloseSSHClients()
 }
 
-func makeNav(setContent func(v view.Navi)) fyne.CanvasObject {
+// showFatalError tells the user why ltool cannot start, it returns when
+// the dialog is closed
+func showFatalError(err error) {
+	a := app.NewWithID("lustre.gui.tool")
+	w := a.NewWindow("ltool")
+	w.Resize(fyne.NewSize(480, 200))
+	d := dialog.NewError(err, w)
+	d.SetOnClosed(a.Quit)
+	d.Show()
+	w.ShowAndRun()
+}
+
+func makeNav(setContent func(v view.Navi), switcher fyne.CanvasObject) fyne.CanvasObject {
 	a := fyne.CurrentApp()
 
 	tree := &widget.Tree{
@@ -104,5 +150,9 @@ func makeNav(setContent func(v view.Navi)) fyne.CanvasObject {
 		}),
 	)
 
-	return container.NewBorder(nil, themes, nil, nil, tree)
+	passphraseBtn := widget.NewButton("Change Passphrase", func() {
+		view.ShowChangePassphraseDialog(topWindow)
+	})
+
+	return container.NewBorder(switcher, container.NewVBox(passphraseBtn, themes), nil, nil, tree)
 }
@@ -0,0 +1,127 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/luo2pei4/ltool/pkg/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// Config ltool settings, loaded from ltool.yaml in the home directory
+type Config struct {
+	Database     Database     `yaml:"database"`
+	SSH          SSH          `yaml:"ssh"`
+	Reachability Reachability `yaml:"reachability"`
+	Check        Check        `yaml:"check"`
+}
+
+// Database the backend of the inventory, a shared postgres or mysql
+// database lets several users work on the same nodes
+type Database struct {
+	// Driver sqlite, postgres or mysql
+	Driver string `yaml:"driver"`
+	// DSN the data source, e.g. the file of sqlite or
+	// "host=db01 user=ltool password=secret dbname=ltool" of postgres
+	DSN string `yaml:"dsn"`
+}
+
+// DriverName returns the configured driver, default sqlite
+func (d *Database) DriverName() string {
+	if d.Driver == "" {
+		return "sqlite"
+	}
+	return d.Driver
+}
+
+// DataSource returns the configured dsn, default ./ltool.db of sqlite
+func (d *Database) DataSource() string {
+	if d.DSN == "" && d.DriverName() == "sqlite" {
+		return "./ltool.db"
+	}
+	return d.DSN
+}
+
+type SSH struct {
+	// KnownHostsFile ltool managed known_hosts file, trust on first use
+	KnownHostsFile string `yaml:"known_hosts_file"`
+	// UserKnownHosts also trust the keys in ~/.ssh/known_hosts
+	UserKnownHosts bool `yaml:"user_known_hosts"`
+}
+
+// Reachability how Check decides whether a node is online
+type Reachability struct {
+	// Probes are tried in order until one succeeds: icmp, udp, tcp or ssh
+	Probes []string `yaml:"probes"`
+}
+
+// DefaultProbes falls back to tcp when icmp is blocked or unprivileged
+var DefaultProbes = []string{"icmp", "udp", "tcp"}
+
+// ProbeList returns the configured probes or the default ones
+func (r *Reachability) ProbeList() []string {
+	if len(r.Probes) == 0 {
+		return DefaultProbes
+	}
+	return r.Probes
+}
+
+// validate checks the probe names, a misspelled probe would leave every
+// node unreachable
+func (r *Reachability) validate() error {
+	for _, probe := range r.Probes {
+		if !slices.Contains(utils.Probes, probe) {
+			return fmt.Errorf("unsupported reachability probe '%s', supported are %v", probe, utils.Probes)
+		}
+	}
+	return nil
+}
+
+// Check limits the concurrency of checking the nodes
+type Check struct {
+	// Workers the number of nodes checked at the same time
+	Workers int `yaml:"workers"`
+	// HostTimeout limits probing and reading the host information of a node
+	HostTimeout time.Duration `yaml:"host_timeout"`
+}
+
+// WorkerCount returns the configured workers, default 32
+func (c *Check) WorkerCount() int {
+	if c.Workers <= 0 {
+		return 32
+	}
+	return c.Workers
+}
+
+// Timeout returns the configured host timeout, default 30 seconds
+func (c *Check) Timeout() time.Duration {
+	if c.HostTimeout <= 0 {
+		return 30 * time.Second
+	}
+	return c.HostTimeout
+}
+
+var Conf = &Config{}
+
+// Load reads the config file, the defaults are kept if the file does not exist
+func Load(file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	conf := &Config{}
+	if err := yaml.Unmarshal(data, conf); err != nil {
+		return fmt.Errorf("parse %s failed, %v", file, err)
+	}
+	if err := conf.Reachability.validate(); err != nil {
+		return fmt.Errorf("invalid %s, %v", file, err)
+	}
+	Conf = conf
+	return nil
+}
@@ -0,0 +1,27 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadProbes(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "ltool.yaml")
+	if err := os.WriteFile(file, []byte("reachability:\n  probes: [tcp, ssh]\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := Load(file); err != nil {
+		t.Fatalf("Load failed, %v", err)
+	}
+	if probes := Conf.Reachability.ProbeList(); len(probes) != 2 || probes[0] != "tcp" || probes[1] != "ssh" {
+		t.Errorf("ProbeList = %v, want [tcp ssh]", probes)
+	}
+	if err := os.WriteFile(file, []byte("reachability:\n  probes: [icmp, tpc]\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := Load(file); err == nil || !strings.Contains(err.Error(), "'tpc'") {
+		t.Errorf("Load error = %v, want the unsupported probe", err)
+	}
+}
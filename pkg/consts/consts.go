@@ -6,5 +6,11 @@ const IPv4Pattern = `^(25[0-5]|2[0-4][0-9]|1[0-9]{2}|[1-9]?[0-9])\.` +
 	`(25[0-5]|2[0-4][0-9]|1[0-9]{2}|[1-9]?[0-9])$`
 
 const (
-	TableNodes = "nodes"
+	TableNodes         = "nodes"
+	TableSettings      = "settings"
+	TableJumpHosts     = "jump_hosts"
+	TableClusters      = "clusters"
+	TableTags          = "tags"
+	TableNodeTags      = "node_tags"      // the tags of the nodes
+	TableSchemaVersion = "schema_version" // the applied migrations
 )
@@ -0,0 +1,102 @@
+package dblayer
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/luo2pei4/ltool/pkg/dblayer/repo"
+)
+
+func TestClusters(t *testing.T) {
+	db := openTestDB(t, filepath.Join(t.TempDir(), "ltool.db"))
+	if err := migrate(db, "sqlite", nil); err != nil {
+		t.Fatal(err)
+	}
+	s := &gormLayer{DB: db}
+	if s.ClusterID() != DefaultCluster {
+		t.Fatalf("active cluster is %d", s.ClusterID())
+	}
+	lustre2 := repo.Cluster{Name: "lustre2", UserName: "admin"}
+	if err := s.SaveCluster(&lustre2); err != nil || lustre2.ID == 0 {
+		t.Fatalf("save cluster failed, %v, id %d", err, lustre2.ID)
+	}
+	if err := s.SaveCluster(&repo.Cluster{Name: "lustre2"}); err == nil {
+		t.Error("a duplicate cluster name was saved")
+	}
+	clusters, err := s.ListClusters()
+	if err != nil || len(clusters) != 2 || clusters[0].Name != "default" {
+		t.Fatalf("clusters are %+v, %v", clusters, err)
+	}
+
+	// the same address and jump host name in two clusters
+	if err := s.SaveJumpHost(&repo.JumpHost{Name: "bastion", Address: "10.0.0.254"}); err != nil {
+		t.Fatal(err)
+	}
+	err = s.AddNodes([]repo.Node{
+		{IPAddress: "10.0.0.1", GroupName: "mds", ProxyJump: "bastion"},
+		{IPAddress: "10.0.0.2", GroupName: "oss"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetNodeTags("10.0.0.1", []string{"rack1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetSetting("global", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetClusterSetting("node_search", "group:mds"); err != nil {
+		t.Fatal(err)
+	}
+	s.UseCluster(lustre2.ID)
+	if nodes, _ := s.ListNodes(NodeQuery{}); len(nodes) != 0 {
+		t.Errorf("nodes of the new cluster are %+v", nodes)
+	}
+	if err := s.AddNodes([]repo.Node{{IPAddress: "10.0.0.2", GroupName: "client"}}); err != nil {
+		t.Fatalf("adding an address of another cluster failed, %v", err)
+	}
+	if value, _ := s.GetClusterSetting("node_search"); value != "" {
+		t.Errorf("setting of the other cluster is %q", value)
+	}
+	if value, _ := s.GetSetting("global"); value != "1" {
+		t.Errorf("global setting is %q", value)
+	}
+	if groups, _ := s.ListGroups(); len(groups) != 1 || groups[0] != "client" {
+		t.Errorf("groups are %v", groups)
+	}
+
+	// copy the nodes of the default cluster to lustre2
+	s.UseCluster(DefaultCluster)
+	copied, err := s.CopyNodes([]string{"10.0.0.1", "10.0.0.2"}, lustre2.ID)
+	if err != nil || copied != 1 {
+		t.Fatalf("copied %d nodes, %v", copied, err)
+	}
+	s.UseCluster(lustre2.ID)
+	nodes, _ := s.ListNodes(NodeQuery{SortBy: SortByIP})
+	if len(nodes) != 2 || nodes[0].IPAddress != "10.0.0.1" || nodes[1].GroupName != "client" {
+		t.Fatalf("nodes after copy are %+v", nodes)
+	}
+	if tags, _ := s.ListNodeTags([]string{"10.0.0.1"}); len(tags["10.0.0.1"]) != 1 {
+		t.Errorf("tags after copy are %v", tags)
+	}
+	if jumpHosts, _ := s.ListJumpHosts(); len(jumpHosts) != 1 || jumpHosts[0].Address != "10.0.0.254" {
+		t.Errorf("jump hosts after copy are %+v", jumpHosts)
+	}
+
+	// deleting the cluster keeps the others
+	s.UseCluster(DefaultCluster)
+	if err := s.DeleteCluster(lustre2.ID); err != nil {
+		t.Fatal(err)
+	}
+	if nodes, _ := s.ListNodes(NodeQuery{}); len(nodes) != 2 {
+		t.Errorf("nodes of the default cluster are %+v", nodes)
+	}
+	var count int64
+	db.Table("nodes").Count(&count)
+	if count != 2 {
+		t.Errorf("%d nodes are left", count)
+	}
+	if tags, _ := s.ListTags(); len(tags) != 1 {
+		t.Errorf("tags are %v", tags)
+	}
+}
@@ -0,0 +1,313 @@
+package dblayer
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/luo2pei4/ltool/pkg/consts"
+	"github.com/luo2pei4/ltool/pkg/dblayer/repo"
+	"github.com/luo2pei4/ltool/pkg/secret"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// TestConformance runs the same checks on every backend, sqlite is the
+// reference. The other backends are tested against the database of their
+// environment variable, e.g.
+//
+//	LTOOL_TEST_POSTGRES_DSN="host=localhost user=ltool password=ltool dbname=ltool_test"
+//	LTOOL_TEST_MYSQL_DSN="ltool:ltool@tcp(localhost:3306)/ltool_test"
+//
+// The tables of the test database are dropped first.
+func TestConformance(t *testing.T) {
+	backends := []struct {
+		name string
+		env  string
+		open func(dsn string) (gorm.Dialector, error)
+	}{
+		{"postgres", "LTOOL_TEST_POSTGRES_DSN", func(dsn string) (gorm.Dialector, error) {
+			return postgres.Open(dsn), nil
+		}},
+		{"mysql", "LTOOL_TEST_MYSQL_DSN", mysqlDialector},
+	}
+	t.Run("sqlite", func(t *testing.T) {
+		testConformance(t, "sqlite", filepath.Join(t.TempDir(), "ltool.db"))
+	})
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			dsn := os.Getenv(b.env)
+			if dsn == "" {
+				t.Skipf("%s is not set", b.env)
+			}
+			dialector, err := b.open(dsn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dropTables(t, dialector)
+			testConformance(t, b.name, dsn)
+		})
+	}
+}
+
+// dropTables drops the tables left by the last run
+func dropTables(t *testing.T, dialector gorm.Dialector) {
+	t.Helper()
+	db, err := gorm.Open(dialector, &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	}()
+	tables := []string{consts.TableSchemaVersion, consts.TableClusters, consts.TableNodes, consts.TableNodeTags,
+		consts.TableTags, consts.TableJumpHosts, consts.TableSettings}
+	for _, table := range tables {
+		if err := db.Migrator().DropTable(table); err != nil {
+			t.Fatalf("drop table %s failed, %v", table, err)
+		}
+	}
+}
+
+// openBackend opens the backend through the registry like Init
+func openBackend(t *testing.T, name, dsn string) *gormLayer {
+	t.Helper()
+	l, err := dbs[name](dsn)
+	if err != nil {
+		t.Fatalf("open %s failed, %v", name, err)
+	}
+	s := l.(*gormLayer)
+	t.Cleanup(func() {
+		if sqlDB, err := s.DB.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return s
+}
+
+func testConformance(t *testing.T, name, dsn string) {
+	s := openBackend(t, name, dsn)
+	// the passphrase functions use the global layer and cipher
+	prevDB, prevCipher := DB, secret.Current()
+	DB = s
+	t.Cleanup(func() {
+		DB = prevDB
+		secret.SetCurrent(prevCipher)
+	})
+
+	// settings, the master passphrase is a global one
+	if err := SetPassphrase("first"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := HasPassphrase(); err != nil || !ok {
+		t.Fatalf("has passphrase %v, %v", ok, err)
+	}
+	if err := Unlock("wrong"); !errors.Is(err, secret.ErrBadPassphrase) {
+		t.Errorf("unlock with a wrong passphrase returned %v", err)
+	}
+	if err := Unlock("first"); err != nil {
+		t.Fatal(err)
+	}
+	for _, value := range []string{"a", "b"} {
+		if err := s.SetClusterSetting("theme", value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if value, err := s.GetClusterSetting("theme"); err != nil || value != "b" {
+		t.Errorf("cluster setting is %q, %v", value, err)
+	}
+	if value, err := s.GetSetting("theme"); err != nil || value != "" {
+		t.Errorf("global setting is %q, %v", value, err)
+	}
+
+	// nodes and their credentials
+	day := time.Date(2026, 1, 10, 8, 30, 0, 0, time.Local)
+	err := s.SaveJumpHost(&repo.JumpHost{Name: "bastion", Address: "10.0.0.254", UserName: "jump", Password: "jumppass"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.AddNodes([]repo.Node{
+		{IPAddress: "10.0.0.1", UserName: "root", Password: "rootpass", Hostname: "mds01", OS: "Rocky Linux 9.4",
+			GroupName: "mds", ProxyJump: "bastion", SSHPort: 2222, CreateTime: day, UpdateTime: day},
+		{IPAddress: "10.0.0.2", UserName: "root", Hostname: "oss01", GroupName: "oss",
+			CreateTime: day, UpdateTime: day.AddDate(0, 0, 1)},
+		{IPAddress: "10.0.1.3", UserName: "root", Hostname: "client_1", CreateTime: day, UpdateTime: day.AddDate(0, 0, 2)},
+		{IPAddress: "fd00::4", UserName: "root", Hostname: "clientx1", CreateTime: day, UpdateTime: day.AddDate(0, 0, 3)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddNodes([]repo.Node{{IPAddress: "10.0.0.1", CreateTime: day, UpdateTime: day}}); err == nil {
+		t.Error("a duplicate node was added")
+	}
+	if saved, err := s.SavedNodes([]string{"10.0.9.9", "10.0.0.1", "fd00::4"}); err != nil || len(saved) != 2 {
+		t.Errorf("saved nodes are %v, %v", saved, err)
+	}
+	node, err := s.FindNode("10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node.Password != "rootpass" || node.SSHPort != 2222 || node.ClusterID != DefaultCluster || !node.UpdateTime.Equal(day) {
+		t.Errorf("found node %+v", node)
+	}
+	// unlocking encrypts the plain text left by older versions only
+	rawPassword := func(table, where, value string) string {
+		t.Helper()
+		var raw string
+		if err := s.Table(table).Select("password").Where(where+" = ?", value).Scan(&raw).Error; err != nil {
+			t.Fatal(err)
+		}
+		return raw
+	}
+	jumpRaw := rawPassword(consts.TableJumpHosts, "name", "bastion")
+	if err := s.Table(consts.TableNodes).Where("ip_address = ?", "10.0.0.2").Update("password", "plainpass").Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := Unlock("first"); err != nil {
+		t.Fatal(err)
+	}
+	if raw := rawPassword(consts.TableNodes, "ip_address", "10.0.0.2"); !secret.IsEncrypted(raw) {
+		t.Errorf("plain text password was left as %q", raw)
+	}
+	if raw := rawPassword(consts.TableJumpHosts, "name", "bastion"); raw != jumpRaw {
+		t.Error("encrypted password was rewritten")
+	}
+	if node, err := s.FindNode("10.0.0.2"); err != nil || node.Password != "plainpass" {
+		t.Errorf("encrypted node %+v, %v", node, err)
+	}
+	if err := s.UpdateNodeStatus("10.0.0.1", "online", day); err != nil {
+		t.Fatal(err)
+	}
+	node.Kernel = "5.14.0"
+	node.Password = ""
+	if err := s.UpdateNode(node); err != nil {
+		t.Fatal(err)
+	}
+	if node, err = s.FindNode("10.0.0.1"); err != nil || node.Kernel != "5.14.0" || node.Password != "" ||
+		node.Status != "online" || !node.StatusTime.Equal(day) {
+		t.Errorf("updated node %+v, %v", node, err)
+	}
+
+	// searching the nodes
+	if err := s.SetNodeTags("10.0.0.2", []string{"lustre", "rack1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetNodeTags("10.0.1.3", []string{"rack1"}); err != nil {
+		t.Fatal(err)
+	}
+	ips := func(q NodeQuery) []string {
+		t.Helper()
+		nodes, err := s.ListNodes(q)
+		if err != nil {
+			t.Fatalf("list %+v failed, %v", q, err)
+		}
+		list := []string{}
+		for _, node := range nodes {
+			list = append(list, node.IPAddress)
+		}
+		return list
+	}
+	cases := []struct {
+		q    NodeQuery
+		want []string
+	}{
+		{NodeQuery{}, []string{"10.0.0.1", "10.0.0.2", "10.0.1.3", "fd00::4"}},
+		{NodeQuery{IP: "10.0.0."}, []string{"10.0.0.1", "10.0.0.2"}},
+		{NodeQuery{Hostname: "CLIENT_"}, []string{"10.0.1.3"}},
+		{NodeQuery{OS: "rocky"}, []string{"10.0.0.1"}},
+		{NodeQuery{Group: "oss"}, []string{"10.0.0.2"}},
+		{NodeQuery{Tag: "rack1"}, []string{"10.0.0.2", "10.0.1.3"}},
+		{NodeQuery{Status: StatusUnknown}, []string{"10.0.0.2", "10.0.1.3", "fd00::4"}},
+		{NodeQuery{UpdatedAfter: day.AddDate(0, 0, 2)}, []string{"10.0.1.3", "fd00::4"}},
+		{NodeQuery{SortBy: SortByHostname, Desc: true, Offset: 1, Limit: 2}, []string{"10.0.0.1", "fd00::4"}},
+	}
+	for _, c := range cases {
+		if got := ips(c.q); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("list %+v got %v, want %v", c.q, got, c.want)
+		}
+	}
+	if count, err := s.CountNodes(NodeQuery{Tag: "rack1", Limit: 1}); err != nil || count != 2 {
+		t.Errorf("count is %d, %v", count, err)
+	}
+	if groups, err := s.ListGroups(); err != nil || !reflect.DeepEqual(groups, []string{"mds", "oss"}) {
+		t.Errorf("groups are %v, %v", groups, err)
+	}
+	if tags, err := s.ListTags(); err != nil || !reflect.DeepEqual(tags, []string{"lustre", "rack1"}) {
+		t.Errorf("tags are %v, %v", tags, err)
+	}
+	nodeTags, err := s.ListNodeTags([]string{"10.0.0.1", "10.0.0.2"})
+	if err != nil || !reflect.DeepEqual(nodeTags, map[string][]string{"10.0.0.2": {"lustre", "rack1"}}) {
+		t.Errorf("node tags are %v, %v", nodeTags, err)
+	}
+
+	// the clusters
+	lustre2 := repo.Cluster{Name: "lustre2", UserName: "admin", AuthType: "password", Password: "adminpass"}
+	if err := s.SaveCluster(&lustre2); err != nil || lustre2.ID <= DefaultCluster {
+		t.Fatalf("save cluster failed, %v, id %d", err, lustre2.ID)
+	}
+	if err := s.SaveCluster(&repo.Cluster{Name: "lustre2"}); err == nil {
+		t.Error("a duplicate cluster was saved")
+	}
+	if cnt, err := s.CopyNodes([]string{"10.0.0.1", "10.0.0.2"}, lustre2.ID); err != nil || cnt != 2 {
+		t.Fatalf("copied %d nodes, %v", cnt, err)
+	}
+	s.UseCluster(lustre2.ID)
+	if cnt, err := s.CountNodes(NodeQuery{}); err != nil || cnt != 2 {
+		t.Errorf("cluster %d has %d nodes, %v", lustre2.ID, cnt, err)
+	}
+	if jumpHosts, err := s.ListJumpHosts(); err != nil || len(jumpHosts) != 1 || jumpHosts[0].Password != "jumppass" {
+		t.Errorf("copied jump hosts are %+v, %v", jumpHosts, err)
+	}
+	if tags, err := s.ListNodeTags([]string{"10.0.0.2"}); err != nil || len(tags["10.0.0.2"]) != 2 {
+		t.Errorf("copied tags are %v, %v", tags, err)
+	}
+	if value, err := s.GetClusterSetting("theme"); err != nil || value != "" {
+		t.Errorf("setting of cluster %d is %q, %v", lustre2.ID, value, err)
+	}
+	if err := s.DeleteNode("10.0.0.2"); err != nil {
+		t.Fatal(err)
+	}
+	if cnt, err := s.DeleteGroup("mds"); err != nil || cnt != 1 {
+		t.Errorf("deleted %d nodes of the group, %v", cnt, err)
+	}
+	s.UseCluster(DefaultCluster)
+	if got := ips(NodeQuery{Group: "mds"}); !reflect.DeepEqual(got, []string{"10.0.0.1"}) {
+		t.Errorf("deleting the group of the copy left %v", got)
+	}
+	if tags, err := s.ListNodeTags([]string{"10.0.0.2"}); err != nil || len(tags["10.0.0.2"]) != 2 {
+		t.Errorf("deleting the copy changed the tags to %v, %v", tags, err)
+	}
+
+	// the credentials of every cluster are encrypted with the new passphrase
+	if err := ChangePassphrase("first", "second"); err != nil {
+		t.Fatal(err)
+	}
+	secret.SetCurrent(nil)
+	if err := Unlock("second"); err != nil {
+		t.Fatal(err)
+	}
+	clusters, err := s.ListClusters()
+	if err != nil || len(clusters) != 2 || clusters[0].Name != "default" || clusters[1].Password != "adminpass" {
+		t.Errorf("clusters are %+v, %v", clusters, err)
+	}
+	if err := s.DeleteCluster(lustre2.ID); err != nil {
+		t.Fatal(err)
+	}
+	if jumpHosts, err := s.ListJumpHosts(); err != nil || len(jumpHosts) != 1 {
+		t.Errorf("jump hosts are %+v, %v", jumpHosts, err)
+	}
+	if err := s.DeleteJumpHost("bastion"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the migrated database is opened again as is
+	again := openBackend(t, name, dsn)
+	if cnt, err := again.CountNodes(NodeQuery{}); err != nil || cnt != 4 {
+		t.Errorf("reopened database has %d nodes, %v", cnt, err)
+	}
+}
@@ -0,0 +1,143 @@
+package dblayer
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/luo2pei4/ltool/pkg/consts"
+	"github.com/luo2pei4/ltool/pkg/dblayer/repo"
+	"gorm.io/gorm"
+)
+
+func (s *gormLayer) ClusterID() int {
+	if id := s.cluster.Load(); id > 0 {
+		return int(id)
+	}
+	return DefaultCluster
+}
+
+func (s *gormLayer) UseCluster(id int) {
+	s.cluster.Store(int64(id))
+}
+
+// nodes scopes the nodes table to the active cluster
+func (s *gormLayer) nodes(tx *gorm.DB) *gorm.DB {
+	return tx.Table(consts.TableNodes).Where("cluster_id = ?", s.ClusterID())
+}
+
+// jumpHosts scopes the jump_hosts table to the active cluster
+func (s *gormLayer) jumpHosts(tx *gorm.DB) *gorm.DB {
+	return tx.Table(consts.TableJumpHosts).Where("cluster_id = ?", s.ClusterID())
+}
+
+func (s *gormLayer) ListClusters() ([]repo.Cluster, error) {
+	var clusters []repo.Cluster
+	err := s.Table(consts.TableClusters).Order("name").Find(&clusters).Error
+	return clusters, err
+}
+
+func (s *gormLayer) SaveCluster(c *repo.Cluster) error {
+	c.UpdateTime = time.Now().Local()
+	if c.ID == 0 {
+		c.CreateTime = c.UpdateTime
+		return s.Table(consts.TableClusters).Create(c).Error
+	}
+	return s.Table(consts.TableClusters).Where("id = ?", c.ID).
+		Select("*").Omit("id", "create_time").Updates(c).Error
+}
+
+func (s *gormLayer) DeleteCluster(id int) error {
+	return s.Transaction(func(tx *gorm.DB) error {
+		err := tx.Table(consts.TableNodeTags).Where("node_id IN (?)",
+			tx.Session(&gorm.Session{NewDB: true}).Table(consts.TableNodes).Select("id").Where("cluster_id = ?", id)).
+			Delete(&repo.NodeTag{}).Error
+		if err != nil {
+			return err
+		}
+		for _, table := range []string{consts.TableNodes, consts.TableJumpHosts, consts.TableSettings} {
+			if err := tx.Table(table).Where("cluster_id = ?", id).Delete(nil).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Table(consts.TableClusters).Delete(&repo.Cluster{}, "id = ?", id).Error
+	})
+}
+
+func (s *gormLayer) CopyNodes(ips []string, to int) (int, error) {
+	var nodes []repo.Node
+	if err := s.nodes(s.DB).Where("ip_address IN ?", ips).Order("id").Find(&nodes).Error; err != nil {
+		return 0, err
+	}
+	from := s.ClusterID()
+	copied := 0
+	err := s.Transaction(func(tx *gorm.DB) error {
+		copied = 0
+		var existing []string
+		err := tx.Table(consts.TableNodes).Where("cluster_id = ? AND ip_address IN ?", to, ips).
+			Pluck("ip_address", &existing).Error
+		if err != nil {
+			return err
+		}
+		var jumps []string
+		nowaTime := time.Now().Local()
+		for _, node := range nodes {
+			if slices.Contains(existing, node.IPAddress) {
+				continue
+			}
+			nodeID := node.ID
+			node.ID = 0
+			node.ClusterID = to
+			node.CreateTime = nowaTime
+			node.UpdateTime = nowaTime
+			if err := tx.Table(consts.TableNodes).Create(&node).Error; err != nil {
+				return err
+			}
+			var tagIDs []int
+			if err := tx.Table(consts.TableNodeTags).Where("node_id = ?", nodeID).Pluck("tag_id", &tagIDs).Error; err != nil {
+				return err
+			}
+			for _, tagID := range tagIDs {
+				if err := tx.Table(consts.TableNodeTags).Create(&repo.NodeTag{NodeID: node.ID, TagID: tagID}).Error; err != nil {
+					return err
+				}
+			}
+			for _, name := range strings.Split(node.ProxyJump, ",") {
+				if name = strings.TrimSpace(name); name != "" && !slices.Contains(jumps, name) {
+					jumps = append(jumps, name)
+				}
+			}
+			copied++
+		}
+		return copyJumpHosts(tx, jumps, from, to)
+	})
+	return copied, err
+}
+
+// copyJumpHosts copies the named jump hosts the target cluster lacks
+func copyJumpHosts(tx *gorm.DB, names []string, from, to int) error {
+	if len(names) == 0 {
+		return nil
+	}
+	var jumpHosts []repo.JumpHost
+	err := tx.Table(consts.TableJumpHosts).Where("cluster_id = ? AND name IN ?", from, names).Find(&jumpHosts).Error
+	if err != nil {
+		return err
+	}
+	var existing []string
+	err = tx.Table(consts.TableJumpHosts).Where("cluster_id = ? AND name IN ?", to, names).Pluck("name", &existing).Error
+	if err != nil {
+		return err
+	}
+	for _, j := range jumpHosts {
+		if slices.Contains(existing, j.Name) {
+			continue
+		}
+		j.ID = 0
+		j.ClusterID = to
+		if err := tx.Table(consts.TableJumpHosts).Create(&j).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package dblayer
+
+import (
+	"time"
+
+	"github.com/luo2pei4/ltool/pkg/consts"
+	"github.com/luo2pei4/ltool/pkg/dblayer/repo"
+)
+
+func (s *gormLayer) ListJumpHosts() ([]repo.JumpHost, error) {
+	var jumpHosts []repo.JumpHost
+	err := s.jumpHosts(s.DB).Order("name").Find(&jumpHosts).Error
+	return jumpHosts, err
+}
+
+func (s *gormLayer) SaveJumpHost(j *repo.JumpHost) error {
+	var existing []repo.JumpHost
+	err := s.jumpHosts(s.DB).Where("name = ?", j.Name).Limit(1).Find(&existing).Error
+	if err != nil {
+		return err
+	}
+	j.ClusterID = s.ClusterID()
+	j.UpdateTime = time.Now().Local()
+	if len(existing) == 0 {
+		j.CreateTime = j.UpdateTime
+		return s.Table(consts.TableJumpHosts).Create(j).Error
+	}
+	j.ID = existing[0].ID
+	return s.Table(consts.TableJumpHosts).Where("id = ?", j.ID).
+		Select("*").Omit("id", "cluster_id", "create_time").Updates(j).Error
+}
+
+func (s *gormLayer) DeleteJumpHost(name string) error {
+	return s.jumpHosts(s.DB).Delete(&repo.JumpHost{}, "name = ?", name).Error
+}
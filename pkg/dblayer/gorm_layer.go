@@ -0,0 +1,36 @@
+package dblayer
+
+import (
+	"sync/atomic"
+
+	"gorm.io/gorm"
+)
+
+// gormLayer implements dblayer with gorm, the backends differ in the
+// dialector and the migrations only.
+type gormLayer struct {
+	*gorm.DB
+	cluster atomic.Int64 // the active cluster, 0 means DefaultCluster
+}
+
+// openGormLayer opens the database and migrates it with the migrations of
+// the dialect, backup is called before an existing database is migrated.
+func openGormLayer(dialector gorm.Dialector, dialect string, backup func(db *gorm.DB) func(version int) error) (*gormLayer, error) {
+	db, err := gorm.Open(dialector, &gorm.Config{
+		PrepareStmt: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var backupFunc func(version int) error
+	if backup != nil {
+		backupFunc = backup(db)
+	}
+	if err := migrate(db, dialect, backupFunc); err != nil {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+		return nil, err
+	}
+	return &gormLayer{DB: db}, nil
+}
@@ -0,0 +1,203 @@
+package dblayer
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/luo2pei4/ltool/pkg/consts"
+	"github.com/luo2pei4/ltool/pkg/dblayer/repo"
+	"github.com/luo2pei4/ltool/pkg/secret"
+	"gorm.io/gorm"
+)
+
+func (s *gormLayer) FindNode(ip string) (*repo.Node, error) {
+	var node repo.Node
+	result := s.nodes(s.DB).Where("ip_address = ?", ip).First(&node)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &node, nil
+}
+
+func (s *gormLayer) ListNodes(q NodeQuery) ([]repo.Node, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+	var nodes []repo.Node
+	err := q.page(q.where(s.nodes(s.DB))).Find(&nodes).Error
+	return nodes, err
+}
+
+func (s *gormLayer) CountNodes(q NodeQuery) (int64, error) {
+	var count int64
+	err := q.where(s.nodes(s.DB)).Count(&count).Error
+	return count, err
+}
+
+// savedNodesBatch keeps the IN list below the bind variable limits of
+// the databases
+const savedNodesBatch = 1000
+
+func (s *gormLayer) SavedNodes(ips []string) ([]string, error) {
+	var saved []string
+	for batch := range slices.Chunk(ips, savedNodesBatch) {
+		var found []string
+		if err := s.nodes(s.DB).Where("ip_address IN ?", batch).Pluck("ip_address", &found).Error; err != nil {
+			return nil, err
+		}
+		saved = append(saved, found...)
+	}
+	return saved, nil
+}
+
+func (s *gormLayer) AddNodes(nodes []repo.Node) error {
+	cluster := s.ClusterID()
+	return s.Table(consts.TableNodes).Transaction(func(tx *gorm.DB) error {
+		for _, node := range nodes {
+			node.ClusterID = cluster
+			if err := tx.Create(&node).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *gormLayer) UpdateNode(n *repo.Node) error {
+	// update the empty values too, e.g. the passphrase was cleared
+	return s.nodes(s.DB).Where("ip_address = ?", n.IPAddress).
+		Select("*").Omit("id", "cluster_id", "create_time", "status", "status_time").Updates(n).Error
+}
+
+func (s *gormLayer) UpdateNodeStatus(ip, status string, t time.Time) error {
+	return s.nodes(s.DB).Where("ip_address = ?", ip).
+		Updates(map[string]any{"status": status, "status_time": t}).Error
+}
+
+func (s *gormLayer) DeleteNode(ip string) error {
+	return s.Transaction(func(tx *gorm.DB) error {
+		err := tx.Table(consts.TableNodeTags).Where("node_id IN (?)",
+			s.nodes(tx.Session(&gorm.Session{NewDB: true})).Select("id").Where("ip_address = ?", ip)).
+			Delete(&repo.NodeTag{}).Error
+		if err != nil {
+			return err
+		}
+		return s.nodes(tx).Delete(&repo.Node{}, "ip_address = ?", ip).Error
+	})
+}
+
+func (s *gormLayer) DeleteGroup(group string) (int64, error) {
+	var deleted int64
+	err := s.Transaction(func(tx *gorm.DB) error {
+		err := tx.Table(consts.TableNodeTags).Where("node_id IN (?)",
+			s.nodes(tx.Session(&gorm.Session{NewDB: true})).Select("id").Where("group_name = ?", group)).
+			Delete(&repo.NodeTag{}).Error
+		if err != nil {
+			return err
+		}
+		result := s.nodes(tx).Delete(&repo.Node{}, "group_name = ?", group)
+		deleted = result.RowsAffected
+		return result.Error
+	})
+	return deleted, err
+}
+
+func (s *gormLayer) RekeyCredentials(next *secret.Cipher, settings []repo.Setting) error {
+	var nodes []repo.Node
+	if err := s.Table(consts.TableNodes).Find(&nodes).Error; err != nil {
+		return err
+	}
+	var jumpHosts []repo.JumpHost
+	if err := s.Table(consts.TableJumpHosts).Find(&jumpHosts).Error; err != nil {
+		return err
+	}
+	var clusters []repo.Cluster
+	if err := s.Table(consts.TableClusters).Find(&clusters).Error; err != nil {
+		return err
+	}
+	ctx := secret.WithCipher(context.Background(), next)
+	return s.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, node := range nodes {
+			err := tx.Table(consts.TableNodes).Where("id = ?", node.ID).
+				Select("password", "passphrase", "become_password").Updates(&node).Error
+			if err != nil {
+				return err
+			}
+		}
+		for _, j := range jumpHosts {
+			err := tx.Table(consts.TableJumpHosts).Where("id = ?", j.ID).
+				Select("password", "passphrase").Updates(&j).Error
+			if err != nil {
+				return err
+			}
+		}
+		for _, c := range clusters {
+			err := tx.Table(consts.TableClusters).Where("id = ?", c.ID).
+				Select("password", "passphrase").Updates(&c).Error
+			if err != nil {
+				return err
+			}
+		}
+		for _, setting := range settings {
+			if err := saveSetting(tx, &setting); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// credentialColumns the columns of the credentials written by serializer:secret
+var credentialColumns = []struct {
+	table   string
+	columns []string
+}{
+	{consts.TableNodes, []string{"password", "passphrase", "become_password"}},
+	{consts.TableJumpHosts, []string{"password", "passphrase"}},
+	{consts.TableClusters, []string{"password", "passphrase"}},
+}
+
+func (s *gormLayer) EncryptPlainCredentials(c *secret.Cipher) error {
+	return s.Transaction(func(tx *gorm.DB) error {
+		for _, t := range credentialColumns {
+			var rows []map[string]any
+			if err := tx.Table(t.table).Select(append([]string{"id"}, t.columns...)).Find(&rows).Error; err != nil {
+				return err
+			}
+			for _, row := range rows {
+				updates := make(map[string]any)
+				for _, column := range t.columns {
+					value := rawString(row[column])
+					if value == "" || secret.IsEncrypted(value) {
+						continue
+					}
+					encrypted, err := c.Encrypt(value)
+					if err != nil {
+						return err
+					}
+					updates[column] = encrypted
+				}
+				if len(updates) == 0 {
+					continue
+				}
+				if err := tx.Table(t.table).Where("id = ?", row["id"]).Updates(updates).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// rawString converts a column value read into a map, NULL is empty
+func rawString(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	default:
+		return ""
+	}
+}
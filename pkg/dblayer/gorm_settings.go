@@ -0,0 +1,41 @@
+package dblayer
+
+import (
+	"github.com/luo2pei4/ltool/pkg/consts"
+	"github.com/luo2pei4/ltool/pkg/dblayer/repo"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func (s *gormLayer) GetSetting(name string) (string, error) {
+	return s.getSetting(0, name)
+}
+
+func (s *gormLayer) SetSetting(name, value string) error {
+	return saveSetting(s.DB, &repo.Setting{Name: name, Value: value})
+}
+
+func (s *gormLayer) GetClusterSetting(name string) (string, error) {
+	return s.getSetting(s.ClusterID(), name)
+}
+
+func (s *gormLayer) SetClusterSetting(name, value string) error {
+	return saveSetting(s.DB, &repo.Setting{ClusterID: s.ClusterID(), Name: name, Value: value})
+}
+
+func (s *gormLayer) getSetting(cluster int, name string) (string, error) {
+	var settings []repo.Setting
+	err := s.Table(consts.TableSettings).Where("cluster_id = ? AND name = ?", cluster, name).Limit(1).Find(&settings).Error
+	if err != nil || len(settings) == 0 {
+		return "", err
+	}
+	return settings[0].Value, nil
+}
+
+// saveSetting adds the setting or updates its value
+func saveSetting(tx *gorm.DB, setting *repo.Setting) error {
+	return tx.Table(consts.TableSettings).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "cluster_id"}, {Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value"}),
+	}).Create(setting).Error
+}
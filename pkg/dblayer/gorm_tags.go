@@ -0,0 +1,67 @@
+package dblayer
+
+import (
+	"github.com/luo2pei4/ltool/pkg/consts"
+	"github.com/luo2pei4/ltool/pkg/dblayer/repo"
+	"gorm.io/gorm"
+)
+
+func (s *gormLayer) ListGroups() ([]string, error) {
+	var groups []string
+	err := s.nodes(s.DB).Distinct("group_name").
+		Where("group_name IS NOT NULL AND group_name <> ''").Order("group_name").Pluck("group_name", &groups).Error
+	return groups, err
+}
+
+func (s *gormLayer) ListTags() ([]string, error) {
+	var tags []string
+	err := s.Table(consts.TableTags).Distinct(consts.TableTags+".name").
+		Joins("JOIN "+consts.TableNodeTags+" ON "+consts.TableNodeTags+".tag_id = "+consts.TableTags+".id").
+		Joins("JOIN "+consts.TableNodes+" ON "+consts.TableNodes+".id = "+consts.TableNodeTags+".node_id").
+		Where(consts.TableNodes+".cluster_id = ?", s.ClusterID()).
+		Order(consts.TableTags+".name").Pluck(consts.TableTags+".name", &tags).Error
+	return tags, err
+}
+
+func (s *gormLayer) ListNodeTags(ips []string) (map[string][]string, error) {
+	var rows []struct {
+		IPAddress string
+		Name      string
+	}
+	err := s.Table(consts.TableNodeTags).
+		Select(consts.TableNodes+".ip_address, "+consts.TableTags+".name").
+		Joins("JOIN "+consts.TableNodes+" ON "+consts.TableNodes+".id = "+consts.TableNodeTags+".node_id").
+		Joins("JOIN "+consts.TableTags+" ON "+consts.TableTags+".id = "+consts.TableNodeTags+".tag_id").
+		Where(consts.TableNodes+".cluster_id = ? AND "+consts.TableNodes+".ip_address IN ?", s.ClusterID(), ips).
+		Order(consts.TableTags + ".name").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	tags := make(map[string][]string, len(ips))
+	for _, row := range rows {
+		tags[row.IPAddress] = append(tags[row.IPAddress], row.Name)
+	}
+	return tags, nil
+}
+
+func (s *gormLayer) SetNodeTags(ip string, tags []string) error {
+	return s.Transaction(func(tx *gorm.DB) error {
+		var node repo.Node
+		if err := s.nodes(tx).Where("ip_address = ?", ip).First(&node).Error; err != nil {
+			return err
+		}
+		if err := tx.Table(consts.TableNodeTags).Delete(&repo.NodeTag{}, "node_id = ?", node.ID).Error; err != nil {
+			return err
+		}
+		for _, name := range tags {
+			tag := repo.Tag{Name: name}
+			if err := tx.Table(consts.TableTags).Where("name = ?", name).FirstOrCreate(&tag).Error; err != nil {
+				return err
+			}
+			if err := tx.Table(consts.TableNodeTags).Create(&repo.NodeTag{NodeID: node.ID, TagID: tag.ID}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
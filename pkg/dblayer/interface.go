@@ -2,12 +2,16 @@ package dblayer
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/luo2pei4/ltool/pkg/dblayer/repo"
+	"github.com/luo2pei4/ltool/pkg/secret"
 )
 
 var supportedDB = map[string]struct{}{
-	"sqlite": {},
+	"sqlite":   {},
+	"postgres": {},
+	"mysql":    {},
 }
 
 var (
@@ -35,16 +39,82 @@ func Init(name, dsn string) error {
 	return nil
 }
 
+// DefaultCluster the cluster of the nodes added before the clusters
+const DefaultCluster = 1
+
+// dblayer the operations of the nodes, jump hosts, tags and cluster
+// settings are scoped to the active cluster, see UseCluster.
 type dblayer interface {
+	// table clusters operations
+	// ClusterID returns the active cluster
+	ClusterID() int
+	// UseCluster makes the cluster active
+	UseCluster(id int)
+	// ListClusters returns the clusters in name order
+	ListClusters() ([]repo.Cluster, error)
+	// SaveCluster adds the cluster if its id is 0, or updates it
+	SaveCluster(c *repo.Cluster) error
+	// DeleteCluster deletes the cluster with its nodes, jump hosts and settings
+	DeleteCluster(id int) error
+	// CopyNodes copies the nodes of the active cluster with their tags and
+	// jump hosts to another cluster, the existing nodes are skipped. It
+	// returns the number of the copied nodes.
+	CopyNodes(ips []string, to int) (int, error)
+
 	// table nodes operations
 	// FindNode
 	FindNode(ip string) (*repo.Node, error)
-	// ListNodes
-	ListNodes(ip string) ([]repo.Node, error)
+	// ListNodes returns the page of the nodes matching q
+	ListNodes(q NodeQuery) ([]repo.Node, error)
+	// CountNodes returns the number of the nodes matching q, the page is ignored
+	CountNodes(q NodeQuery) (int64, error)
+	// SavedNodes returns the addresses of ips which are saved
+	SavedNodes(ips []string) ([]string, error)
 	// AddNodes
 	AddNodes(nodes []repo.Node) error
 	// UpdateNode
 	UpdateNode(n *repo.Node) error
+	// UpdateNodeStatus saves the status of the last check, UpdateNode
+	// leaves the status as is
+	UpdateNodeStatus(ip, status string, t time.Time) error
 	// DeleteNode
 	DeleteNode(ip string) error
+	// DeleteGroup deletes the nodes of the group and their tags in one
+	// transaction, it returns the number of the deleted nodes
+	DeleteGroup(group string) (int64, error)
+	// RekeyCredentials writes the credentials of all nodes and jump hosts
+	// with next and saves settings in one transaction
+	RekeyCredentials(next *secret.Cipher, settings []repo.Setting) error
+	// EncryptPlainCredentials encrypts the credentials still saved in plain
+	// text with c, the encrypted ones are left as they are
+	EncryptPlainCredentials(c *secret.Cipher) error
+
+	// table tags and node_tags operations
+	// ListGroups returns the groups of the nodes in name order
+	ListGroups() ([]string, error)
+	// ListTags returns the tags of the nodes in name order
+	ListTags() ([]string, error)
+	// ListNodeTags returns the tags of the nodes, key of the map is the ip address
+	ListNodeTags(ips []string) (map[string][]string, error)
+	// SetNodeTags replaces the tags of the node, the new tags are created
+	SetNodeTags(ip string, tags []string) error
+
+	// table settings operations
+	// GetSetting returns an empty value if the global setting does not exist
+	GetSetting(name string) (string, error)
+	// SetSetting sets a global setting
+	SetSetting(name, value string) error
+	// GetClusterSetting returns an empty value if the setting of the active
+	// cluster does not exist
+	GetClusterSetting(name string) (string, error)
+	// SetClusterSetting sets a setting of the active cluster
+	SetClusterSetting(name, value string) error
+
+	// table jump_hosts operations
+	// ListJumpHosts
+	ListJumpHosts() ([]repo.JumpHost, error)
+	// SaveJumpHost adds the jump host or updates the one with the same name
+	SaveJumpHost(j *repo.JumpHost) error
+	// DeleteJumpHost
+	DeleteJumpHost(name string) error
 }
@@ -0,0 +1,239 @@
+package dblayer
+
+import (
+	"embed"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/luo2pei4/ltool/pkg/consts"
+	logger "github.com/luo2pei4/ltool/pkg/log"
+	"gorm.io/gorm"
+)
+
+// migrationFiles the up-migrations of every dialect, named
+// migrations/<dialect>/<version>_<name>.sql
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+// migration an up-migration of the schema
+type migration struct {
+	version    int
+	name       string
+	statements []statement
+}
+
+// statement a statement of a migration. MySQL commits each DDL statement
+// implicitly, so a migration which failed halfway leaves its first changes
+// behind. The guard of a statement skips it if the schema has the column
+// or index already, it is the comment line before the statement:
+//
+//	-- unless column nodes.cluster_id
+//	ALTER TABLE `nodes` ADD COLUMN `cluster_id` INT NOT NULL DEFAULT 1;
+type statement struct {
+	sql   string
+	guard string // e.g. "column nodes.cluster_id" or "index nodes.idx_nodes_cluster_ip"
+}
+
+// guardPrefix starts the comment line of a guard
+const guardPrefix = "-- unless "
+
+// schemaVersion a row of the schema_version table
+type schemaVersion struct {
+	Version     int       `gorm:"column:version;primaryKey"`
+	Name        string    `gorm:"column:name"`
+	AppliedTime time.Time `gorm:"column:applied_time"`
+}
+
+// SchemaTooNewError the database was migrated by a newer ltool
+type SchemaTooNewError struct {
+	Version   int
+	Supported int
+}
+
+func (e *SchemaTooNewError) Error() string {
+	return fmt.Sprintf("the database schema version %d is newer than version %d supported by this ltool, please upgrade ltool",
+		e.Version, e.Supported)
+}
+
+// loadMigrations reads the migrations of the dialect in version order
+func loadMigrations(dialect string) ([]migration, error) {
+	dir := path.Join("migrations", dialect)
+	entries, err := migrationFiles.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read %s migrations failed, %v", dialect, err)
+	}
+	migrations := make([]migration, 0, len(entries))
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".sql")
+		if !ok {
+			continue
+		}
+		prefix, _, _ := strings.Cut(name, "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid migration file name %s", entry.Name())
+		}
+		data, err := migrationFiles.ReadFile(path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		statements := splitStatements(string(data))
+		for _, stmt := range statements {
+			if _, _, _, err := parseGuard(stmt.guard); err != nil {
+				return nil, fmt.Errorf("migration %s has %v", name, err)
+			}
+		}
+		migrations = append(migrations, migration{version: version, name: name, statements: statements})
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].version < migrations[j].version
+	})
+	for i, m := range migrations {
+		if m.version != i+1 {
+			return nil, fmt.Errorf("migration %s is out of sequence, want version %d", m.name, i+1)
+		}
+	}
+	return migrations, nil
+}
+
+// splitStatements splits sql by the semicolons at the end of the lines,
+// the comment lines are left out except the guards.
+func splitStatements(sql string) []statement {
+	var statements []statement
+	var b strings.Builder
+	guard := ""
+	for _, line := range strings.Split(sql, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if g, ok := strings.CutPrefix(trimmed, guardPrefix); ok && b.Len() == 0 {
+			guard = strings.TrimSpace(g)
+			continue
+		}
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		b.WriteString(line)
+		b.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, statement{sql: strings.TrimSpace(b.String()), guard: guard})
+			b.Reset()
+			guard = ""
+		}
+	}
+	if rest := strings.TrimSpace(b.String()); rest != "" {
+		statements = append(statements, statement{sql: rest, guard: guard})
+	}
+	return statements
+}
+
+// parseGuard splits the guard of a statement, kind is column or index
+func parseGuard(guard string) (kind, table, name string, err error) {
+	if guard == "" {
+		return "", "", "", nil
+	}
+	kind, object, _ := strings.Cut(guard, " ")
+	table, name, ok := strings.Cut(object, ".")
+	if !ok || table == "" || name == "" || (kind != "column" && kind != "index") {
+		return "", "", "", fmt.Errorf("invalid guard '%s'", guard)
+	}
+	return kind, table, name, nil
+}
+
+// applied reports whether the schema has the column or index of the guard
+func (stmt *statement) applied(db *gorm.DB) bool {
+	kind, table, name, err := parseGuard(stmt.guard)
+	switch {
+	case err != nil, kind == "":
+		return false
+	case kind == "column":
+		return db.Migrator().HasColumn(table, name)
+	default:
+		return db.Migrator().HasIndex(table, name)
+	}
+}
+
+// currentVersion returns the latest applied migration, legacy is true if
+// the database was created before the schema versions, i.e. it has the
+// nodes table but no schema_version table.
+func currentVersion(db *gorm.DB) (version int, legacy bool, err error) {
+	m := db.Migrator()
+	if !m.HasTable(consts.TableSchemaVersion) {
+		return 0, m.HasTable(consts.TableNodes), nil
+	}
+	var latest *int
+	err = db.Table(consts.TableSchemaVersion).Select("max(version)").Scan(&latest).Error
+	if err != nil {
+		return 0, false, fmt.Errorf("read schema version failed, %v", err)
+	}
+	if latest == nil {
+		return 0, false, nil
+	}
+	return *latest, false, nil
+}
+
+// migrate applies the pending migrations of the dialect in order, each one
+// in a transaction. backup is called with the current version before an
+// existing database is migrated.
+func migrate(db *gorm.DB, dialect string, backup func(version int) error) error {
+	migrations, err := loadMigrations(dialect)
+	if err != nil {
+		return err
+	}
+	version, legacy, err := currentVersion(db)
+	if err != nil {
+		return err
+	}
+	if version > len(migrations) {
+		return &SchemaTooNewError{Version: version, Supported: len(migrations)}
+	}
+	if version == len(migrations) {
+		return nil
+	}
+	if (version > 0 || legacy) && backup != nil {
+		if err := backup(version); err != nil {
+			return fmt.Errorf("backup the database failed, %v", err)
+		}
+	}
+	if !db.Migrator().HasTable(consts.TableSchemaVersion) {
+		if err := db.Table(consts.TableSchemaVersion).Migrator().CreateTable(&schemaVersion{}); err != nil {
+			return fmt.Errorf("create table %s failed, %v", consts.TableSchemaVersion, err)
+		}
+	}
+	if legacy {
+		logger.Infof("the database has no schema version, adopting it")
+	}
+	for _, m := range migrations[version:] {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			for _, stmt := range m.statements {
+				if stmt.applied(tx) {
+					logger.Infof("%s of %s was applied already, skipping it", stmt.guard, m.name)
+					continue
+				}
+				err := tx.Exec(stmt.sql).Error
+				// the releases before the schema versions added the columns on startup
+				if err != nil && !(legacy && isDuplicateColumn(err)) {
+					return err
+				}
+			}
+			return tx.Table(consts.TableSchemaVersion).Create(&schemaVersion{
+				Version:     m.version,
+				Name:        m.name,
+				AppliedTime: time.Now().Local(),
+			}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("migrate database to %s failed, %v", m.name, err)
+		}
+		logger.Infof("migrated database to %s", m.name)
+	}
+	return nil
+}
+
+func isDuplicateColumn(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "duplicate column")
+}
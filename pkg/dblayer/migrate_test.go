@@ -0,0 +1,207 @@
+package dblayer
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/luo2pei4/ltool/pkg/consts"
+	"github.com/luo2pei4/ltool/pkg/dblayer/repo"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func openTestDB(t *testing.T, file string) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(file), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+func TestSplitStatements(t *testing.T) {
+	sql := "-- comment\nCREATE TABLE t (\n\ta text\n);\n\n-- unless column t.b\nALTER TABLE t ADD COLUMN b text;\nSELECT 1"
+	got := splitStatements(sql)
+	want := []statement{
+		{sql: "CREATE TABLE t (\n\ta text\n);"},
+		{sql: "ALTER TABLE t ADD COLUMN b text;", guard: "column t.b"},
+		{sql: "SELECT 1"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("statements are %q", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("statement %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+	for _, guard := range []string{"table t", "column t", "column .b", "view t.b"} {
+		if _, _, _, err := parseGuard(guard); err == nil {
+			t.Errorf("guard '%s' is valid", guard)
+		}
+	}
+}
+
+func TestStatementApplied(t *testing.T) {
+	db := openTestDB(t, filepath.Join(t.TempDir(), "guard.db"))
+	if err := db.Exec("CREATE TABLE t (a text, b text)").Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Exec("CREATE INDEX idx_t_a ON t (a)").Error; err != nil {
+		t.Fatal(err)
+	}
+	for guard, want := range map[string]bool{
+		"":                false,
+		"column t.b":      true,
+		"column t.c":      false,
+		"index t.idx_t_a": true,
+		"index t.idx_t_b": false,
+	} {
+		stmt := statement{sql: "SELECT 1", guard: guard}
+		if got := stmt.applied(db); got != want {
+			t.Errorf("'%s' applied = %v, want %v", guard, got, want)
+		}
+	}
+}
+
+// TestMigrateMysqlPartial resumes a migration which MySQL left halfway,
+// its DDL statements were committed without the schema version, e.g.
+//
+//	LTOOL_TEST_MYSQL_DSN="ltool:ltool@tcp(localhost:3306)/ltool_test"
+func TestMigrateMysqlPartial(t *testing.T) {
+	dsn := os.Getenv("LTOOL_TEST_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("LTOOL_TEST_MYSQL_DSN is not set")
+	}
+	dialector, err := mysqlDialector(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dropTables(t, dialector)
+	db, err := gorm.Open(dialector, &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	if err := migrate(db, "mysql", nil); err != nil {
+		t.Fatal(err)
+	}
+	migrations, err := loadMigrations("mysql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// undo the last migration and apply its first changes again
+	last := migrations[len(migrations)-1]
+	if err := db.Exec("DELETE FROM "+consts.TableSchemaVersion+" WHERE version = ?", last.version).Error; err != nil {
+		t.Fatal(err)
+	}
+	undo := []string{
+		"ALTER TABLE `settings` DROP PRIMARY KEY, DROP COLUMN `cluster_id`, ADD PRIMARY KEY (`name`)",
+		"ALTER TABLE `jump_hosts` DROP INDEX `idx_jump_hosts_cluster_name`, ADD UNIQUE INDEX `idx_jump_hosts_name` (`name`)",
+		"ALTER TABLE `jump_hosts` DROP COLUMN `cluster_id`",
+	}
+	for _, sql := range undo {
+		if err := db.Exec(sql).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := migrate(db, "mysql", nil); err != nil {
+		t.Fatalf("resume the partial migration failed, %v", err)
+	}
+	if version, _, err := currentVersion(db); err != nil || version != last.version {
+		t.Errorf("version is %d, %v", version, err)
+	}
+	if !db.Migrator().HasColumn(consts.TableSettings, "cluster_id") ||
+		!db.Migrator().HasIndex(consts.TableJumpHosts, "idx_jump_hosts_cluster_name") {
+		t.Error("the rest of the migration was not applied")
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	migrations, err := loadMigrations("sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	latest := len(migrations)
+
+	// a new database is created without a backup
+	db := openTestDB(t, filepath.Join(t.TempDir(), "new.db"))
+	backups := 0
+	backup := func(int) error {
+		backups++
+		return nil
+	}
+	if err := migrate(db, "sqlite", backup); err != nil {
+		t.Fatal(err)
+	}
+	if version, _, err := currentVersion(db); err != nil || version != latest || backups != 0 {
+		t.Fatalf("version is %d, %v, backups %d", version, err, backups)
+	}
+	node := repo.Node{IPAddress: "192.168.1.10", UserName: "root", SSHPort: 2222, GroupName: "oss"}
+	if err := db.Table(consts.TableNodes).Create(&node).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Table(consts.TableJumpHosts).Create(&repo.JumpHost{Name: "bastion"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	// migrating again changes nothing
+	if err := migrate(db, "sqlite", backup); err != nil || backups != 0 {
+		t.Fatalf("migrate again failed, %v, backups %d", err, backups)
+	}
+
+	// a newer database is refused
+	db.Table(consts.TableSchemaVersion).Create(&schemaVersion{Version: latest + 1, Name: "future"})
+	err = migrate(db, "sqlite", backup)
+	var tooNew *SchemaTooNewError
+	if !errors.As(err, &tooNew) || tooNew.Version != latest+1 || tooNew.Supported != latest {
+		t.Errorf("newer database error is %v", err)
+	}
+}
+
+func TestMigrateLegacy(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "ltool.db")
+	db := openTestDB(t, file)
+	// the first release with a column added on startup by a later release
+	legacy := []string{
+		`CREATE TABLE "nodes" ("id" INTEGER NOT NULL, "ip_address" VARCHAR(48) NOT NULL, "user_name" VARCHAR(48) NOT NULL,
+			"password" VARCHAR(48) NOT NULL, "hostname" VARCHAR(32) NULL, "architecture" VARCHAR(16) NULL,
+			"os" VARCHAR(128) NULL, "kernel" VARCHAR(128) NULL, "create_time" DATETIME NOT NULL,
+			"update_time" DATETIME NOT NULL, PRIMARY KEY ("id"))`,
+		`CREATE UNIQUE INDEX "ip_address" ON "nodes" ("ip_address")`,
+		"ALTER TABLE `nodes` ADD COLUMN `auth_type` text",
+		`INSERT INTO nodes (ip_address, user_name, password, create_time, update_time)
+			VALUES ('192.168.1.10', 'root', '', datetime('now'), datetime('now'))`,
+	}
+	for _, statement := range legacy {
+		if err := db.Exec(statement).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := migrate(db, "sqlite", sqliteBackup(db)); err != nil {
+		t.Fatal(err)
+	}
+	var nodes []repo.Node
+	if err := db.Table(consts.TableNodes).Find(&nodes).Error; err != nil || len(nodes) != 1 || nodes[0].IPAddress != "192.168.1.10" {
+		t.Fatalf("nodes are %+v, %v", nodes, err)
+	}
+	backups, _ := filepath.Glob(file + ".v0-*.bak")
+	if len(backups) != 1 {
+		t.Fatalf("backups are %v", backups)
+	}
+	// the backup keeps the old schema
+	old := openTestDB(t, backups[0])
+	if old.Migrator().HasTable(consts.TableSchemaVersion) || !old.Migrator().HasTable(consts.TableNodes) {
+		t.Error("the backup is not the legacy database")
+	}
+}
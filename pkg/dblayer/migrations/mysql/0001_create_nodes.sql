@@ -0,0 +1,16 @@
+-- the nodes table of the first release, the binary collation compares
+-- the text like sqlite
+CREATE TABLE IF NOT EXISTS `nodes` (
+	`id` BIGINT NOT NULL AUTO_INCREMENT,
+	`ip_address` VARCHAR(48) NOT NULL,
+	`user_name` VARCHAR(255) NOT NULL,
+	`password` TEXT NOT NULL,
+	`hostname` VARCHAR(255) NULL,
+	`architecture` VARCHAR(64) NULL,
+	`os` VARCHAR(255) NULL,
+	`kernel` VARCHAR(255) NULL,
+	`create_time` DATETIME(3) NOT NULL,
+	`update_time` DATETIME(3) NOT NULL,
+	PRIMARY KEY (`id`),
+	UNIQUE INDEX `ip_address` (`ip_address`)
+) DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
@@ -0,0 +1,2 @@
+-- the settings, e.g. the check of the master passphrase
+CREATE TABLE IF NOT EXISTS `settings` (`name` VARCHAR(191) NOT NULL,`value` TEXT,PRIMARY KEY (`name`)) DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
@@ -0,0 +1,13 @@
+-- the authentication methods and the privilege escalation of the nodes
+-- unless column nodes.auth_type
+ALTER TABLE `nodes` ADD COLUMN `auth_type` VARCHAR(16);
+-- unless column nodes.key_file
+ALTER TABLE `nodes` ADD COLUMN `key_file` TEXT;
+-- unless column nodes.passphrase
+ALTER TABLE `nodes` ADD COLUMN `passphrase` TEXT;
+-- unless column nodes.cert_file
+ALTER TABLE `nodes` ADD COLUMN `cert_file` TEXT;
+-- unless column nodes.become_method
+ALTER TABLE `nodes` ADD COLUMN `become_method` VARCHAR(16);
+-- unless column nodes.become_password
+ALTER TABLE `nodes` ADD COLUMN `become_password` TEXT;
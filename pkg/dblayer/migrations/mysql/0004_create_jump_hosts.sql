@@ -0,0 +1,4 @@
+-- the jump hosts and the ProxyJump chain of the nodes
+CREATE TABLE IF NOT EXISTS `jump_hosts` (`id` BIGINT NOT NULL AUTO_INCREMENT,`name` VARCHAR(191),`address` VARCHAR(255),`user_name` VARCHAR(255),`password` TEXT,`auth_type` VARCHAR(16),`key_file` TEXT,`passphrase` TEXT,`cert_file` TEXT,`create_time` DATETIME(3),`update_time` DATETIME(3),PRIMARY KEY (`id`),UNIQUE INDEX `idx_jump_hosts_name` (`name`)) DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+-- unless column nodes.proxy_jump
+ALTER TABLE `nodes` ADD COLUMN `proxy_jump` TEXT;
@@ -0,0 +1,3 @@
+-- the group of the imported nodes
+-- unless column nodes.group_name
+ALTER TABLE `nodes` ADD COLUMN `group_name` VARCHAR(191);
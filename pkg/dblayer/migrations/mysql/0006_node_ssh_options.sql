@@ -0,0 +1,11 @@
+-- the connection options of the nodes
+-- unless column nodes.ssh_port
+ALTER TABLE `nodes` ADD COLUMN `ssh_port` INT;
+-- unless column nodes.connect_timeout
+ALTER TABLE `nodes` ADD COLUMN `connect_timeout` INT;
+-- unless column nodes.keepalive_interval
+ALTER TABLE `nodes` ADD COLUMN `keepalive_interval` INT;
+-- unless column nodes.ciphers
+ALTER TABLE `nodes` ADD COLUMN `ciphers` TEXT;
+-- unless column nodes.kex_algorithms
+ALTER TABLE `nodes` ADD COLUMN `kex_algorithms` TEXT;
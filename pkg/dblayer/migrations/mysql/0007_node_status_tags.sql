@@ -0,0 +1,7 @@
+-- the last checked status of the nodes and the tags to search them by
+-- unless column nodes.status
+ALTER TABLE `nodes` ADD COLUMN `status` VARCHAR(16);
+-- unless column nodes.status_time
+ALTER TABLE `nodes` ADD COLUMN `status_time` DATETIME(3);
+CREATE TABLE IF NOT EXISTS `tags` (`id` BIGINT NOT NULL AUTO_INCREMENT,`name` VARCHAR(191) NOT NULL,PRIMARY KEY (`id`),UNIQUE INDEX `idx_tags_name` (`name`)) DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+CREATE TABLE IF NOT EXISTS `node_tags` (`node_id` BIGINT NOT NULL,`tag_id` BIGINT NOT NULL,PRIMARY KEY (`node_id`,`tag_id`));
@@ -0,0 +1,15 @@
+-- the clusters, each one with its own nodes, jump hosts and settings,
+-- the existing rows belong to the default cluster 1
+CREATE TABLE IF NOT EXISTS `clusters` (`id` BIGINT NOT NULL AUTO_INCREMENT,`name` VARCHAR(191) NOT NULL,`description` TEXT,`user_name` VARCHAR(255),`auth_type` VARCHAR(16),`password` TEXT,`key_file` TEXT,`passphrase` TEXT,`cert_file` TEXT,`create_time` DATETIME(3),`update_time` DATETIME(3),PRIMARY KEY (`id`),UNIQUE INDEX `idx_clusters_name` (`name`)) DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+INSERT IGNORE INTO `clusters` (`id`, `name`, `create_time`, `update_time`) VALUES (1, 'default', NOW(3), NOW(3));
+-- unless column nodes.cluster_id
+ALTER TABLE `nodes` ADD COLUMN `cluster_id` INT NOT NULL DEFAULT 1;
+-- unless index nodes.idx_nodes_cluster_ip
+ALTER TABLE `nodes` DROP INDEX `ip_address`, ADD UNIQUE INDEX `idx_nodes_cluster_ip` (`cluster_id`,`ip_address`);
+-- unless column jump_hosts.cluster_id
+ALTER TABLE `jump_hosts` ADD COLUMN `cluster_id` INT NOT NULL DEFAULT 1;
+-- unless index jump_hosts.idx_jump_hosts_cluster_name
+ALTER TABLE `jump_hosts` DROP INDEX `idx_jump_hosts_name`, ADD UNIQUE INDEX `idx_jump_hosts_cluster_name` (`cluster_id`,`name`);
+-- the settings of cluster 0 are global, e.g. the check of the master passphrase
+-- unless column settings.cluster_id
+ALTER TABLE `settings` ADD COLUMN `cluster_id` INT NOT NULL DEFAULT 0 FIRST, DROP PRIMARY KEY, ADD PRIMARY KEY (`cluster_id`,`name`);
@@ -0,0 +1,15 @@
+-- the nodes table of the first release
+CREATE TABLE IF NOT EXISTS "nodes" (
+	"id" BIGINT GENERATED BY DEFAULT AS IDENTITY,
+	"ip_address" VARCHAR(48) NOT NULL,
+	"user_name" TEXT NOT NULL,
+	"password" TEXT NOT NULL,
+	"hostname" TEXT NULL,
+	"architecture" TEXT NULL,
+	"os" TEXT NULL,
+	"kernel" TEXT NULL,
+	"create_time" TIMESTAMPTZ NOT NULL,
+	"update_time" TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "ip_address" ON "nodes" ("ip_address");
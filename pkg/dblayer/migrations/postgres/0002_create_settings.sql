@@ -0,0 +1,2 @@
+-- the settings, e.g. the check of the master passphrase
+CREATE TABLE IF NOT EXISTS "settings" ("name" TEXT,"value" TEXT,CONSTRAINT "settings_pkey" PRIMARY KEY ("name"));
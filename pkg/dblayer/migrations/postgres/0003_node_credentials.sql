@@ -0,0 +1,7 @@
+-- the authentication methods and the privilege escalation of the nodes
+ALTER TABLE "nodes" ADD COLUMN "auth_type" TEXT;
+ALTER TABLE "nodes" ADD COLUMN "key_file" TEXT;
+ALTER TABLE "nodes" ADD COLUMN "passphrase" TEXT;
+ALTER TABLE "nodes" ADD COLUMN "cert_file" TEXT;
+ALTER TABLE "nodes" ADD COLUMN "become_method" TEXT;
+ALTER TABLE "nodes" ADD COLUMN "become_password" TEXT;
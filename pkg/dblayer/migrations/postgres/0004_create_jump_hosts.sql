@@ -0,0 +1,4 @@
+-- the jump hosts and the ProxyJump chain of the nodes
+CREATE TABLE IF NOT EXISTS "jump_hosts" ("id" BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,"name" TEXT,"address" TEXT,"user_name" TEXT,"password" TEXT,"auth_type" TEXT,"key_file" TEXT,"passphrase" TEXT,"cert_file" TEXT,"create_time" TIMESTAMPTZ,"update_time" TIMESTAMPTZ);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_jump_hosts_name" ON "jump_hosts" ("name");
+ALTER TABLE "nodes" ADD COLUMN "proxy_jump" TEXT;
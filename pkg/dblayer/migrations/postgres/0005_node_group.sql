@@ -0,0 +1,2 @@
+-- the group of the imported nodes
+ALTER TABLE "nodes" ADD COLUMN "group_name" TEXT;
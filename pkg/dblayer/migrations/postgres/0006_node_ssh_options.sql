@@ -0,0 +1,6 @@
+-- the connection options of the nodes
+ALTER TABLE "nodes" ADD COLUMN "ssh_port" INTEGER;
+ALTER TABLE "nodes" ADD COLUMN "connect_timeout" INTEGER;
+ALTER TABLE "nodes" ADD COLUMN "keepalive_interval" INTEGER;
+ALTER TABLE "nodes" ADD COLUMN "ciphers" TEXT;
+ALTER TABLE "nodes" ADD COLUMN "kex_algorithms" TEXT;
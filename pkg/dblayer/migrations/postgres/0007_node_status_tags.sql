@@ -0,0 +1,6 @@
+-- the last checked status of the nodes and the tags to search them by
+ALTER TABLE "nodes" ADD COLUMN "status" TEXT;
+ALTER TABLE "nodes" ADD COLUMN "status_time" TIMESTAMPTZ;
+CREATE TABLE IF NOT EXISTS "tags" ("id" BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,"name" TEXT NOT NULL);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_tags_name" ON "tags" ("name");
+CREATE TABLE IF NOT EXISTS "node_tags" ("node_id" BIGINT NOT NULL,"tag_id" BIGINT NOT NULL,PRIMARY KEY ("node_id","tag_id"));
@@ -0,0 +1,17 @@
+-- the clusters, each one with its own nodes, jump hosts and settings,
+-- the existing rows belong to the default cluster 1
+CREATE TABLE IF NOT EXISTS "clusters" ("id" BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,"name" TEXT NOT NULL,"description" TEXT,"user_name" TEXT,"auth_type" TEXT,"password" TEXT,"key_file" TEXT,"passphrase" TEXT,"cert_file" TEXT,"create_time" TIMESTAMPTZ,"update_time" TIMESTAMPTZ);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_clusters_name" ON "clusters" ("name");
+INSERT INTO "clusters" ("id", "name", "create_time", "update_time") VALUES (1, 'default', now(), now()) ON CONFLICT DO NOTHING;
+-- the identity continues after the default cluster
+SELECT setval(pg_get_serial_sequence('clusters', 'id'), (SELECT MAX("id") FROM "clusters"));
+ALTER TABLE "nodes" ADD COLUMN "cluster_id" INTEGER NOT NULL DEFAULT 1;
+DROP INDEX IF EXISTS "ip_address";
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_nodes_cluster_ip" ON "nodes" ("cluster_id","ip_address");
+ALTER TABLE "jump_hosts" ADD COLUMN "cluster_id" INTEGER NOT NULL DEFAULT 1;
+DROP INDEX IF EXISTS "idx_jump_hosts_name";
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_jump_hosts_cluster_name" ON "jump_hosts" ("cluster_id","name");
+-- the settings of cluster 0 are global, e.g. the check of the master passphrase
+ALTER TABLE "settings" ADD COLUMN "cluster_id" INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE "settings" DROP CONSTRAINT "settings_pkey";
+ALTER TABLE "settings" ADD PRIMARY KEY ("cluster_id","name");
@@ -0,0 +1,15 @@
+-- the nodes table of the first release
+CREATE TABLE IF NOT EXISTS "nodes" (
+	"id" INTEGER NOT NULL,
+	"ip_address" VARCHAR(48) NOT NULL,
+	"user_name" VARCHAR(48) NOT NULL,
+	"password" VARCHAR(48) NOT NULL,
+	"hostname" VARCHAR(32) NULL,
+	"architecture" VARCHAR(16) NULL,
+	"os" VARCHAR(128) NULL,
+	"kernel" VARCHAR(128) NULL,
+	"create_time" DATETIME NOT NULL,
+	"update_time" DATETIME NOT NULL,
+	PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "ip_address" ON "nodes" ("ip_address");
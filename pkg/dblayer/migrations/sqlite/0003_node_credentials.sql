@@ -0,0 +1,7 @@
+-- the authentication methods and the privilege escalation of the nodes
+ALTER TABLE `nodes` ADD COLUMN `auth_type` text;
+ALTER TABLE `nodes` ADD COLUMN `key_file` text;
+ALTER TABLE `nodes` ADD COLUMN `passphrase` text;
+ALTER TABLE `nodes` ADD COLUMN `cert_file` text;
+ALTER TABLE `nodes` ADD COLUMN `become_method` text;
+ALTER TABLE `nodes` ADD COLUMN `become_password` text;
@@ -0,0 +1,4 @@
+-- the jump hosts and the ProxyJump chain of the nodes
+CREATE TABLE IF NOT EXISTS `jump_hosts` (`id` integer PRIMARY KEY AUTOINCREMENT,`name` text,`address` text,`user_name` text,`password` text,`auth_type` text,`key_file` text,`passphrase` text,`cert_file` text,`create_time` datetime,`update_time` datetime);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_jump_hosts_name` ON `jump_hosts`(`name`);
+ALTER TABLE `nodes` ADD COLUMN `proxy_jump` text;
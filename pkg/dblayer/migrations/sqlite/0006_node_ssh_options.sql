@@ -0,0 +1,6 @@
+-- the connection options of the nodes
+ALTER TABLE `nodes` ADD COLUMN `ssh_port` integer;
+ALTER TABLE `nodes` ADD COLUMN `connect_timeout` integer;
+ALTER TABLE `nodes` ADD COLUMN `keepalive_interval` integer;
+ALTER TABLE `nodes` ADD COLUMN `ciphers` text;
+ALTER TABLE `nodes` ADD COLUMN `kex_algorithms` text;
@@ -0,0 +1,6 @@
+-- the last checked status of the nodes and the tags to search them by
+ALTER TABLE `nodes` ADD COLUMN `status` text;
+ALTER TABLE `nodes` ADD COLUMN `status_time` datetime;
+CREATE TABLE IF NOT EXISTS `tags` (`id` integer PRIMARY KEY AUTOINCREMENT,`name` text NOT NULL);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_tags_name` ON `tags`(`name`);
+CREATE TABLE IF NOT EXISTS `node_tags` (`node_id` integer NOT NULL,`tag_id` integer NOT NULL,PRIMARY KEY (`node_id`,`tag_id`));
@@ -0,0 +1,16 @@
+-- the clusters, each one with its own nodes, jump hosts and settings,
+-- the existing rows belong to the default cluster 1
+CREATE TABLE IF NOT EXISTS `clusters` (`id` integer PRIMARY KEY AUTOINCREMENT,`name` text NOT NULL,`description` text,`user_name` text,`auth_type` text,`password` text,`key_file` text,`passphrase` text,`cert_file` text,`create_time` datetime,`update_time` datetime);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_clusters_name` ON `clusters`(`name`);
+INSERT INTO `clusters` (`id`, `name`, `create_time`, `update_time`) SELECT 1, 'default', datetime('now', 'localtime'), datetime('now', 'localtime') WHERE NOT EXISTS (SELECT 1 FROM `clusters` WHERE `id` = 1);
+ALTER TABLE `nodes` ADD COLUMN `cluster_id` integer NOT NULL DEFAULT 1;
+DROP INDEX IF EXISTS `ip_address`;
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_nodes_cluster_ip` ON `nodes`(`cluster_id`,`ip_address`);
+ALTER TABLE `jump_hosts` ADD COLUMN `cluster_id` integer NOT NULL DEFAULT 1;
+DROP INDEX IF EXISTS `idx_jump_hosts_name`;
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_jump_hosts_cluster_name` ON `jump_hosts`(`cluster_id`,`name`);
+-- the settings of cluster 0 are global, e.g. the check of the master passphrase
+CREATE TABLE `settings_new` (`cluster_id` integer NOT NULL DEFAULT 0,`name` text,`value` text,PRIMARY KEY (`cluster_id`,`name`));
+INSERT INTO `settings_new` (`cluster_id`, `name`, `value`) SELECT 0, `name`, `value` FROM `settings`;
+DROP TABLE `settings`;
+ALTER TABLE `settings_new` RENAME TO `settings`;
@@ -0,0 +1,41 @@
+package dblayer
+
+import (
+	"fmt"
+	"time"
+
+	driver "github.com/go-sql-driver/mysql"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+func init() {
+	regist("mysql", createMysqlClient)
+}
+
+// createMysqlClient connects to MySQL or MariaDB, dsn is e.g.
+// "ltool:secret@tcp(db01:3306)/ltool". The database is not backed up
+// before migrating, that is left to its administrator.
+func createMysqlClient(dsn string) (dblayer, error) {
+	dialector, err := mysqlDialector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	l, err := openGormLayer(dialector, "mysql", nil)
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// mysqlDialector parses the times in the local time zone like sqlite
+// stores them
+func mysqlDialector(dsn string) (gorm.Dialector, error) {
+	conf, err := driver.ParseDSN(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("parse mysql dsn failed, %v", err)
+	}
+	conf.ParseTime = true
+	conf.Loc = time.Local
+	return mysql.Open(conf.FormatDSN()), nil
+}
@@ -0,0 +1,133 @@
+package dblayer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/luo2pei4/ltool/pkg/consts"
+	"gorm.io/gorm"
+)
+
+// the fields the nodes can be sorted by
+const (
+	SortByID       = ""
+	SortByIP       = "ip"
+	SortByHostname = "hostname"
+	SortByOS       = "os"
+	SortByKernel   = "kernel"
+	SortByGroup    = "group"
+	SortByStatus   = "status"
+	SortByUpdated  = "updated"
+)
+
+// StatusUnknown the status of the nodes never checked
+const StatusUnknown = "unknown"
+
+// SortFields the fields of NodeQuery.SortBy
+var SortFields = []string{SortByIP, SortByHostname, SortByOS, SortByKernel, SortByGroup, SortByStatus, SortByUpdated}
+
+var sortColumns = map[string]string{
+	SortByID:       "id",
+	SortByIP:       "ip_address",
+	SortByHostname: "hostname",
+	SortByOS:       "os",
+	SortByKernel:   "kernel",
+	SortByGroup:    "group_name",
+	SortByStatus:   "status",
+	SortByUpdated:  "update_time",
+}
+
+// NodeQuery filters, sorts and pages the nodes, the zero value lists all
+// nodes in the order they were added. The text filters match substrings
+// case-insensitively, Group, Tag and Status match exactly.
+type NodeQuery struct {
+	IP            string
+	Hostname      string
+	OS            string
+	Kernel        string
+	Group         string
+	Tag           string
+	Status        string
+	UpdatedAfter  time.Time // zero means no lower bound
+	UpdatedBefore time.Time // zero means no upper bound
+	SortBy        string    // one of SortFields, empty means the id
+	Desc          bool
+	Offset        int
+	Limit         int // 0 means no limit
+}
+
+// Validate checks the sort field and the page
+func (q *NodeQuery) Validate() error {
+	if _, ok := sortColumns[q.SortBy]; !ok {
+		return fmt.Errorf("unsupported sort field '%s'", q.SortBy)
+	}
+	if q.Offset < 0 || q.Limit < 0 {
+		return fmt.Errorf("invalid page, offset %d, limit %d", q.Offset, q.Limit)
+	}
+	return nil
+}
+
+// where applies the filters to the nodes table, the values are bound as
+// parameters
+func (q *NodeQuery) where(tx *gorm.DB) *gorm.DB {
+	for _, f := range [][2]string{
+		{"ip_address", q.IP},
+		{"hostname", q.Hostname},
+		{"os", q.OS},
+		{"kernel", q.Kernel},
+	} {
+		if f[1] != "" {
+			tx = tx.Where("LOWER("+f[0]+") LIKE ? ESCAPE '!'", "%"+escapeLike(strings.ToLower(f[1]))+"%")
+		}
+	}
+	if q.Group != "" {
+		tx = tx.Where("group_name = ?", q.Group)
+	}
+	if q.Status != "" {
+		// the nodes never checked have no status
+		statuses := []string{q.Status}
+		if q.Status == StatusUnknown {
+			statuses = append(statuses, "")
+		}
+		tx = tx.Where("COALESCE(status, '') IN ?", statuses)
+	}
+	if q.Tag != "" {
+		tx = tx.Where("id IN (?)", tx.Session(&gorm.Session{NewDB: true}).Table(consts.TableNodeTags).
+			Select(consts.TableNodeTags+".node_id").
+			Joins("JOIN "+consts.TableTags+" ON "+consts.TableTags+".id = "+consts.TableNodeTags+".tag_id").
+			Where(consts.TableTags+".name = ?", q.Tag))
+	}
+	if !q.UpdatedAfter.IsZero() {
+		tx = tx.Where("update_time >= ?", q.UpdatedAfter)
+	}
+	if !q.UpdatedBefore.IsZero() {
+		tx = tx.Where("update_time < ?", q.UpdatedBefore)
+	}
+	return tx
+}
+
+// page applies the order and the page, the id breaks the ties
+func (q *NodeQuery) page(tx *gorm.DB) *gorm.DB {
+	order := sortColumns[q.SortBy]
+	if q.Desc {
+		order += " DESC"
+	}
+	if q.SortBy != SortByID {
+		order += ", id"
+	}
+	tx = tx.Order(order)
+	if q.Offset > 0 {
+		tx = tx.Offset(q.Offset)
+	}
+	if q.Limit > 0 {
+		tx = tx.Limit(q.Limit)
+	}
+	return tx
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern, the escape character
+// is not a backslash which MySQL reads as a string escape
+func escapeLike(s string) string {
+	return strings.NewReplacer(`!`, `!!`, `%`, `!%`, `_`, `!_`).Replace(s)
+}
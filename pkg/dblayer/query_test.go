@@ -0,0 +1,154 @@
+package dblayer
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/luo2pei4/ltool/pkg/consts"
+	"github.com/luo2pei4/ltool/pkg/dblayer/repo"
+)
+
+func TestListNodes(t *testing.T) {
+	db := openTestDB(t, filepath.Join(t.TempDir(), "ltool.db"))
+	if err := migrate(db, "sqlite", nil); err != nil {
+		t.Fatal(err)
+	}
+	s := &gormLayer{DB: db}
+	day := time.Date(2026, 1, 10, 0, 0, 0, 0, time.Local)
+	err := s.AddNodes([]repo.Node{
+		{IPAddress: "10.0.0.1", Hostname: "mds01", OS: "Rocky Linux 9.4", GroupName: "mds", UpdateTime: day},
+		{IPAddress: "10.0.0.2", Hostname: "oss01", OS: "Rocky Linux 8.10", GroupName: "oss", UpdateTime: day.AddDate(0, 0, 1)},
+		{IPAddress: "10.0.1.3", Hostname: "oss02", OS: "Ubuntu 22.04", GroupName: "oss", UpdateTime: day.AddDate(0, 0, 2)},
+		{IPAddress: "10.0.1.4", Hostname: "client_1", Kernel: "5.14.0", UpdateTime: day.AddDate(0, 0, 3)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpdateNodeStatus("10.0.0.2", "online", day); err != nil {
+		t.Fatal(err)
+	}
+	db.Exec("INSERT INTO tags (name) VALUES ('lustre')")
+	db.Exec("INSERT INTO node_tags (node_id, tag_id) SELECT id, 1 FROM nodes WHERE hostname LIKE 'oss%'")
+
+	ips := func(q NodeQuery) []string {
+		t.Helper()
+		nodes, err := s.ListNodes(q)
+		if err != nil {
+			t.Fatalf("list %+v failed, %v", q, err)
+		}
+		count, err := s.CountNodes(q)
+		if err != nil {
+			t.Fatalf("count %+v failed, %v", q, err)
+		}
+		if q.Limit == 0 && q.Offset == 0 && count != int64(len(nodes)) {
+			t.Errorf("count %+v is %d, listed %d", q, count, len(nodes))
+		}
+		var list []string
+		for _, node := range nodes {
+			list = append(list, node.IPAddress)
+		}
+		return list
+	}
+	cases := []struct {
+		name string
+		q    NodeQuery
+		want []string
+	}{
+		{"all", NodeQuery{}, []string{"10.0.0.1", "10.0.0.2", "10.0.1.3", "10.0.1.4"}},
+		{"ip", NodeQuery{IP: "0.1."}, []string{"10.0.1.3", "10.0.1.4"}},
+		{"hostname ignores case", NodeQuery{Hostname: "OSS"}, []string{"10.0.0.2", "10.0.1.3"}},
+		{"underscore is literal", NodeQuery{Hostname: "t_"}, []string{"10.0.1.4"}},
+		{"percent is literal", NodeQuery{Hostname: "%"}, nil},
+		{"os and group", NodeQuery{OS: "rocky", Group: "oss"}, []string{"10.0.0.2"}},
+		{"kernel", NodeQuery{Kernel: "5.14"}, []string{"10.0.1.4"}},
+		{"tag", NodeQuery{Tag: "lustre"}, []string{"10.0.0.2", "10.0.1.3"}},
+		{"status", NodeQuery{Status: "online"}, []string{"10.0.0.2"}},
+		{"unknown status", NodeQuery{Status: StatusUnknown}, []string{"10.0.0.1", "10.0.1.3", "10.0.1.4"}},
+		{"updated", NodeQuery{UpdatedAfter: day.AddDate(0, 0, 1), UpdatedBefore: day.AddDate(0, 0, 3)}, []string{"10.0.0.2", "10.0.1.3"}},
+		{"injection", NodeQuery{IP: "' OR '1'='1"}, nil},
+		{"sort", NodeQuery{SortBy: SortByHostname, Desc: true}, []string{"10.0.1.3", "10.0.0.2", "10.0.0.1", "10.0.1.4"}},
+		{"page", NodeQuery{SortBy: SortByUpdated, Offset: 1, Limit: 2}, []string{"10.0.0.2", "10.0.1.3"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := ips(c.q)
+			if len(got) != len(c.want) {
+				t.Fatalf("nodes are %v, want %v", got, c.want)
+			}
+			for i := range c.want {
+				if got[i] != c.want[i] {
+					t.Fatalf("nodes are %v, want %v", got, c.want)
+				}
+			}
+		})
+	}
+	if count, _ := s.CountNodes(NodeQuery{Group: "oss", Limit: 1}); count != 2 {
+		t.Errorf("count ignoring the page is %d", count)
+	}
+	if _, err := s.ListNodes(NodeQuery{SortBy: "password"}); err == nil {
+		t.Error("sorting by an unsupported field succeeded")
+	}
+
+	// updating the node keeps its status
+	node := repo.Node{IPAddress: "10.0.0.2", Hostname: "oss01"}
+	if err := s.UpdateNode(&node); err != nil {
+		t.Fatal(err)
+	}
+	var status string
+	db.Table(consts.TableNodes).Select("status").Where("ip_address = ?", "10.0.0.2").Scan(&status)
+	if status != "online" {
+		t.Errorf("status after update is %q", status)
+	}
+}
+
+func TestNodeTags(t *testing.T) {
+	db := openTestDB(t, filepath.Join(t.TempDir(), "ltool.db"))
+	if err := migrate(db, "sqlite", nil); err != nil {
+		t.Fatal(err)
+	}
+	s := &gormLayer{DB: db}
+	err := s.AddNodes([]repo.Node{
+		{IPAddress: "10.0.0.1", GroupName: "mds"},
+		{IPAddress: "10.0.0.2", GroupName: "oss"},
+		{IPAddress: "10.0.0.3", GroupName: "oss"},
+		{IPAddress: "10.0.0.4"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if groups, err := s.ListGroups(); err != nil || !reflect.DeepEqual(groups, []string{"mds", "oss"}) {
+		t.Errorf("groups are %v, %v", groups, err)
+	}
+	for ip, tags := range map[string][]string{"10.0.0.1": {"rack1"}, "10.0.0.2": {"rack2", "lustre"}, "10.0.0.3": {"lustre"}} {
+		if err := s.SetNodeTags(ip, tags); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// replacing the tags drops rack1 from the listed tags
+	if err := s.SetNodeTags("10.0.0.1", []string{"lustre"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetNodeTags("10.0.0.9", []string{"lustre"}); err == nil {
+		t.Error("tagging an unknown node succeeded")
+	}
+	if tags, err := s.ListTags(); err != nil || !reflect.DeepEqual(tags, []string{"lustre", "rack2"}) {
+		t.Errorf("tags are %v, %v", tags, err)
+	}
+	want := map[string][]string{"10.0.0.1": {"lustre"}, "10.0.0.2": {"lustre", "rack2"}}
+	if tags, err := s.ListNodeTags([]string{"10.0.0.1", "10.0.0.2", "10.0.0.4"}); err != nil || !reflect.DeepEqual(tags, want) {
+		t.Errorf("node tags are %v, %v", tags, err)
+	}
+	if nodes, _ := s.ListNodes(NodeQuery{Tag: "rack2"}); len(nodes) != 1 || nodes[0].IPAddress != "10.0.0.2" {
+		t.Errorf("nodes tagged rack2 are %+v", nodes)
+	}
+
+	// deleting the node deletes its tags
+	if err := s.DeleteNode("10.0.0.2"); err != nil {
+		t.Fatal(err)
+	}
+	if tags, err := s.ListTags(); err != nil || !reflect.DeepEqual(tags, []string{"lustre"}) {
+		t.Errorf("tags after delete are %v, %v", tags, err)
+	}
+}
@@ -0,0 +1,19 @@
+package repo
+
+import "time"
+
+// Cluster a Lustre filesystem with its own nodes, jump hosts and settings,
+// the credentials are the defaults of the nodes added to it
+type Cluster struct {
+	ID          int       `gorm:"column:id;primaryKey;autoIncrement"`
+	Name        string    `gorm:"column:name;uniqueIndex"`
+	Description string    `gorm:"column:description"`
+	UserName    string    `gorm:"column:user_name"`
+	AuthType    string    `gorm:"column:auth_type"`
+	Password    string    `gorm:"column:password;serializer:secret"`
+	KeyFile     string    `gorm:"column:key_file"`
+	Passphrase  string    `gorm:"column:passphrase;serializer:secret"`
+	CertFile    string    `gorm:"column:cert_file"`
+	CreateTime  time.Time `gorm:"column:create_time"`
+	UpdateTime  time.Time `gorm:"column:update_time"`
+}
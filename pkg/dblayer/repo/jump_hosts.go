@@ -0,0 +1,19 @@
+package repo
+
+import "time"
+
+// JumpHost a bastion which tunnels the ssh connections to the nodes
+type JumpHost struct {
+	ID         int       `gorm:"column:id;primaryKey;autoIncrement"`
+	ClusterID  int       `gorm:"column:cluster_id"`
+	Name       string    `gorm:"column:name;uniqueIndex"`
+	Address    string    `gorm:"column:address"` // host[:port]
+	UserName   string    `gorm:"column:user_name"`
+	Password   string    `gorm:"column:password;serializer:secret"`
+	AuthType   string    `gorm:"column:auth_type"`
+	KeyFile    string    `gorm:"column:key_file"`
+	Passphrase string    `gorm:"column:passphrase;serializer:secret"`
+	CertFile   string    `gorm:"column:cert_file"`
+	CreateTime time.Time `gorm:"column:create_time"`
+	UpdateTime time.Time `gorm:"column:update_time"`
+}
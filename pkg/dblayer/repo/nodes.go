@@ -3,14 +3,30 @@ package repo
 import "time"
 
 type Node struct {
-	ID           int       `gorm:"column:id"`
-	IPAddress    string    `gorm:"column:ip_address"`
-	UserName     string    `gorm:"column:user_name"`
-	Password     string    `gorm:"column:password"`
-	Hostname     string    `gorm:"column:hostname"`
-	Architecture string    `gorm:"column:architecture"`
-	OS           string    `gorm:"column:os"`
-	Kernel       string    `gorm:"column:kernel"`
-	CreateTime   time.Time `gorm:"column:create_time"`
-	UpdateTime   time.Time `gorm:"column:update_time"`
+	ID                int       `gorm:"column:id"`
+	ClusterID         int       `gorm:"column:cluster_id"`
+	IPAddress         string    `gorm:"column:ip_address"`
+	UserName          string    `gorm:"column:user_name"`
+	Password          string    `gorm:"column:password;serializer:secret"`
+	AuthType          string    `gorm:"column:auth_type"`
+	KeyFile           string    `gorm:"column:key_file"`
+	Passphrase        string    `gorm:"column:passphrase;serializer:secret"`
+	CertFile          string    `gorm:"column:cert_file"`
+	ProxyJump         string    `gorm:"column:proxy_jump"` // jump host names separated by comma
+	BecomeMethod      string    `gorm:"column:become_method"`
+	BecomePassword    string    `gorm:"column:become_password;serializer:secret"`
+	Hostname          string    `gorm:"column:hostname"`
+	Architecture      string    `gorm:"column:architecture"`
+	OS                string    `gorm:"column:os"`
+	Kernel            string    `gorm:"column:kernel"`
+	GroupName         string    `gorm:"column:group_name"`
+	SSHPort           int       `gorm:"column:ssh_port"`           // 0 means 22
+	ConnectTimeout    int       `gorm:"column:connect_timeout"`    // seconds, 0 means the default
+	KeepaliveInterval int       `gorm:"column:keepalive_interval"` // seconds, 0 sends no keepalives
+	Ciphers           string    `gorm:"column:ciphers"`            // separated by comma
+	KexAlgorithms     string    `gorm:"column:kex_algorithms"`     // separated by comma
+	Status            string    `gorm:"column:status"`             // of the last check, empty means unknown
+	StatusTime        time.Time `gorm:"column:status_time;serializer:nulltime"`
+	CreateTime        time.Time `gorm:"column:create_time"`
+	UpdateTime        time.Time `gorm:"column:update_time"`
 }
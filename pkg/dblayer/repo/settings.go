@@ -0,0 +1,7 @@
+package repo
+
+type Setting struct {
+	ClusterID int    `gorm:"column:cluster_id;primaryKey;autoIncrement:false"` // 0 means global
+	Name      string `gorm:"column:name;primaryKey"`
+	Value     string `gorm:"column:value"`
+}
@@ -0,0 +1,13 @@
+package repo
+
+// Tag a label of the nodes, e.g. a rack or a filesystem
+type Tag struct {
+	ID   int    `gorm:"column:id;primaryKey;autoIncrement"`
+	Name string `gorm:"column:name"`
+}
+
+// NodeTag assigns a tag to a node
+type NodeTag struct {
+	NodeID int `gorm:"column:node_id;primaryKey"`
+	TagID  int `gorm:"column:tag_id;primaryKey"`
+}
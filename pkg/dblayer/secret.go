@@ -0,0 +1,96 @@
+package dblayer
+
+import (
+	"encoding/base64"
+	"errors"
+
+	"github.com/luo2pei4/ltool/pkg/dblayer/repo"
+	"github.com/luo2pei4/ltool/pkg/secret"
+)
+
+const (
+	settingSecretSalt     = "secret_salt"
+	settingSecretVerifier = "secret_verifier"
+	// verifierText is encrypted to check the master passphrase
+	verifierText = "ltool"
+)
+
+// HasPassphrase reports whether the master passphrase was set
+func HasPassphrase() (bool, error) {
+	salt, err := DB.GetSetting(settingSecretSalt)
+	if err != nil {
+		return false, err
+	}
+	return salt != "", nil
+}
+
+// SetPassphrase sets the first master passphrase and encrypts
+// the plain-text credentials
+func SetPassphrase(passphrase string) error {
+	if ok, err := HasPassphrase(); err != nil {
+		return err
+	} else if ok {
+		return errors.New("master passphrase was set already")
+	}
+	return rekey(passphrase)
+}
+
+// Unlock checks the master passphrase and encrypts the plain-text
+// credentials left by older versions
+func Unlock(passphrase string) error {
+	salt, err := DB.GetSetting(settingSecretSalt)
+	if err != nil {
+		return err
+	}
+	rawSalt, err := base64.StdEncoding.DecodeString(salt)
+	if err != nil {
+		return err
+	}
+	c, err := secret.New(passphrase, rawSalt)
+	if err != nil {
+		return err
+	}
+	verifier, err := DB.GetSetting(settingSecretVerifier)
+	if err != nil {
+		return err
+	}
+	if text, err := c.Decrypt(verifier); err != nil || text != verifierText {
+		return secret.ErrBadPassphrase
+	}
+	secret.SetCurrent(c)
+	// only the rows saved in plain text are rewritten
+	return DB.EncryptPlainCredentials(c)
+}
+
+// ChangePassphrase re-encrypts the credentials with a new master passphrase
+func ChangePassphrase(oldPassphrase, newPassphrase string) error {
+	if err := Unlock(oldPassphrase); err != nil {
+		return err
+	}
+	return rekey(newPassphrase)
+}
+
+// rekey derives a new key with a fresh salt and rewrites the credentials
+func rekey(passphrase string) error {
+	salt, err := secret.NewSalt()
+	if err != nil {
+		return err
+	}
+	c, err := secret.New(passphrase, salt)
+	if err != nil {
+		return err
+	}
+	verifier, err := c.Encrypt(verifierText)
+	if err != nil {
+		return err
+	}
+	settings := []repo.Setting{
+		{Name: settingSecretSalt, Value: base64.StdEncoding.EncodeToString(salt)},
+		{Name: settingSecretVerifier, Value: verifier},
+	}
+	if err := DB.RekeyCredentials(c, settings); err != nil {
+		return err
+	}
+	secret.SetCurrent(c)
+	return nil
+}
@@ -0,0 +1,89 @@
+package dblayer
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/luo2pei4/ltool/pkg/secret"
+	"gorm.io/gorm/schema"
+)
+
+// secretSerializer encrypts the fields tagged with serializer:secret,
+// the plain-text values written by older versions are read as they are.
+type secretSerializer struct{}
+
+func init() {
+	schema.RegisterSerializer("secret", secretSerializer{})
+}
+
+func (secretSerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	var value string
+	switch v := dbValue.(type) {
+	case nil:
+	case string:
+		value = v
+	case []byte:
+		value = string(v)
+	default:
+		return fmt.Errorf("unsupported value type %T of %s", dbValue, field.DBName)
+	}
+	if secret.IsEncrypted(value) {
+		c := secret.FromContext(ctx)
+		if c == nil {
+			return secret.ErrLocked
+		}
+		plain, err := c.Decrypt(value)
+		if err != nil {
+			return err
+		}
+		value = plain
+	}
+	return field.Set(ctx, dst, value)
+}
+
+func (secretSerializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	value, ok := fieldValue.(string)
+	if !ok {
+		return nil, fmt.Errorf("unsupported value type %T of %s", fieldValue, field.DBName)
+	}
+	if value == "" {
+		return "", nil
+	}
+	c := secret.FromContext(ctx)
+	if c == nil {
+		return nil, secret.ErrLocked
+	}
+	return c.Encrypt(value)
+}
+
+// nullTimeSerializer writes the zero time of the fields tagged with
+// serializer:nulltime as NULL, MySQL rejects or mangles the zero dates.
+type nullTimeSerializer struct{}
+
+func init() {
+	schema.RegisterSerializer("nulltime", nullTimeSerializer{})
+}
+
+func (nullTimeSerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	switch v := dbValue.(type) {
+	case nil:
+		return field.Set(ctx, dst, time.Time{})
+	case []byte:
+		return field.Set(ctx, dst, string(v))
+	default:
+		return field.Set(ctx, dst, v)
+	}
+}
+
+func (nullTimeSerializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	t, ok := fieldValue.(time.Time)
+	if !ok {
+		return nil, fmt.Errorf("unsupported value type %T of %s", fieldValue, field.DBName)
+	}
+	if t.IsZero() {
+		return nil, nil
+	}
+	return t, nil
+}
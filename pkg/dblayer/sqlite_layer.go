@@ -1,24 +1,47 @@
 package dblayer
 
 import (
+	"fmt"
+	"time"
+
+	logger "github.com/luo2pei4/ltool/pkg/log"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
-type sqliteLayer struct {
-	*gorm.DB
-}
-
 func init() {
 	regist("sqlite", createSqliteClient)
 }
 
 func createSqliteClient(dsn string) (dblayer, error) {
-	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{
-		PrepareStmt: true,
-	})
+	l, err := openGormLayer(sqlite.Open(dsn), "sqlite", sqliteBackup)
 	if err != nil {
 		return nil, err
 	}
-	return &sqliteLayer{DB: db}, nil
+	return l, nil
+}
+
+// sqliteBackup copies the database file to <file>.v<version>-<time>.bak,
+// an in-memory database is not copied.
+func sqliteBackup(db *gorm.DB) func(version int) error {
+	return func(version int) error {
+		var files []struct {
+			Name string
+			File string
+		}
+		if err := db.Raw("PRAGMA database_list").Scan(&files).Error; err != nil {
+			return err
+		}
+		for _, f := range files {
+			if f.Name != "main" || f.File == "" {
+				continue
+			}
+			backup := fmt.Sprintf("%s.v%d-%s.bak", f.File, version, time.Now().Format("20060102150405"))
+			if err := db.Exec("VACUUM INTO ?", backup).Error; err != nil {
+				return err
+			}
+			logger.Infof("backed up the database to %s", backup)
+		}
+		return nil
+	}
 }
@@ -0,0 +1,309 @@
+package inventory
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ansibleGroup a group of an Ansible inventory
+type ansibleGroup struct {
+	name     string
+	hosts    []ansibleHost
+	vars     map[string]string
+	children []string
+	parents  []string
+}
+
+// ansibleHost a host pattern and its variables
+type ansibleHost struct {
+	line    int
+	pattern string
+	vars    map[string]string
+}
+
+// ansibleInventory the groups in the order they appear
+type ansibleInventory struct {
+	groups []*ansibleGroup
+	byName map[string]*ansibleGroup
+}
+
+func newAnsibleInventory() *ansibleInventory {
+	inv := &ansibleInventory{byName: make(map[string]*ansibleGroup)}
+	inv.group("all")
+	return inv
+}
+
+// group returns the group, it is added if it does not exist
+func (inv *ansibleInventory) group(name string) *ansibleGroup {
+	if g, ok := inv.byName[name]; ok {
+		return g
+	}
+	g := &ansibleGroup{name: name, vars: make(map[string]string)}
+	inv.groups = append(inv.groups, g)
+	inv.byName[name] = g
+	return g
+}
+
+func (inv *ansibleInventory) addChild(parent, child string) {
+	p := inv.group(parent)
+	c := inv.group(child)
+	p.children = append(p.children, child)
+	c.parents = append(c.parents, parent)
+}
+
+// parseAnsibleINI reads an Ansible inventory in ini format
+func parseAnsibleINI(data []byte) ([]Entry, error) {
+	inv := newAnsibleInventory()
+	current, kind := inv.group("ungrouped"), ""
+	for i, raw := range strings.Split(string(data), "\n") {
+		line := strings.TrimSpace(raw)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: invalid section %s", i+1, line)
+			}
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			name, kind, _ = strings.Cut(name, ":")
+			if kind != "" && kind != "vars" && kind != "children" {
+				return nil, fmt.Errorf("line %d: invalid section %s", i+1, line)
+			}
+			current = inv.group(name)
+			continue
+		}
+		fields, err := splitFields(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+		switch kind {
+		case "vars":
+			key, value, ok := strings.Cut(line, "=")
+			if !ok {
+				return nil, fmt.Errorf("line %d: expected key=value", i+1)
+			}
+			current.vars[strings.TrimSpace(key)] = unquote(strings.TrimSpace(value))
+		case "children":
+			inv.addChild(current.name, fields[0])
+		default:
+			host := ansibleHost{line: i + 1, pattern: fields[0], vars: make(map[string]string)}
+			for _, field := range fields[1:] {
+				key, value, ok := strings.Cut(field, "=")
+				if !ok {
+					return nil, fmt.Errorf("line %d: expected key=value, got %s", i+1, field)
+				}
+				host.vars[key] = unquote(value)
+			}
+			current.hosts = append(current.hosts, host)
+		}
+	}
+	return inv.entries(), nil
+}
+
+// parseAnsibleYAML reads an Ansible inventory in yaml format
+func parseAnsibleYAML(data []byte) ([]Entry, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parse yaml failed, %v", err)
+	}
+	inv := newAnsibleInventory()
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("line %d: expected the groups", root.Line)
+	}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if err := inv.addYAMLGroup(root.Content[i].Value, root.Content[i+1]); err != nil {
+			return nil, err
+		}
+	}
+	return inv.entries(), nil
+}
+
+func (inv *ansibleInventory) addYAMLGroup(name string, node *yaml.Node) error {
+	g := inv.group(name)
+	if node.Kind != yaml.MappingNode {
+		// an empty group
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		switch key.Value {
+		case "hosts":
+			if value.Kind != yaml.MappingNode {
+				continue
+			}
+			for j := 0; j+1 < len(value.Content); j += 2 {
+				host := ansibleHost{line: value.Content[j].Line, pattern: value.Content[j].Value}
+				vars, err := yamlVars(value.Content[j+1])
+				if err != nil {
+					return err
+				}
+				host.vars = vars
+				g.hosts = append(g.hosts, host)
+			}
+		case "vars":
+			vars, err := yamlVars(value)
+			if err != nil {
+				return err
+			}
+			for k, v := range vars {
+				g.vars[k] = v
+			}
+		case "children":
+			if value.Kind != yaml.MappingNode {
+				continue
+			}
+			for j := 0; j+1 < len(value.Content); j += 2 {
+				child := value.Content[j].Value
+				inv.addChild(name, child)
+				if err := inv.addYAMLGroup(child, value.Content[j+1]); err != nil {
+					return err
+				}
+			}
+		default:
+			return fmt.Errorf("line %d: unexpected key %s in group %s", key.Line, key.Value, name)
+		}
+	}
+	return nil
+}
+
+// yamlVars returns the scalar variables of node, the others are ignored
+func yamlVars(node *yaml.Node) (map[string]string, error) {
+	vars := make(map[string]string)
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+		return vars, nil
+	}
+	if node.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("line %d: expected the variables", node.Line)
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if value := node.Content[i+1]; value.Kind == yaml.ScalarNode {
+			vars[node.Content[i].Value] = value.Value
+		}
+	}
+	return vars, nil
+}
+
+// entries returns a node per host, a host listed in several groups keeps
+// its first group. The variables of the groups apply from the outermost.
+func (inv *ansibleInventory) entries() []Entry {
+	var entries []Entry
+	seen := make(map[string]bool)
+	for _, g := range inv.groups {
+		for _, host := range g.hosts {
+			names, err := ExpandNodeSet(host.pattern)
+			if err != nil {
+				entries = append(entries, Entry{Line: host.line, Host: host.pattern, Err: err.Error()})
+				continue
+			}
+			vars := inv.vars(g)
+			for k, v := range host.vars {
+				vars[k] = v
+			}
+			for _, name := range names {
+				if seen[name] {
+					continue
+				}
+				seen[name] = true
+				entries = append(entries, ansibleEntry(host.line, name, g.name, vars))
+			}
+		}
+	}
+	return entries
+}
+
+// vars merges the variables of all, the ancestors of g and g
+func (inv *ansibleInventory) vars(g *ansibleGroup) map[string]string {
+	chain := []*ansibleGroup{g}
+	visited := map[string]bool{g.name: true}
+	for p := g; len(p.parents) > 0 && !visited[p.parents[0]]; {
+		p = inv.byName[p.parents[0]]
+		visited[p.name] = true
+		chain = append(chain, p)
+	}
+	if !visited["all"] {
+		chain = append(chain, inv.byName["all"])
+	}
+	vars := make(map[string]string)
+	for i := len(chain) - 1; i >= 0; i-- {
+		for k, v := range chain[i].vars {
+			vars[k] = v
+		}
+	}
+	return vars
+}
+
+func ansibleEntry(line int, name, group string, vars map[string]string) Entry {
+	if group == "all" || group == "ungrouped" {
+		group = ""
+	}
+	entry := entryForHost(line, name, group)
+	if host := firstVar(vars, "ansible_host", "ansible_ssh_host"); host != "" {
+		entry.Host = host
+		if net.ParseIP(host) != nil {
+			entry.IP = host
+		}
+	}
+	entry.User = firstVar(vars, "ansible_user", "ansible_ssh_user")
+	if password := firstVar(vars, "ansible_password", "ansible_ssh_pass"); password != "" {
+		entry.Auth = "password:" + password
+	} else if keyFile := vars["ansible_ssh_private_key_file"]; keyFile != "" {
+		entry.Auth = "key:" + keyFile
+	}
+	return entry
+}
+
+func firstVar(vars map[string]string, names ...string) string {
+	for _, name := range names {
+		if v := vars[name]; v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// splitFields splits line by the spaces outside of quotes
+func splitFields(line string) ([]string, error) {
+	var fields []string
+	var b strings.Builder
+	var quote rune
+	for _, c := range line {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+			b.WriteRune(c)
+		case c == '\'' || c == '"':
+			quote = c
+			b.WriteRune(c)
+		case c == ' ' || c == '\t':
+			if b.Len() > 0 {
+				fields = append(fields, b.String())
+				b.Reset()
+			}
+		default:
+			b.WriteRune(c)
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unclosed quote")
+	}
+	if b.Len() > 0 {
+		fields = append(fields, b.String())
+	}
+	return fields, nil
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '\'' || s[0] == '"') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
@@ -0,0 +1,134 @@
+package inventory
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// nodeGroup a group and its node set as written in the file
+type nodeGroup struct {
+	line  int
+	name  string
+	nodes string
+}
+
+var clusterShellLine = regexp.MustCompile(`^([\w.-]+)\s*:\s*(.*)$`)
+
+// parseClusterShell reads ClusterShell group files, either the
+// "group: nodeset" lines of local.cfg or the yaml files of groups.d.
+// The nodes of a group may refer to other groups by @group.
+func parseClusterShell(data []byte) ([]Entry, error) {
+	groups, err := clusterShellYAML(data)
+	if err != nil {
+		groups = nil
+		for i, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			m := clusterShellLine.FindStringSubmatch(line)
+			if m == nil {
+				return nil, fmt.Errorf("line %d is not 'group: nodes'", i+1)
+			}
+			groups = append(groups, nodeGroup{line: i + 1, name: m[1], nodes: m[2]})
+		}
+	}
+
+	byName := make(map[string]*nodeGroup, len(groups))
+	for i := range groups {
+		byName[groups[i].name] = &groups[i]
+	}
+	var entries []Entry
+	seen := make(map[string]bool)
+	for _, group := range groups {
+		nodes, err := expandGroup(byName, group.name, map[string]bool{})
+		if err != nil {
+			entries = append(entries, Entry{Line: group.line, Host: group.nodes, Group: group.name, Err: err.Error()})
+			continue
+		}
+		for _, node := range nodes {
+			if seen[node] {
+				continue
+			}
+			seen[node] = true
+			entries = append(entries, entryForHost(group.line, node, group.name))
+		}
+	}
+	return entries, nil
+}
+
+// clusterShellYAML reads the groups of every source of a groups.d file
+func clusterShellYAML(data []byte) ([]nodeGroup, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("not a yaml mapping")
+	}
+	var groups []nodeGroup
+	var add func(mapping *yaml.Node, nested bool) error
+	add = func(mapping *yaml.Node, nested bool) error {
+		for i := 0; i+1 < len(mapping.Content); i += 2 {
+			key, value := mapping.Content[i], mapping.Content[i+1]
+			switch value.Kind {
+			case yaml.ScalarNode:
+				groups = append(groups, nodeGroup{line: key.Line, name: key.Value, nodes: value.Value})
+			case yaml.SequenceNode:
+				var nodes []string
+				for _, item := range value.Content {
+					nodes = append(nodes, item.Value)
+				}
+				groups = append(groups, nodeGroup{line: key.Line, name: key.Value, nodes: strings.Join(nodes, ",")})
+			case yaml.MappingNode:
+				if nested {
+					return fmt.Errorf("line %d: unexpected mapping", value.Line)
+				}
+				// a group source, e.g. roles
+				if err := add(value, true); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if err := add(doc.Content[0], false); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// expandGroup expands the node set of the group and the groups it refers to
+func expandGroup(groups map[string]*nodeGroup, name string, visiting map[string]bool) ([]string, error) {
+	group, ok := groups[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown group @%s", name)
+	}
+	if visiting[name] {
+		return nil, fmt.Errorf("group @%s refers to itself", name)
+	}
+	visiting[name] = true
+	defer delete(visiting, name)
+
+	var nodes []string
+	for _, part := range splitTopLevel(group.nodes) {
+		part = strings.TrimSpace(part)
+		if strings.HasPrefix(part, "@") {
+			referred, err := expandGroup(groups, strings.TrimPrefix(part, "@"), visiting)
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, referred...)
+			continue
+		}
+		expanded, err := ExpandNodeSet(part)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, expanded...)
+	}
+	return nodes, nil
+}
@@ -0,0 +1,81 @@
+package inventory
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// csvColumns the columns of a csv file without a header line
+var csvColumns = []string{"ip", "user", "auth", "hostname", "group"}
+
+// parseCSV reads "ip,user,auth,hostname,group" lines, a header line
+// with an "ip" column may reorder or omit the columns.
+func parseCSV(data []byte) ([]Entry, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	columns := csvColumns
+	var entries []Entry
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		line, _ := reader.FieldPos(0)
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				entries = append(entries, Entry{Line: parseErr.Line, Err: parseErr.Err.Error()})
+				continue
+			}
+			return nil, fmt.Errorf("read csv failed, %v", err)
+		}
+		if first && isCSVHeader(record) {
+			columns = make([]string, len(record))
+			for i, name := range record {
+				columns[i] = strings.ToLower(strings.TrimSpace(name))
+			}
+			continue
+		}
+		entry := Entry{Line: line}
+		for i, value := range record {
+			if i >= len(columns) {
+				break
+			}
+			value = strings.TrimSpace(value)
+			switch columns[i] {
+			case "ip":
+				entry.Host = value
+				entry.IP = value
+			case "user":
+				entry.User = value
+			case "auth":
+				entry.Auth = value
+			case "hostname":
+				entry.Hostname = value
+			case "group":
+				entry.Group = value
+			}
+		}
+		if entry.IP == "" {
+			entry.Err = "the ip address is empty"
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func isCSVHeader(record []string) bool {
+	for _, name := range record {
+		if strings.EqualFold(strings.TrimSpace(name), "ip") {
+			return true
+		}
+	}
+	return false
+}
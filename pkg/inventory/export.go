@@ -0,0 +1,203 @@
+package inventory
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// export only formats
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+)
+
+// ExportFormats the formats the nodes can be exported to
+var ExportFormats = []string{FormatCSV, FormatJSON, FormatYAML, FormatAnsibleINI, FormatClusterShell}
+
+// Redacted replaces the secrets of the exported nodes, the importer treats
+// it as a missing secret.
+const Redacted = "********"
+
+// Node a node written to an export file, the secrets are the password,
+// the passphrase and the become password.
+type Node struct {
+	IP             string `json:"ip" yaml:"ip"`
+	User           string `json:"user" yaml:"user"`
+	AuthType       string `json:"auth_type,omitempty" yaml:"auth_type,omitempty"`
+	Password       string `json:"password,omitempty" yaml:"password,omitempty"`
+	KeyFile        string `json:"key_file,omitempty" yaml:"key_file,omitempty"`
+	Passphrase     string `json:"passphrase,omitempty" yaml:"passphrase,omitempty"`
+	CertFile       string `json:"cert_file,omitempty" yaml:"cert_file,omitempty"`
+	ProxyJump      string `json:"proxy_jump,omitempty" yaml:"proxy_jump,omitempty"`
+	BecomeMethod   string `json:"become_method,omitempty" yaml:"become_method,omitempty"`
+	BecomePassword string `json:"become_password,omitempty" yaml:"become_password,omitempty"`
+	Hostname       string `json:"hostname,omitempty" yaml:"hostname,omitempty"`
+	OS             string `json:"os,omitempty" yaml:"os,omitempty"`
+	Arch           string `json:"arch,omitempty" yaml:"arch,omitempty"`
+	Kernel         string `json:"kernel,omitempty" yaml:"kernel,omitempty"`
+	Group          string `json:"group,omitempty" yaml:"group,omitempty"`
+}
+
+// HideSecrets removes the secrets, or replaces them with Redacted if redact is true
+func (n *Node) HideSecrets(redact bool) {
+	for _, secret := range []*string{&n.Password, &n.Passphrase, &n.BecomePassword} {
+		if *secret == "" {
+			continue
+		}
+		if redact {
+			*secret = Redacted
+		} else {
+			*secret = ""
+		}
+	}
+}
+
+// name is the name of the node in Ansible and ClusterShell, the hostname if known
+func (n *Node) name() string {
+	if n.Hostname != "" {
+		return n.Hostname
+	}
+	return n.IP
+}
+
+// Export writes the nodes in format
+func Export(w io.Writer, format string, nodes []Node) error {
+	switch format {
+	case FormatCSV:
+		return exportCSV(w, nodes)
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(nodes)
+	case FormatYAML:
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(nodes); err != nil {
+			return err
+		}
+		return encoder.Close()
+	case FormatAnsibleINI:
+		return exportAnsibleINI(w, nodes)
+	case FormatClusterShell:
+		return exportClusterShell(w, nodes)
+	default:
+		return fmt.Errorf("unsupported format '%s'", format)
+	}
+}
+
+// exportCSV writes the columns the csv import reads and the host information
+func exportCSV(w io.Writer, nodes []Node) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"ip", "user", "auth", "hostname", "group", "os", "arch", "kernel"})
+	for _, n := range nodes {
+		auth := n.AuthType
+		switch {
+		case n.Password != "":
+			auth = joinAuth(auth, "password", n.Password)
+		case n.KeyFile != "":
+			auth = joinAuth(auth, "key", n.KeyFile)
+		}
+		writer.Write([]string{n.IP, n.User, auth, n.Hostname, n.Group, n.OS, n.Arch, n.Kernel})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func joinAuth(authType, defType, value string) string {
+	if authType == "" {
+		authType = defType
+	}
+	return authType + ":" + value
+}
+
+// groupedNodes returns the group names in the order they appear, the nodes
+// without a group come under "".
+func groupedNodes(nodes []Node) ([]string, map[string][]Node) {
+	var names []string
+	groups := make(map[string][]Node)
+	for _, n := range nodes {
+		if _, ok := groups[n.Group]; !ok {
+			names = append(names, n.Group)
+		}
+		groups[n.Group] = append(groups[n.Group], n)
+	}
+	return names, groups
+}
+
+// exportAnsibleINI writes a section per group, the host information
+// becomes the ltool_* host variables.
+func exportAnsibleINI(w io.Writer, nodes []Node) error {
+	names, groups := groupedNodes(nodes)
+	// the ungrouped hosts must come before the first section
+	sort.SliceStable(names, func(i, j int) bool {
+		return names[i] == "" && names[j] != ""
+	})
+	var b strings.Builder
+	for _, name := range names {
+		if name != "" {
+			fmt.Fprintf(&b, "\n[%s]\n", name)
+		}
+		for _, n := range groups[name] {
+			b.WriteString(n.name())
+			vars := [][2]string{
+				{"ansible_host", n.IP},
+				{"ansible_user", n.User},
+				{"ansible_password", n.Password},
+				{"ansible_ssh_private_key_file", n.KeyFile},
+				{"ansible_become_method", n.BecomeMethod},
+				{"ansible_become_password", n.BecomePassword},
+				{"ltool_os", n.OS},
+				{"ltool_arch", n.Arch},
+				{"ltool_kernel", n.Kernel},
+			}
+			for _, v := range vars {
+				if v[1] != "" {
+					fmt.Fprintf(&b, " %s=%s", v[0], iniQuote(v[1]))
+				}
+			}
+			b.WriteString("\n")
+		}
+	}
+	_, err := io.WriteString(w, strings.TrimPrefix(b.String(), "\n"))
+	return err
+}
+
+func iniQuote(s string) string {
+	switch {
+	case !strings.ContainsAny(s, " \t'\"#;="):
+		return s
+	case strings.Contains(s, "'"):
+		return `"` + s + `"`
+	default:
+		return "'" + s + "'"
+	}
+}
+
+// exportClusterShell writes a "group: nodeset" line per group and the all
+// group of every node, like local.cfg.
+func exportClusterShell(w io.Writer, nodes []Node) error {
+	names, groups := groupedNodes(nodes)
+	var b strings.Builder
+	var all []string
+	for _, name := range names {
+		var members []string
+		for _, n := range groups[name] {
+			members = append(members, n.name())
+		}
+		all = append(all, members...)
+		if name != "" && name != "all" {
+			fmt.Fprintf(&b, "%s: %s\n", name, FoldNodeSet(members))
+		}
+	}
+	if len(all) > 0 {
+		fmt.Fprintf(&b, "all: %s\n", FoldNodeSet(all))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,35 @@
+package inventory
+
+import (
+	"net"
+	"strings"
+)
+
+// parseHosts reads "ip hostname [aliases...]" lines like /etc/hosts,
+// the loopback, link-local and multicast addresses are skipped.
+func parseHosts(data []byte) ([]Entry, error) {
+	var entries []Entry
+	for i, line := range strings.Split(string(data), "\n") {
+		if comment := strings.IndexByte(line, '#'); comment >= 0 {
+			line = line[:comment]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		ip := net.ParseIP(fields[0])
+		switch {
+		case ip == nil:
+			entries = append(entries, Entry{Line: i + 1, Host: fields[0], Err: "invalid ip address"})
+			continue
+		case !ip.IsGlobalUnicast():
+			continue
+		}
+		entry := Entry{Line: i + 1, Host: fields[0], IP: fields[0]}
+		if len(fields) > 1 {
+			entry.Hostname = fields[1]
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
@@ -0,0 +1,103 @@
+// Package inventory reads and writes node lists of other tools, e.g. csv,
+// /etc/hosts, ClusterShell groups and Ansible inventories.
+package inventory
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// supported formats
+const (
+	FormatCSV          = "csv"
+	FormatHosts        = "hosts"
+	FormatClusterShell = "clustershell"
+	FormatAnsibleINI   = "ansible-ini"
+	FormatAnsibleYAML  = "ansible-yaml"
+)
+
+// ImportFormats the formats which can be imported
+var ImportFormats = []string{FormatCSV, FormatHosts, FormatClusterShell, FormatAnsibleINI, FormatAnsibleYAML}
+
+// Entry a node read from a file
+type Entry struct {
+	Line     int    // the line of the node in the file, 0 if unknown
+	Host     string // the name or address the file refers to the node by
+	IP       string // empty if Host must be resolved
+	User     string
+	Auth     string // authentication method, optionally followed by ':' and the password or key file
+	Hostname string
+	Group    string
+	Err      string // the entry could not be parsed
+}
+
+// Parse reads the nodes of data in format
+func Parse(format string, data []byte) ([]Entry, error) {
+	switch format {
+	case FormatCSV:
+		return parseCSV(data)
+	case FormatHosts:
+		return parseHosts(data)
+	case FormatClusterShell:
+		return parseClusterShell(data)
+	case FormatAnsibleINI:
+		return parseAnsibleINI(data)
+	case FormatAnsibleYAML:
+		return parseAnsibleYAML(data)
+	default:
+		return nil, fmt.Errorf("unsupported format '%s'", format)
+	}
+}
+
+var groupLine = regexp.MustCompile(`^[\w.-]+:\s`)
+
+// Detect guesses the format of the file from its name and content
+func Detect(name string, data []byte) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv":
+		return FormatCSV
+	case ".yaml", ".yml":
+		if bytes.Contains(data, []byte("hosts:")) || bytes.Contains(data, []byte("children:")) {
+			return FormatAnsibleYAML
+		}
+		return FormatClusterShell
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		fields := strings.Fields(line)
+		switch {
+		case strings.HasPrefix(line, "["):
+			return FormatAnsibleINI
+		case net.ParseIP(fields[0]) != nil && !strings.Contains(fields[0], ","):
+			if strings.Contains(line, "=") {
+				return FormatAnsibleINI
+			}
+			return FormatHosts
+		case groupLine.MatchString(line):
+			return FormatClusterShell
+		case strings.Contains(line, ","):
+			return FormatCSV
+		default:
+			return FormatAnsibleINI
+		}
+	}
+	return FormatCSV
+}
+
+// entryForHost returns the entry of host, the address of the node if host is an ip address
+func entryForHost(line int, host, group string) Entry {
+	entry := Entry{Line: line, Host: host, Group: group}
+	if ip := net.ParseIP(host); ip != nil {
+		entry.IP = host
+	} else {
+		entry.Hostname = host
+	}
+	return entry
+}
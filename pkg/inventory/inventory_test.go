@@ -0,0 +1,281 @@
+package inventory
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// summary renders the entries as "host ip user auth hostname group" lines
+func summary(entries []Entry) string {
+	var lines []string
+	for _, e := range entries {
+		if e.Err != "" {
+			lines = append(lines, fmt.Sprintf("%d: %s", e.Line, e.Err))
+			continue
+		}
+		lines = append(lines, strings.Join([]string{e.Host, e.IP, e.User, e.Auth, e.Hostname, e.Group}, "|"))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestExpandNodeSet(t *testing.T) {
+	tests := []struct {
+		set     string
+		want    string
+		wantErr bool
+	}{
+		{"oss[01-03]", "oss01,oss02,oss03", false},
+		{"oss[1-2,8],mds1", "oss1,oss2,oss8,mds1", false},
+		{"oss[01:05:2]", "oss01,oss03,oss05", false},
+		{"rack[1-2]-node[1-2]", "rack1-node1,rack1-node2,rack2-node1,rack2-node2", false},
+		{"10.0.0.[1:3]", "10.0.0.1,10.0.0.2,10.0.0.3", false},
+		{"oss[3-1]", "", true},
+		{"oss[1-3", "", true},
+		{"oss[1-100000]", "", true},
+	}
+	for _, tt := range tests {
+		nodes, err := ExpandNodeSet(tt.set)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v", tt.set, err)
+			continue
+		}
+		if got := strings.Join(nodes, ","); !tt.wantErr && got != tt.want {
+			t.Errorf("%s = %s, want %s", tt.set, got, tt.want)
+		}
+	}
+}
+
+func TestParseCSV(t *testing.T) {
+	data := "# exported nodes\n" +
+		"192.168.1.10,root,password:secret,oss01,oss\n" +
+		"192.168.1.11, admin, agent\n" +
+		",root\n"
+	entries, err := Parse(FormatCSV, []byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "192.168.1.10|192.168.1.10|root|password:secret|oss01|oss\n" +
+		"192.168.1.11|192.168.1.11|admin|agent||\n" +
+		"4: the ip address is empty"
+	if got := summary(entries); got != want {
+		t.Errorf("entries =\n%s\nwant\n%s", got, want)
+	}
+
+	// the header reorders the columns
+	entries, _ = Parse(FormatCSV, []byte("hostname,ip,group\noss01,192.168.1.10,oss\n"))
+	if got := summary(entries); got != "192.168.1.10|192.168.1.10|||oss01|oss" {
+		t.Errorf("entries = %s", got)
+	}
+}
+
+func TestParseHosts(t *testing.T) {
+	data := "127.0.0.1   localhost localhost.localdomain\n" +
+		"::1         localhost6\n" +
+		"192.168.1.10 oss01.lustre oss01 # the first oss\n" +
+		"\n" +
+		"192.168.1.300 broken\n"
+	entries, err := Parse(FormatHosts, []byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "192.168.1.10|192.168.1.10|||oss01.lustre|\n5: invalid ip address"
+	if got := summary(entries); got != want {
+		t.Errorf("entries =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestParseClusterShell(t *testing.T) {
+	local := "# local.cfg\nmds: mds[1-2]\noss: oss[01-02]\nall: @mds,@oss,client1\nbad: @missing\n"
+	entries, err := Parse(FormatClusterShell, []byte(local))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "mds1||||mds1|mds\nmds2||||mds2|mds\noss01||||oss01|oss\noss02||||oss02|oss\n" +
+		"client1||||client1|all\n5: unknown group @missing"
+	if got := summary(entries); got != want {
+		t.Errorf("entries =\n%s\nwant\n%s", got, want)
+	}
+
+	yamlGroups := "roles:\n  mds: 'mds[1-2]'\n  oss: 'oss[01-02]'\n"
+	entries, err = Parse(FormatClusterShell, []byte(yamlGroups))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 4 || entries[2].Hostname != "oss01" || entries[2].Group != "oss" || entries[2].Line != 3 {
+		t.Errorf("entries =\n%s", summary(entries))
+	}
+}
+
+func TestParseAnsibleINI(t *testing.T) {
+	data := `mgmt1 ansible_host=192.168.1.2
+
+[oss]
+oss[01:02] ansible_user=admin
+192.168.1.20
+
+[mds]
+mds1 ansible_host=192.168.1.5 ansible_ssh_private_key_file="/root/.ssh/id ed25519"
+
+[lustre:children]
+oss
+mds
+
+[lustre:vars]
+ansible_user=root
+ansible_password=secret
+`
+	entries, err := Parse(FormatAnsibleINI, []byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "192.168.1.2|192.168.1.2|||mgmt1|\n" +
+		"oss01||admin|password:secret|oss01|oss\n" +
+		"oss02||admin|password:secret|oss02|oss\n" +
+		"192.168.1.20|192.168.1.20|root|password:secret||oss\n" +
+		"192.168.1.5|192.168.1.5|root|password:secret|mds1|mds"
+	if got := summary(entries); got != want {
+		t.Errorf("entries =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestParseAnsibleYAML(t *testing.T) {
+	data := `all:
+  vars:
+    ansible_user: root
+  hosts:
+    mgmt1:
+      ansible_host: 192.168.1.2
+  children:
+    oss:
+      hosts:
+        oss[01:02]:
+      vars:
+        ansible_ssh_private_key_file: /root/.ssh/id_ed25519
+`
+	entries, err := Parse(FormatAnsibleYAML, []byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "192.168.1.2|192.168.1.2|root||mgmt1|\n" +
+		"oss01||root|key:/root/.ssh/id_ed25519|oss01|oss\n" +
+		"oss02||root|key:/root/.ssh/id_ed25519|oss02|oss"
+	if got := summary(entries); got != want {
+		t.Errorf("entries =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"nodes.csv", "192.168.1.10,root", FormatCSV},
+		{"hosts", "# comment\n192.168.1.10 oss01\n", FormatHosts},
+		{"inventory", "[oss]\noss01\n", FormatAnsibleINI},
+		{"inventory", "192.168.1.10 ansible_user=root\n", FormatAnsibleINI},
+		{"local.cfg", "oss: oss[01-16]\n", FormatClusterShell},
+		{"lustre.yaml", "roles:\n  oss: oss[01-16]\n", FormatClusterShell},
+		{"inventory.yml", "all:\n  hosts:\n", FormatAnsibleYAML},
+		{"nodes.txt", "192.168.1.10,root,agent\n", FormatCSV},
+	}
+	for _, tt := range tests {
+		if got := Detect(tt.name, []byte(tt.data)); got != tt.want {
+			t.Errorf("Detect(%s) = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFoldNodeSet(t *testing.T) {
+	tests := []struct {
+		names []string
+		want  string
+	}{
+		{[]string{"oss03", "oss01", "oss02", "mds1"}, "oss[01-03],mds1"},
+		{[]string{"oss09", "oss10", "oss12", "oss01"}, "oss[01,09-10,12]"},
+		{[]string{"node9", "node10", "node11"}, "node[9-11]"},
+		{[]string{"rack1-ib", "rack2-ib", "client"}, "client,rack[1-2]-ib"},
+		{[]string{"192.168.1.10", "192.168.1.11"}, "192.168.1.[10-11]"},
+		{[]string{"oss1"}, "oss1"},
+	}
+	for _, tt := range tests {
+		got := FoldNodeSet(tt.names)
+		if got != tt.want {
+			t.Errorf("FoldNodeSet(%v) = %s, want %s", tt.names, got, tt.want)
+		}
+		expanded, err := ExpandNodeSet(got)
+		if err != nil || len(expanded) != len(tt.names) {
+			t.Errorf("%s expands to %v, %v", got, expanded, err)
+		}
+	}
+}
+
+func TestExport(t *testing.T) {
+	nodes := []Node{
+		{IP: "192.168.1.10", User: "root", AuthType: "password", Password: "secret", Hostname: "oss01",
+			OS: "Rocky Linux 8.10", Arch: "x86-64", Kernel: "Linux 4.18.0", Group: "oss"},
+		{IP: "192.168.1.11", User: "root", AuthType: "key", KeyFile: "/root/.ssh/id_ed25519", Hostname: "oss02", Group: "oss"},
+		{IP: "192.168.1.2", User: "admin", AuthType: "agent"},
+	}
+	for i := range nodes {
+		nodes[i].HideSecrets(true)
+	}
+	if nodes[0].Password != Redacted || nodes[1].Password != "" {
+		t.Fatalf("secrets = %+v", nodes)
+	}
+
+	var b strings.Builder
+	if err := Export(&b, FormatCSV, nodes); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := Parse(FormatCSV, []byte(b.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "192.168.1.10|192.168.1.10|root|password:********|oss01|oss\n" +
+		"192.168.1.11|192.168.1.11|root|key:/root/.ssh/id_ed25519|oss02|oss\n" +
+		"192.168.1.2|192.168.1.2|admin|agent||"
+	if got := summary(entries); got != want {
+		t.Errorf("csv entries =\n%s\nwant\n%s", got, want)
+	}
+
+	b.Reset()
+	if err := Export(&b, FormatAnsibleINI, nodes); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(b.String(), "192.168.1.2 ansible_host=192.168.1.2 ansible_user=admin\n\n[oss]\n") ||
+		!strings.Contains(b.String(), "ltool_os='Rocky Linux 8.10'") {
+		t.Errorf("ansible inventory =\n%s", b.String())
+	}
+	entries, err = Parse(FormatAnsibleINI, []byte(b.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 || entries[1].IP != "192.168.1.10" || entries[1].Group != "oss" || entries[1].Hostname != "oss01" {
+		t.Errorf("ansible entries =\n%s", summary(entries))
+	}
+
+	b.Reset()
+	if err := Export(&b, FormatClusterShell, nodes); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != "oss: oss[01-02]\nall: oss[01-02],192.168.1.2\n" {
+		t.Errorf("clustershell groups =\n%s", b.String())
+	}
+
+	b.Reset()
+	if err := Export(&b, FormatJSON, nodes[:1]); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), `"password": "********"`) || !strings.Contains(b.String(), `"kernel": "Linux 4.18.0"`) {
+		t.Errorf("json =\n%s", b.String())
+	}
+	b.Reset()
+	if err := Export(&b, FormatYAML, nodes[2:]); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != "- ip: 192.168.1.2\n  user: admin\n  auth_type: agent\n" {
+		t.Errorf("yaml =\n%s", b.String())
+	}
+}
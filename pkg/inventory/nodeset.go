@@ -0,0 +1,216 @@
+package inventory
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MaxNodeSetSize limits the nodes a node set expands to
+const MaxNodeSetSize = 65536
+
+// ExpandNodeSet expands a ClusterShell node set like "oss[01-04,08],mds1"
+// or an Ansible host pattern like "oss[01:04]" to the node names.
+func ExpandNodeSet(set string) ([]string, error) {
+	var nodes []string
+	for _, pattern := range splitTopLevel(set) {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		expanded, err := expandPattern(pattern, MaxNodeSetSize-len(nodes))
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, expanded...)
+	}
+	return nodes, nil
+}
+
+// splitTopLevel splits s by the commas outside of brackets
+func splitTopLevel(s string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, c := range s {
+		switch c {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// expandPattern expands the first bracket of pattern and the rest recursively
+func expandPattern(pattern string, limit int) ([]string, error) {
+	open := strings.IndexByte(pattern, '[')
+	if open < 0 {
+		if strings.ContainsAny(pattern, "]!&^") {
+			return nil, fmt.Errorf("unsupported node set '%s'", pattern)
+		}
+		return []string{pattern}, nil
+	}
+	end := strings.IndexByte(pattern[open:], ']')
+	if end < 0 {
+		return nil, fmt.Errorf("unclosed '[' in '%s'", pattern)
+	}
+	end += open
+	prefix, ranges, suffix := pattern[:open], pattern[open+1:end], pattern[end+1:]
+	rest, err := expandPattern(suffix, limit)
+	if err != nil {
+		return nil, err
+	}
+	var nodes []string
+	for _, r := range strings.Split(ranges, ",") {
+		values, err := expandRange(strings.TrimSpace(r))
+		if err != nil {
+			return nil, fmt.Errorf("invalid range '%s' in '%s', %v", r, pattern, err)
+		}
+		if len(nodes)+len(values)*len(rest) > limit {
+			return nil, fmt.Errorf("'%s' expands to more than %d nodes", pattern, MaxNodeSetSize)
+		}
+		for _, value := range values {
+			for _, tail := range rest {
+				nodes = append(nodes, prefix+value+tail)
+			}
+		}
+	}
+	return nodes, nil
+}
+
+// expandRange expands "1-3", "01:03", "1:9:2" or a single value, the
+// numbers keep the width of a zero padded start.
+func expandRange(r string) ([]string, error) {
+	sep := "-"
+	if strings.Contains(r, ":") {
+		sep = ":"
+	}
+	parts := strings.Split(r, sep)
+	if len(parts) == 1 {
+		if parts[0] == "" {
+			return nil, errors.New("empty range")
+		}
+		return parts, nil
+	}
+	step := 1
+	switch {
+	case sep == "-" && len(parts) == 2:
+	case sep == ":" && (len(parts) == 2 || len(parts) == 3):
+		if len(parts) == 3 {
+			var err error
+			if step, err = strconv.Atoi(parts[2]); err != nil || step <= 0 {
+				return nil, errors.New("invalid step")
+			}
+		}
+	default:
+		return nil, errors.New("too many bounds")
+	}
+	from, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return nil, err
+	}
+	to, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	if to < from {
+		return nil, errors.New("the end is less than the start")
+	}
+	if (to-from)/step >= MaxNodeSetSize {
+		return nil, fmt.Errorf("more than %d values", MaxNodeSetSize)
+	}
+	width := 0
+	if len(parts[0]) > 1 && parts[0][0] == '0' {
+		width = len(parts[0])
+	}
+	var values []string
+	for i := from; i <= to; i += step {
+		values = append(values, fmt.Sprintf("%0*d", width, i))
+	}
+	return values, nil
+}
+
+// numbered matches the last number of a node name
+var numbered = regexp.MustCompile(`^(.*?)(\d+)(\D*)$`)
+
+// FoldNodeSet folds the node names to a node set, the reverse of ExpandNodeSet,
+// e.g. oss01,oss02,oss03,mds1 to oss[01-03],mds1
+func FoldNodeSet(names []string) string {
+	type pattern struct {
+		prefix, suffix string
+		width          int // 0 means not zero padded
+	}
+	// the widths of the zero padded numbers per prefix and suffix
+	padded := make(map[[2]string]map[int]bool)
+	for _, name := range names {
+		if m := numbered.FindStringSubmatch(name); m != nil && len(m[2]) > 1 && m[2][0] == '0' {
+			key := [2]string{m[1], m[3]}
+			if padded[key] == nil {
+				padded[key] = make(map[int]bool)
+			}
+			padded[key][len(m[2])] = true
+		}
+	}
+
+	var order []pattern
+	numbers := make(map[pattern][]int)
+	var parts []string
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		m := numbered.FindStringSubmatch(name)
+		if m == nil {
+			parts = append(parts, name)
+			continue
+		}
+		p := pattern{prefix: m[1], suffix: m[3]}
+		if padded[[2]string{m[1], m[3]}][len(m[2])] {
+			p.width = len(m[2])
+		}
+		number, err := strconv.Atoi(m[2])
+		if err != nil || (p.width == 0 && m[2][0] == '0' && len(m[2]) > 1) {
+			parts = append(parts, name)
+			continue
+		}
+		if _, ok := numbers[p]; !ok {
+			order = append(order, p)
+		}
+		numbers[p] = append(numbers[p], number)
+	}
+
+	for _, p := range order {
+		nums := numbers[p]
+		if len(nums) == 1 {
+			parts = append(parts, fmt.Sprintf("%s%0*d%s", p.prefix, p.width, nums[0], p.suffix))
+			continue
+		}
+		sort.Ints(nums)
+		var ranges []string
+		for i := 0; i < len(nums); {
+			j := i
+			for j+1 < len(nums) && nums[j+1] == nums[j]+1 {
+				j++
+			}
+			if i == j {
+				ranges = append(ranges, fmt.Sprintf("%0*d", p.width, nums[i]))
+			} else {
+				ranges = append(ranges, fmt.Sprintf("%0*d-%0*d", p.width, nums[i], p.width, nums[j]))
+			}
+			i = j + 1
+		}
+		parts = append(parts, p.prefix+"["+strings.Join(ranges, ",")+"]"+p.suffix)
+	}
+	return strings.Join(parts, ",")
+}
@@ -7,7 +7,8 @@ import (
 	"gopkg.in/natefinch/lumberjack.v2"
 )
 
-var logger *logrus.Logger
+// logger writes to stderr until InitLog is called, e.g. in tests
+var logger = logrus.New()
 
 func InitLog(logLevel string, logPath string) {
 	level, err := logrus.ParseLevel(logLevel)
@@ -0,0 +1,129 @@
+package secret
+
+import (
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// prefix marks an encrypted value, the values without it are plain text
+const prefix = "enc:v1:"
+
+// argon2id parameters of the key derivation
+const (
+	kdfTime    = 3
+	kdfMemory  = 64 * 1024
+	kdfThreads = 4
+	saltSize   = 16
+)
+
+var (
+	ErrLocked        = errors.New("credentials are locked, enter the master passphrase first")
+	ErrBadPassphrase = errors.New("incorrect master passphrase")
+)
+
+// Cipher encrypts credentials with XChaCha20-Poly1305,
+// the key is derived from the master passphrase with argon2id.
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+var (
+	mu      sync.RWMutex
+	current *Cipher
+)
+
+// NewSalt generates a random salt for the key derivation
+func NewSalt() ([]byte, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+// New derives the key from passphrase and salt
+func New(passphrase string, salt []byte) (*Cipher, error) {
+	key := argon2.IDKey([]byte(passphrase), salt, kdfTime, kdfMemory, kdfThreads, chacha20poly1305.KeySize)
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{aead: aead}, nil
+}
+
+// Encrypt seals plain, the empty string stays empty
+func (c *Cipher) Encrypt(plain string) (string, error) {
+	if plain == "" {
+		return "", nil
+	}
+	nonce := make([]byte, c.aead.NonceSize(), c.aead.NonceSize()+len(plain)+c.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := c.aead.Seal(nonce, nonce, []byte(plain), nil)
+	return prefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt opens an encrypted value, plain text is returned as it is
+func (c *Cipher) Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, prefix))
+	if err != nil {
+		return "", fmt.Errorf("decode credential failed, %v", err)
+	}
+	if len(sealed) < c.aead.NonceSize() {
+		return "", errors.New("credential is truncated")
+	}
+	nonce, ciphertext := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	plain, err := c.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", ErrBadPassphrase
+	}
+	return string(plain), nil
+}
+
+// IsEncrypted reports whether value was produced by Encrypt
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// SetCurrent sets the cipher used to read and write the credentials
+func SetCurrent(c *Cipher) {
+	mu.Lock()
+	defer mu.Unlock()
+	current = c
+}
+
+// Current returns the cipher of the unlocked session, nil if locked
+func Current() *Cipher {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+type ctxKey struct{}
+
+// WithCipher overrides the current cipher for the operations using ctx,
+// e.g. writing the credentials with a new passphrase.
+func WithCipher(ctx context.Context, c *Cipher) context.Context {
+	return context.WithValue(ctx, ctxKey{}, c)
+}
+
+// FromContext returns the cipher of ctx, or the current one
+func FromContext(ctx context.Context) *Cipher {
+	if c, ok := ctx.Value(ctxKey{}).(*Cipher); ok {
+		return c
+	}
+	return Current()
+}
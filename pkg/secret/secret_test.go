@@ -0,0 +1,126 @@
+package secret
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func newTestCipher(t *testing.T, passphrase string, salt []byte) *Cipher {
+	t.Helper()
+	c, err := New(passphrase, salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	salt, err := NewSalt()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := newTestCipher(t, "first", salt)
+	for _, plain := range []string{"", "rootpass", "pass with spaces and ünïcode"} {
+		encrypted, err := c.Encrypt(plain)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if plain != "" && (!IsEncrypted(encrypted) || strings.Contains(encrypted, plain)) {
+			t.Errorf("Encrypt(%q) = %q", plain, encrypted)
+		}
+		if got, err := c.Decrypt(encrypted); err != nil || got != plain {
+			t.Errorf("Decrypt(Encrypt(%q)) = %q, %v", plain, got, err)
+		}
+	}
+	// the random nonce gives a different value each time
+	a, _ := c.Encrypt("rootpass")
+	b, _ := c.Encrypt("rootpass")
+	if a == b {
+		t.Error("the same value was encrypted twice to the same text")
+	}
+	// the same passphrase and salt derive the same key
+	if got, err := newTestCipher(t, "first", salt).Decrypt(a); err != nil || got != "rootpass" {
+		t.Errorf("Decrypt with the derived key = %q, %v", got, err)
+	}
+}
+
+func TestDecryptWrongPassphrase(t *testing.T) {
+	salt, err := NewSalt()
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := newTestCipher(t, "first", salt).Encrypt("rootpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newTestCipher(t, "second", salt).Decrypt(encrypted); !errors.Is(err, ErrBadPassphrase) {
+		t.Errorf("Decrypt error = %v, want ErrBadPassphrase", err)
+	}
+	otherSalt, err := NewSalt()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newTestCipher(t, "first", otherSalt).Decrypt(encrypted); !errors.Is(err, ErrBadPassphrase) {
+		t.Errorf("Decrypt with another salt error = %v, want ErrBadPassphrase", err)
+	}
+}
+
+func TestDecryptCorrupted(t *testing.T) {
+	salt, err := NewSalt()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := newTestCipher(t, "first", salt)
+	encrypted, err := c.Encrypt("rootpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(encrypted, prefix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	flipped := append([]byte(nil), sealed...)
+	flipped[len(flipped)-1] ^= 0x01
+	cases := map[string]string{
+		"empty":        prefix,
+		"not base64":   prefix + "!!!",
+		"short nonce":  prefix + base64.StdEncoding.EncodeToString(sealed[:10]),
+		"nonce only":   prefix + base64.StdEncoding.EncodeToString(sealed[:24]),
+		"truncated":    encrypted[:len(encrypted)-8],
+		"flipped byte": prefix + base64.StdEncoding.EncodeToString(flipped),
+	}
+	for name, value := range cases {
+		if got, err := c.Decrypt(value); err == nil {
+			t.Errorf("%s: Decrypt(%q) = %q, want an error", name, value, got)
+		}
+	}
+}
+
+func TestIsEncrypted(t *testing.T) {
+	c := newTestCipher(t, "first", make([]byte, saltSize))
+	encrypted, err := c.Encrypt("rootpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		value string
+		want  bool
+	}{
+		{"", false},
+		{"rootpass", false},
+		{"enc:rootpass", false},
+		{"ENC:V1:abc", false},
+		{encrypted, true},
+	}
+	for _, tt := range cases {
+		if got := IsEncrypted(tt.value); got != tt.want {
+			t.Errorf("IsEncrypted(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+	// the plain text of older versions is returned as it is
+	if got, err := c.Decrypt("rootpass"); err != nil || got != "rootpass" {
+		t.Errorf("Decrypt(plain) = %q, %v", got, err)
+	}
+}
@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"context"
+	"io"
+	"io/fs"
+)
+
+// Executor runs the remote operations of the views,
+// tests replace it to avoid touching real nodes.
+type Executor interface {
+	// RemoteCmd executes cmd on the node and returns the combined output
+	RemoteCmd(ctx context.Context, conf *SSHConfig, cmd string) ([]byte, error)
+	// RemoteRun executes cmd on the node and keeps stdout and stderr apart
+	RemoteRun(ctx context.Context, conf *SSHConfig, cmd string) (*CmdResult, error)
+	// Reach probes the node in order until one probe succeeds
+	Reach(ctx context.Context, conf *SSHConfig, probes []string) (*Reachability, error)
+	// OpenShell starts an interactive shell on a pty of the node
+	OpenShell(ctx context.Context, conf *SSHConfig, cols, rows int) (*Shell, error)
+	// ListDir lists a remote directory over sftp
+	ListDir(ctx context.Context, conf *SSHConfig, dir string) ([]RemoteFile, error)
+	// Download copies a remote file to w and verifies its checksum
+	Download(ctx context.Context, conf *SSHConfig, remotePath string, w io.Writer) (*Transfer, error)
+	// Upload copies r to a remote file and verifies its checksum
+	Upload(ctx context.Context, conf *SSHConfig, r io.Reader, remotePath string, perm fs.FileMode) (*Transfer, error)
+}
+
+type sshExecutor struct{}
+
+// DefaultExecutor executes the commands over the pooled ssh connections
+var DefaultExecutor Executor = sshExecutor{}
+
+func (sshExecutor) RemoteCmd(ctx context.Context, conf *SSHConfig, cmd string) ([]byte, error) {
+	return RemoteCmd(ctx, conf, cmd)
+}
+
+func (sshExecutor) RemoteRun(ctx context.Context, conf *SSHConfig, cmd string) (*CmdResult, error) {
+	return RemoteRun(ctx, conf, cmd)
+}
+
+func (sshExecutor) Reach(ctx context.Context, conf *SSHConfig, probes []string) (*Reachability, error) {
+	return Reach(ctx, conf, probes)
+}
+
+func (sshExecutor) OpenShell(ctx context.Context, conf *SSHConfig, cols, rows int) (*Shell, error) {
+	return OpenShell(ctx, conf, cols, rows)
+}
+
+func (sshExecutor) ListDir(ctx context.Context, conf *SSHConfig, dir string) ([]RemoteFile, error) {
+	return ListDir(ctx, conf, dir)
+}
+
+func (sshExecutor) Download(ctx context.Context, conf *SSHConfig, remotePath string, w io.Writer) (*Transfer, error) {
+	return Download(ctx, conf, remotePath, w)
+}
+
+func (sshExecutor) Upload(ctx context.Context, conf *SSHConfig, r io.Reader, remotePath string, perm fs.FileMode) (*Transfer, error) {
+	return Upload(ctx, conf, r, remotePath, perm)
+}
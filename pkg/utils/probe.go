@@ -0,0 +1,115 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+)
+
+// reachability probes
+const (
+	ProbeICMP = "icmp" // privileged icmp echo, requires CAP_NET_RAW
+	ProbeUDP  = "udp"  // unprivileged icmp echo over a udp socket
+	ProbeTCP  = "tcp"  // connect to the ssh port
+	ProbeSSH  = "ssh"  // ssh handshake and authentication
+)
+
+var Probes = []string{ProbeICMP, ProbeUDP, ProbeTCP, ProbeSSH}
+
+// Reachability the result of probing a node
+type Reachability struct {
+	Reachable bool
+	Probe     string // the probe which succeeded
+	Latency   time.Duration
+}
+
+// Reach tries the probes in order until one succeeds. The node is
+// unreachable if some probe ran without a response, an error is returned
+// if none of the probes could run, e.g. icmp without privileges.
+func Reach(ctx context.Context, conf *SSHConfig, probes []string) (*Reachability, error) {
+	var errs []error
+	tried := false
+	for _, probe := range probes {
+		ok, latency, err := runProbe(ctx, conf, probe)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s probe failed, %v", probe, err))
+			continue
+		}
+		tried = true
+		if ok {
+			return &Reachability{Reachable: true, Probe: probe, Latency: latency}, nil
+		}
+	}
+	if !tried {
+		if len(errs) == 0 {
+			return nil, errors.New("no reachability probe is configured")
+		}
+		return nil, errors.Join(errs...)
+	}
+	return &Reachability{}, nil
+}
+
+func runProbe(ctx context.Context, conf *SSHConfig, probe string) (bool, time.Duration, error) {
+	switch probe {
+	case ProbeICMP, ProbeUDP:
+		if len(conf.Jumps) > 0 {
+			return false, 0, errors.New("echo requests do not pass jump hosts")
+		}
+		host, _, err := net.SplitHostPort(conf.Address())
+		if err != nil {
+			return false, 0, err
+		}
+		return Ping(ctx, host, probe == ProbeICMP)
+	case ProbeTCP:
+		return probeTCP(ctx, conf)
+	case ProbeSSH:
+		return probeSSH(ctx, conf)
+	default:
+		return false, 0, fmt.Errorf("unsupported probe '%s'", probe)
+	}
+}
+
+// probeTCP connects the ssh port, through the jump hosts if any,
+// a refused connection still proves the node is up.
+func probeTCP(ctx context.Context, conf *SSHConfig) (bool, time.Duration, error) {
+	jumps, err := dialJumps(ctx, conf.Jumps)
+	if err != nil {
+		return false, 0, err
+	}
+	defer closeClients(jumps)
+	dialCtx, cancel := context.WithTimeout(ctx, conf.Options.connectTimeout())
+	defer cancel()
+	start := time.Now()
+	conn, err := dialerVia(lastHop(jumps)).DialContext(dialCtx, "tcp", conf.Address())
+	latency := time.Since(start)
+	if err != nil {
+		if errors.Is(err, syscall.ECONNREFUSED) {
+			return true, latency, nil
+		}
+		return false, 0, nil
+	}
+	conn.Close()
+	return true, latency, nil
+}
+
+// probeSSH authenticates with the node, the latency includes the handshake
+func probeSSH(ctx context.Context, conf *SSHConfig) (bool, time.Duration, error) {
+	start := time.Now()
+	client, err := dialChain(ctx, conf)
+	if err != nil {
+		var opErr *net.OpError
+		if errors.As(err, &opErr) && opErr.Op == "dial" {
+			return false, 0, nil
+		}
+		return false, 0, err
+	}
+	latency := time.Since(start)
+	client.Close()
+	return true, latency, nil
+}
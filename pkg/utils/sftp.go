@@ -0,0 +1,236 @@
+package utils
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// partSuffix is appended to the uploaded file until its checksum is verified
+const partSuffix = ".ltool-part"
+
+// RemoteFile an entry of a remote directory
+type RemoteFile struct {
+	Name    string
+	Path    string
+	Size    int64
+	Mode    fs.FileMode
+	ModTime time.Time
+	IsDir   bool // also true for the symbolic links to directories
+}
+
+// Transfer the result of a verified download or upload
+type Transfer struct {
+	Size int64
+	Sum  string      // sha256 of the content
+	Mode fs.FileMode // permission bits of the remote file
+}
+
+// ChecksumError the content on the node differs from the transferred one
+type ChecksumError struct {
+	Path   string
+	Local  string
+	Remote string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("checksum of %s mismatch, local %s, remote %s", e.Path, e.Local, e.Remote)
+}
+
+// withSFTP runs fn with an sftp client on a pooled session of the node,
+// the transfer is aborted when ctx is done. The files are accessed as root
+// if the node escalates with sudo or su.
+func withSFTP(ctx context.Context, conf *SSHConfig, fn func(client *sftp.Client) error) error {
+	host := conf.Address()
+	dial := func(ctx context.Context) (*ssh.Client, error) {
+		return dialChain(ctx, conf)
+	}
+	var fnErr error
+	err := defaultSSHPool.run(ctx, conf.User+"@"+host, conf.poolKey(), dial, func(session *ssh.Session) error {
+		stop := context.AfterFunc(ctx, func() {
+			session.Close()
+		})
+		defer stop()
+		var client *sftp.Client
+		if conf.Become.enabled() {
+			client, fnErr = startBecomeSFTP(session, &conf.Become)
+		} else {
+			client, fnErr = startSFTP(session)
+		}
+		if fnErr != nil {
+			if ctx.Err() != nil {
+				fnErr = ctx.Err()
+			}
+			return fnErr
+		}
+		defer client.Close()
+		fnErr = fn(client)
+		if fnErr != nil && ctx.Err() != nil {
+			fnErr = ctx.Err()
+		}
+		return fnErr
+	})
+	if fnErr != nil {
+		return fnErr
+	}
+	return err
+}
+
+// startSFTP starts the sftp subsystem of the login user in the session
+func startSFTP(session *ssh.Session) (*sftp.Client, error) {
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := session.RequestSubsystem("sftp"); err != nil {
+		return nil, fmt.Errorf("start sftp subsystem failed, %v", err)
+	}
+	client, err := sftp.NewClientPipe(stdout, stdin)
+	if err != nil {
+		return nil, fmt.Errorf("start sftp client failed, %v", err)
+	}
+	return client, nil
+}
+
+// ListDir lists dir on the node, the directories first
+func ListDir(ctx context.Context, conf *SSHConfig, dir string) ([]RemoteFile, error) {
+	var files []RemoteFile
+	err := withSFTP(ctx, conf, func(client *sftp.Client) error {
+		infos, err := client.ReadDir(dir)
+		if err != nil {
+			return fmt.Errorf("list %s failed, %v", dir, err)
+		}
+		files = make([]RemoteFile, 0, len(infos))
+		for _, info := range infos {
+			file := RemoteFile{
+				Name:    info.Name(),
+				Path:    path.Join(dir, info.Name()),
+				Size:    info.Size(),
+				Mode:    info.Mode(),
+				ModTime: info.ModTime(),
+				IsDir:   info.IsDir(),
+			}
+			if info.Mode()&fs.ModeSymlink != 0 {
+				if target, err := client.Stat(file.Path); err == nil {
+					file.IsDir = target.IsDir()
+				}
+			}
+			files = append(files, file)
+		}
+		return nil
+	})
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].IsDir != files[j].IsDir {
+			return files[i].IsDir
+		}
+		return files[i].Name < files[j].Name
+	})
+	return files, err
+}
+
+// Download copies the remote file to w and verifies the checksum
+func Download(ctx context.Context, conf *SSHConfig, remotePath string, w io.Writer) (*Transfer, error) {
+	transfer := &Transfer{}
+	hash := sha256.New()
+	err := withSFTP(ctx, conf, func(client *sftp.Client) error {
+		f, err := client.Open(remotePath)
+		if err != nil {
+			return fmt.Errorf("open %s failed, %v", remotePath, err)
+		}
+		defer f.Close()
+		if info, err := f.Stat(); err == nil {
+			transfer.Mode = info.Mode().Perm()
+		}
+		transfer.Size, err = f.WriteTo(io.MultiWriter(w, hash))
+		if err != nil {
+			return fmt.Errorf("download %s failed, %v", remotePath, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	transfer.Sum = hex.EncodeToString(hash.Sum(nil))
+	if err := verifyChecksum(ctx, conf, remotePath, transfer.Sum); err != nil {
+		return nil, err
+	}
+	return transfer, nil
+}
+
+// Upload writes r to remotePath through a temporary file which replaces
+// remotePath after its checksum was verified. The mode of an existing
+// remotePath is kept, perm is used for a new file.
+func Upload(ctx context.Context, conf *SSHConfig, r io.Reader, remotePath string, perm fs.FileMode) (*Transfer, error) {
+	part := remotePath + partSuffix
+	transfer := &Transfer{}
+	err := withSFTP(ctx, conf, func(client *sftp.Client) error {
+		if info, err := client.Stat(remotePath); err == nil {
+			if info.IsDir() {
+				return fmt.Errorf("%s is a directory", remotePath)
+			}
+			perm = info.Mode().Perm()
+		}
+		f, err := client.OpenFile(part, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+		if err != nil {
+			return fmt.Errorf("create %s failed, %v", part, err)
+		}
+		hash := sha256.New()
+		transfer.Size, err = f.ReadFrom(io.TeeReader(r, hash))
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = client.Chmod(part, perm)
+		}
+		if err == nil {
+			transfer.Sum = hex.EncodeToString(hash.Sum(nil))
+			err = verifyChecksum(ctx, conf, part, transfer.Sum)
+		}
+		if err == nil {
+			err = client.PosixRename(part, remotePath)
+		}
+		transfer.Mode = perm
+		if err != nil {
+			client.Remove(part)
+			return fmt.Errorf("upload %s failed, %w", remotePath, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return transfer, nil
+}
+
+// verifyChecksum compares sum with the sha256sum of the file on the node,
+// run as root like the sftp-server of withSFTP
+func verifyChecksum(ctx context.Context, conf *SSHConfig, remotePath, sum string) error {
+	output, err := remoteCmd(ctx, conf, "sha256sum -- "+shellQuote(remotePath), conf.Become.enabled())
+	if err != nil {
+		return fmt.Errorf("checksum %s failed, %w", remotePath, err)
+	}
+	fields := strings.Fields(string(output))
+	if len(fields) == 0 || fields[0] != sum {
+		remoteSum := ""
+		if len(fields) > 0 {
+			remoteSum = fields[0]
+		}
+		return &ChecksumError{Path: remotePath, Local: sum, Remote: remoteSum}
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// supported authentication methods
+const (
+	AuthPassword = "password"
+	AuthKey      = "key"
+	AuthAgent    = "agent"
+	AuthCert     = "cert"
+)
+
+var AuthTypes = []string{AuthPassword, AuthKey, AuthAgent, AuthCert}
+
+// SSHAuth credentials of a node
+type SSHAuth struct {
+	AuthType   string // empty means password
+	Password   string
+	KeyFile    string // private key file, used by key and cert
+	Passphrase string // passphrase of the private key
+	CertFile   string // OpenSSH user certificate, default <KeyFile>-cert.pub
+}
+
+// SSHConfig connection parameters of a node
+type SSHConfig struct {
+	Host string
+	User string
+	SSHAuth
+	Become  Become
+	Options SSHOptions
+	Jumps   []*SSHConfig // ProxyJump chain, the first hop is dialed directly
+}
+
+// ValidateAuth checks the required fields of the authentication method
+func ValidateAuth(auth *SSHAuth) error {
+	switch auth.AuthType {
+	case "", AuthPassword:
+		if auth.Password == "" {
+			return errors.New("password is required")
+		}
+	case AuthKey, AuthCert:
+		if auth.KeyFile == "" {
+			return errors.New("private key file is required")
+		}
+	case AuthAgent:
+	default:
+		return fmt.Errorf("unsupported authentication method '%s'", auth.AuthType)
+	}
+	return nil
+}
+
+// authMethods builds the ssh auth methods, the returned close function
+// releases the ssh-agent connection and must be called after handshake.
+func (a *SSHAuth) authMethods() ([]ssh.AuthMethod, func(), error) {
+	noop := func() {}
+	switch a.AuthType {
+	case "", AuthPassword:
+		return []ssh.AuthMethod{ssh.Password(a.Password)}, noop, nil
+	case AuthKey:
+		signer, err := a.loadSigner()
+		if err != nil {
+			return nil, noop, err
+		}
+		return []ssh.AuthMethod{ssh.PublicKeys(signer)}, noop, nil
+	case AuthCert:
+		signer, err := a.loadSigner()
+		if err != nil {
+			return nil, noop, err
+		}
+		certSigner, err := a.loadCertSigner(signer)
+		if err != nil {
+			return nil, noop, err
+		}
+		return []ssh.AuthMethod{ssh.PublicKeys(certSigner)}, noop, nil
+	case AuthAgent:
+		sock := os.Getenv("SSH_AUTH_SOCK")
+		if sock == "" {
+			return nil, noop, errors.New("ssh-agent is unavailable, SSH_AUTH_SOCK is not set")
+		}
+		conn, err := net.Dial("unix", sock)
+		if err != nil {
+			return nil, noop, fmt.Errorf("connect ssh-agent failed, %v", err)
+		}
+		client := agent.NewClient(conn)
+		return []ssh.AuthMethod{ssh.PublicKeysCallback(client.Signers)}, func() { conn.Close() }, nil
+	default:
+		return nil, noop, fmt.Errorf("unsupported authentication method '%s'", a.AuthType)
+	}
+}
+
+func (a *SSHAuth) loadSigner() (ssh.Signer, error) {
+	data, err := os.ReadFile(expandHome(a.KeyFile))
+	if err != nil {
+		return nil, fmt.Errorf("read private key failed, %v", err)
+	}
+	var signer ssh.Signer
+	if a.Passphrase == "" {
+		signer, err = ssh.ParsePrivateKey(data)
+	} else {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(data, []byte(a.Passphrase))
+	}
+	if err != nil {
+		var missingErr *ssh.PassphraseMissingError
+		if errors.As(err, &missingErr) {
+			return nil, fmt.Errorf("private key '%s' is encrypted, passphrase is required", a.KeyFile)
+		}
+		return nil, fmt.Errorf("parse private key failed, %v", err)
+	}
+	return signer, nil
+}
+
+func (a *SSHAuth) loadCertSigner(signer ssh.Signer) (ssh.Signer, error) {
+	certFile := a.CertFile
+	if certFile == "" {
+		certFile = a.KeyFile + "-cert.pub"
+	}
+	data, err := os.ReadFile(expandHome(certFile))
+	if err != nil {
+		return nil, fmt.Errorf("read certificate failed, %v", err)
+	}
+	pub, _, _, _, err := ssh.ParseAuthorizedKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse certificate failed, %v", err)
+	}
+	cert, ok := pub.(*ssh.Certificate)
+	if !ok {
+		return nil, fmt.Errorf("'%s' is not an ssh certificate", certFile)
+	}
+	certSigner, err := ssh.NewCertSigner(cert, signer)
+	if err != nil {
+		return nil, fmt.Errorf("certificate does not match private key, %v", err)
+	}
+	return certSigner, nil
+}
+
+// poolKey identifies the credentials of a pooled client,
+// the client is reconnected when the credentials were changed.
+func (a *SSHAuth) poolKey() string {
+	return strings.Join([]string{a.AuthType, a.Password, a.KeyFile, a.Passphrase, a.CertFile}, "\x00")
+}
+
+func expandHome(p string) string {
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, p[1:])
+		}
+	}
+	return p
+}
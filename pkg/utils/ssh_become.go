@@ -0,0 +1,358 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// privilege escalation methods
+const (
+	BecomeNone = "none"
+	BecomeSudo = "sudo"
+	BecomeSu   = "su"
+)
+
+var BecomeMethods = []string{BecomeNone, BecomeSudo, BecomeSu}
+
+// sudoPrompt is unlikely to appear in the output of a command,
+// so it is safe to answer it with the password.
+const sudoPrompt = "[ltool-sudo-password]"
+
+// privilegedCmds the commands which require root, matched by prefix
+var privilegedCmds = []string{
+	"nmcli con add",
+	"nmcli con mod",
+	"nmcli con delete",
+	"nmcli connection up",
+	"nmcli connection down",
+	"lnetctl",
+}
+
+// Become escalates the privileged commands of a non-root user
+type Become struct {
+	Method string // none, sudo or su, empty means none
+	// Password answers the sudo prompt with the password of the user,
+	// or the su prompt with the password of root. Empty sudo password
+	// means NOPASSWD in sudoers.
+	Password string
+}
+
+// BecomeRefusedError sudo or su refused to run the command
+type BecomeRefusedError struct {
+	Method string
+	Reason string
+}
+
+func (e *BecomeRefusedError) Error() string {
+	return fmt.Sprintf("%s refused to run the command, %s", e.Method, e.Reason)
+}
+
+// ValidateBecome checks the required fields of the escalation method
+func ValidateBecome(b *Become) error {
+	switch b.Method {
+	case "", BecomeNone, BecomeSudo:
+	case BecomeSu:
+		if b.Password == "" {
+			return errors.New("root password is required by su")
+		}
+	default:
+		return fmt.Errorf("unsupported become method '%s'", b.Method)
+	}
+	return nil
+}
+
+// IsPrivileged reports whether cmd requires root
+func IsPrivileged(cmd string) bool {
+	cmd = strings.TrimSpace(cmd)
+	for _, prefix := range privilegedCmds {
+		if cmd == prefix || strings.HasPrefix(cmd, prefix+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// enabled reports whether an escalation method is set
+func (b *Become) enabled() bool {
+	return b.Method != "" && b.Method != BecomeNone
+}
+
+// escalates reports whether cmd must run through sudo or su
+func (b *Become) escalates(cmd string) bool {
+	return b.enabled() && IsPrivileged(cmd)
+}
+
+// command wraps cmd with sudo or su, the messages are not localized
+// so that the refusal can be recognized
+func (b *Become) command(cmd string) string {
+	quoted := shellQuote(cmd)
+	switch b.Method {
+	case BecomeSu:
+		return "LC_ALL=C su - root -c " + quoted
+	default:
+		if b.Password == "" {
+			return "LC_ALL=C sudo -n sh -c " + quoted
+		}
+		return "LC_ALL=C sudo -p " + shellQuote(sudoPrompt) + " sh -c " + quoted
+	}
+}
+
+// isPrompt reports whether the output ends with the password prompt
+func (b *Become) isPrompt(output []byte) bool {
+	tail := strings.TrimRight(string(output), " ")
+	if b.Method == BecomeSu {
+		return strings.HasSuffix(tail, "Password:")
+	}
+	return strings.HasSuffix(tail, sudoPrompt)
+}
+
+// refusals the messages of sudo and su when the command is not allowed
+var refusals = []string{
+	"is not in the sudoers file",
+	"is not allowed to execute",
+	"a password is required",
+	"incorrect password attempt",
+	"a terminal is required",
+	"Authentication failure",
+}
+
+// runBecome runs cmd through sudo or su on a pty with echo disabled,
+// and answers the password prompt once. A repeated prompt means the
+// password is wrong and the command is aborted.
+func runBecome(ctx context.Context, session *ssh.Session, b *Become, cmd string) ([]byte, error) {
+	modes := ssh.TerminalModes{
+		ssh.ECHO:          0,
+		ssh.TTY_OP_ISPEED: 14400,
+		ssh.TTY_OP_OSPEED: 14400,
+	}
+	if err := session.RequestPty("xterm", 40, 200, modes); err != nil {
+		return nil, fmt.Errorf("request pty failed, %v", err)
+	}
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	w := &promptWriter{become: b, stdin: stdin, abort: make(chan struct{})}
+	session.Stdout = w
+	session.Stderr = w
+	err = waitSession(ctx, session, w.abort, func() error {
+		return session.Run(b.command(cmd))
+	})
+	if err != nil {
+		if reason := w.refusedReason(); reason != "" {
+			return nil, &BecomeRefusedError{Method: b.Method, Reason: reason}
+		}
+		var exitErr *ssh.ExitError
+		if errors.As(err, &exitErr) {
+			return w.output(), err
+		}
+		return nil, err
+	}
+	return w.output(), nil
+}
+
+// promptWriter collects the pty output and answers the password prompt
+type promptWriter struct {
+	mu       sync.Mutex
+	become   *Become
+	stdin    io.Writer
+	buf      bytes.Buffer
+	answered bool
+	mark     int // where the output continues after the answered prompt
+	refused  string
+	abort    chan struct{}
+}
+
+func (w *promptWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf.Write(p)
+	if w.refused != "" || !w.become.isPrompt(w.buf.Bytes()) {
+		return len(p), nil
+	}
+	if w.answered || w.become.Password == "" {
+		w.refused = "incorrect password"
+		close(w.abort)
+		return len(p), nil
+	}
+	// drop the prompt from the output
+	data := w.buf.Bytes()
+	if idx := bytes.LastIndex(data, []byte(w.promptText())); idx >= 0 {
+		w.buf.Truncate(idx)
+	}
+	w.answered = true
+	w.mark = w.buf.Len()
+	if _, err := io.WriteString(w.stdin, w.become.Password+"\n"); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (w *promptWriter) promptText() string {
+	if w.become.Method == BecomeSu {
+		return "Password:"
+	}
+	return sudoPrompt
+}
+
+// output returns the collected output with unix line endings
+func (w *promptWriter) output() []byte {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	data := append([]byte(nil), w.buf.Bytes()...)
+	if w.answered {
+		// the newline printed after the password was entered
+		rest := data[w.mark:]
+		rest = bytes.TrimPrefix(rest, []byte("\r"))
+		rest = bytes.TrimPrefix(rest, []byte("\n"))
+		data = append(data[:w.mark], rest...)
+	}
+	return bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+}
+
+// refusedReason returns why sudo or su refused the failed command,
+// empty if the command itself failed. Only the messages of sudo and su are
+// matched, i.e. the lines before the answered prompt and the first line
+// after it, which is written before the command starts. Without a prompt
+// only the first line is matched if it is prefixed with sudo: or su:. The
+// output of the command is not matched, e.g. grep on the auth log.
+func (w *promptWriter) refusedReason() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.refused != "" {
+		return w.refused
+	}
+	data := w.buf.String()
+	var lines []string
+	if w.answered {
+		lines = strings.Split(data[:w.mark], "\n")
+		data = data[w.mark:]
+	}
+	first, _, _ := strings.Cut(strings.TrimLeft(data, "\r\n"), "\n")
+	if trimmed := strings.TrimSpace(first); w.answered || strings.HasPrefix(trimmed, "sudo:") || strings.HasPrefix(trimmed, "su:") {
+		lines = append(lines, first)
+	}
+	for _, line := range lines {
+		for _, refusal := range refusals {
+			if strings.Contains(line, refusal) {
+				return strings.TrimSpace(line)
+			}
+		}
+	}
+	return ""
+}
+
+// sftpReady is printed by the escalated shell before it starts sftp-server
+const sftpReady = "ltool-sftp-ready"
+
+// sftpServers the paths of sftp-server on the common distributions
+var sftpServers = []string{
+	"/usr/libexec/openssh/sftp-server",
+	"/usr/lib/openssh/sftp-server",
+	"/usr/lib/ssh/sftp-server",
+	"/usr/libexec/sftp-server",
+}
+
+// sftpCommand starts the first sftp-server found as root
+func (b *Become) sftpCommand() string {
+	return b.command("for p in " + strings.Join(sftpServers, " ") + `; do if [ -x "$p" ]; then echo ` + sftpReady +
+		`; exec "$p"; fi; done; echo "sftp-server is not found" >&2; exit 127`)
+}
+
+// startBecomeSFTP starts sftp-server through sudo or su in the session and
+// answers the password prompt like runBecome. The pty is raw so that it
+// passes the sftp packets unchanged, it is not requested by sudo -n.
+func startBecomeSFTP(session *ssh.Session, b *Become) (*sftp.Client, error) {
+	if b.Method == BecomeSu || b.Password != "" {
+		modes := ssh.TerminalModes{
+			ssh.ECHO:          0,
+			ssh.ICANON:        0,
+			ssh.ISIG:          0,
+			ssh.IEXTEN:        0,
+			ssh.OPOST:         0,
+			ssh.ICRNL:         0,
+			ssh.INLCR:         0,
+			ssh.IGNCR:         0,
+			ssh.IXON:          0,
+			ssh.IXOFF:         0,
+			ssh.ISTRIP:        0,
+			ssh.CS8:           1,
+			ssh.TTY_OP_ISPEED: 14400,
+			ssh.TTY_OP_OSPEED: 14400,
+		}
+		if err := session.RequestPty("xterm", 40, 200, modes); err != nil {
+			return nil, fmt.Errorf("request pty failed, %v", err)
+		}
+	}
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	w := &promptWriter{become: b, stdin: stdin, abort: make(chan struct{})}
+	session.Stderr = w
+	if err := session.Start(b.sftpCommand()); err != nil {
+		return nil, fmt.Errorf("start sftp-server failed, %v", err)
+	}
+	r := bufio.NewReader(stdout)
+	for !w.ready() {
+		c, err := r.ReadByte()
+		if err == nil {
+			w.Write([]byte{c})
+		}
+		select {
+		case <-w.abort:
+			session.Close()
+			err = io.EOF
+		default:
+		}
+		if err != nil {
+			session.Wait()
+			if reason := w.refusedReason(); reason != "" {
+				return nil, &BecomeRefusedError{Method: b.Method, Reason: reason}
+			}
+			return nil, fmt.Errorf("start sftp-server failed, %s", w.lastLine())
+		}
+	}
+	client, err := sftp.NewClientPipe(r, stdin)
+	if err != nil {
+		return nil, fmt.Errorf("start sftp client failed, %v", err)
+	}
+	return client, nil
+}
+
+// ready reports whether the escalated shell printed sftpReady
+func (w *promptWriter) ready() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	data := w.buf.String()
+	return strings.HasSuffix(data, "\n") && strings.HasSuffix(strings.TrimRight(data, "\r\n"), sftpReady)
+}
+
+// lastLine returns the last non-empty line of the output
+func (w *promptWriter) lastLine() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	data := strings.TrimSpace(w.buf.String())
+	if idx := strings.LastIndex(data, "\n"); idx >= 0 {
+		data = data[idx+1:]
+	}
+	return strings.TrimSpace(data)
+}
+
+// shellQuote quotes s for the posix shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
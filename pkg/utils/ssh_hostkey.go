@@ -0,0 +1,281 @@
+package utils
+
+import (
+	"bufio"
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	logger "github.com/luo2pei4/ltool/pkg/log"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// HostKeyMismatchError the host key offered by the node differs from the trusted one
+type HostKeyMismatchError struct {
+	Host        string
+	Fingerprint string   // fingerprint offered by the node
+	Known       []string // trusted fingerprints
+}
+
+func (e *HostKeyMismatchError) Error() string {
+	return fmt.Sprintf("host key of %s has changed, offered %s, trusted %s, "+
+		"revoke the old host key on the Node page if the node was reinstalled",
+		e.Host, e.Fingerprint, strings.Join(e.Known, ", "))
+}
+
+// KnownHostKey a trusted host key
+type KnownHostKey struct {
+	Type        string
+	Fingerprint string
+	File        string
+	Line        int
+}
+
+// hostKeyStore verifies host keys against the ltool managed known_hosts file,
+// the key of an unknown host is trusted and saved on first use.
+type hostKeyStore struct {
+	sync.Mutex
+	file      string // ltool managed known_hosts
+	userFiles []string
+}
+
+var hostKeys = &hostKeyStore{}
+
+// SetKnownHosts sets the ltool managed known_hosts file,
+// useUserKnownHosts also trusts the keys in ~/.ssh/known_hosts.
+func SetKnownHosts(file string, useUserKnownHosts bool) {
+	hostKeys.Lock()
+	defer hostKeys.Unlock()
+	hostKeys.file = file
+	hostKeys.userFiles = nil
+	if useUserKnownHosts {
+		if home, err := os.UserHomeDir(); err == nil {
+			hostKeys.userFiles = append(hostKeys.userFiles, filepath.Join(home, ".ssh", "known_hosts"))
+		}
+	}
+}
+
+// load parses the known_hosts files, the caller must hold the lock
+func (s *hostKeyStore) load() (ssh.HostKeyCallback, error) {
+	if s.file == "" {
+		return nil, errors.New("known_hosts file is not configured")
+	}
+	if err := os.MkdirAll(filepath.Dir(s.file), 0700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(s.file, os.O_CREATE|os.O_RDONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+	files := []string{s.file}
+	for _, file := range s.userFiles {
+		if _, err := os.Stat(file); err == nil {
+			files = append(files, file)
+		}
+	}
+	return knownhosts.New(files...)
+}
+
+func (s *hostKeyStore) callback(hostname string, remote net.Addr, key ssh.PublicKey) error {
+	s.Lock()
+	defer s.Unlock()
+	cb, err := s.load()
+	if err != nil {
+		return err
+	}
+	err = cb(hostname, remote, key)
+	var keyErr *knownhosts.KeyError
+	if errors.As(err, &keyErr) {
+		if len(keyErr.Want) > 0 {
+			known := make([]string, 0, len(keyErr.Want))
+			for _, want := range keyErr.Want {
+				known = append(known, ssh.FingerprintSHA256(want.Key))
+			}
+			return &HostKeyMismatchError{
+				Host:        knownhosts.Normalize(hostname),
+				Fingerprint: ssh.FingerprintSHA256(key),
+				Known:       known,
+			}
+		}
+		// trust on first use
+		logger.Infof("trust host key of %s, %s %s", hostname, key.Type(), ssh.FingerprintSHA256(key))
+		return s.append(hostname, key)
+	}
+	return err
+}
+
+// append writes the key of the host into the ltool managed file,
+// the caller must hold the lock
+func (s *hostKeyStore) append(hostname string, key ssh.PublicKey) error {
+	f, err := os.OpenFile(s.file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("save host key failed, %v", err)
+	}
+	defer f.Close()
+	_, err = fmt.Fprintln(f, knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key))
+	return err
+}
+
+// remove deletes the keys of the host from the ltool managed file,
+// the caller must hold the lock
+func (s *hostKeyStore) remove(hostname string) error {
+	data, err := os.ReadFile(s.file)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	address := knownhosts.Normalize(hostname)
+	var kept []string
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for scanner.Scan() {
+		line := scanner.Text()
+		fields := strings.Fields(line)
+		if len(fields) > 0 && !strings.HasPrefix(fields[0], "#") {
+			matched := false
+			for _, h := range strings.Split(fields[0], ",") {
+				if h == address {
+					matched = true
+					break
+				}
+			}
+			if matched {
+				continue
+			}
+		}
+		kept = append(kept, line)
+	}
+	content := strings.Join(kept, "\n")
+	if len(kept) > 0 {
+		content += "\n"
+	}
+	tmp := s.file + ".tmp"
+	if err := os.WriteFile(tmp, []byte(content), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.file)
+}
+
+// KnownHostKeys lists the trusted keys of the host
+func KnownHostKeys(host string) ([]KnownHostKey, error) {
+	hostKeys.Lock()
+	defer hostKeys.Unlock()
+	cb, err := hostKeys.load()
+	if err != nil {
+		return nil, err
+	}
+	// a key nobody trusts makes the callback report all known keys
+	_, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		return nil, err
+	}
+	probe, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		return nil, err
+	}
+	hostname := hostWithPort(host)
+	err = cb(hostname, hostAddr(hostname), probe.PublicKey())
+	var keyErr *knownhosts.KeyError
+	if !errors.As(err, &keyErr) {
+		return nil, err
+	}
+	keys := make([]KnownHostKey, 0, len(keyErr.Want))
+	for _, want := range keyErr.Want {
+		keys = append(keys, KnownHostKey{
+			Type:        want.Key.Type(),
+			Fingerprint: ssh.FingerprintSHA256(want.Key),
+			File:        want.Filename,
+			Line:        want.Line,
+		})
+	}
+	return keys, nil
+}
+
+// FetchHostKey connects the host through its jump hosts with its ssh
+// options and returns the offered host key without authenticating.
+func FetchHostKey(conf *SSHConfig) (ssh.PublicKey, error) {
+	host := conf.Address()
+	var offered ssh.PublicKey
+	errFetched := errors.New("host key fetched")
+	config := conf.optionsConfig()
+	config.HostKeyCallback = func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		offered = key
+		return errFetched
+	}
+	ctx := context.Background()
+	clients, err := dialJumps(ctx, conf.Jumps)
+	if err != nil {
+		return nil, fmt.Errorf("fetch host key of %s failed, %v", host, err)
+	}
+	defer closeClients(clients)
+	conn, err := dialSSH(ctx, dialerVia(lastHop(clients)), host, config)
+	if err == nil {
+		conn.Close()
+	}
+	if offered == nil {
+		return nil, fmt.Errorf("fetch host key of %s failed, %v", host, err)
+	}
+	return offered, nil
+}
+
+// AcceptHostKey replaces the trusted keys of the host with key
+func AcceptHostKey(host string, key ssh.PublicKey) error {
+	hostname := hostWithPort(host)
+	if err := hostKeys.replace(hostname, key); err != nil {
+		return err
+	}
+	// the pooled clients were verified with the old key
+	defaultSSHPool.evictHost(hostname)
+	return nil
+}
+
+// RevokeHostKey removes the trusted keys of the host from the ltool managed
+// file, the next connection trusts the offered key again.
+func RevokeHostKey(host string) error {
+	hostname := hostWithPort(host)
+	if err := hostKeys.replace(hostname, nil); err != nil {
+		return err
+	}
+	defaultSSHPool.evictHost(hostname)
+	return nil
+}
+
+// replace removes the keys of the host and saves key if it is not nil
+func (s *hostKeyStore) replace(hostname string, key ssh.PublicKey) error {
+	s.Lock()
+	defer s.Unlock()
+	if s.file == "" {
+		return errors.New("known_hosts file is not configured")
+	}
+	if err := s.remove(hostname); err != nil {
+		return err
+	}
+	if key == nil {
+		return nil
+	}
+	return s.append(hostname, key)
+}
+
+// hostWithPort appends the ssh port to host if it has none, an ipv6
+// address is put in brackets.
+func hostWithPort(host string) string {
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	return net.JoinHostPort(host, "22")
+}
+
+type hostAddr string
+
+func (a hostAddr) Network() string { return "tcp" }
+func (a hostAddr) String() string  { return string(a) }
@@ -0,0 +1,120 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// clientConfig builds the ssh client config of the hop, the returned close
+// function must be called after handshake.
+func (c *SSHConfig) clientConfig() (*ssh.ClientConfig, func(), error) {
+	auth, closeAuth, err := c.authMethods()
+	if err != nil {
+		return nil, nil, err
+	}
+	config := c.optionsConfig()
+	config.Auth = auth
+	config.HostKeyCallback = hostKeys.callback
+	return config, closeAuth, nil
+}
+
+// optionsConfig applies the ssh options of the host, without authentication
+func (c *SSHConfig) optionsConfig() *ssh.ClientConfig {
+	return &ssh.ClientConfig{
+		Config: ssh.Config{
+			Ciphers:      c.Options.Ciphers,
+			KeyExchanges: c.Options.KeyExchanges,
+		},
+		User:    c.User,
+		Timeout: c.Options.connectTimeout(),
+	}
+}
+
+// dialChain connects the host through its jump hosts one by one,
+// the jump clients are closed after the returned client was closed.
+func dialChain(ctx context.Context, conf *SSHConfig) (*ssh.Client, error) {
+	jumps, err := dialJumps(ctx, conf.Jumps)
+	if err != nil {
+		return nil, err
+	}
+	client, err := dialHop(ctx, lastHop(jumps), conf)
+	if err != nil {
+		closeClients(jumps)
+		return nil, err
+	}
+	if len(jumps) > 0 {
+		go func() {
+			client.Wait()
+			closeClients(jumps)
+		}()
+	}
+	return client, nil
+}
+
+// dialJumps connects the jump hosts in order, each one through the previous
+func dialJumps(ctx context.Context, hops []*SSHConfig) ([]*ssh.Client, error) {
+	clients := make([]*ssh.Client, 0, len(hops))
+	for _, hop := range hops {
+		client, err := dialHop(ctx, lastHop(clients), hop)
+		if err != nil {
+			closeClients(clients)
+			return nil, fmt.Errorf("connect jump host failed, %w", err)
+		}
+		clients = append(clients, client)
+	}
+	return clients, nil
+}
+
+func dialHop(ctx context.Context, via *ssh.Client, hop *SSHConfig) (*ssh.Client, error) {
+	host := hop.Address()
+	config, closeAuth, err := hop.clientConfig()
+	if err != nil {
+		return nil, err
+	}
+	defer closeAuth()
+	client, err := dialSSH(ctx, dialerVia(via), host, config)
+	if err != nil {
+		if via != nil {
+			return nil, fmt.Errorf("dail %s via %s failed, %w", host, via.RemoteAddr(), err)
+		}
+		return nil, fmt.Errorf("dail %s failed, %w", host, err)
+	}
+	keepAlive(client, hop.Options.KeepaliveInterval)
+	return client, nil
+}
+
+// dialerVia tunnels through the jump client, or dials directly if it is nil
+func dialerVia(via *ssh.Client) contextDialer {
+	if via == nil {
+		return &net.Dialer{}
+	}
+	return via
+}
+
+func lastHop(clients []*ssh.Client) *ssh.Client {
+	if len(clients) == 0 {
+		return nil
+	}
+	return clients[len(clients)-1]
+}
+
+// closeClients closes the jump clients from the nearest to the node
+func closeClients(clients []*ssh.Client) {
+	for i := len(clients) - 1; i >= 0; i-- {
+		clients[i].Close()
+	}
+}
+
+// poolKey identifies the credentials, the options and the jump chain of a
+// pooled client
+func (c *SSHConfig) poolKey() string {
+	keys := []string{c.SSHAuth.poolKey(), c.Options.poolKey()}
+	for _, hop := range c.Jumps {
+		keys = append(keys, hop.User+"@"+hop.Address(), hop.poolKey())
+	}
+	return strings.Join(keys, "\x01")
+}
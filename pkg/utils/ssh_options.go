@@ -0,0 +1,143 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	logger "github.com/luo2pei4/ltool/pkg/log"
+	"golang.org/x/crypto/ssh"
+)
+
+// SupportedCiphers the ciphers golang.org/x/crypto/ssh implements
+var SupportedCiphers = []string{
+	"aes128-gcm@openssh.com", "aes256-gcm@openssh.com", "chacha20-poly1305@openssh.com",
+	"aes128-ctr", "aes192-ctr", "aes256-ctr",
+	"aes128-cbc", "3des-cbc", "arcfour256", "arcfour128", "arcfour",
+}
+
+// SupportedKeyExchanges the key exchange algorithms golang.org/x/crypto/ssh implements
+var SupportedKeyExchanges = []string{
+	"curve25519-sha256", "curve25519-sha256@libssh.org",
+	"ecdh-sha2-nistp256", "ecdh-sha2-nistp384", "ecdh-sha2-nistp521",
+	"diffie-hellman-group14-sha256", "diffie-hellman-group16-sha512",
+	"diffie-hellman-group14-sha1", "diffie-hellman-group1-sha1",
+	"diffie-hellman-group-exchange-sha256", "diffie-hellman-group-exchange-sha1",
+}
+
+// SSHOptions connection options of a node, the zero values use the defaults
+type SSHOptions struct {
+	Port              int           // 0 means the port of Host or 22
+	ConnectTimeout    time.Duration // 0 means DialTimeout
+	KeepaliveInterval time.Duration // 0 sends no keepalives
+	Ciphers           []string      // in preference order, empty means the defaults
+	KeyExchanges      []string      // in preference order, empty means the defaults
+}
+
+// ValidateOptions checks the port, the durations and the algorithms
+func ValidateOptions(o *SSHOptions) error {
+	switch {
+	case o.Port < 0 || o.Port > 65535:
+		return fmt.Errorf("invalid port %d", o.Port)
+	case o.ConnectTimeout < 0:
+		return errors.New("the connect timeout is negative")
+	case o.KeepaliveInterval < 0:
+		return errors.New("the keepalive interval is negative")
+	}
+	for _, cipher := range o.Ciphers {
+		if !slices.Contains(SupportedCiphers, cipher) {
+			return fmt.Errorf("unsupported cipher '%s'", cipher)
+		}
+	}
+	for _, kex := range o.KeyExchanges {
+		if !slices.Contains(SupportedKeyExchanges, kex) {
+			return fmt.Errorf("unsupported key exchange '%s'", kex)
+		}
+	}
+	return nil
+}
+
+// SplitAlgorithms splits a comma separated algorithm list
+func SplitAlgorithms(s string) []string {
+	var algos []string
+	for _, algo := range strings.Split(s, ",") {
+		if algo = strings.TrimSpace(algo); algo != "" {
+			algos = append(algos, algo)
+		}
+	}
+	return algos
+}
+
+// Address returns host:port of the node, the port of the options wins over
+// the one of Host.
+func (c *SSHConfig) Address() string {
+	if c.Options.Port == 0 {
+		return hostWithPort(c.Host)
+	}
+	host := c.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	return net.JoinHostPort(host, strconv.Itoa(c.Options.Port))
+}
+
+// connectTimeout limits connecting and authenticating the hop
+func (o *SSHOptions) connectTimeout() time.Duration {
+	if o.ConnectTimeout > 0 {
+		return o.ConnectTimeout
+	}
+	return DialTimeout
+}
+
+// poolKey identifies the options which need a new connection if changed
+func (o *SSHOptions) poolKey() string {
+	return fmt.Sprintf("%s|%s|%s|%s", o.ConnectTimeout, o.KeepaliveInterval,
+		strings.Join(o.Ciphers, ","), strings.Join(o.KeyExchanges, ","))
+}
+
+// keepAlive sends a keepalive request every interval until the client is
+// closed, the client is closed if the node does not answer.
+func keepAlive(client *ssh.Client, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	done := make(chan struct{})
+	go func() {
+		client.Wait()
+		close(done)
+	}()
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			reply := make(chan error, 1)
+			go func() {
+				_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+				reply <- err
+			}()
+			var err error
+			select {
+			case <-done:
+				return
+			case err = <-reply:
+			case <-time.After(interval):
+				err = errors.New("no reply")
+			}
+			if err != nil {
+				logger.Warnf("keepalive of %s failed, %v", client.RemoteAddr(), err)
+				client.Close()
+				return
+			}
+		}
+	}()
+}
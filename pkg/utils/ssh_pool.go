@@ -0,0 +1,223 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	logger "github.com/luo2pei4/ltool/pkg/log"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// sshIdleTimeout is how long an unused client stays in the pool
+	sshIdleTimeout = 5 * time.Minute
+	// sshMaxSessions limits the concurrent sessions on one client,
+	// sshd allows 10 sessions per connection by default (MaxSessions)
+	sshMaxSessions = 8
+)
+
+// sshPool keeps authenticated ssh clients alive per host,
+// every command only opens a new session on the pooled client.
+type sshPool struct {
+	sync.Mutex
+	clients     map[string]*pooledClient // key: user@host
+	idleTimeout time.Duration
+	janitorOnce sync.Once
+}
+
+type dialFunc func(ctx context.Context) (*ssh.Client, error)
+
+type pooledClient struct {
+	sync.Mutex
+	client   *ssh.Client
+	authKey  string
+	lastUsed time.Time
+	sessions chan struct{}
+}
+
+var defaultSSHPool = newSSHPool(sshIdleTimeout)
+
+func newSSHPool(idleTimeout time.Duration) *sshPool {
+	return &sshPool{
+		clients:     make(map[string]*pooledClient),
+		idleTimeout: idleTimeout,
+	}
+}
+
+// get returns the pooled client of the host with its ssh client, dials a
+// new one if the host has no client yet or the credentials were changed.
+// The ssh client is returned since evict may clear pc.client as soon as
+// the lock is released.
+func (p *sshPool) get(ctx context.Context, key, authKey string, dial dialFunc) (*pooledClient, *ssh.Client, error) {
+	p.janitorOnce.Do(func() {
+		go p.janitor()
+	})
+
+	p.Lock()
+	pc, ok := p.clients[key]
+	if !ok {
+		pc = &pooledClient{sessions: make(chan struct{}, sshMaxSessions)}
+		p.clients[key] = pc
+	}
+	p.Unlock()
+
+	pc.Lock()
+	defer pc.Unlock()
+	if pc.client != nil && pc.authKey != authKey {
+		pc.client.Close()
+		pc.client = nil
+	}
+	if pc.client == nil {
+		client, err := dial(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		pc.client = client
+		pc.authKey = authKey
+	}
+	pc.lastUsed = time.Now()
+	return pc, pc.client, nil
+}
+
+// evict closes the client if it is still the one held by the pool.
+func (p *sshPool) evict(key string, client *ssh.Client) {
+	p.Lock()
+	pc, ok := p.clients[key]
+	p.Unlock()
+	if !ok {
+		return
+	}
+	pc.Lock()
+	defer pc.Unlock()
+	if pc.client == client {
+		pc.client.Close()
+		pc.client = nil
+	}
+}
+
+// evictHost closes the clients of the host, e.g. its host key was revoked
+func (p *sshPool) evictHost(host string) {
+	p.Lock()
+	defer p.Unlock()
+	for key, pc := range p.clients {
+		if !strings.HasSuffix(key, "@"+host) {
+			continue
+		}
+		pc.Lock()
+		if pc.client != nil {
+			pc.client.Close()
+			pc.client = nil
+		}
+		pc.Unlock()
+	}
+}
+
+// run executes cmd in a new session of the pooled client,
+// reconnects once if the pooled connection was broken.
+func (p *sshPool) run(ctx context.Context, key, authKey string, dial dialFunc, run func(*ssh.Session) error) error {
+	session, release, err := p.open(ctx, key, authKey, dial)
+	if err != nil {
+		return err
+	}
+	err = run(session)
+	release(err)
+	return err
+}
+
+// open creates a session on the pooled client of the host, the session
+// takes one of the slots of the client until release is called with the
+// result of the session.
+func (p *sshPool) open(ctx context.Context, key, authKey string, dial dialFunc) (*ssh.Session, func(error), error) {
+	for attempt := 0; ; attempt++ {
+		pc, client, err := p.get(ctx, key, authKey, dial)
+		if err != nil {
+			return nil, nil, err
+		}
+		select {
+		case pc.sessions <- struct{}{}:
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		}
+		// a client closed meanwhile fails here and is redialed once
+		session, err := client.NewSession()
+		if err != nil {
+			<-pc.sessions
+			p.evict(key, client)
+			if attempt == 0 {
+				logger.Warnf("session of %s broken, reconnecting, %v", key, err)
+				continue
+			}
+			return nil, nil, fmt.Errorf("create session failed, %v", err)
+		}
+		release := func(err error) {
+			session.Close()
+			<-pc.sessions
+			if err != nil && isConnBroken(err) {
+				p.evict(key, client)
+			}
+			pc.Lock()
+			pc.lastUsed = time.Now()
+			pc.Unlock()
+		}
+		return session, release, nil
+	}
+}
+
+// janitor closes the clients which were idle longer than idleTimeout.
+func (p *sshPool) janitor() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for range ticker.C {
+		p.closeIdle(time.Now().Add(-p.idleTimeout))
+	}
+}
+
+func (p *sshPool) closeIdle(before time.Time) {
+	p.Lock()
+	defer p.Unlock()
+	for key, pc := range p.clients {
+		if !pc.TryLock() {
+			continue
+		}
+		if pc.client != nil && len(pc.sessions) == 0 && pc.lastUsed.Before(before) {
+			logger.Debugf("close idle ssh client %s", key)
+			pc.client.Close()
+			pc.client = nil
+		}
+		pc.Unlock()
+	}
+}
+
+func (p *sshPool) closeAll() {
+	p.Lock()
+	defer p.Unlock()
+	for _, pc := range p.clients {
+		pc.Lock()
+		if pc.client != nil {
+			pc.client.Close()
+			pc.client = nil
+		}
+		pc.Unlock()
+	}
+}
+
+// isConnBroken reports whether err means the underlying connection is
+// unusable, a non-zero exit status of the command is not.
+func isConnBroken(err error) bool {
+	var exitErr *ssh.ExitError
+	if errors.As(err, &exitErr) {
+		return false
+	}
+	var missingErr *ssh.ExitMissingError
+	return errors.As(err, &missingErr) || errors.Is(err, io.EOF)
+}
+
+// CloseSSHClients closes all pooled ssh connections.
+func CloseSSHClients() {
+	defaultSSHPool.closeAll()
+}
@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// TerminalType is the TERM of the interactive shells
+const TerminalType = "xterm-256color"
+
+// Shell an interactive login shell on a pty of the node,
+// Read returns the output until the shell exits.
+type Shell struct {
+	session *ssh.Session
+	stdin   io.WriteCloser
+	stdout  *io.PipeReader
+	done    chan struct{}
+	err     error
+	once    sync.Once
+}
+
+// OpenShell starts the login shell of conf.User on a pty of cols x rows,
+// the session is opened on the pooled connection of the node.
+func OpenShell(ctx context.Context, conf *SSHConfig, cols, rows int) (*Shell, error) {
+	host := conf.Address()
+	dial := func(ctx context.Context) (*ssh.Client, error) {
+		return dialChain(ctx, conf)
+	}
+	session, release, err := defaultSSHPool.open(ctx, conf.User+"@"+host, conf.poolKey(), dial)
+	if err != nil {
+		return nil, err
+	}
+	modes := ssh.TerminalModes{
+		ssh.ECHO:          1,
+		ssh.TTY_OP_ISPEED: 38400,
+		ssh.TTY_OP_OSPEED: 38400,
+	}
+	if err := session.RequestPty(TerminalType, rows, cols, modes); err != nil {
+		release(err)
+		return nil, fmt.Errorf("request pty failed, %v", err)
+	}
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		release(err)
+		return nil, err
+	}
+	pr, pw := io.Pipe()
+	session.Stdout = pw
+	session.Stderr = pw
+	if err := session.Shell(); err != nil {
+		release(err)
+		return nil, fmt.Errorf("start shell failed, %v", err)
+	}
+	s := &Shell{
+		session: session,
+		stdin:   stdin,
+		stdout:  pr,
+		done:    make(chan struct{}),
+	}
+	go func() {
+		s.err = session.Wait()
+		release(s.err)
+		pw.Close()
+		close(s.done)
+	}()
+	return s, nil
+}
+
+// Read reads the output of the shell
+func (s *Shell) Read(p []byte) (int, error) {
+	return s.stdout.Read(p)
+}
+
+// Write types p on the shell
+func (s *Shell) Write(p []byte) (int, error) {
+	return s.stdin.Write(p)
+}
+
+// Resize tells the shell the new size of the terminal
+func (s *Shell) Resize(cols, rows int) error {
+	return s.session.WindowChange(rows, cols)
+}
+
+// Wait waits until the shell exits
+func (s *Shell) Wait() error {
+	<-s.done
+	return s.err
+}
+
+// Close hangs up the shell
+func (s *Shell) Close() error {
+	s.once.Do(func() {
+		s.session.Close()
+		// unblock the output copy if nobody reads it anymore
+		s.stdout.Close()
+	})
+	return nil
+}
@@ -0,0 +1,390 @@
+// Package sshtest provides an in-process ssh server for tests,
+// it replays scripted outputs instead of executing the commands.
+package sshtest
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// Reply the scripted result of a command
+type Reply struct {
+	Stdout     string
+	Stderr     string
+	ExitStatus int
+	// Delay holds the reply, the command can be cancelled meanwhile
+	Delay time.Duration
+	// Prompt is written before the output until Password is entered,
+	// like sudo on a pty
+	Prompt   string
+	Password string
+	// SFTP serves sftp on the channel after the output, like sftp-server
+	// started by sudo
+	SFTP bool
+}
+
+// Server an ssh server listening on 127.0.0.1 with password authentication
+type Server struct {
+	Addr    string // host:port
+	HostKey ssh.PublicKey
+
+	listener net.Listener
+	config   *ssh.ServerConfig
+
+	mu       sync.Mutex
+	replies  map[string]Reply
+	commands []string // executed commands in order
+	conns    int      // accepted ssh connections
+	windows  []string // pty sizes of the shells, e.g. 80x24
+	wg       sync.WaitGroup
+}
+
+// NewServer starts a server accepting user with password
+func NewServer(user, password string) (*Server, error) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		return nil, err
+	}
+	return NewServerWithKey(user, password, signer)
+}
+
+// NewServerWithKey starts a server using hostKey, e.g. to test a changed host key
+func NewServerWithKey(user, password string, hostKey ssh.Signer) (*Server, error) {
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if c.User() == user && string(pass) == password {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("password rejected for %s", c.User())
+		},
+	}
+	config.AddHostKey(hostKey)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		Addr:     listener.Addr().String(),
+		HostKey:  hostKey.PublicKey(),
+		listener: listener,
+		config:   config,
+		replies:  make(map[string]Reply),
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Handle scripts the reply of cmd, the unknown commands exit with 127.
+// sha256sum of a local file is answered with its real sum, so that the
+// sftp transfers verify, a scripted reply overrides it, e.g. to fake a
+// corrupted transfer.
+func (s *Server) Handle(cmd string, reply Reply) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.replies[cmd] = reply
+}
+
+// Commands returns the executed commands in order
+func (s *Server) Commands() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.commands...)
+}
+
+// Conns returns the number of accepted ssh connections
+func (s *Server) Conns() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conns
+}
+
+// Windows returns the pty sizes requested by the shells in order, e.g. 80x24
+func (s *Server) Windows() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.windows...)
+}
+
+// Close stops listening, the established connections are closed by the clients
+func (s *Server) Close() {
+	s.listener.Close()
+	s.wg.Wait()
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handleConn(conn)
+	}
+}
+
+func (s *Server) handleConn(conn net.Conn) {
+	sconn, chans, reqs, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	defer sconn.Close()
+	s.mu.Lock()
+	s.conns++
+	s.mu.Unlock()
+	go ssh.DiscardRequests(reqs)
+	for newChan := range chans {
+		switch newChan.ChannelType() {
+		case "session":
+			ch, requests, err := newChan.Accept()
+			if err != nil {
+				continue
+			}
+			go s.handleSession(ch, requests)
+		case "direct-tcpip":
+			// the server acts as a jump host
+			go s.forward(newChan)
+		default:
+			newChan.Reject(ssh.UnknownChannelType, "unsupported channel type")
+		}
+	}
+}
+
+func (s *Server) forward(newChan ssh.NewChannel) {
+	var payload struct {
+		Host       string
+		Port       uint32
+		OriginHost string
+		OriginPort uint32
+	}
+	if err := ssh.Unmarshal(newChan.ExtraData(), &payload); err != nil {
+		newChan.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	conn, err := net.Dial("tcp", net.JoinHostPort(payload.Host, strconv.Itoa(int(payload.Port))))
+	if err != nil {
+		newChan.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	ch, requests, err := newChan.Accept()
+	if err != nil {
+		conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(requests)
+	go func() {
+		io.Copy(ch, conn)
+		ch.CloseWrite()
+	}()
+	io.Copy(conn, ch)
+	conn.Close()
+	ch.Close()
+}
+
+func (s *Server) handleSession(ch ssh.Channel, requests <-chan *ssh.Request) {
+	defer ch.Close()
+	signals := make(chan struct{}, 1)
+	for req := range requests {
+		switch req.Type {
+		case "pty-req":
+			var payload struct {
+				Term    string
+				Columns uint32
+				Rows    uint32
+				Width   uint32
+				Height  uint32
+				Modes   string
+			}
+			if err := ssh.Unmarshal(req.Payload, &payload); err == nil {
+				s.window(payload.Columns, payload.Rows)
+			}
+			req.Reply(true, nil)
+		case "subsystem":
+			var payload struct{ Name string }
+			if err := ssh.Unmarshal(req.Payload, &payload); err != nil || payload.Name != "sftp" {
+				req.Reply(false, nil)
+				continue
+			}
+			req.Reply(true, nil)
+			// serves the local file system, the tests use temporary directories
+			go func() {
+				server, err := sftp.NewServer(ch)
+				if err == nil {
+					server.Serve()
+					server.Close()
+				}
+				ch.Close()
+			}()
+		case "shell":
+			req.Reply(true, nil)
+			go s.shell(ch)
+		case "window-change":
+			var payload struct {
+				Columns uint32
+				Rows    uint32
+				Width   uint32
+				Height  uint32
+			}
+			if err := ssh.Unmarshal(req.Payload, &payload); err == nil {
+				s.window(payload.Columns, payload.Rows)
+			}
+		case "exec":
+			var payload struct{ Command string }
+			if err := ssh.Unmarshal(req.Payload, &payload); err != nil {
+				req.Reply(false, nil)
+				continue
+			}
+			req.Reply(true, nil)
+			go func() {
+				for r := range requests {
+					if r.Type == "signal" {
+						signals <- struct{}{}
+					}
+					if r.WantReply {
+						r.Reply(false, nil)
+					}
+				}
+			}()
+			s.exec(ch, payload.Command, signals)
+			return
+		default:
+			if req.WantReply {
+				req.Reply(false, nil)
+			}
+		}
+	}
+}
+
+func (s *Server) window(cols, rows uint32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.windows = append(s.windows, fmt.Sprintf("%dx%d", cols, rows))
+}
+
+// shell echoes the typed lines like a pty, the scripted commands are
+// answered, "exit" ends the shell.
+func (s *Server) shell(ch ssh.Channel) {
+	for {
+		ch.Write([]byte("$ "))
+		line, err := readTyped(ch)
+		if err != nil {
+			return
+		}
+		if line == "exit" {
+			ch.SendRequest("exit-status", false, make([]byte, 4))
+			ch.Close()
+			return
+		}
+		s.mu.Lock()
+		s.commands = append(s.commands, line)
+		reply := s.replies[line]
+		s.mu.Unlock()
+		ch.Write([]byte(reply.Stdout))
+	}
+}
+
+func (s *Server) exec(ch ssh.Channel, cmd string, signals <-chan struct{}) {
+	s.mu.Lock()
+	s.commands = append(s.commands, cmd)
+	reply, ok := s.replies[cmd]
+	s.mu.Unlock()
+	if !ok {
+		reply = builtin(cmd)
+	}
+	if reply.Prompt != "" {
+		for {
+			ch.Write([]byte(reply.Prompt))
+			line, err := readLine(ch)
+			if err != nil {
+				return
+			}
+			if line == reply.Password {
+				ch.Write([]byte("\r\n"))
+				break
+			}
+			ch.Write([]byte("\r\nSorry, try again.\r\n"))
+		}
+	}
+	if reply.Delay > 0 {
+		select {
+		case <-time.After(reply.Delay):
+		case <-signals:
+			return
+		}
+	}
+	ch.Write([]byte(reply.Stdout))
+	ch.Stderr().Write([]byte(reply.Stderr))
+	if reply.SFTP {
+		if server, err := sftp.NewServer(ch); err == nil {
+			server.Serve()
+			server.Close()
+		}
+	}
+	status := make([]byte, 4)
+	binary.BigEndian.PutUint32(status, uint32(reply.ExitStatus))
+	ch.SendRequest("exit-status", false, status)
+}
+
+// builtin answers the commands which are not scripted
+func builtin(cmd string) Reply {
+	if file, ok := strings.CutPrefix(cmd, "sha256sum -- '"); ok && strings.HasSuffix(file, "'") {
+		file = strings.TrimSuffix(file, "'")
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return Reply{Stderr: "sha256sum: " + file + ": No such file or directory\n", ExitStatus: 1}
+		}
+		sum := sha256.Sum256(content)
+		return Reply{Stdout: hex.EncodeToString(sum[:]) + "  " + file + "\n"}
+	}
+	return Reply{Stderr: "sh: " + cmd + ": command not found\n", ExitStatus: 127}
+}
+
+// readTyped reads a line ended by return and echoes it
+func readTyped(ch ssh.Channel) (string, error) {
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		if _, err := ch.Read(b); err != nil {
+			return "", err
+		}
+		if b[0] == '\r' {
+			ch.Write([]byte("\r\n"))
+			return string(line), nil
+		}
+		ch.Write(b)
+		line = append(line, b[0])
+	}
+}
+
+func readLine(r io.Reader) (string, error) {
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		if _, err := r.Read(b); err != nil {
+			return "", err
+		}
+		if b[0] == '\n' {
+			return string(line), nil
+		}
+		line = append(line, b[0])
+	}
+}
@@ -1,8 +1,12 @@
 package utils
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"net"
+	"net/netip"
 	"regexp"
 	"strconv"
 	"strings"
@@ -13,79 +17,302 @@ import (
 	"golang.org/x/crypto/ssh"
 )
 
+var (
+	// DialTimeout limits connecting and authenticating a node
+	DialTimeout = 10 * time.Second
+	// CommandTimeout limits a remote command if the caller set no deadline
+	CommandTimeout = 2 * time.Minute
+)
+
+// MaxIPv4Range limits the addresses a range or a cidr block expands to
+const MaxIPv4Range = 65536
+
 // ValidateIPv4 validate ipv4 address
-// support xxx.xxx.xxx.xxx-xxx format, "-xxx" mains 'to xxx'
+// support the ranges and cidr blocks of ExpandIPv4
 func ValidateIPv4(ip string) error {
-	arr := strings.Split(ip, "-")
-	if len(arr) > 2 {
-		return errors.New("unsupported ip address format")
+	_, err := ExpandIPv4(ip)
+	return err
+}
+
+// ValidateIP validate an ipv4 address like ValidateIPv4 or an ipv6 address
+func ValidateIP(ip string) error {
+	_, err := ExpandIP(ip)
+	return err
+}
+
+// ExpandIP expands an ipv4 address, range or cidr block like ExpandIPv4,
+// an ipv6 address is returned in its canonical form. The ipv6 ranges and
+// blocks are not supported.
+func ExpandIP(ip string) ([]string, error) {
+	if !strings.Contains(ip, ":") {
+		return ExpandIPv4(ip)
 	}
-	if len(arr) == 1 || len(arr) == 2 {
-		matched, err := regexp.MatchString(consts.IPv4Pattern, arr[0])
+	if strings.ContainsAny(ip, "/-") {
+		return nil, errors.New("ipv6 ranges and cidr blocks are not supported")
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil || addr.Is4In6() {
+		return nil, errors.New("invalid ip address")
+	}
+	return []string{addr.String()}, nil
+}
+
+// ExpandIPv4 expands an ipv4 address, a range in the last octet
+// (10.0.0.1-20), a range across octets (10.0.0.250-10.0.1.10) or a cidr
+// block (10.0.1.0/26) to the addresses. The network and broadcast addresses
+// of a block are left out.
+func ExpandIPv4(ip string) ([]string, error) {
+	var from, to uint32
+	if addr, bits, ok := strings.Cut(ip, "/"); ok {
+		start, err := parseIPv4(addr)
 		if err != nil {
-			return fmt.Errorf("check ip address failed, %v", err)
+			return nil, err
 		}
-		if !matched {
-			return fmt.Errorf("invalid ip address")
+		ones, err := strconv.Atoi(bits)
+		if err != nil || ones < 0 || ones > 32 {
+			return nil, fmt.Errorf("invalid prefix length /%s", bits)
 		}
-	}
-	if len(arr) == 2 {
-		toNodeIP, err := strconv.Atoi(arr[1])
-		if err != nil {
-			return err
+		mask := uint32(0xffffffff) << (32 - ones)
+		if ones == 0 {
+			mask = 0
 		}
-		if toNodeIP > 255 {
-			return errors.New("invalid ip address range")
+		from, to = start&mask, start|^mask
+		if ones < 31 {
+			from, to = from+1, to-1
 		}
+	} else {
+		arr := strings.Split(ip, "-")
+		if len(arr) > 2 {
+			return nil, errors.New("unsupported ip address format")
+		}
+		var err error
+		if from, err = parseIPv4(arr[0]); err != nil {
+			return nil, err
+		}
+		to = from
+		if len(arr) == 2 {
+			if strings.Contains(arr[1], ".") {
+				to, err = parseIPv4(arr[1])
+			} else {
+				var last int
+				last, err = strconv.Atoi(arr[1])
+				if err == nil && (last < 0 || last > 255) {
+					err = errors.New("invalid ip address range")
+				}
+				to = from&0xffffff00 | uint32(last)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		if to < from {
+			return nil, errors.New("invalid ip address range, the end is less than the start")
+		}
+	}
+	if to-from >= MaxIPv4Range {
+		return nil, fmt.Errorf("%s has more than %d addresses", ip, MaxIPv4Range)
+	}
+	ips := make([]string, 0, to-from+1)
+	for addr := uint64(from); addr <= uint64(to); addr++ {
+		ips = append(ips, net.IPv4(byte(addr>>24), byte(addr>>16), byte(addr>>8), byte(addr)).String())
+	}
+	return ips, nil
+}
+
+func parseIPv4(ip string) (uint32, error) {
+	matched, err := regexp.MatchString(consts.IPv4Pattern, ip)
+	if err != nil {
+		return 0, fmt.Errorf("check ip address failed, %v", err)
+	}
+	if !matched {
+		return 0, fmt.Errorf("invalid ip address")
 	}
-	return nil
+	v4 := net.ParseIP(ip).To4()
+	return uint32(v4[0])<<24 | uint32(v4[1])<<16 | uint32(v4[2])<<8 | uint32(v4[3]), nil
 }
 
-func Ping(ip string) (bool, error) {
+// Ping sends three echo requests and returns the average round trip time,
+// privileged uses a raw icmp socket, otherwise an unprivileged udp one.
+// It returns early when ctx is done.
+func Ping(ctx context.Context, ip string, privileged bool) (bool, time.Duration, error) {
 	pinger, err := probing.NewPinger(ip)
 	if err != nil {
-		return false, err
+		return false, 0, err
 	}
 	pinger.Count = 3                 // sends and receives three packets
 	pinger.Timeout = time.Second * 3 // timeout
-	pinger.SetPrivileged(true)
-	err = pinger.Run()
+	pinger.SetPrivileged(privileged)
+	err = pinger.RunWithContext(ctx)
+	if ctx.Err() != nil {
+		return false, 0, ctx.Err()
+	}
 	if err != nil {
-		return false, err
+		return false, 0, err
 	}
 	stats := pinger.Statistics()
 	if stats.PacketsRecv > 0 {
-		return true, nil
+		return true, stats.AvgRtt, nil
 	}
-	return false, nil
+	return false, 0, nil
+}
+
+// RemoteCmd executes cmd on the host over a pooled ssh connection,
+// the command is killed when ctx is done or CommandTimeout elapsed.
+func RemoteCmd(ctx context.Context, conf *SSHConfig, cmd string) ([]byte, error) {
+	return remoteCmd(ctx, conf, cmd, conf.Become.escalates(cmd))
 }
 
-func RemoteCmd(host, user, password, cmd string) ([]byte, error) {
-	config := &ssh.ClientConfig{
-		User: user,
-		Auth: []ssh.AuthMethod{
-			ssh.Password(password),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+// remoteCmd executes cmd like RemoteCmd, through sudo or su if escalate
+func remoteCmd(ctx context.Context, conf *SSHConfig, cmd string, escalate bool) ([]byte, error) {
+	var output []byte
+	err := withSession(ctx, conf, func(ctx context.Context, session *ssh.Session) error {
+		var err error
+		if escalate {
+			output, err = runBecome(ctx, session, &conf.Become, cmd)
+		} else {
+			output, err = runSession(ctx, session, cmd)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
-	if len(strings.Split(host, ":")) == 1 {
-		host += ":22"
+	return output, nil
+}
+
+// CmdResult the outputs of a command
+type CmdResult struct {
+	Stdout     []byte
+	Stderr     []byte // empty if the command was escalated, the pty merges it into Stdout
+	ExitStatus int
+	Duration   time.Duration
+}
+
+// RemoteRun executes cmd like RemoteCmd but keeps stdout and stderr apart,
+// a non-zero exit status is reported in the result instead of an error.
+func RemoteRun(ctx context.Context, conf *SSHConfig, cmd string) (*CmdResult, error) {
+	result := &CmdResult{}
+	start := time.Now()
+	err := withSession(ctx, conf, func(ctx context.Context, session *ssh.Session) error {
+		if conf.Become.escalates(cmd) {
+			output, err := runBecome(ctx, session, &conf.Become, cmd)
+			result.Stdout = output
+			return err
+		}
+		var stdout, stderr bytes.Buffer
+		session.Stdout = &stdout
+		session.Stderr = &stderr
+		err := waitSession(ctx, session, nil, func() error {
+			return session.Run(cmd)
+		})
+		// the buffers are still written if the command was killed
+		var exitErr *ssh.ExitError
+		if err == nil || errors.As(err, &exitErr) {
+			result.Stdout, result.Stderr = stdout.Bytes(), stderr.Bytes()
+		}
+		return err
+	})
+	result.Duration = time.Since(start)
+	var exitErr *ssh.ExitError
+	if errors.As(err, &exitErr) {
+		result.ExitStatus = exitErr.ExitStatus()
+		return result, nil
 	}
-	conn, err := ssh.Dial("tcp", host, config)
 	if err != nil {
-		return nil, fmt.Errorf("dail %s failed, %v", host, err)
+		return nil, err
+	}
+	return result, nil
+}
+
+// withSession runs fn in a session of the pooled client of the host,
+// CommandTimeout applies if ctx has no deadline.
+func withSession(ctx context.Context, conf *SSHConfig, fn func(ctx context.Context, session *ssh.Session) error) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, CommandTimeout)
+		defer cancel()
+	}
+	host := conf.Address()
+	dial := func(ctx context.Context) (*ssh.Client, error) {
+		return dialChain(ctx, conf)
 	}
-	defer conn.Close()
-	session, err := conn.NewSession()
+	var cmdErr error
+	err := defaultSSHPool.run(ctx, conf.User+"@"+host, conf.poolKey(), dial, func(session *ssh.Session) error {
+		cmdErr = fn(ctx, session)
+		return cmdErr
+	})
+	if cmdErr != nil {
+		return fmt.Errorf("execute command failed, %w", cmdErr)
+	}
+	return err
+}
+
+// contextDialer opens a connection to the address, it is implemented by
+// net.Dialer and by ssh.Client to tunnel through a jump host
+type contextDialer interface {
+	DialContext(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+// dialSSH connects and handshakes with the host, both are aborted when
+// ctx is done or config.Timeout elapsed.
+func dialSSH(ctx context.Context, dialer contextDialer, host string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	dialCtx, cancel := context.WithTimeout(ctx, config.Timeout)
+	defer cancel()
+	conn, err := dialer.DialContext(dialCtx, "tcp", host)
 	if err != nil {
-		return nil, fmt.Errorf("create session failed, %v", err)
+		return nil, err
+	}
+	// the handshake has no context, close the connection to abort it
+	stop := context.AfterFunc(dialCtx, func() {
+		conn.Close()
+	})
+	c, chans, reqs, err := ssh.NewClientConn(conn, host, config)
+	if !stop() {
+		if err == nil {
+			c.Close()
+		}
+		return nil, dialCtx.Err()
 	}
-	defer session.Close()
-	output, err := session.CombinedOutput(cmd)
 	if err != nil {
-		return nil, fmt.Errorf("execute command failed, %w", err)
+		conn.Close()
+		return nil, err
+	}
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+// runSession runs cmd and kills it when ctx is done
+func runSession(ctx context.Context, session *ssh.Session, cmd string) ([]byte, error) {
+	var output []byte
+	err := waitSession(ctx, session, nil, func() error {
+		var err error
+		output, err = session.CombinedOutput(cmd)
+		return err
+	})
+	return output, err
+}
+
+// waitSession waits for run, the command is killed when ctx is done
+// or abort is closed
+func waitSession(ctx context.Context, session *ssh.Session, abort <-chan struct{}, run func() error) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- run()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-abort:
+		session.Signal(ssh.SIGKILL)
+		session.Close()
+		<-done
+		return errors.New("command was aborted")
+	case <-ctx.Done():
+		session.Signal(ssh.SIGKILL)
+		session.Close()
+		// run still writes the output until the closed session returns
+		<-done
+		return ctx.Err()
 	}
-	return output, nil
 }
 
 func AssembleCmd(args ...string) string {
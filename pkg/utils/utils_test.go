@@ -0,0 +1,828 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/luo2pei4/ltool/pkg/utils/sshtest"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestValidateIPv4(t *testing.T) {
+	tests := []struct {
+		ip      string
+		wantErr bool
+	}{
+		{"192.168.1.10", false},
+		{"192.168.1.10-20", false},
+		{"10.0.0.1-255", false},
+		{"10.0.0.1-256", true},
+		{"10.0.0.1-a", true},
+		{"10.0.0.256", true},
+		{"10.0.0", true},
+		{"10.0.0.1-2-3", true},
+		{"10.0.0.20-10", true},
+		{"10.0.1.0/26", false},
+		{"10.0.1.0/33", true},
+		{"10.0.0.0/8", true},
+		{"10.0.0.250-10.0.1.10", false},
+		{"10.0.1.10-10.0.0.250", true},
+	}
+	for _, tt := range tests {
+		err := ValidateIPv4(tt.ip)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ValidateIPv4(%q) error = %v, wantErr %v", tt.ip, err, tt.wantErr)
+		}
+	}
+}
+
+func TestExpandIPv4(t *testing.T) {
+	tests := []struct {
+		ip    string
+		count int
+		first string
+		last  string
+	}{
+		{"192.168.1.10", 1, "192.168.1.10", "192.168.1.10"},
+		{"192.168.1.10-12", 3, "192.168.1.10", "192.168.1.12"},
+		{"10.0.1.0/26", 62, "10.0.1.1", "10.0.1.62"},
+		{"10.0.1.4/31", 2, "10.0.1.4", "10.0.1.5"},
+		{"10.0.1.7/32", 1, "10.0.1.7", "10.0.1.7"},
+		{"10.0.0.250-10.0.1.10", 17, "10.0.0.250", "10.0.1.10"},
+	}
+	for _, tt := range tests {
+		ips, err := ExpandIPv4(tt.ip)
+		if err != nil {
+			t.Errorf("ExpandIPv4(%q) error = %v", tt.ip, err)
+			continue
+		}
+		if len(ips) != tt.count || ips[0] != tt.first || ips[len(ips)-1] != tt.last {
+			t.Errorf("ExpandIPv4(%q) = %d addresses %s..%s", tt.ip, len(ips), ips[0], ips[len(ips)-1])
+		}
+	}
+}
+
+func TestExpandIP(t *testing.T) {
+	tests := []struct {
+		ip      string
+		want    string
+		wantErr bool
+	}{
+		{"192.168.1.10", "192.168.1.10", false},
+		{"2001:DB8:0:0::10", "2001:db8::10", false},
+		{"fe80::1%eth0", "fe80::1%eth0", false},
+		{"::ffff:192.168.1.10", "", true},
+		{"2001:db8::10-20", "", true},
+		{"2001:db8::/64", "", true},
+		{"2001:db8::g", "", true},
+	}
+	for _, tt := range tests {
+		ips, err := ExpandIP(tt.ip)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ExpandIP(%q) error = %v, wantErr %v", tt.ip, err, tt.wantErr)
+			continue
+		}
+		if err == nil && (len(ips) != 1 || ips[0] != tt.want) {
+			t.Errorf("ExpandIP(%q) = %v, want %s", tt.ip, ips, tt.want)
+		}
+	}
+}
+
+func TestHostWithPort(t *testing.T) {
+	tests := map[string]string{
+		"192.168.1.10":        "192.168.1.10:22",
+		"192.168.1.10:2222":   "192.168.1.10:2222",
+		"node1":               "node1:22",
+		"2001:db8::10":        "[2001:db8::10]:22",
+		"[2001:db8::10]":      "[2001:db8::10]:22",
+		"[2001:db8::10]:2222": "[2001:db8::10]:2222",
+	}
+	for host, want := range tests {
+		if got := hostWithPort(host); got != want {
+			t.Errorf("hostWithPort(%q) = %q, want %q", host, got, want)
+		}
+	}
+}
+
+func TestAssembleCmd(t *testing.T) {
+	if got := AssembleCmd(); got != "" {
+		t.Errorf("AssembleCmd() = %q, want empty", got)
+	}
+	if got := AssembleCmd("nmcli", "con", "show", "eth0"); got != "nmcli con show eth0" {
+		t.Errorf("AssembleCmd() = %q", got)
+	}
+}
+
+func TestValidateAuth(t *testing.T) {
+	tests := []struct {
+		auth    SSHAuth
+		wantErr bool
+	}{
+		{SSHAuth{Password: "secret"}, false},
+		{SSHAuth{AuthType: AuthPassword}, true},
+		{SSHAuth{AuthType: AuthKey, KeyFile: "~/.ssh/id_ed25519"}, false},
+		{SSHAuth{AuthType: AuthCert}, true},
+		{SSHAuth{AuthType: AuthAgent}, false},
+		{SSHAuth{AuthType: "kerberos"}, true},
+	}
+	for _, tt := range tests {
+		err := ValidateAuth(&tt.auth)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ValidateAuth(%+v) error = %v, wantErr %v", tt.auth, err, tt.wantErr)
+		}
+	}
+}
+
+// newTestServer starts a fake ssh server and trusts hosts in a temporary known_hosts
+func newTestServer(t *testing.T) *sshtest.Server {
+	t.Helper()
+	return newTestServerFor(t, "root", "secret")
+}
+
+func newTestServerFor(t *testing.T, user, password string) *sshtest.Server {
+	t.Helper()
+	srv, err := sshtest.NewServer(user, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetKnownHosts(filepath.Join(t.TempDir(), "known_hosts"), false)
+	t.Cleanup(func() {
+		CloseSSHClients()
+		srv.Close()
+	})
+	return srv
+}
+
+func TestRemoteCmd(t *testing.T) {
+	srv := newTestServer(t)
+	srv.Handle("hostnamectl", sshtest.Reply{Stdout: "Static hostname: oss01\n"})
+	srv.Handle("false", sshtest.Reply{Stderr: "failed\n", ExitStatus: 1})
+	conf := &SSHConfig{Host: srv.Addr, User: "root", SSHAuth: SSHAuth{Password: "secret"}}
+
+	for i := 0; i < 3; i++ {
+		output, err := RemoteCmd(context.Background(), conf, "hostnamectl")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(output) != "Static hostname: oss01\n" {
+			t.Errorf("output = %q", output)
+		}
+	}
+	if conns := srv.Conns(); conns != 1 {
+		t.Errorf("pooled client dialed %d times, want 1", conns)
+	}
+
+	_, err := RemoteCmd(context.Background(), conf, "false")
+	var exitErr *ssh.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitStatus() != 1 {
+		t.Errorf("RemoteCmd(false) error = %v, want exit status 1", err)
+	}
+	// a failed command keeps the pooled connection
+	if _, err := RemoteCmd(context.Background(), conf, "hostnamectl"); err != nil {
+		t.Fatal(err)
+	}
+	if conns := srv.Conns(); conns != 1 {
+		t.Errorf("pooled client dialed %d times, want 1", conns)
+	}
+}
+
+func TestRemoteCmdWhileClosing(t *testing.T) {
+	srv := newTestServer(t)
+	srv.Handle("hostnamectl", sshtest.Reply{Stdout: "Static hostname: oss01\n"})
+	conf := &SSHConfig{Host: srv.Addr, User: "root", SSHAuth: SSHAuth{Password: "secret"}}
+
+	// the pooled client is closed while the commands take their sessions,
+	// a command may fail but must not use a cleared client
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			CloseSSHClients()
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				RemoteCmd(context.Background(), conf, "hostnamectl")
+			}
+		}()
+	}
+	wg.Wait()
+	close(stop)
+	<-done
+	if _, err := RemoteCmd(context.Background(), conf, "hostnamectl"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRemoteCmdOptions(t *testing.T) {
+	srv := newTestServer(t)
+	srv.Handle("hostnamectl", sshtest.Reply{Stdout: "Static hostname: oss01\n"})
+	host, port, _ := net.SplitHostPort(srv.Addr)
+	portNum, _ := strconv.Atoi(port)
+	conf := &SSHConfig{Host: host, User: "root", SSHAuth: SSHAuth{Password: "secret"}, Options: SSHOptions{
+		Port:              portNum,
+		ConnectTimeout:    time.Second,
+		KeepaliveInterval: 20 * time.Millisecond,
+		Ciphers:           []string{"aes256-ctr"},
+		KeyExchanges:      []string{"curve25519-sha256"},
+	}}
+	if err := ValidateOptions(&conf.Options); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RemoteCmd(context.Background(), conf, "hostnamectl"); err != nil {
+		t.Fatal(err)
+	}
+	// the answered keepalives keep the pooled client
+	time.Sleep(100 * time.Millisecond)
+	if _, err := RemoteCmd(context.Background(), conf, "hostnamectl"); err != nil {
+		t.Fatal(err)
+	}
+	if conns := srv.Conns(); conns != 1 {
+		t.Errorf("pooled client dialed %d times, want 1", conns)
+	}
+	// changed options need a new connection
+	conf.Options.Ciphers = []string{"chacha20-poly1305@openssh.com"}
+	if _, err := RemoteCmd(context.Background(), conf, "hostnamectl"); err != nil {
+		t.Fatal(err)
+	}
+	if conns := srv.Conns(); conns != 2 {
+		t.Errorf("changed options dialed %d times, want 2", conns)
+	}
+
+	for _, opts := range []SSHOptions{{Port: 65536}, {ConnectTimeout: -time.Second}, {Ciphers: []string{"rot13"}},
+		{KeyExchanges: []string{"curve25519-sha256", "none"}}} {
+		if err := ValidateOptions(&opts); err == nil {
+			t.Errorf("ValidateOptions(%+v) passed", opts)
+		}
+	}
+}
+
+func TestSSHConfigAddress(t *testing.T) {
+	tests := []struct {
+		host string
+		port int
+		want string
+	}{
+		{"192.168.1.10", 0, "192.168.1.10:22"},
+		{"192.168.1.10", 2222, "192.168.1.10:2222"},
+		{"192.168.1.10:2200", 2222, "192.168.1.10:2222"},
+		{"2001:db8::10", 2222, "[2001:db8::10]:2222"},
+		{"[2001:db8::10]:22", 2222, "[2001:db8::10]:2222"},
+	}
+	for _, tt := range tests {
+		conf := &SSHConfig{Host: tt.host, Options: SSHOptions{Port: tt.port}}
+		if got := conf.Address(); got != tt.want {
+			t.Errorf("Address(%q, %d) = %q, want %q", tt.host, tt.port, got, tt.want)
+		}
+	}
+}
+
+func TestRemoteCmdWrongPassword(t *testing.T) {
+	srv := newTestServer(t)
+	conf := &SSHConfig{Host: srv.Addr, User: "root", SSHAuth: SSHAuth{Password: "wrong"}}
+	if _, err := RemoteCmd(context.Background(), conf, "hostnamectl"); err == nil {
+		t.Fatal("RemoteCmd with a wrong password succeeded")
+	}
+}
+
+func TestRemoteCmdCancel(t *testing.T) {
+	srv := newTestServer(t)
+	srv.Handle("sleep", sshtest.Reply{Delay: time.Minute})
+	conf := &SSHConfig{Host: srv.Addr, User: "root", SSHAuth: SSHAuth{Password: "secret"}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := RemoteCmd(ctx, conf, "sleep")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("RemoteCmd error = %v, want deadline exceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("RemoteCmd returned after %v", elapsed)
+	}
+}
+
+func TestHostKeyTrustOnFirstUse(t *testing.T) {
+	srv := newTestServer(t)
+	srv.Handle("true", sshtest.Reply{})
+	conf := &SSHConfig{Host: srv.Addr, User: "root", SSHAuth: SSHAuth{Password: "secret"}}
+
+	if _, err := RemoteCmd(context.Background(), conf, "true"); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := KnownHostKeys(srv.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0].Fingerprint != ssh.FingerprintSHA256(srv.HostKey) {
+		t.Fatalf("KnownHostKeys() = %+v, want the key of the server", keys)
+	}
+
+	// the node is reinstalled with a new host key on the same address
+	CloseSSHClients()
+	srv.Close()
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+	signer, _ := ssh.NewSignerFromKey(priv)
+	srv2, err := sshtest.NewServerWithKey("root", "secret", signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv2.Close()
+	srv2.Handle("true", sshtest.Reply{})
+	if err := AcceptHostKey(srv2.Addr, srv.HostKey); err != nil {
+		t.Fatal(err)
+	}
+	conf.Host = srv2.Addr
+	_, err = RemoteCmd(context.Background(), conf, "true")
+	var mismatchErr *HostKeyMismatchError
+	if !errors.As(err, &mismatchErr) {
+		t.Fatalf("RemoteCmd error = %v, want HostKeyMismatchError", err)
+	}
+	if !strings.Contains(err.Error(), ssh.FingerprintSHA256(srv2.HostKey)) {
+		t.Errorf("error %q does not show the offered fingerprint", err)
+	}
+
+	if err := RevokeHostKey(srv2.Addr); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RemoteCmd(context.Background(), conf, "true"); err != nil {
+		t.Errorf("RemoteCmd after revoking error = %v", err)
+	}
+}
+
+func TestRemoteCmdThroughJumpHost(t *testing.T) {
+	srv := newTestServer(t)
+	srv.Handle("hostname", sshtest.Reply{Stdout: "oss01\n"})
+	bastion, err := sshtest.NewServer("jump", "bastion")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bastion.Close()
+	jump := &SSHConfig{Host: bastion.Addr, User: "jump", SSHAuth: SSHAuth{Password: "bastion"}}
+	conf := &SSHConfig{Host: srv.Addr, User: "root", SSHAuth: SSHAuth{Password: "secret"}, Jumps: []*SSHConfig{jump}}
+
+	output, err := RemoteCmd(context.Background(), conf, "hostname")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(output) != "oss01\n" {
+		t.Errorf("output = %q", output)
+	}
+	if bastion.Conns() != 1 || len(bastion.Commands()) != 0 {
+		t.Errorf("bastion accepted %d connections and ran %q", bastion.Conns(), bastion.Commands())
+	}
+	// the host key is fetched with the port and the algorithms of the node
+	host, port, _ := net.SplitHostPort(srv.Addr)
+	portNum, _ := strconv.Atoi(port)
+	fetchConf := &SSHConfig{
+		Host:    host,
+		Options: SSHOptions{Port: portNum, Ciphers: []string{"aes128-ctr"}},
+		Jumps:   []*SSHConfig{jump},
+	}
+	key, err := FetchHostKey(fetchConf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ssh.FingerprintSHA256(key) != ssh.FingerprintSHA256(srv.HostKey) {
+		t.Errorf("fetched key %s", ssh.FingerprintSHA256(key))
+	}
+	fetchConf.Options.KeyExchanges = []string{"unsupported-kex"}
+	if _, err := FetchHostKey(fetchConf); err == nil {
+		t.Error("the host key was fetched with an unsupported kex")
+	}
+
+	// the wrong credentials of a hop fail the chain
+	CloseSSHClients()
+	jump.Password = "wrong"
+	if _, err := RemoteCmd(context.Background(), conf, "hostname"); err == nil ||
+		!strings.Contains(err.Error(), "jump host") {
+		t.Errorf("RemoteCmd error = %v, want jump host failure", err)
+	}
+}
+
+func TestIsPrivileged(t *testing.T) {
+	tests := map[string]bool{
+		"lnetctl net show":                   true,
+		"nmcli con mod ib0 ipv4.method auto": true,
+		"nmcli connection up ib0":            true,
+		"nmcli con show ib0":                 false,
+		"nmcli":                              false,
+		"hostnamectl":                        false,
+		"lnetctlx":                           false,
+	}
+	for cmd, want := range tests {
+		if got := IsPrivileged(cmd); got != want {
+			t.Errorf("IsPrivileged(%q) = %v, want %v", cmd, got, want)
+		}
+	}
+}
+
+func TestRemoteCmdBecome(t *testing.T) {
+	srv := newTestServerFor(t, "lustre", "secret")
+	conf := &SSHConfig{
+		Host:    srv.Addr,
+		User:    "lustre",
+		SSHAuth: SSHAuth{Password: "secret"},
+		Become:  Become{Method: BecomeSudo, Password: "secret"},
+	}
+	srv.Handle("hostnamectl", sshtest.Reply{Stdout: "Static hostname: oss01\n"})
+	srv.Handle(conf.Become.command("lnetctl net show"), sshtest.Reply{
+		Prompt:   sudoPrompt,
+		Password: "secret",
+		Stdout:   "net:\r\n    - net type: lo\r\n",
+	})
+
+	output, err := RemoteCmd(context.Background(), conf, "lnetctl net show")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(output) != "net:\n    - net type: lo\n" {
+		t.Errorf("output = %q", output)
+	}
+	// the unprivileged commands are not escalated
+	if _, err := RemoteCmd(context.Background(), conf, "hostnamectl"); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"LC_ALL=C sudo -p '[ltool-sudo-password]' sh -c 'lnetctl net show'", "hostnamectl"}
+	if got := srv.Commands(); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("commands = %q, want %q", got, want)
+	}
+
+	conf.Become.Password = "wrong"
+	_, err = RemoteCmd(context.Background(), conf, "lnetctl net show")
+	var refusedErr *BecomeRefusedError
+	if !errors.As(err, &refusedErr) || refusedErr.Reason != "incorrect password" {
+		t.Errorf("RemoteCmd error = %v, want incorrect password", err)
+	}
+
+	conf.Become.Password = ""
+	srv.Handle(conf.Become.command("lnetctl net show"), sshtest.Reply{
+		Stderr:     "sudo: a password is required\r\n",
+		ExitStatus: 1,
+	})
+	_, err = RemoteCmd(context.Background(), conf, "lnetctl net show")
+	if !errors.As(err, &refusedErr) || refusedErr.Reason != "sudo: a password is required" {
+		t.Errorf("RemoteCmd error = %v, want a password is required", err)
+	}
+	// the output of a failed command is not taken for a refusal
+	srv.Handle(conf.Become.command("lnetctl net show"), sshtest.Reply{
+		Stdout:     "Jan 10 sshd: Authentication failure for lustre\r\n",
+		ExitStatus: 1,
+	})
+	_, err = RemoteCmd(context.Background(), conf, "lnetctl net show")
+	if err == nil || errors.As(err, &refusedErr) {
+		t.Errorf("RemoteCmd error = %v, want the exit status", err)
+	}
+
+	// sudo refuses right after the password was entered
+	conf.Become.Password = "secret"
+	srv.Handle(conf.Become.command("lnetctl net show"), sshtest.Reply{
+		Prompt:     sudoPrompt,
+		Password:   "secret",
+		Stderr:     "lustre is not in the sudoers file.\r\n",
+		ExitStatus: 1,
+	})
+	_, err = RemoteCmd(context.Background(), conf, "lnetctl net show")
+	if !errors.As(err, &refusedErr) || refusedErr.Reason != "lustre is not in the sudoers file." {
+		t.Errorf("RemoteCmd error = %v, want not in the sudoers file", err)
+	}
+	srv.Handle(conf.Become.command("lnetctl net show"), sshtest.Reply{
+		Prompt:     sudoPrompt,
+		Password:   "secret",
+		Stdout:     "net:\r\nerror: a password is required by the peer\r\n",
+		ExitStatus: 1,
+	})
+	_, err = RemoteCmd(context.Background(), conf, "lnetctl net show")
+	if err == nil || errors.As(err, &refusedErr) {
+		t.Errorf("RemoteCmd error = %v, want the exit status", err)
+	}
+	// the command prints a sudo message itself after the password was accepted
+	srv.Handle(conf.Become.command("lnetctl net show"), sshtest.Reply{
+		Prompt:     sudoPrompt,
+		Password:   "secret",
+		Stdout:     "net:\r\nsudo: a password is required\r\n",
+		ExitStatus: 1,
+	})
+	_, err = RemoteCmd(context.Background(), conf, "lnetctl net show")
+	if err == nil || errors.As(err, &refusedErr) {
+		t.Errorf("RemoteCmd error = %v, want the exit status", err)
+	}
+	conf.Become.Password = ""
+	srv.Handle(conf.Become.command("lnetctl net show"), sshtest.Reply{
+		Stdout:     "net:\r\nsudo: a terminal is required to read the password\r\n",
+		ExitStatus: 1,
+	})
+	_, err = RemoteCmd(context.Background(), conf, "lnetctl net show")
+	if err == nil || errors.As(err, &refusedErr) {
+		t.Errorf("RemoteCmd error = %v, want the exit status", err)
+	}
+}
+
+func TestReach(t *testing.T) {
+	srv := newTestServer(t)
+	conf := &SSHConfig{Host: srv.Addr, User: "root", SSHAuth: SSHAuth{Password: "secret"}}
+
+	reach, err := Reach(context.Background(), conf, []string{ProbeTCP})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reach.Reachable || reach.Probe != ProbeTCP || reach.Latency <= 0 {
+		t.Errorf("tcp probe = %+v", reach)
+	}
+	reach, err = Reach(context.Background(), conf, []string{ProbeSSH})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reach.Reachable || reach.Probe != ProbeSSH {
+		t.Errorf("ssh probe = %+v", reach)
+	}
+
+	// the failed probe falls back to the next one
+	wrong := *conf
+	wrong.Password = "wrong"
+	reach, err = Reach(context.Background(), &wrong, []string{ProbeSSH, ProbeTCP})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reach.Probe != ProbeTCP {
+		t.Errorf("fallback probe = %+v", reach)
+	}
+
+	// a refused connection proves the node is up
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := &SSHConfig{Host: listener.Addr().String()}
+	listener.Close()
+	reach, err = Reach(context.Background(), closed, []string{ProbeTCP})
+	if err != nil || !reach.Reachable {
+		t.Errorf("refused tcp probe = %+v, %v", reach, err)
+	}
+
+	if _, err := Reach(context.Background(), conf, []string{"arp"}); err == nil {
+		t.Error("unsupported probe did not fail")
+	}
+}
+
+func TestRemoteRun(t *testing.T) {
+	srv := newTestServer(t)
+	srv.Handle("uptime", sshtest.Reply{Stdout: "up 3 days\n"})
+	srv.Handle("ls /nonexistent", sshtest.Reply{Stderr: "ls: cannot access '/nonexistent'\n", ExitStatus: 2})
+	conf := &SSHConfig{Host: srv.Addr, User: "root", SSHAuth: SSHAuth{Password: "secret"}}
+
+	result, err := RemoteRun(context.Background(), conf, "uptime")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(result.Stdout) != "up 3 days\n" || len(result.Stderr) != 0 || result.ExitStatus != 0 {
+		t.Errorf("result = %+v", result)
+	}
+	result, err = RemoteRun(context.Background(), conf, "ls /nonexistent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ExitStatus != 2 || string(result.Stderr) != "ls: cannot access '/nonexistent'\n" || len(result.Stdout) != 0 {
+		t.Errorf("result = %+v", result)
+	}
+}
+
+// readUntil reads the output of the shell until it contains want
+func readUntil(t *testing.T, r io.Reader, want string) string {
+	t.Helper()
+	var out []byte
+	buf := make([]byte, 256)
+	for !strings.Contains(string(out), want) {
+		n, err := r.Read(buf)
+		out = append(out, buf[:n]...)
+		if err != nil {
+			t.Fatalf("read %q, %v", out, err)
+		}
+	}
+	return string(out)
+}
+
+func TestOpenShell(t *testing.T) {
+	srv := newTestServer(t)
+	srv.Handle("hostname", sshtest.Reply{Stdout: "oss01\r\n"})
+	conf := &SSHConfig{Host: srv.Addr, User: "root", SSHAuth: SSHAuth{Password: "secret"}}
+
+	shell, err := OpenShell(context.Background(), conf, 80, 24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer shell.Close()
+	readUntil(t, shell, "$ ")
+	if _, err := shell.Write([]byte("hostname\r")); err != nil {
+		t.Fatal(err)
+	}
+	if out := readUntil(t, shell, "oss01\r\n$ "); !strings.Contains(out, "hostname\r\n") {
+		t.Errorf("the typed command is not echoed, %q", out)
+	}
+	if err := shell.Resize(120, 40); err != nil {
+		t.Fatal(err)
+	}
+	shell.Write([]byte("exit\r"))
+	if _, err := io.ReadAll(shell); err != nil {
+		t.Errorf("read until exit error, %v", err)
+	}
+	if err := shell.Wait(); err != nil {
+		t.Errorf("wait shell error, %v", err)
+	}
+	if windows := srv.Windows(); !slices.Equal(windows, []string{"80x24", "120x40"}) {
+		t.Errorf("windows = %v", windows)
+	}
+	// the session slot is released, the connection is reused
+	if _, err := RemoteCmd(context.Background(), conf, "hostname"); err != nil {
+		t.Fatal(err)
+	}
+	if srv.Conns() != 1 {
+		t.Errorf("conns = %d, want 1", srv.Conns())
+	}
+}
+
+func TestSFTP(t *testing.T) {
+	srv := newTestServer(t)
+	conf := &SSHConfig{Host: srv.Addr, User: "root", SSHAuth: SSHAuth{Password: "secret"}}
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "modprobe.d"), 0o755)
+	os.WriteFile(filepath.Join(dir, "lnet.conf"), []byte("net:\n"), 0o600)
+
+	files, err := ListDir(context.Background(), conf, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || files[0].Name != "modprobe.d" || !files[0].IsDir || files[1].Name != "lnet.conf" || files[1].Size != 5 {
+		t.Errorf("files = %+v", files)
+	}
+
+	// upload replaces the file through the verified part file and keeps its mode
+	content := "options lnet networks=o2ib0(ib0)\n"
+	target := filepath.Join(dir, "lnet.conf")
+	sum := sha256.Sum256([]byte(content))
+	transfer, err := Upload(context.Background(), conf, strings.NewReader(content), target, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transfer.Sum != hex.EncodeToString(sum[:]) || transfer.Size != int64(len(content)) {
+		t.Errorf("transfer = %+v", transfer)
+	}
+	if data, _ := os.ReadFile(target); string(data) != content {
+		t.Errorf("uploaded content = %q", data)
+	}
+	if info, _ := os.Stat(target); info.Mode().Perm() != 0o600 {
+		t.Errorf("mode = %v, want 0600", info.Mode().Perm())
+	}
+	if _, err := os.Stat(target + partSuffix); !os.IsNotExist(err) {
+		t.Errorf("the part file is left, %v", err)
+	}
+
+	var buf bytes.Buffer
+	if _, err := Download(context.Background(), conf, target, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != content {
+		t.Errorf("downloaded content = %q", buf.String())
+	}
+
+	// a corrupted upload keeps the original file
+	srv.Handle("sha256sum -- '"+target+partSuffix+"'", sshtest.Reply{Stdout: "0000  x\n"})
+	_, err = Upload(context.Background(), conf, strings.NewReader("broken"), target, 0o644)
+	var sumErr *ChecksumError
+	if !errors.As(err, &sumErr) {
+		t.Fatalf("err = %v, want ChecksumError", err)
+	}
+	if sumErr.Path != target+partSuffix || sumErr.Remote != "0000" {
+		t.Errorf("checksum error = %+v", sumErr)
+	}
+	if data, _ := os.ReadFile(target); string(data) != content {
+		t.Errorf("the file was replaced, %q", data)
+	}
+	if _, err := os.Stat(target + partSuffix); !os.IsNotExist(err) {
+		t.Errorf("the rejected part file is left, %v", err)
+	}
+
+	// a download differing from the file on the node is rejected too
+	srv.Handle("sha256sum -- '"+target+"'", sshtest.Reply{Stdout: "0000  x\n"})
+	if _, err := Download(context.Background(), conf, target, io.Discard); !errors.As(err, &sumErr) {
+		t.Errorf("err = %v, want ChecksumError", err)
+	}
+}
+
+func TestSFTPCommand(t *testing.T) {
+	script := `'for p in /usr/libexec/openssh/sftp-server /usr/lib/openssh/sftp-server /usr/lib/ssh/sftp-server /usr/libexec/sftp-server; ` +
+		`do if [ -x "$p" ]; then echo ltool-sftp-ready; exec "$p"; fi; done; echo "sftp-server is not found" >&2; exit 127'`
+	tests := []struct {
+		become Become
+		want   string
+	}{
+		{Become{Method: BecomeSudo}, "LC_ALL=C sudo -n sh -c " + script},
+		{Become{Method: BecomeSudo, Password: "secret"}, "LC_ALL=C sudo -p '[ltool-sudo-password]' sh -c " + script},
+		{Become{Method: BecomeSu, Password: "secret"}, "LC_ALL=C su - root -c " + script},
+	}
+	for _, tt := range tests {
+		if got := tt.become.sftpCommand(); got != tt.want {
+			t.Errorf("sftpCommand(%s) = %s, want %s", tt.become.Method, got, tt.want)
+		}
+	}
+}
+
+func TestSFTPBecome(t *testing.T) {
+	srv := newTestServerFor(t, "lustre", "secret")
+	conf := &SSHConfig{
+		Host:    srv.Addr,
+		User:    "lustre",
+		SSHAuth: SSHAuth{Password: "secret"},
+		Become:  Become{Method: BecomeSudo, Password: "secret"},
+	}
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "lnet.conf"), []byte("net:\n"), 0o600)
+	srv.Handle(conf.Become.sftpCommand(), sshtest.Reply{
+		Prompt:   sudoPrompt,
+		Password: "secret",
+		Stdout:   sftpReady + "\r\n",
+		SFTP:     true,
+	})
+
+	files, err := ListDir(context.Background(), conf, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Name != "lnet.conf" {
+		t.Errorf("files = %+v", files)
+	}
+
+	// the checksum is verified as root too
+	content := "options lnet networks=o2ib0(ib0)\n"
+	target := filepath.Join(dir, "lnet.conf")
+	sum := sha256.Sum256([]byte(content))
+	sumCmd := conf.Become.command("sha256sum -- '" + target + partSuffix + "'")
+	srv.Handle(sumCmd, sshtest.Reply{
+		Prompt:   sudoPrompt,
+		Password: "secret",
+		Stdout:   hex.EncodeToString(sum[:]) + "  " + target + partSuffix + "\r\n",
+	})
+	if _, err := Upload(context.Background(), conf, strings.NewReader(content), target, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(target); string(data) != content {
+		t.Errorf("uploaded content = %q", data)
+	}
+	if got := srv.Commands(); !slices.Contains(got, conf.Become.sftpCommand()) || !slices.Contains(got, sumCmd) {
+		t.Errorf("commands = %q", got)
+	}
+
+	// su answers the prompt with the password of root
+	conf.Become = Become{Method: BecomeSu, Password: "rootpw"}
+	srv.Handle(conf.Become.sftpCommand(), sshtest.Reply{
+		Prompt:   "Password: ",
+		Password: "rootpw",
+		Stdout:   sftpReady + "\r\n",
+		SFTP:     true,
+	})
+	if files, err := ListDir(context.Background(), conf, dir); err != nil || len(files) != 1 {
+		t.Errorf("ListDir through su = %+v, %v", files, err)
+	}
+
+	conf.Become = Become{Method: BecomeSudo, Password: "wrong"}
+	_, err = ListDir(context.Background(), conf, dir)
+	var refusedErr *BecomeRefusedError
+	if !errors.As(err, &refusedErr) || refusedErr.Reason != "incorrect password" {
+		t.Errorf("ListDir error = %v, want incorrect password", err)
+	}
+
+	conf.Become.Password = ""
+	srv.Handle(conf.Become.sftpCommand(), sshtest.Reply{
+		Stderr:     "sudo: a password is required\n",
+		ExitStatus: 1,
+	})
+	_, err = ListDir(context.Background(), conf, dir)
+	if !errors.As(err, &refusedErr) || refusedErr.Reason != "sudo: a password is required" {
+		t.Errorf("ListDir error = %v, want a password is required", err)
+	}
+}
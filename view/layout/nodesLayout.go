@@ -16,8 +16,8 @@ func (n *NodeRecordsGrid) MinSize(objects []fyne.CanvasObject) fyne.Size {
 
 func (n *NodeRecordsGrid) Layout(objects []fyne.CanvasObject, size fyne.Size) {
 	x := 0
-	// ip/user/password/status/arch/kernel
-	widths := []int{120, 100, 150, 80, 60, int(size.Width) - 490}
+	// ip/user/auth/credential/status/hostname/group and tags/kernel
+	widths := []int{120, 100, 100, 150, 150, 60, 120, int(size.Width) - 800}
 	for i, o := range objects {
 		w := widths[i]
 		o.Resize(fyne.NewSize(float32(w), size.Height))
@@ -21,9 +21,10 @@ var (
 		"lustre": {"Lustre", createFirstScreen},
 		"node":   {"Node", NewNodesUI},
 		"net":    {"Net", NewNetMainUI},
+		"files":  {"Files", NewFilesUI},
 	}
 	NaviItemsIndex = map[string][]string{
-		"":       {"node", "lustre"},
+		"":       {"node", "files", "lustre"},
 		"lustre": {"net"},
 	}
 )
@@ -1,7 +1,227 @@
 package state
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/luo2pei4/ltool/pkg/dblayer"
+	"github.com/luo2pei4/ltool/pkg/utils"
+	"gorm.io/gorm"
+)
+
 type SSHConnection struct {
 	IPAddress string
 	User      string
-	Password  string
+	utils.SSHAuth
+	Become  utils.Become
+	Options utils.SSHOptions
+	Jumps   []*utils.SSHConfig
+	Group   string
+}
+
+// Config converts the connection to the remote command parameters
+func (c *SSHConnection) Config() *utils.SSHConfig {
+	return &utils.SSHConfig{
+		Host:    c.IPAddress,
+		User:    c.User,
+		SSHAuth: c.SSHAuth,
+		Become:  c.Become,
+		Options: c.Options,
+		Jumps:   c.Jumps,
+	}
+}
+
+// loadConnections returns the saved nodes and their connections,
+// key of the map is the ip address
+func loadConnections() ([]string, map[string]SSHConnection, error) {
+	repoNodes, err := dblayer.DB.ListNodes(dblayer.NodeQuery{})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+	proxyJumps := make([]string, 0, len(repoNodes))
+	for _, repoNode := range repoNodes {
+		proxyJumps = append(proxyJumps, repoNode.ProxyJump)
+	}
+	jumps, err := resolveJumps(proxyJumps)
+	if err != nil {
+		return nil, nil, err
+	}
+	nodeList := make([]string, 0, len(repoNodes))
+	conns := make(map[string]SSHConnection, len(repoNodes))
+	for _, repoNode := range repoNodes {
+		nodeList = append(nodeList, repoNode.IPAddress)
+		conns[repoNode.IPAddress] = SSHConnection{
+			IPAddress: repoNode.IPAddress,
+			User:      repoNode.UserName,
+			SSHAuth:   repoNodeAuth(&repoNode),
+			Become:    repoNodeBecome(&repoNode),
+			Options:   repoNodeOptions(&repoNode),
+			Jumps:     jumps[repoNode.ProxyJump],
+			Group:     repoNode.GroupName,
+		}
+	}
+	return nodeList, conns, nil
+}
+
+// CancelledError reports what finished before an operation was cancelled
+type CancelledError struct {
+	Finished  []string
+	Cancelled []string
+}
+
+func (e *CancelledError) Error() string {
+	return fmt.Sprintf("operation was cancelled, finished: [%s], cancelled: [%s]",
+		strings.Join(e.Finished, ", "), strings.Join(e.Cancelled, ", "))
+}
+
+func (e *CancelledError) Unwrap() error {
+	return context.Canceled
+}
+
+// cancelledAt converts err to CancelledError if ctx was cancelled by the user
+func cancelledAt(ctx context.Context, err error, finished []string, cancelled ...string) error {
+	if !errors.Is(ctx.Err(), context.Canceled) {
+		return err
+	}
+	return &CancelledError{Finished: finished, Cancelled: cancelled}
+}
+
+func isCancelled(err error) bool {
+	var cancelledErr *CancelledError
+	return errors.As(err, &cancelledErr)
+}
+
+// stepRunner runs the commands of an operation one by one,
+// and records the finished steps for the cancellation report.
+type stepRunner struct {
+	ctx      context.Context
+	exec     utils.Executor
+	conf     *utils.SSHConfig
+	finished []string
+}
+
+func newStepRunner(ctx context.Context, exec utils.Executor, conf *utils.SSHConfig) *stepRunner {
+	return &stepRunner{ctx: ctx, exec: exec, conf: conf}
+}
+
+func (r *stepRunner) cmd(step, cmd string) ([]byte, error) {
+	output, err := r.exec.RemoteCmd(r.ctx, r.conf, cmd)
+	if err != nil {
+		return nil, cancelledAt(r.ctx, err, r.finished, step)
+	}
+	r.finished = append(r.finished, step)
+	return output, nil
+}
+
+// forEachBounded calls fn for the items 0..total-1 with at most workers
+// goroutines, done is called on the calling goroutine after each item.
+func forEachBounded(total, workers int, fn func(i int), done func(i int)) {
+	if total == 0 {
+		return
+	}
+	jobs := make(chan int)
+	finished := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(max(workers, 1), total); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				fn(i)
+				finished <- i
+			}
+		}()
+	}
+	go func() {
+		for i := 0; i < total; i++ {
+			jobs <- i
+		}
+		close(jobs)
+		wg.Wait()
+		close(finished)
+	}()
+	for i := range finished {
+		done(i)
+	}
+}
+
+// nodeResult the outcome of a node of forEachNode
+type nodeResult[T any] struct {
+	IP    string
+	Value T
+	Err   error
+}
+
+// forEachNode calls fn for the nodes with at most workers goroutines, the
+// nodes still queued when ctx is done fail with ctx.Err() without calling
+// fn. onDone is called on the calling goroutine after each node with the
+// count of the done nodes. The results of the cancelled nodes are left out
+// and the others are sorted by ip address, CancelledError is returned with
+// them if ctx was cancelled.
+func forEachNode[T any](ctx context.Context, ips []string, workers int, fn func(i int) (T, error),
+	onDone func(i, done int, err error)) ([]nodeResult[T], error) {
+
+	results := make([]nodeResult[T], len(ips))
+	done := 0
+	var finished, cancelled []string
+	forEachBounded(len(ips), workers, func(i int) {
+		results[i].IP = ips[i]
+		if err := ctx.Err(); err != nil {
+			results[i].Err = err
+			return
+		}
+		results[i].Value, results[i].Err = fn(i)
+	}, func(i int) {
+		if errors.Is(results[i].Err, context.Canceled) {
+			cancelled = append(cancelled, ips[i])
+		} else {
+			finished = append(finished, ips[i])
+		}
+		done++
+		if onDone != nil {
+			onDone(i, done, results[i].Err)
+		}
+	})
+
+	kept := results[:0]
+	for _, result := range results {
+		if !errors.Is(result.Err, context.Canceled) {
+			kept = append(kept, result)
+		}
+	}
+	sort.Slice(kept, func(i, j int) bool {
+		return ipLess(kept[i].IP, kept[j].IP)
+	})
+	if len(cancelled) > 0 {
+		sortIPs(finished)
+		sortIPs(cancelled)
+		return kept, &CancelledError{Finished: finished, Cancelled: cancelled}
+	}
+	return kept, nil
+}
+
+// connIPs returns the ip addresses of the connections
+func connIPs(conns []SSHConnection) []string {
+	ips := make([]string, len(conns))
+	for i, conn := range conns {
+		ips[i] = conn.IPAddress
+	}
+	return ips
+}
+
+// progressOf adapts onProgress to forEachNode
+func progressOf(onProgress func(done, total int), total int) func(i, done int, err error) {
+	if onProgress == nil {
+		return nil
+	}
+	return func(_, done int, _ error) {
+		onProgress(done, total)
+	}
 }
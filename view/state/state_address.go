@@ -0,0 +1,102 @@
+package state
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/luo2pei4/ltool/pkg/inventory"
+	"github.com/luo2pei4/ltool/pkg/utils"
+)
+
+// LargeExpansion adding more nodes at once needs to be confirmed
+const LargeExpansion = 256
+
+// NodeAddress an address of a node to add and the name it was resolved from
+type NodeAddress struct {
+	IP       string
+	Hostname string
+}
+
+// ipSpec matches the ipv4 addresses, ranges and cidr blocks and the ipv6
+// addresses of utils.ExpandIP
+var ipSpec = regexp.MustCompile(`^([0-9./-]+|.*:.*)$`)
+
+// splitAddresses expands the comma separated addresses, ranges, cidr
+// blocks and node sets like oss[01-16] of spec. The ip addresses are
+// returned in ips, the host names to be resolved in names.
+func splitAddresses(spec string) (ips, names []string, err error) {
+	parts, err := inventory.ExpandNodeSet(strings.TrimSpace(spec))
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, part := range parts {
+		if !ipSpec.MatchString(part) {
+			names = append(names, part)
+			continue
+		}
+		expanded, err := utils.ExpandIP(part)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %v", part, err)
+		}
+		ips = append(ips, expanded...)
+	}
+	if len(ips)+len(names) == 0 {
+		return nil, nil, fmt.Errorf("no ip address or host name")
+	}
+	if len(ips)+len(names) > inventory.MaxNodeSetSize {
+		return nil, nil, fmt.Errorf("%s has more than %d nodes", spec, inventory.MaxNodeSetSize)
+	}
+	return ips, names, nil
+}
+
+// CountAddresses returns the number of the nodes spec expands to, the
+// host names are not resolved.
+func CountAddresses(spec string) (int, error) {
+	ips, names, err := splitAddresses(spec)
+	return len(ips) + len(names), err
+}
+
+// ExpandAddresses expands spec like splitAddresses and resolves the host
+// names, the duplicate addresses are removed.
+func ExpandAddresses(ctx context.Context, spec string, lookup Lookup) ([]NodeAddress, error) {
+	ips, names, err := splitAddresses(spec)
+	if err != nil {
+		return nil, err
+	}
+	resolved := make([]string, len(names))
+	errs := make([]error, len(names))
+	forEachBounded(len(names), lookupWorkers, func(i int) {
+		resolved[i], errs[i] = resolveHost(ctx, lookup, names[i])
+	}, func(int) {})
+
+	var failed []string
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err.Error())
+		}
+	}
+	if len(failed) > 0 {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%s", strings.Join(failed, "\n"))
+	}
+
+	seen := make(map[string]bool, len(ips)+len(names))
+	addrs := make([]NodeAddress, 0, len(ips)+len(names))
+	for _, ip := range ips {
+		if !seen[ip] {
+			seen[ip] = true
+			addrs = append(addrs, NodeAddress{IP: ip})
+		}
+	}
+	for i, ip := range resolved {
+		if !seen[ip] {
+			seen[ip] = true
+			addrs = append(addrs, NodeAddress{IP: ip, Hostname: names[i]})
+		}
+	}
+	return addrs, nil
+}
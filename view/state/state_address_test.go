@@ -0,0 +1,53 @@
+package state
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestExpandAddresses(t *testing.T) {
+	hosts := map[string]string{"oss01": "192.168.2.1", "oss02": "192.168.2.2", "mds1": "192.168.1.1"}
+	lookup := func(ctx context.Context, host string) ([]string, error) {
+		if ip, ok := hosts[host]; ok {
+			return []string{ip}, nil
+		}
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+
+	addrs, err := ExpandAddresses(context.Background(), "192.168.1.0/30, 192.168.1.255-192.168.2.0,oss[01-02],mds1", lookup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []NodeAddress{
+		{IP: "192.168.1.1"},
+		{IP: "192.168.1.2"},
+		{IP: "192.168.1.255"},
+		{IP: "192.168.2.0"},
+		{IP: "192.168.2.1", Hostname: "oss01"},
+		{IP: "192.168.2.2", Hostname: "oss02"},
+	}
+	if !reflect.DeepEqual(addrs, want) {
+		t.Errorf("addresses are %v, want %v", addrs, want)
+	}
+
+	if count, err := CountAddresses("10.0.0.0/24,oss[01-16]"); err != nil || count != 270 {
+		t.Errorf("count is %d, %v, want 270", count, err)
+	}
+	for _, spec := range []string{"", "10.0.0.0/8", "10.0.0.5-1", "10.0.0.256", "oss[01-"} {
+		if _, err := CountAddresses(spec); err == nil {
+			t.Errorf("%q was counted", spec)
+		}
+	}
+	addrs, err = ExpandAddresses(context.Background(), "2001:DB8::1,mgs", func(ctx context.Context, host string) ([]string, error) {
+		return []string{"2001:db8::2", "::ffff:192.168.1.3"}, nil
+	})
+	want = []NodeAddress{{IP: "2001:db8::1"}, {IP: "192.168.1.3", Hostname: "mgs"}}
+	if err != nil || !reflect.DeepEqual(addrs, want) {
+		t.Errorf("addresses are %v, %v, want %v", addrs, err, want)
+	}
+	if _, err := ExpandAddresses(context.Background(), "oss01,oss03", lookup); err == nil || err.Error() != "unknown host oss03" {
+		t.Errorf("unknown host error is %v", err)
+	}
+}
@@ -0,0 +1,150 @@
+package state
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/luo2pei4/ltool/pkg/dblayer"
+	"github.com/luo2pei4/ltool/pkg/dblayer/repo"
+	"github.com/luo2pei4/ltool/pkg/utils"
+)
+
+// SettingNodeSearch the cluster setting of the last search of the nodes
+const SettingNodeSearch = "node_search"
+
+// ClustersState the Lustre filesystems, the nodes, jump hosts and cluster
+// settings of the other states belong to the active one
+type ClustersState struct {
+	sync.RWMutex
+	Records []repo.Cluster
+}
+
+func (c *ClustersState) LoadAllRecords() error {
+	records, err := dblayer.DB.ListClusters()
+	if err != nil {
+		return err
+	}
+	c.Lock()
+	defer c.Unlock()
+	c.Records = records
+	return nil
+}
+
+// Names returns the names of the clusters in order
+func (c *ClustersState) Names() []string {
+	c.RLock()
+	defer c.RUnlock()
+	names := make([]string, 0, len(c.Records))
+	for _, rec := range c.Records {
+		names = append(names, rec.Name)
+	}
+	return names
+}
+
+// Find returns the cluster with the name
+func (c *ClustersState) Find(name string) (repo.Cluster, bool) {
+	c.RLock()
+	defer c.RUnlock()
+	for _, rec := range c.Records {
+		if rec.Name == name {
+			return rec, true
+		}
+	}
+	return repo.Cluster{}, false
+}
+
+// Active returns the active cluster
+func (c *ClustersState) Active() repo.Cluster {
+	id := dblayer.DB.ClusterID()
+	c.RLock()
+	defer c.RUnlock()
+	for _, rec := range c.Records {
+		if rec.ID == id {
+			return rec
+		}
+	}
+	return repo.Cluster{ID: id}
+}
+
+// Switch makes the cluster with the name active
+func (c *ClustersState) Switch(name string) error {
+	rec, ok := c.Find(name)
+	if !ok {
+		return fmt.Errorf("cluster %s does not exist", name)
+	}
+	dblayer.DB.UseCluster(rec.ID)
+	return nil
+}
+
+// Save validates and saves the cluster, it is added if its id is 0
+func (c *ClustersState) Save(rec repo.Cluster) error {
+	rec.Name = strings.TrimSpace(rec.Name)
+	if rec.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if other, ok := c.Find(rec.Name); ok && other.ID != rec.ID {
+		return fmt.Errorf("cluster %s exists", rec.Name)
+	}
+	if rec.UserName != "" {
+		auth := clusterAuth(&rec)
+		if err := utils.ValidateAuth(&auth); err != nil {
+			return err
+		}
+	}
+	if err := dblayer.DB.SaveCluster(&rec); err != nil {
+		return err
+	}
+	return c.LoadAllRecords()
+}
+
+// Delete deletes the cluster with its nodes, the active cluster is kept
+func (c *ClustersState) Delete(id int) error {
+	if id == dblayer.DB.ClusterID() {
+		return fmt.Errorf("the active cluster cannot be deleted, switch to another one first")
+	}
+	if err := dblayer.DB.DeleteCluster(id); err != nil {
+		return err
+	}
+	return c.LoadAllRecords()
+}
+
+// Defaults returns the default user and credentials of the active cluster
+func (c *ClustersState) Defaults() (string, utils.SSHAuth) {
+	rec := c.Active()
+	return rec.UserName, clusterAuth(&rec)
+}
+
+func clusterAuth(rec *repo.Cluster) utils.SSHAuth {
+	authType := rec.AuthType
+	if authType == "" {
+		authType = utils.AuthPassword
+	}
+	return utils.SSHAuth{
+		AuthType:   authType,
+		Password:   rec.Password,
+		KeyFile:    rec.KeyFile,
+		Passphrase: rec.Passphrase,
+		CertFile:   rec.CertFile,
+	}
+}
+
+// CopyCheckedNodes copies the checked saved nodes to the cluster, it
+// returns the number of the copied nodes, the new records are not copied.
+func (n *NodesState) CopyCheckedNodes(to int) (int, error) {
+	if to == dblayer.DB.ClusterID() {
+		return 0, fmt.Errorf("the nodes are in the cluster already")
+	}
+	n.RLock()
+	var ips []string
+	for _, rec := range n.Records {
+		if rec.Checked && !rec.NewRec {
+			ips = append(ips, rec.IP)
+		}
+	}
+	n.RUnlock()
+	if len(ips) == 0 {
+		return 0, fmt.Errorf("no saved node is checked")
+	}
+	return dblayer.DB.CopyNodes(ips, to)
+}
@@ -0,0 +1,140 @@
+package state
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+
+	logger "github.com/luo2pei4/ltool/pkg/log"
+	"github.com/luo2pei4/ltool/pkg/utils"
+)
+
+// FileVersion a content of the compared file and the nodes having it
+type FileVersion struct {
+	Sum     string
+	Size    int64
+	Mode    fs.FileMode
+	Content string
+	IPs     []string
+}
+
+// Name is the short name of the version used in the diffs
+func (v *FileVersion) Name() string {
+	return fmt.Sprintf("%s (%d nodes)", v.Sum[:12], len(v.IPs))
+}
+
+// DriftReport the versions of a file across the nodes
+type DriftReport struct {
+	Path     string
+	Versions []FileVersion    // the most common first
+	Failed   []TransferResult // the nodes the file could not be read from, e.g. it is missing
+}
+
+// CompareFile downloads remotePath of the nodes with at most workers at the
+// same time and groups the nodes by the checksum of the content.
+// CancelledError is returned with the report of the finished nodes if ctx
+// was cancelled.
+func CompareFile(ctx context.Context, exec utils.Executor, conns []SSHConnection, remotePath string,
+	workers int, onProgress func(done, total int)) (*DriftReport, error) {
+
+	type download struct {
+		transfer *utils.Transfer
+		content  string
+	}
+	nodes, err := forEachNode(ctx, connIPs(conns), workers, func(i int) (download, error) {
+		var buf bytes.Buffer
+		transfer, err := exec.Download(ctx, conns[i].Config(), remotePath, &buf)
+		if err != nil {
+			logger.Errorf("download %s:%s failed, %v", conns[i].IPAddress, remotePath, err)
+			return download{}, err
+		}
+		return download{transfer: transfer, content: buf.String()}, nil
+	}, progressOf(onProgress, len(conns)))
+
+	report := &DriftReport{Path: remotePath}
+	index := make(map[string]int)
+	for _, node := range nodes {
+		if node.Err != nil {
+			report.Failed = append(report.Failed, TransferResult{IP: node.IP, Path: remotePath, Err: node.Err.Error()})
+			continue
+		}
+		d := node.Value
+		if j, ok := index[d.transfer.Sum]; ok {
+			report.Versions[j].IPs = append(report.Versions[j].IPs, node.IP)
+			continue
+		}
+		index[d.transfer.Sum] = len(report.Versions)
+		report.Versions = append(report.Versions, FileVersion{
+			Sum:     d.transfer.Sum,
+			Size:    d.transfer.Size,
+			Mode:    d.transfer.Mode,
+			Content: d.content,
+			IPs:     []string{node.IP},
+		})
+	}
+	for i := range report.Versions {
+		sortIPs(report.Versions[i].IPs)
+	}
+	sort.SliceStable(report.Versions, func(i, j int) bool {
+		if len(report.Versions[i].IPs) != len(report.Versions[j].IPs) {
+			return len(report.Versions[i].IPs) > len(report.Versions[j].IPs)
+		}
+		return ipLess(report.Versions[i].IPs[0], report.Versions[j].IPs[0])
+	})
+	return report, err
+}
+
+// Drifted returns the nodes without the version, including the nodes
+// the file could not be read from.
+func (r *DriftReport) Drifted(version int) []string {
+	var ips []string
+	for i, v := range r.Versions {
+		if i != version {
+			ips = append(ips, v.IPs...)
+		}
+	}
+	for _, result := range r.Failed {
+		ips = append(ips, result.IP)
+	}
+	sortIPs(ips)
+	return ips
+}
+
+// Diffs returns the unified diffs from the version to each other version
+func (r *DriftReport) Diffs(version int) string {
+	base := &r.Versions[version]
+	var b strings.Builder
+	for i := range r.Versions {
+		if i == version {
+			continue
+		}
+		other := &r.Versions[i]
+		fmt.Fprintf(&b, "# %s: %s\n", other.Name(), strings.Join(other.IPs, ","))
+		b.WriteString(UnifiedDiff(base.Name(), other.Name(), base.Content, other.Content))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// PushFile writes the version to remotePath of the nodes with at most
+// workers at the same time, see UploadFile for the results.
+func PushFile(ctx context.Context, exec utils.Executor, conns []SSHConnection, remotePath string, version *FileVersion,
+	workers int, onProgress func(done, total int)) ([]TransferResult, error) {
+
+	perm := version.Mode
+	if perm == 0 {
+		perm = 0o644
+	}
+	return transferToNodes(ctx, conns, remotePath, workers, onProgress, func(conn SSHConnection) (*utils.Transfer, error) {
+		transfer, err := exec.Upload(ctx, conn.Config(), strings.NewReader(version.Content), remotePath, perm)
+		if err != nil {
+			logger.Errorf("push %s to %s failed, %v", remotePath, conn.IPAddress, err)
+			return nil, err
+		}
+		logger.Infof("pushed %s to %s, sha256 %s", remotePath, conn.IPAddress, transfer.Sum)
+		return transfer, nil
+	})
+}
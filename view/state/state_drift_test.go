@@ -0,0 +1,106 @@
+package state
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/luo2pei4/ltool/pkg/utils"
+)
+
+// fileExecutor keeps a file per node in memory
+type fileExecutor struct {
+	*testExecutor
+	mu    sync.Mutex
+	files map[string]string // key: ip address
+}
+
+func (e *fileExecutor) Download(ctx context.Context, conf *utils.SSHConfig, remotePath string, w io.Writer) (*utils.Transfer, error) {
+	e.mu.Lock()
+	content, ok := e.files[conf.Host]
+	e.mu.Unlock()
+	if !ok {
+		return nil, errors.New("open " + remotePath + " failed, file does not exist")
+	}
+	io.WriteString(w, content)
+	sum := sha256.Sum256([]byte(content))
+	return &utils.Transfer{Size: int64(len(content)), Sum: hex.EncodeToString(sum[:]), Mode: 0o644}, nil
+}
+
+func (e *fileExecutor) Upload(ctx context.Context, conf *utils.SSHConfig, r io.Reader, remotePath string, perm fs.FileMode) (*utils.Transfer, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	e.mu.Lock()
+	e.files[conf.Host] = string(data)
+	e.mu.Unlock()
+	sum := sha256.Sum256(data)
+	return &utils.Transfer{Size: int64(len(data)), Sum: hex.EncodeToString(sum[:]), Mode: perm}, nil
+}
+
+func TestCompareFile(t *testing.T) {
+	good := "net  o2ib  ib0\n"
+	exec := &fileExecutor{
+		testExecutor: &testExecutor{},
+		files: map[string]string{
+			"192.168.1.10": good,
+			"192.168.1.11": good,
+			"192.168.1.12": "net  tcp  eth0\n",
+			"192.168.1.2":  good,
+		},
+	}
+	var conns []SSHConnection
+	for _, ip := range []string{"192.168.1.12", "192.168.1.11", "192.168.1.13", "192.168.1.10", "192.168.1.2"} {
+		conn := testConn()
+		conn.IPAddress = ip
+		conns = append(conns, conn)
+	}
+	report, err := CompareFile(context.Background(), exec, conns, "/etc/lnet.conf", 2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Versions) != 2 || len(report.Failed) != 1 || report.Failed[0].IP != "192.168.1.13" {
+		t.Fatalf("report = %+v", report)
+	}
+	if got := strings.Join(report.Versions[0].IPs, ","); got != "192.168.1.2,192.168.1.10,192.168.1.11" {
+		t.Errorf("the most common version on %s", got)
+	}
+	diffs := report.Diffs(0)
+	for _, line := range []string{"# " + report.Versions[1].Name() + ": 192.168.1.12", "-net  o2ib  ib0", "+net  tcp  eth0"} {
+		if !strings.Contains(diffs, line+"\n") {
+			t.Errorf("diffs lack %q:\n%s", line, diffs)
+		}
+	}
+	drifted := report.Drifted(0)
+	if strings.Join(drifted, ",") != "192.168.1.12,192.168.1.13" {
+		t.Fatalf("drifted = %v", drifted)
+	}
+
+	var targets []SSHConnection
+	for _, conn := range conns {
+		if conn.IPAddress == drifted[0] || conn.IPAddress == drifted[1] {
+			targets = append(targets, conn)
+		}
+	}
+	results, err := PushFile(context.Background(), exec, targets, report.Path, &report.Versions[0], 2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Sum != report.Versions[0].Sum || results[1].Err != "" {
+		t.Errorf("results = %+v", results)
+	}
+	report, err = CompareFile(context.Background(), exec, conns, "/etc/lnet.conf", 2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Versions) != 1 || len(report.Failed) != 0 || len(report.Drifted(0)) != 0 {
+		t.Errorf("report after push = %+v", report)
+	}
+}
@@ -0,0 +1,210 @@
+package state
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	logger "github.com/luo2pei4/ltool/pkg/log"
+	"github.com/luo2pei4/ltool/pkg/utils"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// backupInfix separates the file name and the time of its backups,
+// e.g. lustre.conf.ltool-bak-20240102-150405.123456
+const backupInfix = ".ltool-bak-"
+
+// backupTimeLayout is the time format of the backup names, the microseconds
+// keep the backups of the saves in the same second apart
+const backupTimeLayout = "20060102-150405.000000"
+
+// backupParseLayout parses the backup names with or without the fraction,
+// the older versions named them by the second
+const backupParseLayout = "20060102-150405"
+
+// restoreInfix records a restore in the name of the backup it took, followed
+// by the time of the restored backup, e.g.
+// lustre.conf.ltool-bak-20240102-160405.000000-restored-20240102-150405.000000
+const restoreInfix = "-restored-"
+
+// RemoteTextFile a text file of a node opened in the editor
+type RemoteTextFile struct {
+	Conn    SSHConnection
+	Path    string
+	Content string // the content on the node when opened or saved
+	Sum     string
+	Mode    fs.FileMode
+}
+
+// Backup a backup of a remote file, saved next to it
+type Backup struct {
+	Path string
+	Time time.Time
+	Size int64
+	// Restored is the time of the backup restored when this one was taken,
+	// zero if it was taken by a save
+	Restored time.Time
+}
+
+// ChangedError the file was changed on the node after it was opened
+type ChangedError struct {
+	Path string
+}
+
+func (e *ChangedError) Error() string {
+	return fmt.Sprintf("%s was changed on the node since it was opened, reload it before saving", e.Path)
+}
+
+// OpenRemoteFile downloads a text file of the node
+func OpenRemoteFile(ctx context.Context, exec utils.Executor, conn SSHConnection, filePath string) (*RemoteTextFile, error) {
+	content, transfer, err := readRemoteText(ctx, exec, conn, filePath)
+	if err != nil {
+		return nil, err
+	}
+	return &RemoteTextFile{
+		Conn:    conn,
+		Path:    filePath,
+		Content: content,
+		Sum:     transfer.Sum,
+		Mode:    transfer.Mode,
+	}, nil
+}
+
+func readRemoteText(ctx context.Context, exec utils.Executor, conn SSHConnection, filePath string) (string, *utils.Transfer, error) {
+	var buf bytes.Buffer
+	transfer, err := exec.Download(ctx, conn.Config(), filePath, &buf)
+	if err != nil {
+		return "", nil, err
+	}
+	if !utf8.Valid(buf.Bytes()) || bytes.IndexByte(buf.Bytes(), 0) >= 0 {
+		return "", nil, fmt.Errorf("%s is not a text file", filePath)
+	}
+	return buf.String(), transfer, nil
+}
+
+// Diff returns the unified diff from the content on the node to content
+func (f *RemoteTextFile) Diff(content string) string {
+	return UnifiedDiff(f.Conn.IPAddress+":"+f.Path, "edited", f.Content, content)
+}
+
+// UnifiedDiff returns the unified diff of two texts, empty if they are equal
+func UnifiedDiff(fromName, toName, from, to string) string {
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(from),
+		B:        difflib.SplitLines(to),
+		FromFile: fromName,
+		ToFile:   toName,
+		Context:  3,
+	})
+	if err != nil {
+		return err.Error()
+	}
+	return diff
+}
+
+// BackupPath returns the path of the backup of filePath taken at t
+func BackupPath(filePath string, t time.Time) string {
+	return filePath + backupInfix + t.Format(backupTimeLayout)
+}
+
+// Save backs up the content on the node next to the file and replaces it
+// with content atomically, ChangedError is returned if the file was changed
+// on the node after it was opened. It returns the path of the backup.
+func (f *RemoteTextFile) Save(ctx context.Context, exec utils.Executor, content string, now time.Time) (string, error) {
+	return f.save(ctx, exec, content, BackupPath(f.Path, now))
+}
+
+// save replaces the file with content after backing it up as backup
+func (f *RemoteTextFile) save(ctx context.Context, exec utils.Executor, content, backup string) (string, error) {
+	current, _, err := readRemoteText(ctx, exec, f.Conn, f.Path)
+	if err != nil {
+		return "", err
+	}
+	if current != f.Content {
+		return "", &ChangedError{Path: f.Path}
+	}
+	conf := f.Conn.Config()
+	if _, err := exec.Upload(ctx, conf, strings.NewReader(f.Content), backup, f.perm()); err != nil {
+		return "", fmt.Errorf("backup %s failed, %w", f.Path, err)
+	}
+	transfer, err := exec.Upload(ctx, conf, strings.NewReader(content), f.Path, f.perm())
+	if err != nil {
+		return backup, err
+	}
+	logger.Infof("saved %s:%s, backup %s", f.Conn.IPAddress, f.Path, backup)
+	f.Content = content
+	f.Sum = transfer.Sum
+	return backup, nil
+}
+
+// ListBackups returns the backups of the file, the newest first
+func (f *RemoteTextFile) ListBackups(ctx context.Context, exec utils.Executor) ([]Backup, error) {
+	entries, err := exec.ListDir(ctx, f.Conn.Config(), path.Dir(f.Path))
+	if err != nil {
+		return nil, err
+	}
+	prefix := path.Base(f.Path) + backupInfix
+	var backups []Backup
+	for _, entry := range entries {
+		if entry.IsDir || !strings.HasPrefix(entry.Name, prefix) {
+			continue
+		}
+		taken, restored, _ := strings.Cut(strings.TrimPrefix(entry.Name, prefix), restoreInfix)
+		t, err := time.ParseInLocation(backupParseLayout, taken, time.Local)
+		if err != nil {
+			continue
+		}
+		b := Backup{Path: entry.Path, Time: t, Size: entry.Size}
+		if restored != "" {
+			if b.Restored, err = time.ParseInLocation(backupParseLayout, restored, time.Local); err != nil {
+				continue
+			}
+		}
+		backups = append(backups, b)
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].Time.After(backups[j].Time)
+	})
+	return backups, nil
+}
+
+// ReadBackup returns the content of a backup
+func (f *RemoteTextFile) ReadBackup(ctx context.Context, exec utils.Executor, backup string) (string, error) {
+	content, _, err := readRemoteText(ctx, exec, f.Conn, backup)
+	return content, err
+}
+
+// Restore saves the content of the backup as the file, the replaced
+// content is backed up too. The restore is recorded in the name of that
+// backup, so that the backups list shows it.
+func (f *RemoteTextFile) Restore(ctx context.Context, exec utils.Executor, backup string, now time.Time) (string, error) {
+	content, err := f.ReadBackup(ctx, exec, backup)
+	if err != nil {
+		return "", err
+	}
+	saveAs := BackupPath(f.Path, now)
+	prefix := f.Path + backupInfix
+	if restored, ok := strings.CutPrefix(backup, prefix); ok {
+		restored, _, _ = strings.Cut(restored, restoreInfix)
+		saveAs += restoreInfix + restored
+	}
+	saved, err := f.save(ctx, exec, content, saveAs)
+	if err == nil {
+		logger.Infof("restored %s:%s from %s", f.Conn.IPAddress, f.Path, backup)
+	}
+	return saved, err
+}
+
+// perm is the mode of the backups, and of the file if it was removed meanwhile
+func (f *RemoteTextFile) perm() fs.FileMode {
+	if f.Mode == 0 {
+		return 0o644
+	}
+	return f.Mode
+}
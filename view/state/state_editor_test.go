@@ -0,0 +1,130 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	if diff := UnifiedDiff("a", "b", "x\ny\n", "x\ny\n"); diff != "" {
+		t.Errorf("diff of equal texts = %q", diff)
+	}
+	diff := UnifiedDiff("a", "b", "x\ny\n", "x\nz\n")
+	for _, line := range []string{"--- a", "+++ b", "-y", "+z", " x"} {
+		if !strings.Contains(diff, line+"\n") {
+			t.Errorf("diff lacks %q:\n%s", line, diff)
+		}
+	}
+}
+
+func TestRemoteTextFile(t *testing.T) {
+	_, exec := newTestServer(t)
+	dir := t.TempDir()
+	target := filepath.Join(dir, "lustre.conf")
+	os.WriteFile(target, []byte("options lnet networks=tcp0(eth0)\n"), 0o600)
+	ctx := context.Background()
+
+	f, err := OpenRemoteFile(ctx, exec, testConn(), target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Content != "options lnet networks=tcp0(eth0)\n" || f.Mode != 0o600 {
+		t.Errorf("opened file = %+v", f)
+	}
+	edited := "options lnet networks=o2ib0(ib0)\n"
+	if diff := f.Diff(edited); !strings.Contains(diff, "+options lnet networks=o2ib0(ib0)") {
+		t.Errorf("diff = %s", diff)
+	}
+
+	first := time.Date(2024, 1, 2, 15, 4, 5, 0, time.Local)
+	backup, err := f.Save(ctx, exec, edited, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if backup != target+".ltool-bak-20240102-150405.000000" {
+		t.Errorf("backup = %s", backup)
+	}
+	if data, _ := os.ReadFile(backup); string(data) != "options lnet networks=tcp0(eth0)\n" {
+		t.Errorf("backup content = %q", data)
+	}
+	if data, _ := os.ReadFile(target); string(data) != edited {
+		t.Errorf("saved content = %q", data)
+	}
+	if info, _ := os.Stat(target); info.Mode().Perm() != 0o600 {
+		t.Errorf("mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	// the file was changed by someone else meanwhile
+	os.WriteFile(target, []byte("changed\n"), 0o600)
+	var changed *ChangedError
+	if _, err := f.Save(ctx, exec, "mine\n", first.Add(time.Minute)); !errors.As(err, &changed) {
+		t.Fatalf("err = %v, want ChangedError", err)
+	}
+	if data, _ := os.ReadFile(target); string(data) != "changed\n" {
+		t.Errorf("the changed file was overwritten, %q", data)
+	}
+
+	f, err = OpenRemoteFile(ctx, exec, testConn(), target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Restore(ctx, exec, backup, first.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(target); string(data) != "options lnet networks=tcp0(eth0)\n" {
+		t.Errorf("restored content = %q", data)
+	}
+
+	backups, err := f.ListBackups(ctx, exec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 || !backups[0].Time.Equal(first.Add(time.Hour)) || backups[1].Path != backup {
+		t.Errorf("backups = %+v", backups)
+	}
+	// the restore is recorded in the backup it took
+	if len(backups) == 2 && (!backups[0].Restored.Equal(first) || !backups[1].Restored.IsZero()) {
+		t.Errorf("restored = %v, %v, want %v", backups[0].Restored, backups[1].Restored, first)
+	}
+	if _, err := f.Restore(ctx, exec, backups[0].Path, first.Add(2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if backups, _ = f.ListBackups(ctx, exec); len(backups) != 3 || !backups[0].Restored.Equal(first.Add(time.Hour)) {
+		t.Errorf("backups = %+v", backups)
+	}
+
+	// the saves in the same second keep their own backups
+	f, err = OpenRemoteFile(ctx, exec, testConn(), target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, before := first.Add(3*time.Hour), f.Content
+	one, err := f.Save(ctx, exec, "one\n", second.Add(100*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	two, err := f.Save(ctx, exec, "two\n", second.Add(200*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(one); one == two || string(data) != before {
+		t.Errorf("backup %s was overwritten by %s, %q", one, two, data)
+	}
+	// the backups named by the second by the older versions are listed too
+	os.WriteFile(target+".ltool-bak-20231231-235959", []byte("old\n"), 0o600)
+	backups, err = f.ListBackups(ctx, exec)
+	if err != nil || len(backups) != 6 || !backups[0].Time.Equal(second.Add(200*time.Millisecond)) ||
+		!backups[5].Time.Equal(time.Date(2023, 12, 31, 23, 59, 59, 0, time.Local)) {
+		t.Errorf("backups = %+v, %v", backups, err)
+	}
+
+	os.WriteFile(filepath.Join(dir, "binary"), []byte{0x7f, 'E', 'L', 'F', 0}, 0o755)
+	if _, err := OpenRemoteFile(ctx, exec, testConn(), filepath.Join(dir, "binary")); err == nil {
+		t.Error("a binary file was opened")
+	}
+}
@@ -0,0 +1,52 @@
+package state
+
+import (
+	"errors"
+	"io"
+	"sort"
+
+	"github.com/luo2pei4/ltool/pkg/dblayer"
+	"github.com/luo2pei4/ltool/pkg/dblayer/repo"
+	"github.com/luo2pei4/ltool/pkg/inventory"
+	"gorm.io/gorm"
+)
+
+// ExportNodes writes the saved nodes in format and returns their number,
+// the secrets are left out, or replaced with inventory.Redacted if redact
+// is true.
+func ExportNodes(w io.Writer, format string, redact bool) (int, error) {
+	repoNodes, err := dblayer.DB.ListNodes(dblayer.NodeQuery{})
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, err
+	}
+	sort.Slice(repoNodes, func(i, j int) bool {
+		return ipLess(repoNodes[i].IPAddress, repoNodes[j].IPAddress)
+	})
+	nodes := make([]inventory.Node, 0, len(repoNodes))
+	for i := range repoNodes {
+		node := exportNode(&repoNodes[i])
+		node.HideSecrets(redact)
+		nodes = append(nodes, node)
+	}
+	return len(nodes), inventory.Export(w, format, nodes)
+}
+
+func exportNode(repoNode *repo.Node) inventory.Node {
+	return inventory.Node{
+		IP:             repoNode.IPAddress,
+		User:           repoNode.UserName,
+		AuthType:       repoNode.AuthType,
+		Password:       repoNode.Password,
+		KeyFile:        repoNode.KeyFile,
+		Passphrase:     repoNode.Passphrase,
+		CertFile:       repoNode.CertFile,
+		ProxyJump:      repoNode.ProxyJump,
+		BecomeMethod:   repoNode.BecomeMethod,
+		BecomePassword: repoNode.BecomePassword,
+		Hostname:       repoNode.Hostname,
+		OS:             repoNode.OS,
+		Arch:           repoNode.Architecture,
+		Kernel:         repoNode.Kernel,
+		Group:          repoNode.GroupName,
+	}
+}
@@ -0,0 +1,197 @@
+package state
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	logger "github.com/luo2pei4/ltool/pkg/log"
+	"github.com/luo2pei4/ltool/pkg/utils"
+)
+
+// FilesState the remote directory tree of the selected node
+type FilesState struct {
+	sync.RWMutex
+	NodeList []string
+	SSHCon   map[string]SSHConnection
+	Exec     utils.Executor // nil means utils.DefaultExecutor
+
+	dirs  map[string][]utils.RemoteFile // key: directory path
+	files map[string]utils.RemoteFile   // key: file path
+}
+
+// TransferResult the result of a file transfer on a node
+type TransferResult struct {
+	IP   string
+	Path string
+	Size int64
+	Sum  string // sha256 verified on the node
+	Err  string
+}
+
+// Executor returns the executor of the remote operations
+func (f *FilesState) Executor() utils.Executor {
+	if f.Exec == nil {
+		return utils.DefaultExecutor
+	}
+	return f.Exec
+}
+
+func (f *FilesState) LoadNodeList() error {
+	nodeList, conns, err := loadConnections()
+	if err != nil || len(nodeList) == 0 {
+		return err
+	}
+	f.Lock()
+	defer f.Unlock()
+	f.NodeList = nodeList
+	f.SSHCon = conns
+	return nil
+}
+
+// Reset forgets the listed directories, e.g. when another node is selected
+func (f *FilesState) Reset() {
+	f.Lock()
+	defer f.Unlock()
+	f.dirs = nil
+	f.files = nil
+}
+
+// LoadDir lists dir on the node and caches its entries
+func (f *FilesState) LoadDir(ctx context.Context, conn SSHConnection, dir string) error {
+	entries, err := f.Executor().ListDir(ctx, conn.Config(), dir)
+	if err != nil {
+		logger.Errorf("list %s of %s failed, %v", dir, conn.IPAddress, err)
+		return err
+	}
+	f.Lock()
+	defer f.Unlock()
+	if f.dirs == nil {
+		f.dirs = make(map[string][]utils.RemoteFile)
+		f.files = make(map[string]utils.RemoteFile)
+	}
+	f.dirs[dir] = entries
+	for _, entry := range entries {
+		f.files[entry.Path] = entry
+	}
+	return nil
+}
+
+// Children returns the paths in dir, loaded is false if dir was not listed yet
+func (f *FilesState) Children(dir string) (paths []string, loaded bool) {
+	f.RLock()
+	defer f.RUnlock()
+	entries, loaded := f.dirs[dir]
+	paths = make([]string, 0, len(entries))
+	for _, entry := range entries {
+		paths = append(paths, entry.Path)
+	}
+	return paths, loaded
+}
+
+// File returns the cached entry of p
+func (f *FilesState) File(p string) (utils.RemoteFile, bool) {
+	f.RLock()
+	defer f.RUnlock()
+	file, ok := f.files[p]
+	return file, ok
+}
+
+// RemoteTarget returns the remote path of the uploaded local file,
+// the file keeps its name if remotePath is a directory ending with '/'.
+func RemoteTarget(localPath, remotePath string) string {
+	if strings.HasSuffix(remotePath, "/") {
+		return path.Join(remotePath, filepath.Base(localPath))
+	}
+	return remotePath
+}
+
+// UploadFile uploads the local file to remotePath of the nodes with at most
+// workers at the same time, each upload is verified by its checksum. The
+// results are sorted by ip address, CancelledError is returned with the
+// results of the finished nodes if ctx was cancelled.
+func UploadFile(ctx context.Context, exec utils.Executor, conns []SSHConnection, localPath, remotePath string,
+	workers int, onProgress func(done, total int)) ([]TransferResult, error) {
+
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("%s is a directory", localPath)
+	}
+	remotePath = RemoteTarget(localPath, remotePath)
+	return transferToNodes(ctx, conns, remotePath, workers, onProgress, func(conn SSHConnection) (*utils.Transfer, error) {
+		transfer, err := uploadTo(ctx, exec, conn, localPath, remotePath, info.Mode().Perm())
+		if err != nil {
+			logger.Errorf("upload %s to %s:%s failed, %v", localPath, conn.IPAddress, remotePath, err)
+		}
+		return transfer, err
+	})
+}
+
+// transferToNodes calls transfer for each node with at most workers at the
+// same time, see UploadFile for the results.
+func transferToNodes(ctx context.Context, conns []SSHConnection, remotePath string, workers int,
+	onProgress func(done, total int), transfer func(conn SSHConnection) (*utils.Transfer, error)) ([]TransferResult, error) {
+
+	nodes, err := forEachNode(ctx, connIPs(conns), workers, func(i int) (*utils.Transfer, error) {
+		return transfer(conns[i])
+	}, progressOf(onProgress, len(conns)))
+	results := make([]TransferResult, len(nodes))
+	for i, node := range nodes {
+		results[i] = TransferResult{IP: node.IP, Path: remotePath}
+		if node.Err != nil {
+			results[i].Err = node.Err.Error()
+			continue
+		}
+		results[i].Size = node.Value.Size
+		results[i].Sum = node.Value.Sum
+	}
+	return results, err
+}
+
+func uploadTo(ctx context.Context, exec utils.Executor, conn SSHConnection, localPath, remotePath string,
+	perm os.FileMode) (*utils.Transfer, error) {
+
+	file, err := os.Open(localPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return exec.Upload(ctx, conn.Config(), file, remotePath, perm)
+}
+
+// DownloadFile downloads remotePath of the node to w and verifies its checksum
+func DownloadFile(ctx context.Context, exec utils.Executor, conn SSHConnection, remotePath string,
+	w io.Writer) (TransferResult, error) {
+
+	result := TransferResult{IP: conn.IPAddress, Path: remotePath}
+	transfer, err := exec.Download(ctx, conn.Config(), remotePath, w)
+	if err != nil {
+		logger.Errorf("download %s:%s failed, %v", conn.IPAddress, remotePath, err)
+		result.Err = err.Error()
+		return result, err
+	}
+	result.Size = transfer.Size
+	result.Sum = transfer.Sum
+	return result, nil
+}
+
+// FormatTransfers renders a line per node with the verified checksum or the error
+func FormatTransfers(results []TransferResult) string {
+	var b strings.Builder
+	for _, result := range results {
+		if result.Err != "" {
+			fmt.Fprintf(&b, "%s  failed  %s\n", result.IP, result.Err)
+			continue
+		}
+		fmt.Fprintf(&b, "%s  ok  %d bytes  sha256 %s\n", result.IP, result.Size, result.Sum)
+	}
+	return b.String()
+}
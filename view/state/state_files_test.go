@@ -0,0 +1,69 @@
+package state
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRemoteTarget(t *testing.T) {
+	if got := RemoteTarget("/home/me/lustre.conf", "/etc/modprobe.d/"); got != "/etc/modprobe.d/lustre.conf" {
+		t.Errorf("target = %s", got)
+	}
+	if got := RemoteTarget("/home/me/lustre.conf", "/etc/modprobe.d/lnet.conf"); got != "/etc/modprobe.d/lnet.conf" {
+		t.Errorf("target = %s", got)
+	}
+}
+
+func TestUploadFile(t *testing.T) {
+	_, exec := newTestServer(t)
+	remoteDir := t.TempDir()
+	local := filepath.Join(t.TempDir(), "lustre.conf")
+	content := "options lnet networks=tcp0(eth1)\n"
+	os.WriteFile(local, []byte(content), 0o644)
+	sum := sha256.Sum256([]byte(content))
+	want := hex.EncodeToString(sum[:])
+	target := filepath.Join(remoteDir, "lustre.conf")
+
+	var conns []SSHConnection
+	for _, ip := range []string{"192.168.1.11", "192.168.1.10"} {
+		conn := testConn()
+		conn.IPAddress = ip
+		conns = append(conns, conn)
+	}
+	// the nodes share the directory of the fake server, upload one by one
+	results, err := UploadFile(context.Background(), exec, conns, local, remoteDir+"/", 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].IP != "192.168.1.10" {
+		t.Fatalf("results = %+v", results)
+	}
+	for _, result := range results {
+		if result.Err != "" || result.Sum != want || result.Path != target {
+			t.Errorf("result = %+v", result)
+		}
+	}
+	if data, _ := os.ReadFile(target); string(data) != content {
+		t.Errorf("uploaded content = %q", data)
+	}
+	if report := FormatTransfers(results); !strings.Contains(report, "192.168.1.10  ok  33 bytes  sha256 "+want) {
+		t.Errorf("report = %q", report)
+	}
+
+	files := &FilesState{Exec: exec}
+	if err := files.LoadDir(context.Background(), conns[0], remoteDir); err != nil {
+		t.Fatal(err)
+	}
+	paths, loaded := files.Children(remoteDir)
+	if !loaded || len(paths) != 1 || paths[0] != target {
+		t.Errorf("children = %v %v", paths, loaded)
+	}
+	if file, ok := files.File(target); !ok || file.Size != int64(len(content)) {
+		t.Errorf("file = %+v", file)
+	}
+}
@@ -0,0 +1,173 @@
+package state
+
+import (
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/luo2pei4/ltool/pkg/dblayer"
+)
+
+// Roles the groups of the Lustre servers and clients
+var Roles = []string{"mgs", "mds", "oss", "client", "router"}
+
+// SplitTags splits the comma separated tags, the duplicates are left out
+func SplitTags(s string) []string {
+	var tags []string
+	for _, tag := range strings.Split(s, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" && !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// LoadFilters returns the groups of the saved nodes and the roles, and the
+// tags of the saved nodes
+func LoadFilters() (groups, tags []string, err error) {
+	groups, err = dblayer.DB.ListGroups()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, role := range Roles {
+		if !slices.Contains(groups, role) {
+			groups = append(groups, role)
+		}
+	}
+	sort.Strings(groups)
+	tags, err = dblayer.DB.ListTags()
+	return groups, tags, err
+}
+
+// ChangeGroup sets the group of the node
+func (n *NodesState) ChangeGroup(id int, group string) {
+	n.Lock()
+	defer n.Unlock()
+	n.changeGroup(id, group)
+}
+
+// SetCheckedGroup sets the group of all checked nodes, it returns the
+// number of the changed nodes.
+func (n *NodesState) SetCheckedGroup(group string) int {
+	n.Lock()
+	defer n.Unlock()
+	cnt := 0
+	for id, rec := range n.Records {
+		if rec.Checked && rec.Group != strings.TrimSpace(group) {
+			n.changeGroup(id, group)
+			cnt++
+		}
+	}
+	return cnt
+}
+
+func (n *NodesState) changeGroup(id int, group string) {
+	group = strings.TrimSpace(group)
+	if n.Records[id].Group == group {
+		return
+	}
+	n.Records[id].Group = group
+	n.recordChanged(id)
+}
+
+// ChangeTags sets the tags of the node
+func (n *NodesState) ChangeTags(id int, tags []string) {
+	n.Lock()
+	defer n.Unlock()
+	n.changeTags(id, tags)
+}
+
+// TagChecked adds the tags to all checked nodes and removes the untags, it
+// returns the number of the changed nodes.
+func (n *NodesState) TagChecked(tags, untags []string) int {
+	n.Lock()
+	defer n.Unlock()
+	cnt := 0
+	for id, rec := range n.Records {
+		if !rec.Checked {
+			continue
+		}
+		newTags := slices.DeleteFunc(slices.Clone(rec.Tags), func(tag string) bool {
+			return slices.Contains(untags, tag)
+		})
+		for _, tag := range tags {
+			if !slices.Contains(newTags, tag) {
+				newTags = append(newTags, tag)
+			}
+		}
+		if !sameTags(rec.Tags, newTags) {
+			n.changeTags(id, newTags)
+			cnt++
+		}
+	}
+	return cnt
+}
+
+func (n *NodesState) changeTags(id int, tags []string) {
+	tags = slices.Clone(tags)
+	sort.Strings(tags)
+	if sameTags(n.Records[id].Tags, tags) {
+		return
+	}
+	n.Records[id].Tags = tags
+	n.recordChanged(id)
+}
+
+// sameTags compares the sorted tags
+func sameTags(t1, t2 []string) bool {
+	return slices.Equal(t1, t2)
+}
+
+// SelectGroup checks the nodes of the group and unchecks the others, it
+// returns the number of the checked nodes.
+func (n *NodesState) SelectGroup(group string) int {
+	n.Lock()
+	defer n.Unlock()
+	cnt := 0
+	for i, rec := range n.Records {
+		n.Records[i].Checked = rec.Group == group
+		if rec.Group == group {
+			cnt++
+		}
+	}
+	return cnt
+}
+
+// SetTargetGroup sets the group Check and Delete are aimed at, empty means all
+func (n *NodesState) SetTargetGroup(group string) {
+	n.Lock()
+	defer n.Unlock()
+	n.targetGroup = group
+}
+
+// TargetGroup returns the group Check and Delete are aimed at
+func (n *NodesState) TargetGroup() string {
+	n.RLock()
+	defer n.RUnlock()
+	return n.targetGroup
+}
+
+// inTarget reports if the node is aimed at by the actions of the target
+// group, the caller holds the lock
+func (n *NodesState) inTarget(rec *Node) bool {
+	return n.targetGroup == "" || rec.Group == n.targetGroup
+}
+
+// DeleteGroup deletes all saved nodes of the group, not only the loaded
+// ones, and removes its records. It returns the number of the deleted nodes.
+func (n *NodesState) DeleteGroup(group string) (int, error) {
+	cnt, err := dblayer.DB.DeleteGroup(group)
+	if err != nil {
+		return 0, err
+	}
+	n.Lock()
+	defer n.Unlock()
+	deleted := int(cnt)
+	n.Records = slices.DeleteFunc(n.Records, func(rec Node) bool {
+		if rec.Group == group && rec.NewRec {
+			deleted++
+		}
+		return rec.Group == group
+	})
+	return deleted, nil
+}
@@ -0,0 +1,107 @@
+package state
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/luo2pei4/ltool/pkg/dblayer"
+	"github.com/luo2pei4/ltool/pkg/dblayer/repo"
+	"github.com/luo2pei4/ltool/pkg/utils"
+)
+
+func TestGroupsAndTags(t *testing.T) {
+	n := &NodesState{Records: []Node{
+		{IP: "192.168.1.1", Group: "mds", rawGroup: "mds"},
+		{IP: "192.168.1.2", Group: "oss", rawGroup: "oss", Tags: []string{"rack1"}, rawTags: []string{"rack1"}},
+		{IP: "192.168.1.3", Group: "oss", rawGroup: "oss"},
+	}}
+	if cnt := n.SelectGroup("oss"); cnt != 2 || n.Records[0].Checked || !n.Records[2].Checked {
+		t.Fatalf("selected %d nodes, %+v", cnt, n.Records)
+	}
+	if cnt := n.TagChecked([]string{"lustre", "rack2"}, []string{"rack1"}); cnt != 2 {
+		t.Errorf("tagged %d nodes", cnt)
+	}
+	if tags := n.Records[1].Tags; !reflect.DeepEqual(tags, []string{"lustre", "rack2"}) || !n.Records[1].Changed {
+		t.Errorf("tags are %v, changed %v", tags, n.Records[1].Changed)
+	}
+	n.ChangeTags(1, []string{"rack1"})
+	if n.Records[1].Changed {
+		t.Error("restoring tags was still marked changed")
+	}
+
+	n.CheckedRecord(0, true)
+	if cnt := n.SetCheckedGroup(" client "); cnt != 3 || n.Records[0].Group != "client" || !n.Records[0].Changed {
+		t.Errorf("changed %d groups, %+v", cnt, n.Records[0])
+	}
+	n.ChangeGroup(0, "mds")
+	if n.Records[0].Changed {
+		t.Error("restoring group was still marked changed")
+	}
+	// the group and the tags keep the pending edits of the other fields
+	n.ChangeOptions(2, utils.SSHOptions{Port: 2222})
+	n.ChangeGroup(2, "mds")
+	n.ChangeGroup(2, "oss")
+	n.ChangeTags(2, []string{"rack3"})
+	n.ChangeTags(2, nil)
+	if !n.Records[2].Changed {
+		t.Error("restoring group and tags dropped the changed options")
+	}
+	if tags := SplitTags("rack1, lustre,,rack1"); !reflect.DeepEqual(tags, []string{"rack1", "lustre"}) {
+		t.Errorf("tags are %v", tags)
+	}
+}
+
+func TestCheckTargetGroup(t *testing.T) {
+	_, exec := newTestServer(t)
+	auth := utils.SSHAuth{AuthType: utils.AuthPassword, Password: "secret"}
+	n := &NodesState{
+		Exec: exec,
+		Records: []Node{
+			{IP: "192.168.1.10", User: "root", SSHAuth: auth, Status: "unknown", Group: "mds"},
+			{IP: "192.168.1.11", User: "root", SSHAuth: auth, Status: "unknown", Group: "oss"},
+		},
+	}
+	n.SetTargetGroup("oss")
+	if err := n.CheckNodesStatus(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if n.Records[0].Status != "unknown" || n.Records[1].Status != "offline" {
+		t.Errorf("statuses are %s and %s", n.Records[0].Status, n.Records[1].Status)
+	}
+}
+
+func TestDeleteGroup(t *testing.T) {
+	useTestDB(t)
+	err := dblayer.DB.AddNodes([]repo.Node{
+		{IPAddress: "192.168.1.10", UserName: "root", GroupName: "mds"},
+		{IPAddress: "192.168.1.11", UserName: "root", GroupName: "oss"},
+		{IPAddress: "192.168.1.12", UserName: "root", GroupName: "oss"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dblayer.DB.SetNodeTags("192.168.1.11", []string{"rack1"}); err != nil {
+		t.Fatal(err)
+	}
+	n := &NodesState{
+		Records: []Node{
+			{IP: "192.168.1.10", Group: "mds"},
+			{IP: "192.168.1.11", Group: "oss"},
+			{IP: "192.168.1.13", Group: "oss", NewRec: true},
+		},
+	}
+	deleted, err := n.DeleteGroup("oss")
+	if err != nil || deleted != 3 {
+		t.Errorf("deleted %d nodes, %v", deleted, err)
+	}
+	if len(n.Records) != 1 || n.Records[0].IP != "192.168.1.10" {
+		t.Errorf("records are %+v", n.Records)
+	}
+	if cnt, err := dblayer.DB.CountNodes(dblayer.NodeQuery{}); err != nil || cnt != 1 {
+		t.Errorf("%d nodes are left, %v", cnt, err)
+	}
+	if tags, err := dblayer.DB.ListTags(); err != nil || len(tags) != 0 {
+		t.Errorf("tags %v are left, %v", tags, err)
+	}
+}
@@ -0,0 +1,189 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/luo2pei4/ltool/pkg/dblayer"
+	"github.com/luo2pei4/ltool/pkg/inventory"
+	"github.com/luo2pei4/ltool/pkg/utils"
+)
+
+// ImportRow an entry of the imported file, it is added if Problem is empty
+type ImportRow struct {
+	inventory.Entry
+	SSHAuth   utils.SSHAuth
+	Duplicate bool
+	Problem   string
+}
+
+// Lookup resolves a host name to its addresses, e.g. net.DefaultResolver.LookupHost
+type Lookup func(ctx context.Context, host string) ([]string, error)
+
+// lookupWorkers the host names resolved at the same time
+const lookupWorkers = 16
+
+// PreviewImport resolves the host names of the entries and fills in the
+// default user and credentials. The invalid entries and the duplicates of
+// the records, of the saved nodes or of a former entry are marked with the
+// problem.
+func (n *NodesState) PreviewImport(ctx context.Context, entries []inventory.Entry, user string, auth utils.SSHAuth,
+	lookup Lookup) ([]ImportRow, error) {
+
+	rows := make([]ImportRow, len(entries))
+	forEachBounded(len(entries), lookupWorkers, func(i int) {
+		rows[i].Entry = entries[i]
+		rows[i].Problem = entries[i].Err
+		if rows[i].Problem != "" || rows[i].IP != "" {
+			return
+		}
+		ip, err := resolveHost(ctx, lookup, rows[i].Host)
+		if err != nil {
+			rows[i].Problem = err.Error()
+			return
+		}
+		rows[i].IP = ip
+	}, func(int) {})
+
+	ips := make([]string, 0, len(rows))
+	for _, row := range rows {
+		if row.IP != "" {
+			ips = append(ips, row.IP)
+		}
+	}
+	saved, err := dblayer.DB.SavedNodes(ips)
+	if err != nil {
+		return nil, fmt.Errorf("find the saved nodes failed, %v", err)
+	}
+	existing := make(map[string]bool, len(n.Records)+len(saved))
+	for _, ip := range saved {
+		existing[ip] = true
+	}
+	n.RLock()
+	for _, rec := range n.Records {
+		existing[rec.IP] = true
+	}
+	n.RUnlock()
+	imported := make(map[string]int)
+	for i := range rows {
+		row := &rows[i]
+		if row.Problem != "" {
+			continue
+		}
+		if row.User == "" {
+			row.User = user
+		}
+		var err error
+		row.SSHAuth, err = importAuth(row.Auth, auth)
+		switch {
+		case utils.ValidateIP(row.IP) != nil:
+			row.Problem = "invalid ip address " + row.IP
+		case existing[row.IP]:
+			row.Duplicate = true
+			row.Problem = "the node exists"
+		case imported[row.IP] > 0:
+			row.Duplicate = true
+			row.Problem = fmt.Sprintf("duplicate of line %d", imported[row.IP])
+		case row.User == "":
+			row.Problem = "the user is empty"
+		case err != nil:
+			row.Problem = err.Error()
+		}
+		if !row.Duplicate {
+			imported[row.IP] = max(row.Line, 1)
+		}
+	}
+	return rows, nil
+}
+
+// resolveHost returns the first ipv4 address of host, or the first ipv6
+// one if it has no ipv4 address
+func resolveHost(ctx context.Context, lookup Lookup, host string) (string, error) {
+	addrs, err := lookup(ctx, host)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return "", fmt.Errorf("unknown host %s", host)
+		}
+		return "", fmt.Errorf("resolve %s failed, %v", host, err)
+	}
+	var ipv6 string
+	for _, addr := range addrs {
+		ip, err := netip.ParseAddr(addr)
+		switch {
+		case err != nil:
+		case ip.Unmap().Is4():
+			return ip.Unmap().String(), nil
+		case ipv6 == "":
+			ipv6 = ip.String()
+		}
+	}
+	if ipv6 == "" {
+		return "", fmt.Errorf("%s has no ip address", host)
+	}
+	return ipv6, nil
+}
+
+// importAuth returns the credentials of the auth column, "method" uses
+// the default credentials if the method is the default one. A redacted
+// secret counts as missing.
+func importAuth(column string, def utils.SSHAuth) (utils.SSHAuth, error) {
+	if column == "" {
+		return def, utils.ValidateAuth(&def)
+	}
+	method, value, _ := strings.Cut(column, ":")
+	if value == inventory.Redacted {
+		value = ""
+	}
+	method = strings.ToLower(strings.TrimSpace(method))
+	if !slices.Contains(utils.AuthTypes, method) {
+		return utils.SSHAuth{}, fmt.Errorf("unsupported authentication method '%s'", method)
+	}
+	defMethod := def.AuthType
+	if defMethod == "" {
+		defMethod = utils.AuthPassword
+	}
+	auth := utils.SSHAuth{AuthType: method}
+	switch {
+	case value == "" && method == defMethod:
+		auth = def
+	case value == "":
+	case method == utils.AuthPassword:
+		auth.Password = value
+	case method == utils.AuthKey, method == utils.AuthCert:
+		auth.KeyFile = value
+	default:
+		return auth, fmt.Errorf("%s takes no value", method)
+	}
+	return auth, utils.ValidateAuth(&auth)
+}
+
+// ImportNodes adds the rows without problems as new records and returns
+// the number of the added ones.
+func (n *NodesState) ImportNodes(rows []ImportRow) (int, error) {
+	added := 0
+	for _, row := range rows {
+		if row.Problem != "" {
+			continue
+		}
+		if err := n.AddNode(row.IP, row.User, row.SSHAuth); err != nil {
+			return added, err
+		}
+		n.Lock()
+		for i := range n.Records {
+			if n.Records[i].IP == row.IP && n.Records[i].NewRec {
+				n.Records[i].Hostname = row.Hostname
+				n.Records[i].Group = row.Group
+				added++
+				break
+			}
+		}
+		n.Unlock()
+	}
+	return added, nil
+}
@@ -0,0 +1,86 @@
+package state
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/luo2pei4/ltool/pkg/dblayer"
+	"github.com/luo2pei4/ltool/pkg/dblayer/repo"
+	"github.com/luo2pei4/ltool/pkg/inventory"
+	"github.com/luo2pei4/ltool/pkg/utils"
+)
+
+func TestPreviewImport(t *testing.T) {
+	auth := utils.SSHAuth{AuthType: utils.AuthPassword, Password: "secret"}
+	useTestDB(t)
+	if err := dblayer.DB.AddNodes([]repo.Node{{IPAddress: "192.168.1.14", UserName: "root"}}); err != nil {
+		t.Fatal(err)
+	}
+	n := &NodesState{Records: []Node{{IP: "192.168.1.9", User: "root", SSHAuth: auth}}}
+	data := "192.168.1.10,,,oss01,oss\n" +
+		"192.168.1.11,admin,key:/root/.ssh/id_ed25519\n" +
+		"192.168.1.10,root\n" +
+		"192.168.1.9,root\n" +
+		"192.168.1.300,root\n" +
+		"192.168.1.12,root,key\n" +
+		"192.168.1.13,root,telnet\n" +
+		"192.168.1.14,root\n"
+	entries, err := inventory.Parse(inventory.FormatCSV, []byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries = append(entries, inventory.Entry{Line: 9, Host: "oss02", Hostname: "oss02", Group: "oss"},
+		inventory.Entry{Line: 10, Host: "oss99"})
+	lookup := func(ctx context.Context, host string) ([]string, error) {
+		if host == "oss02" {
+			return []string{"fe80::1", "192.168.1.20"}, nil
+		}
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	rows, err := n.PreviewImport(context.Background(), entries, "root", auth, lookup)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantProblems := []string{
+		"",
+		"",
+		"duplicate of line 1",
+		"the node exists",
+		"invalid ip address 192.168.1.300",
+		"private key file is required",
+		"unsupported authentication method 'telnet'",
+		"the node exists",
+		"",
+		"unknown host oss99",
+	}
+	if len(rows) != len(wantProblems) {
+		t.Fatalf("rows = %+v", rows)
+	}
+	for i, want := range wantProblems {
+		if rows[i].Problem != want {
+			t.Errorf("row %d problem = %q, want %q", i, rows[i].Problem, want)
+		}
+	}
+	if !rows[2].Duplicate || !rows[3].Duplicate || rows[4].Duplicate {
+		t.Errorf("duplicates are not marked, %+v", rows)
+	}
+	if rows[0].User != "root" || rows[0].SSHAuth != auth {
+		t.Errorf("the defaults were not applied, %+v", rows[0])
+	}
+	if rows[1].SSHAuth.AuthType != utils.AuthKey || rows[1].SSHAuth.KeyFile != "/root/.ssh/id_ed25519" {
+		t.Errorf("auth = %+v", rows[1].SSHAuth)
+	}
+	if rows[8].IP != "192.168.1.20" {
+		t.Errorf("oss02 resolved to %s", rows[8].IP)
+	}
+
+	if added, err := n.ImportNodes(rows); err != nil || added != 3 {
+		t.Errorf("added %d nodes, %v", added, err)
+	}
+	if len(n.Records) != 4 || n.Records[1].IP != "192.168.1.10" || n.Records[1].Hostname != "oss01" ||
+		n.Records[1].Group != "oss" || !n.Records[1].NewRec {
+		t.Errorf("records = %+v", n.Records)
+	}
+}
@@ -0,0 +1,184 @@
+package state
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/luo2pei4/ltool/pkg/dblayer"
+	"github.com/luo2pei4/ltool/pkg/dblayer/repo"
+	"github.com/luo2pei4/ltool/pkg/utils"
+)
+
+// JumpHostsState the bastions which the nodes tunnel through
+type JumpHostsState struct {
+	sync.RWMutex
+	Records []repo.JumpHost
+}
+
+func (j *JumpHostsState) LoadAllRecords() error {
+	records, err := dblayer.DB.ListJumpHosts()
+	if err != nil {
+		return err
+	}
+	j.Lock()
+	defer j.Unlock()
+	j.Records = records
+	return nil
+}
+
+// Names returns the names of the jump hosts in order
+func (j *JumpHostsState) Names() []string {
+	j.RLock()
+	defer j.RUnlock()
+	names := make([]string, 0, len(j.Records))
+	for _, rec := range j.Records {
+		names = append(names, rec.Name)
+	}
+	return names
+}
+
+// ValidateProxyJump checks that every hop of the chain exists
+func (j *JumpHostsState) ValidateProxyJump(proxyJump string) error {
+	j.RLock()
+	defer j.RUnlock()
+	for _, name := range SplitProxyJump(proxyJump) {
+		found := false
+		for _, rec := range j.Records {
+			if rec.Name == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("jump host %s does not exist", name)
+		}
+	}
+	return nil
+}
+
+// Save validates and saves the jump host, the one with the same name is replaced
+func (j *JumpHostsState) Save(rec repo.JumpHost) error {
+	switch {
+	case rec.Name == "":
+		return fmt.Errorf("name is required")
+	case strings.ContainsAny(rec.Name, ", "):
+		return fmt.Errorf("name must not contain comma or space")
+	case rec.Address == "":
+		return fmt.Errorf("address is required")
+	case rec.UserName == "":
+		return fmt.Errorf("user is required")
+	}
+	auth := jumpHostAuth(&rec)
+	if err := utils.ValidateAuth(&auth); err != nil {
+		return err
+	}
+	if err := dblayer.DB.SaveJumpHost(&rec); err != nil {
+		return err
+	}
+	return j.LoadAllRecords()
+}
+
+// Delete removes the jump host if no node tunnels through it
+func (j *JumpHostsState) Delete(name string) error {
+	repoNodes, err := dblayer.DB.ListNodes(dblayer.NodeQuery{})
+	if err != nil {
+		return err
+	}
+	used := 0
+	for _, repoNode := range repoNodes {
+		for _, hop := range SplitProxyJump(repoNode.ProxyJump) {
+			if hop == name {
+				used++
+				break
+			}
+		}
+	}
+	if used > 0 {
+		return fmt.Errorf("jump host %s is used by %d nodes", name, used)
+	}
+	if err := dblayer.DB.DeleteJumpHost(name); err != nil {
+		return err
+	}
+	return j.LoadAllRecords()
+}
+
+// SplitProxyJump splits the comma separated jump host names
+func SplitProxyJump(proxyJump string) []string {
+	var names []string
+	for _, name := range strings.Split(proxyJump, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// jumpChains resolves the ProxyJump chains of the nodes by the stored jump hosts
+type jumpChains map[string]repo.JumpHost
+
+func loadJumpChains() (jumpChains, error) {
+	records, err := dblayer.DB.ListJumpHosts()
+	if err != nil {
+		return nil, fmt.Errorf("load jump hosts failed, %v", err)
+	}
+	chains := make(jumpChains, len(records))
+	for _, rec := range records {
+		chains[rec.Name] = rec
+	}
+	return chains, nil
+}
+
+// resolve returns the hops of proxyJump, the first hop is dialed directly
+func (c jumpChains) resolve(proxyJump string) ([]*utils.SSHConfig, error) {
+	var hops []*utils.SSHConfig
+	for _, name := range SplitProxyJump(proxyJump) {
+		rec, ok := c[name]
+		if !ok {
+			return nil, fmt.Errorf("jump host %s does not exist", name)
+		}
+		hops = append(hops, &utils.SSHConfig{
+			Host:    rec.Address,
+			User:    rec.UserName,
+			SSHAuth: jumpHostAuth(&rec),
+		})
+	}
+	return hops, nil
+}
+
+// resolveJumps loads the jump hosts only if some node tunnels through them
+func resolveJumps(proxyJumps []string) (map[string][]*utils.SSHConfig, error) {
+	result := make(map[string][]*utils.SSHConfig)
+	var chains jumpChains
+	for _, proxyJump := range proxyJumps {
+		if _, ok := result[proxyJump]; ok || proxyJump == "" {
+			continue
+		}
+		if chains == nil {
+			var err error
+			if chains, err = loadJumpChains(); err != nil {
+				return nil, err
+			}
+		}
+		hops, err := chains.resolve(proxyJump)
+		if err != nil {
+			return nil, err
+		}
+		result[proxyJump] = hops
+	}
+	return result, nil
+}
+
+func jumpHostAuth(rec *repo.JumpHost) utils.SSHAuth {
+	authType := rec.AuthType
+	if authType == "" {
+		authType = utils.AuthPassword
+	}
+	return utils.SSHAuth{
+		AuthType:   authType,
+		Password:   rec.Password,
+		KeyFile:    rec.KeyFile,
+		Passphrase: rec.Passphrase,
+		CertFile:   rec.CertFile,
+	}
+}
@@ -1,20 +1,21 @@
 package state
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"net/netip"
 	"regexp"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
 
-	"github.com/luo2pei4/ltool/pkg/dblayer"
 	logger "github.com/luo2pei4/ltool/pkg/log"
 	"github.com/luo2pei4/ltool/pkg/utils"
 	"golang.org/x/crypto/ssh"
 	"gopkg.in/yaml.v3"
-	"gorm.io/gorm"
 )
 
 type LocalNIS struct {
@@ -43,7 +44,7 @@ type NetInterface struct {
 	LinkType string
 	AltNames []string
 	IPv4     string
-	IPv6     string
+	IPv6     []string // every ipv6 address with its prefix length
 	Mask     int
 	Gateway  string
 }
@@ -58,7 +59,7 @@ type NetDetail struct {
 	LinkType  string
 	AltNames  string
 	IPv4      string
-	IPv6      string
+	IPv6      []string
 	NID       string
 	NIDIP     string
 	NetType   string
@@ -78,6 +79,7 @@ type NetState struct {
 	NodeList []string
 	SSHCon   map[string]SSHConnection
 	Details  []NetDetail
+	Exec     utils.Executor // nil means utils.DefaultExecutor
 }
 
 var IPv4MaskCIDRList = []string{
@@ -87,30 +89,54 @@ var IPv4MaskCIDRList = []string{
 	"24", "25", "26", "27", "28", "29", "30", "31", "32",
 }
 
+// Executor returns the executor of the remote operations
+func (n *NetState) Executor() utils.Executor {
+	if n.Exec == nil {
+		return utils.DefaultExecutor
+	}
+	return n.Exec
+}
+
 func (n *NetState) LoadNodeList() error {
-	repoNodes, err := dblayer.DB.ListNodes("")
-	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil
-		}
+	nodeList, conns, err := loadConnections()
+	if err != nil || len(nodeList) == 0 {
 		return err
 	}
 	n.Lock()
 	defer n.Unlock()
-	if len(repoNodes) == 0 {
-		return nil
+	n.NodeList = nodeList
+	n.SSHCon = conns
+	return nil
+}
+
+// Groups returns the groups of the nodes in name order
+func (n *NetState) Groups() []string {
+	n.RLock()
+	defer n.RUnlock()
+	var groups []string
+	for _, ip := range n.NodeList {
+		if group := n.SSHCon[ip].Group; group != "" && !slices.Contains(groups, group) {
+			groups = append(groups, group)
+		}
+	}
+	sort.Strings(groups)
+	return groups
+}
+
+// NodesOf returns the nodes of the group, all nodes if group is empty
+func (n *NetState) NodesOf(group string) []string {
+	n.RLock()
+	defer n.RUnlock()
+	if group == "" {
+		return n.NodeList
 	}
-	n.NodeList = make([]string, 0, len(repoNodes))
-	n.SSHCon = make(map[string]SSHConnection, len(repoNodes))
-	for _, repoNode := range repoNodes {
-		n.NodeList = append(n.NodeList, repoNode.IPAddress)
-		n.SSHCon[repoNode.IPAddress] = SSHConnection{
-			IPAddress: repoNode.IPAddress,
-			User:      repoNode.UserName,
-			Password:  repoNode.Password,
+	var nodes []string
+	for _, ip := range n.NodeList {
+		if n.SSHCon[ip].Group == group {
+			nodes = append(nodes, ip)
 		}
 	}
-	return nil
+	return nodes
 }
 
 // ipOLinkReg
@@ -130,8 +156,8 @@ func (n *NetState) LoadNodeList() error {
 // var ipOLinkReg = regexp.MustCompile(`^\d+: (\w+): <([^>]+)> mtu (\d+) .* state (\w+) .* link/(\w+) ([^ ]+) (?:altname (\w+))?`)
 
 // exec: lnetctl net show
-func loadLnetCtlInfo(ip, user, pwd string) (*LnetCtl, error) {
-	data, err := utils.RemoteCmd(ip, user, pwd, "lnetctl net show")
+func loadLnetCtlInfo(ctx context.Context, exec utils.Executor, conn *SSHConnection) (*LnetCtl, error) {
+	data, err := exec.RemoteCmd(ctx, conn.Config(), "lnetctl net show")
 	if err != nil {
 		return nil, err
 	}
@@ -143,9 +169,9 @@ func loadLnetCtlInfo(ip, user, pwd string) (*LnetCtl, error) {
 }
 
 // exec: ip -o link show / ip -o address show
-func loadLinkInfo(ip, user, pwd string) (map[string]NetInterface, error) {
+func loadLinkInfo(ctx context.Context, exec utils.Executor, conn *SSHConnection) (map[string]NetInterface, error) {
 
-	data, err := utils.RemoteCmd(ip, user, pwd, "ip -o link show")
+	data, err := exec.RemoteCmd(ctx, conn.Config(), "ip -o link show")
 	if err != nil {
 		return nil, err
 	}
@@ -226,7 +252,7 @@ func loadLinkInfo(ip, user, pwd string) (map[string]NetInterface, error) {
 		interfaces[info.Name] = info
 	}
 	// ip addresses
-	data, err = utils.RemoteCmd(ip, user, pwd, "ip -o address show")
+	data, err = exec.RemoteCmd(ctx, conn.Config(), "ip -o address show")
 	if err != nil {
 		return nil, err
 	}
@@ -267,19 +293,16 @@ func loadLinkInfo(ip, user, pwd string) (map[string]NetInterface, error) {
 		if !ok {
 			continue
 		}
-		iinfo.Mask = mask
-		if fields[4] == "brd" {
-			iinfo.Gateway = fields[5]
-		}
-
-		if family == "inet" {
+		switch family {
+		case "inet":
 			iinfo.IPv4 = ip
-		} else if family == "inet6" {
-			if strings.HasPrefix(ip, "fe80:") {
-				continue
+			iinfo.Mask = mask
+			if len(fields) > 5 && fields[4] == "brd" {
+				iinfo.Gateway = fields[5]
 			}
-			iinfo.IPv6 = ip
-		} else {
+		case "inet6":
+			iinfo.IPv6 = append(iinfo.IPv6, ipWithMask)
+		default:
 			continue
 		}
 		interfaces[ifName] = iinfo
@@ -287,14 +310,14 @@ func loadLinkInfo(ip, user, pwd string) (map[string]NetInterface, error) {
 	return interfaces, nil
 }
 
-func (n *NetState) LoadInterfaceDetail(ip, user, pwd string) error {
+func (n *NetState) LoadInterfaceDetail(ctx context.Context, conn SSHConnection) error {
 
 	n.RLock()
 	defer n.RUnlock()
 
-	ifMap, err := loadLinkInfo(ip, user, pwd)
+	ifMap, err := loadLinkInfo(ctx, n.Executor(), &conn)
 	if err != nil {
-		return err
+		return cancelledAt(ctx, err, nil, "interfaces", "lnet NIDs")
 	}
 	details := make([]NetDetail, 0, len(ifMap))
 	for _, info := range ifMap {
@@ -318,9 +341,9 @@ func (n *NetState) LoadInterfaceDetail(ip, user, pwd string) error {
 	})
 	n.Details = details
 
-	lnetInfo, err := loadLnetCtlInfo(ip, user, pwd)
+	lnetInfo, err := loadLnetCtlInfo(ctx, n.Executor(), &conn)
 	if err != nil {
-		return err
+		return cancelledAt(ctx, err, []string{"interfaces"}, "lnet NIDs")
 	}
 	if len(lnetInfo.Net) == 0 {
 		return nil
@@ -358,27 +381,53 @@ func (n *NetState) LoadInterfaceDetail(ip, user, pwd string) error {
 	return nil
 }
 
-func (n *NetDetail) SetIPv4(ip, user, pwd string) error {
+// HasAddress reports whether ip is an ipv4 or ipv6 address of the interface
+func (n *NetDetail) HasAddress(ip string) bool {
+	if ip == "" {
+		return false
+	}
+	if n.IPv4 == ip {
+		return true
+	}
+	want, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	for _, addr := range n.IPv6 {
+		if prefix, err := netip.ParsePrefix(addr); err == nil && prefix.Addr() == want {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *NetDetail) SetIPv4(ctx context.Context, exec utils.Executor, conn SSHConnection) error {
+	run := newStepRunner(ctx, exec, conn.Config())
 	// check command exist
-	if _, err := utils.RemoteCmd(ip, user, pwd, "nmcli"); err != nil {
+	if _, err := run.cmd("check nmcli", "nmcli"); err != nil {
 		logger.Errorf("check cmd 'nmcli' error, %v", err)
+		if isCancelled(err) {
+			return err
+		}
 		return errors.New("unable to complete the operation, check whether the 'nmcli' command is installed")
 	}
 	// check interface exist
-	if _, err := utils.RemoteCmd(ip, user, pwd, "nmcli device show "+n.Name); err != nil {
+	if _, err := run.cmd("find iface", "nmcli device show "+n.Name); err != nil {
 		logger.Errorf("find iface '%s' error, %v", n.Name, err)
+		if isCancelled(err) {
+			return err
+		}
 		return fmt.Errorf("find iface '%s' error: %v", n.Name, err)
 	}
 	cmd := utils.AssembleCmd("nmcli", "con", "show", n.Name)
-	if _, err := utils.RemoteCmd(ip, user, pwd, cmd); err != nil {
+	if _, err := run.cmd("show connection", cmd); err != nil {
 		logger.Errorf("show iface error, %v", err)
-		if exitErr, ok := err.(*ssh.ExitError); ok {
-			if exitErr.ExitStatus() != 10 {
-				return err
-			}
+		var exitErr *ssh.ExitError
+		if !errors.As(err, &exitErr) || exitErr.ExitStatus() != 10 {
+			return err
 		}
 		cmd = utils.AssembleCmd("nmcli", "con", "add", "type", "ethernet", "ifname", n.Name, "con-name", n.Name)
-		if _, err := utils.RemoteCmd(ip, user, pwd, cmd); err != nil {
+		if _, err := run.cmd("add connection", cmd); err != nil {
 			logger.Errorf("set ipv4 address error, %v", err)
 			return err
 		}
@@ -388,43 +437,53 @@ func (n *NetDetail) SetIPv4(ip, user, pwd string) error {
 		cmdItems = append(cmdItems, "ipv4.gateway", n.Gateway)
 	}
 	cmd = utils.AssembleCmd(cmdItems...)
-	if _, err := utils.RemoteCmd(ip, user, pwd, cmd); err != nil {
+	if _, err := run.cmd("modify ipv4", cmd); err != nil {
 		logger.Errorf("modify ipv4 address error, cmd: %s, %v", cmd, err)
 		return err
 	}
-	if _, err := utils.RemoteCmd(ip, user, pwd, "nmcli connection up "+n.Name); err != nil {
+	if _, err := run.cmd("up connection", "nmcli connection up "+n.Name); err != nil {
 		logger.Errorf("up connection error, %v", err)
 		return err
 	}
 	return nil
 }
 
-func (n *NetDetail) DeleteIPv4(ip, user, pwd string) error {
+func (n *NetDetail) DeleteIPv4(ctx context.Context, exec utils.Executor, conn SSHConnection) error {
+	run := newStepRunner(ctx, exec, conn.Config())
 	// check command exist
-	if _, err := utils.RemoteCmd(ip, user, pwd, "nmcli"); err != nil {
+	if _, err := run.cmd("check nmcli", "nmcli"); err != nil {
 		logger.Errorf("check cmd 'nmcli' error, %v", err)
+		if isCancelled(err) {
+			return err
+		}
 		return errors.New("unable to complete the operation, check whether the 'nmcli' command is installed")
 	}
 	// check interface exist
-	if _, err := utils.RemoteCmd(ip, user, pwd, "nmcli device show "+n.Name); err != nil {
+	if _, err := run.cmd("find iface", "nmcli device show "+n.Name); err != nil {
 		logger.Errorf("find iface '%s' error, %v", n.Name, err)
+		if isCancelled(err) {
+			return err
+		}
 		return fmt.Errorf("find iface '%s' error: %v", n.Name, err)
 	}
 	cmd := utils.AssembleCmd("nmcli", "con", "show", n.Name)
-	if _, err := utils.RemoteCmd(ip, user, pwd, cmd); err != nil {
+	if _, err := run.cmd("show connection", cmd); err != nil {
 		logger.Errorf("show iface error, %v", err)
+		if isCancelled(err) {
+			return err
+		}
 		// if connection not exist, return directly
 		return nil
 	}
 	// set ipv4.method to disabled to remove ip address
 	cmdItems := []string{"nmcli", "con", "mod", n.Name, "ipv4.method", "disabled", "ipv4.addr", "\"\"", "ipv4.gateway", "\"\""}
 	cmd = utils.AssembleCmd(cmdItems...)
-	if _, err := utils.RemoteCmd(ip, user, pwd, cmd); err != nil {
+	if _, err := run.cmd("delete ipv4", cmd); err != nil {
 		logger.Errorf("delete ipv4 address error, cmd: %s, %v", cmd, err)
 		return err
 	}
 	upConnCmd := "nmcli connection up " + n.Name
-	if _, err := utils.RemoteCmd(ip, user, pwd, upConnCmd); err != nil {
+	if _, err := run.cmd("up connection", upConnCmd); err != nil {
 		var exitErr *ssh.ExitError
 		if errors.As(err, &exitErr) {
 			if exitErr.ExitStatus() == 4 {
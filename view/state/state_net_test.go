@@ -0,0 +1,250 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/luo2pei4/ltool/pkg/utils"
+	"github.com/luo2pei4/ltool/pkg/utils/sshtest"
+)
+
+const ipLinkOutput = `1: lo: <LOOPBACK,UP,LOWER_UP> mtu 65536 qdisc noqueue state UNKNOWN mode DEFAULT group default qlen 1000\    link/loopback 00:00:00:00:00:00 brd 00:00:00:00:00:00
+2: ens33: <BROADCAST,MULTICAST,UP,LOWER_UP> mtu 1500 qdisc fq_codel state UP mode DEFAULT group default qlen 1000\    link/ether 00:0c:29:3a:4b:5c brd ff:ff:ff:ff:ff:ff\    altname enp2s1
+3: ib0: <BROADCAST,MULTICAST,UP,LOWER_UP> mtu 2044 qdisc mq state UP mode DEFAULT group default qlen 256\    link/infiniband 80:00:02:08:fe:80:00:00:00:00:00:00:00:02:c9:03:00:0f:1a:2b brd 00:ff:ff:ff:ff:12:40:1b:ff:ff:00:00:00:00:00:00:ff:ff:ff:ff
+4: vlan10@ens33: <BROADCAST,MULTICAST> mtu 1500 qdisc noop state DOWN mode DEFAULT group default qlen 1000\    link/ether 00:0c:29:3a:4b:5c brd ff:ff:ff:ff:ff:ff
+`
+
+const ipAddressOutput = `1: lo    inet 127.0.0.1/8 scope host lo\       valid_lft forever preferred_lft forever
+2: ens33    inet 192.168.1.10/24 brd 192.168.1.255 scope global noprefixroute ens33\       valid_lft forever preferred_lft forever
+2: ens33    inet6 2001:db8::10/64 scope global noprefixroute \       valid_lft forever preferred_lft forever
+2: ens33    inet6 fe80::20c:29ff:fe3a:4b5c/64 scope link noprefixroute \       valid_lft forever preferred_lft forever
+3: ib0    inet 10.10.0.10/16 brd 10.10.255.255 scope global ib0\       valid_lft forever preferred_lft forever
+`
+
+const lnetctlOutput = `net:
+    - net type: lo
+      local NI(s):
+        - nid: 0@lo
+          status: up
+    - net type: o2ib1
+      local NI(s):
+        - nid: 10.10.0.10@o2ib1
+          status: up
+          interfaces:
+              0: ib0
+    - net type: tcp
+      local NI(s):
+        - nid: 192.168.1.10@tcp
+          status: up
+          interfaces:
+              0: ens33
+`
+
+func TestLoadLinkInfo(t *testing.T) {
+	srv, exec := newTestServer(t)
+	srv.Handle("ip -o link show", sshtest.Reply{Stdout: ipLinkOutput})
+	srv.Handle("ip -o address show", sshtest.Reply{Stdout: ipAddressOutput})
+	conn := testConn()
+
+	ifMap, err := loadLinkInfo(context.Background(), exec, &conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ifMap["lo"]; ok {
+		t.Error("loopback interface was not filtered")
+	}
+	ens33 := ifMap["ens33"]
+	want := NetInterface{
+		Index:    2,
+		Name:     "ens33",
+		Flags:    []string{"BROADCAST", "MULTICAST", "UP", "LOWER_UP"},
+		State:    "UP",
+		MAC:      "00:0c:29:3a:4b:5c",
+		MTU:      1500,
+		LinkType: "ether",
+		AltNames: []string{"enp2s1"},
+		IPv4:     "192.168.1.10",
+		IPv6:     []string{"2001:db8::10/64", "fe80::20c:29ff:fe3a:4b5c/64"},
+		Mask:     24,
+		Gateway:  "192.168.1.255",
+	}
+	if !reflect.DeepEqual(ens33, want) {
+		t.Errorf("ens33 = %+v\nwant %+v", ens33, want)
+	}
+	vlan := ifMap["vlan10"]
+	if vlan.IfAlias != "ens33" || vlan.State != "DOWN" || vlan.IPv4 != "" {
+		t.Errorf("vlan10 = %+v", vlan)
+	}
+	if ib := ifMap["ib0"]; ib.LinkType != "infiniband" || ib.IPv4 != "10.10.0.10" || ib.Mask != 16 {
+		t.Errorf("ib0 = %+v", ib)
+	}
+}
+
+func TestLoadLnetCtlInfo(t *testing.T) {
+	srv, exec := newTestServer(t)
+	srv.Handle("lnetctl net show", sshtest.Reply{Stdout: lnetctlOutput})
+	conn := testConn()
+
+	lnet, err := loadLnetCtlInfo(context.Background(), exec, &conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lnet.Net) != 3 {
+		t.Fatalf("got %d nets, want 3", len(lnet.Net))
+	}
+	ni := lnet.Net[1].LocalNIs[0]
+	if lnet.Net[1].NetType != "o2ib1" || ni.NID != "10.10.0.10@o2ib1" || ni.Interfaces[0] != "ib0" {
+		t.Errorf("net[1] = %+v", lnet.Net[1])
+	}
+}
+
+func TestLoadInterfaceDetail(t *testing.T) {
+	srv, exec := newTestServer(t)
+	srv.Handle("ip -o link show", sshtest.Reply{Stdout: ipLinkOutput})
+	srv.Handle("ip -o address show", sshtest.Reply{Stdout: ipAddressOutput})
+	srv.Handle("lnetctl net show", sshtest.Reply{Stdout: lnetctlOutput})
+
+	n := &NetState{Exec: exec}
+	if err := n.LoadInterfaceDetail(context.Background(), testConn()); err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0, len(n.Details))
+	for _, d := range n.Details {
+		names = append(names, d.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"ens33", "ib0", "vlan10"}) {
+		t.Fatalf("details are %v", names)
+	}
+	ib := n.Details[1]
+	if ib.NID != "10.10.0.10@o2ib1" || ib.NIDIP != "10.10.0.10" || ib.NetType != "o2ib" || ib.SuffixIdx != "1" {
+		t.Errorf("ib0 detail = %+v", ib)
+	}
+	if ens := n.Details[0]; ens.NetType != "tcp" || ens.SuffixIdx != "" {
+		t.Errorf("ens33 detail = %+v", ens)
+	}
+	for ip, want := range map[string]bool{"192.168.1.10": true, "2001:DB8::10": true, "2001:db8::11": false, "": false} {
+		if got := n.Details[0].HasAddress(ip); got != want {
+			t.Errorf("HasAddress(%q) = %v, want %v", ip, got, want)
+		}
+	}
+}
+
+func TestLoadInterfaceDetailCancelled(t *testing.T) {
+	srv, exec := newTestServer(t)
+	srv.Handle("ip -o link show", sshtest.Reply{Stdout: ipLinkOutput})
+	srv.Handle("ip -o address show", sshtest.Reply{Stdout: ipAddressOutput})
+	srv.Handle("lnetctl net show", sshtest.Reply{Stdout: lnetctlOutput, Delay: time.Minute})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(300*time.Millisecond, cancel)
+	n := &NetState{Exec: exec}
+	err := n.LoadInterfaceDetail(ctx, testConn())
+	var cancelledErr *CancelledError
+	if !errors.As(err, &cancelledErr) {
+		t.Fatalf("LoadInterfaceDetail error = %v, want CancelledError", err)
+	}
+	if !reflect.DeepEqual(cancelledErr.Finished, []string{"interfaces"}) ||
+		!reflect.DeepEqual(cancelledErr.Cancelled, []string{"lnet NIDs"}) {
+		t.Errorf("cancelled error = %+v", cancelledErr)
+	}
+	if len(n.Details) != 3 {
+		t.Errorf("the loaded interfaces were dropped, got %d", len(n.Details))
+	}
+}
+
+func TestSetIPv4(t *testing.T) {
+	srv, exec := newTestServer(t)
+	srv.Handle("nmcli", sshtest.Reply{})
+	srv.Handle("nmcli device show ib0", sshtest.Reply{})
+	srv.Handle("nmcli con show ib0", sshtest.Reply{ExitStatus: 10})
+	srv.Handle("nmcli con add type ethernet ifname ib0 con-name ib0", sshtest.Reply{})
+	srv.Handle("nmcli con mod ib0 ipv4.method manual ipv4.addr 10.10.0.11/16", sshtest.Reply{})
+	srv.Handle("nmcli connection up ib0", sshtest.Reply{})
+
+	detail := &NetDetail{Name: "ib0", IPv4: "10.10.0.11", Mask: 16}
+	if err := detail.SetIPv4(context.Background(), exec, testConn()); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"nmcli",
+		"nmcli device show ib0",
+		"nmcli con show ib0",
+		"nmcli con add type ethernet ifname ib0 con-name ib0",
+		"nmcli con mod ib0 ipv4.method manual ipv4.addr 10.10.0.11/16",
+		"nmcli connection up ib0",
+	}
+	if got := srv.Commands(); !reflect.DeepEqual(got, want) {
+		t.Errorf("commands = %q\nwant %q", got, want)
+	}
+}
+
+func TestSetIPv4WithoutNmcli(t *testing.T) {
+	_, exec := newTestServer(t)
+	detail := &NetDetail{Name: "ib0", IPv4: "10.10.0.11", Mask: 16}
+	err := detail.SetIPv4(context.Background(), exec, testConn())
+	if err == nil || err.Error() != "unable to complete the operation, check whether the 'nmcli' command is installed" {
+		t.Errorf("SetIPv4 error = %v", err)
+	}
+}
+
+func TestDeleteIPv4(t *testing.T) {
+	srv, exec := newTestServer(t)
+	srv.Handle("nmcli", sshtest.Reply{})
+	srv.Handle("nmcli device show ib0", sshtest.Reply{})
+	srv.Handle("nmcli con show ib0", sshtest.Reply{})
+	srv.Handle(`nmcli con mod ib0 ipv4.method disabled ipv4.addr "" ipv4.gateway ""`, sshtest.Reply{})
+	// nmcli exits with 4 if the connection has nothing to activate
+	srv.Handle("nmcli connection up ib0", sshtest.Reply{ExitStatus: 4})
+
+	detail := &NetDetail{Name: "ib0", IPv4: "10.10.0.11", Mask: 16}
+	if err := detail.DeleteIPv4(context.Background(), exec, testConn()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSetIPv4WithSudo(t *testing.T) {
+	srv, exec := newTestServer(t)
+	srv.Handle("nmcli", sshtest.Reply{})
+	srv.Handle("nmcli device show ib0", sshtest.Reply{})
+	srv.Handle("nmcli con show ib0", sshtest.Reply{})
+	srv.Handle("LC_ALL=C sudo -n sh -c 'nmcli con mod ib0 ipv4.method manual ipv4.addr 10.10.0.11/16'", sshtest.Reply{})
+	srv.Handle("LC_ALL=C sudo -n sh -c 'nmcli connection up ib0'", sshtest.Reply{
+		Stderr:     "sudo: a password is required\r\n",
+		ExitStatus: 1,
+	})
+
+	conn := testConn()
+	conn.Become = utils.Become{Method: utils.BecomeSudo}
+	detail := &NetDetail{Name: "ib0", IPv4: "10.10.0.11", Mask: 16}
+	err := detail.SetIPv4(context.Background(), exec, conn)
+	var refusedErr *utils.BecomeRefusedError
+	if !errors.As(err, &refusedErr) {
+		t.Fatalf("SetIPv4 error = %v, want BecomeRefusedError", err)
+	}
+	if got := srv.Commands(); len(got) != 5 {
+		t.Errorf("commands = %q", got)
+	}
+}
+
+func TestNetNodesOfGroup(t *testing.T) {
+	n := &NetState{
+		NodeList: []string{"192.168.1.1", "192.168.1.2", "192.168.1.3"},
+		SSHCon: map[string]SSHConnection{
+			"192.168.1.1": {IPAddress: "192.168.1.1", Group: "oss"},
+			"192.168.1.2": {IPAddress: "192.168.1.2", Group: "mds"},
+			"192.168.1.3": {IPAddress: "192.168.1.3", Group: "oss"},
+		},
+	}
+	if groups := n.Groups(); !reflect.DeepEqual(groups, []string{"mds", "oss"}) {
+		t.Errorf("groups are %v", groups)
+	}
+	if nodes := n.NodesOf("oss"); !reflect.DeepEqual(nodes, []string{"192.168.1.1", "192.168.1.3"}) {
+		t.Errorf("oss nodes are %v", nodes)
+	}
+	if nodes := n.NodesOf(""); len(nodes) != 3 {
+		t.Errorf("all nodes are %v", nodes)
+	}
+}
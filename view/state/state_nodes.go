@@ -1,16 +1,19 @@
 package state
 
 import (
+	"cmp"
+	"context"
 	"errors"
 	"fmt"
 	"image/color"
-	"net"
+	"net/netip"
+	"slices"
 	"sort"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/luo2pei4/ltool/pkg/config"
 	"github.com/luo2pei4/ltool/pkg/dblayer"
 	"github.com/luo2pei4/ltool/pkg/dblayer/repo"
 	logger "github.com/luo2pei4/ltool/pkg/log"
@@ -18,98 +21,184 @@ import (
 	"gorm.io/gorm"
 )
 
+// StatusHostKeyChanged the node offered a host key different from the trusted one
+const StatusHostKeyChanged = "key changed"
+
 type Node struct {
-	IP       string
-	User     string
-	rawUser  string
-	Password string
-	rawPwd   string
-	Status   string
-	Hostname string
-	OS       string
-	Arch     string
-	Kernel   string
-	Checked  bool
-	NewRec   bool
-	Changed  bool
+	IP      string
+	User    string
+	rawUser string
+	utils.SSHAuth
+	rawAuth utils.SSHAuth
+	// ProxyJump the jump host names separated by comma
+	ProxyJump    string
+	rawProxyJump string
+	// Become escalates the privileged commands of a non-root user
+	Become    utils.Become
+	rawBecome utils.Become
+	// Options the port, timeouts and algorithms of the connection
+	Options    utils.SSHOptions
+	rawOptions utils.SSHOptions
+	Status     string
+	// StatusTime when Status was checked, zero if never
+	StatusTime    time.Time
+	rawStatusTime time.Time
+	Probe         string        // the reachability probe which succeeded
+	Latency       time.Duration // measured by Probe
+	Hostname      string
+	rawHostname   string
+	OS            string
+	rawOS         string
+	Arch          string
+	rawArch       string
+	Kernel        string
+	rawKernel     string
+	Group         string
+	rawGroup      string
+	Tags          []string // sorted
+	rawTags       []string
+	Checked       bool
+	NewRec        bool
+	Changed       bool
 }
 
 type NodesState struct {
 	sync.RWMutex
 	Records []Node
+	Exec    utils.Executor    // nil means utils.DefaultExecutor
+	Query   dblayer.NodeQuery // the search of the saved nodes, Limit is PageSize
+	Total   int64             // the number of the saved nodes matching Query
+	// targetGroup the group Check and Delete are aimed at, empty means all
+	targetGroup string
 }
 
 type hostnamectlResult struct {
 	ipAddress       string
 	user            string
-	password        string
+	auth            utils.SSHAuth
+	options         utils.SSHOptions
+	jumps           []*utils.SSHConfig
 	status          string
+	probe           string
+	latency         time.Duration
 	hostname        string
 	architecture    string
 	operationSystem string
 	kernel          string
+	cancelled       bool
+}
+
+// Executor returns the executor of the remote operations
+func (n *NodesState) Executor() utils.Executor {
+	if n.Exec == nil {
+		return utils.DefaultExecutor
+	}
+	return n.Exec
 }
 
+// LoadAllRecords loads the page of the saved nodes matching Query, the
+// unsaved new records are kept.
 func (n *NodesState) LoadAllRecords() error {
-	repoNodes, err := dblayer.DB.ListNodes("")
+	n.RLock()
+	q := n.Query
+	n.RUnlock()
+	q.Limit = PageSize
+	repoNodes, err := dblayer.DB.ListNodes(q)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil
 		}
 		return err
 	}
+	total, err := dblayer.DB.CountNodes(q)
+	if err != nil {
+		return err
+	}
+	ips := make([]string, 0, len(repoNodes))
+	for _, repoNode := range repoNodes {
+		ips = append(ips, repoNode.IPAddress)
+	}
+	tags, err := dblayer.DB.ListNodeTags(ips)
+	if err != nil {
+		return err
+	}
 	n.Lock()
 	defer n.Unlock()
+	n.Total = total
 	if len(n.Records) == 0 {
-		for _, repoNode := range repoNodes {
-			n.Records = append(n.Records, Node{
-				IP:       repoNode.IPAddress,
-				User:     repoNode.UserName,
-				rawUser:  repoNode.UserName,
-				Password: repoNode.Password,
-				rawPwd:   repoNode.Password,
-				Status:   "unknown",
-				Hostname: repoNode.Hostname,
-				Arch:     repoNode.Architecture,
-				OS:       repoNode.OS,
-				Kernel:   repoNode.Kernel,
-			})
+		for i := range repoNodes {
+			n.Records = append(n.Records, recordFromRepo(&repoNodes[i], tags[repoNodes[i].IPAddress]))
 		}
+		return nil
 	}
 
-	repoNodesMap := make(map[string]repo.Node, len(repoNodes))
-	for _, repoNode := range repoNodes {
-		repoNodesMap[repoNode.IPAddress] = repoNode
+	repoNodesMap := make(map[string]*repo.Node, len(repoNodes))
+	for i := range repoNodes {
+		repoNodesMap[repoNodes[i].IPAddress] = &repoNodes[i]
 	}
 	for i, nod := range n.Records {
-		repoNode, ok := repoNodesMap[nod.IP]
-		if ok {
-			n.Records[i].NewRec = false
-			n.Records[i].Changed = false
-			n.Records[i].User = repoNode.UserName
-			n.Records[i].rawUser = repoNode.UserName
-			n.Records[i].Password = repoNode.Password
-			n.Records[i].rawPwd = repoNode.Password
-			n.Records[i].Hostname = repoNode.Hostname
-			n.Records[i].Arch = repoNode.Architecture
-			n.Records[i].OS = repoNode.OS
-			n.Records[i].Kernel = repoNode.Kernel
+		if repoNode, ok := repoNodesMap[nod.IP]; ok {
+			rec := recordFromRepo(repoNode, tags[nod.IP])
+			// the status of this session is newer than the saved one
+			if nod.StatusTime.After(rec.StatusTime) {
+				rec.Status, rec.StatusTime = nod.Status, nod.StatusTime
+			}
+			rec.Probe, rec.Latency = nod.Probe, nod.Latency
+			rec.Checked = nod.Checked
+			n.Records[i] = rec
 		}
 	}
-	pageNodesMap := make(map[string]Node, len(n.Records))
+	pageNodesMap := make(map[string]struct{}, len(n.Records))
 	for _, nod := range n.Records {
-		pageNodesMap[nod.IP] = nod
+		pageNodesMap[nod.IP] = struct{}{}
 	}
-	for _, repoNode := range repoNodes {
-		nod, ok := pageNodesMap[repoNode.IPAddress]
-		if !ok {
-			nod.Status = "unknown"
-			n.Records = append(n.Records, nod)
+	for i := range repoNodes {
+		if _, ok := pageNodesMap[repoNodes[i].IPAddress]; !ok {
+			n.Records = append(n.Records, recordFromRepo(&repoNodes[i], tags[repoNodes[i].IPAddress]))
 		}
 	}
 	return nil
 }
 
+// recordFromRepo converts the saved node and its tags to an unchanged record
+func recordFromRepo(repoNode *repo.Node, tags []string) Node {
+	auth := repoNodeAuth(repoNode)
+	become := repoNodeBecome(repoNode)
+	options := repoNodeOptions(repoNode)
+	status := repoNode.Status
+	if status == "" {
+		status = dblayer.StatusUnknown
+	}
+	return Node{
+		IP:            repoNode.IPAddress,
+		User:          repoNode.UserName,
+		rawUser:       repoNode.UserName,
+		SSHAuth:       auth,
+		rawAuth:       auth,
+		ProxyJump:     repoNode.ProxyJump,
+		rawProxyJump:  repoNode.ProxyJump,
+		Become:        become,
+		rawBecome:     become,
+		Options:       options,
+		rawOptions:    options,
+		Status:        status,
+		StatusTime:    repoNode.StatusTime,
+		rawStatusTime: repoNode.StatusTime,
+		Hostname:      repoNode.Hostname,
+		rawHostname:   repoNode.Hostname,
+		Arch:          repoNode.Architecture,
+		rawArch:       repoNode.Architecture,
+		OS:            repoNode.OS,
+		rawOS:         repoNode.OS,
+		Kernel:        repoNode.Kernel,
+		rawKernel:     repoNode.Kernel,
+		Group:         repoNode.GroupName,
+		rawGroup:      repoNode.GroupName,
+		Tags:          tags,
+		rawTags:       tags,
+	}
+}
+
 func (n *NodesState) MakeStatsMsg() string {
 	n.RLock()
 	defer n.RUnlock()
@@ -130,81 +219,64 @@ func (n *NodesState) MakeStatsMsg() string {
 	return fmt.Sprintf("Total: %d, New: %d, Changed: %d, Checked: %d", total, newRecs, changed, selected)
 }
 
-func (n *NodesState) AddNode(ip, user, password string) {
-
-	n.RLock()
-	tmpMap := make(map[string]struct{})
-	for _, rec := range n.Records {
-		tmpMap[rec.IP] = struct{}{}
+// AddNode adds the nodes of an address, a range or a cidr block of
+// utils.ExpandIP, the existing nodes are skipped. An invalid ip adds nothing
+// and returns the error.
+func (n *NodesState) AddNode(ip, user string, auth utils.SSHAuth) error {
+	ips, err := utils.ExpandIP(ip)
+	if err != nil {
+		return fmt.Errorf("add node %s failed, %v", ip, err)
 	}
-	n.RUnlock()
-
-	// sort records by ip address
-	defer func() {
-		sort.SliceStable(n.Records, func(i, j int) bool {
-			ip1 := net.ParseIP(n.Records[i].IP)
-			ip2 := net.ParseIP(n.Records[j].IP)
-			return ipToUint32(ip1) < ipToUint32(ip2)
-		})
-	}()
-
-	arr := strings.Split(ip, "-")
-	if len(arr) == 1 {
-		if _, ok := tmpMap[ip]; ok {
-			return
-		}
-		n.Lock()
-		defer n.Unlock()
-		n.Records = append(n.Records, Node{
-			IP:       ip,
-			User:     user,
-			Password: password,
-			Status:   "unknown",
-			NewRec:   true,
-		})
-		return
+	addrs := make([]NodeAddress, 0, len(ips))
+	for _, ip := range ips {
+		addrs = append(addrs, NodeAddress{IP: ip})
 	}
+	_, err = n.AddNodes(addrs, user, auth)
+	return err
+}
 
-	toNodeIP, _ := strconv.Atoi(arr[1])
-	tmp := strings.Split(arr[0], ".")
-	fromNodeIP, _ := strconv.Atoi(tmp[3])
-	if toNodeIP == fromNodeIP {
-		if _, ok := tmpMap[arr[0]]; ok {
-			return
-		}
-		n.Lock()
-		defer n.Unlock()
-		n.Records = append(n.Records, Node{
-			IP:       arr[0],
-			User:     user,
-			Password: password,
-			Status:   "unknown",
-			NewRec:   true,
-		})
-		return
+// AddNodes adds the addresses as new records and returns the number of the
+// added ones, the loaded records and the saved nodes off the page are
+// skipped.
+func (n *NodesState) AddNodes(addrs []NodeAddress, user string, auth utils.SSHAuth) (int, error) {
+	ips := make([]string, len(addrs))
+	for i, addr := range addrs {
+		ips[i] = addr.IP
 	}
-
-	// if to is less than from
-	if toNodeIP < fromNodeIP {
-		fromNodeIP, toNodeIP = toNodeIP, fromNodeIP
+	saved, err := dblayer.DB.SavedNodes(ips)
+	if err != nil {
+		return 0, fmt.Errorf("find the saved nodes failed, %v", err)
 	}
-
 	n.Lock()
 	defer n.Unlock()
-	for ; fromNodeIP <= toNodeIP; fromNodeIP++ {
-		tmp[3] = strconv.Itoa(fromNodeIP)
-		ip := strings.Join(tmp, ".")
-		if _, ok := tmpMap[ip]; ok {
+	tmpMap := make(map[string]struct{}, len(n.Records)+len(saved))
+	for _, rec := range n.Records {
+		tmpMap[rec.IP] = struct{}{}
+	}
+	for _, ip := range saved {
+		tmpMap[ip] = struct{}{}
+	}
+	added := 0
+	for _, addr := range addrs {
+		if _, ok := tmpMap[addr.IP]; ok {
 			continue
 		}
+		tmpMap[addr.IP] = struct{}{}
 		n.Records = append(n.Records, Node{
-			IP:       ip,
+			IP:       addr.IP,
 			User:     user,
-			Password: password,
+			SSHAuth:  auth,
+			Hostname: addr.Hostname,
 			Status:   "unknown",
 			NewRec:   true,
 		})
+		added++
 	}
+	// sort records by ip address
+	sort.SliceStable(n.Records, func(i, j int) bool {
+		return ipLess(n.Records[i].IP, n.Records[j].IP)
+	})
+	return added, nil
 }
 
 func (n *NodesState) SelectAllRecords() {
@@ -266,28 +338,56 @@ func (n *NodesState) SaveRecords() error {
 		nowaTime := time.Now().Local()
 		if rec.NewRec {
 			newRepos = append(newRepos, repo.Node{
-				IPAddress:    rec.IP,
-				UserName:     rec.User,
-				Password:     rec.Password,
-				Hostname:     rec.Hostname,
-				Architecture: rec.Arch,
-				OS:           rec.OS,
-				Kernel:       rec.Kernel,
-				CreateTime:   nowaTime,
-				UpdateTime:   nowaTime,
+				IPAddress:         rec.IP,
+				UserName:          rec.User,
+				Password:          rec.Password,
+				AuthType:          rec.AuthType,
+				KeyFile:           rec.KeyFile,
+				Passphrase:        rec.Passphrase,
+				CertFile:          rec.CertFile,
+				ProxyJump:         rec.ProxyJump,
+				BecomeMethod:      rec.Become.Method,
+				BecomePassword:    rec.Become.Password,
+				Hostname:          rec.Hostname,
+				Architecture:      rec.Arch,
+				OS:                rec.OS,
+				Kernel:            rec.Kernel,
+				GroupName:         rec.Group,
+				SSHPort:           rec.Options.Port,
+				ConnectTimeout:    int(rec.Options.ConnectTimeout / time.Second),
+				KeepaliveInterval: int(rec.Options.KeepaliveInterval / time.Second),
+				Ciphers:           strings.Join(rec.Options.Ciphers, ","),
+				KexAlgorithms:     strings.Join(rec.Options.KeyExchanges, ","),
+				Status:            rec.Status,
+				StatusTime:        rec.StatusTime,
+				CreateTime:        nowaTime,
+				UpdateTime:        nowaTime,
 			})
 			continue
 		}
 		if rec.Changed {
 			updRepos = append(updRepos, repo.Node{
-				IPAddress:    rec.IP,
-				UserName:     rec.User,
-				Password:     rec.Password,
-				Hostname:     rec.Hostname,
-				Architecture: rec.Arch,
-				OS:           rec.OS,
-				Kernel:       rec.Kernel,
-				UpdateTime:   nowaTime,
+				IPAddress:         rec.IP,
+				UserName:          rec.User,
+				Password:          rec.Password,
+				AuthType:          rec.AuthType,
+				KeyFile:           rec.KeyFile,
+				Passphrase:        rec.Passphrase,
+				CertFile:          rec.CertFile,
+				ProxyJump:         rec.ProxyJump,
+				BecomeMethod:      rec.Become.Method,
+				BecomePassword:    rec.Become.Password,
+				Hostname:          rec.Hostname,
+				Architecture:      rec.Arch,
+				OS:                rec.OS,
+				Kernel:            rec.Kernel,
+				GroupName:         rec.Group,
+				SSHPort:           rec.Options.Port,
+				ConnectTimeout:    int(rec.Options.ConnectTimeout / time.Second),
+				KeepaliveInterval: int(rec.Options.KeepaliveInterval / time.Second),
+				Ciphers:           strings.Join(rec.Options.Ciphers, ","),
+				KexAlgorithms:     strings.Join(rec.Options.KeyExchanges, ","),
+				UpdateTime:        nowaTime,
 			})
 		}
 	}
@@ -295,6 +395,14 @@ func (n *NodesState) SaveRecords() error {
 		if err := dblayer.DB.AddNodes(newRepos); err != nil {
 			return err
 		}
+		// the added nodes are updated by the next save if a later step fails
+		for i := range n.Records {
+			if n.Records[i].NewRec {
+				n.Records[i].NewRec = false
+				n.Records[i].Changed = true
+				n.Records[i].rawStatusTime = n.Records[i].StatusTime
+			}
+		}
 	}
 	if len(updRepos) > 0 {
 		for _, r := range updRepos {
@@ -303,24 +411,60 @@ func (n *NodesState) SaveRecords() error {
 			}
 		}
 	}
+	for i, rec := range n.Records {
+		if !rec.Changed {
+			continue
+		}
+		if !sameTags(rec.Tags, rec.rawTags) {
+			if err := dblayer.DB.SetNodeTags(rec.IP, rec.Tags); err != nil {
+				return err
+			}
+		}
+		// the record stays saved if it is not on the reloaded page
+		n.Records[i].markSaved()
+	}
 	return nil
 }
 
+// markSaved makes the current values of the saved record the raw ones
+func (rec *Node) markSaved() {
+	rec.rawUser = rec.User
+	rec.rawAuth = rec.SSHAuth
+	rec.rawProxyJump = rec.ProxyJump
+	rec.rawBecome = rec.Become
+	rec.rawOptions = rec.Options
+	rec.rawHostname = rec.Hostname
+	rec.rawOS = rec.OS
+	rec.rawArch = rec.Arch
+	rec.rawKernel = rec.Kernel
+	rec.rawGroup = rec.Group
+	rec.rawTags = slices.Clone(rec.Tags)
+	rec.Changed = false
+}
+
 func (n *NodesState) GetNodeRecord(id int) Node {
 	n.Lock()
 	defer n.Unlock()
 	return Node{
-		IP:       n.Records[id].IP,
-		User:     n.Records[id].User,
-		Password: n.Records[id].Password,
-		Status:   n.Records[id].Status,
-		Hostname: n.Records[id].Hostname,
-		Arch:     n.Records[id].Arch,
-		OS:       n.Records[id].OS,
-		Kernel:   n.Records[id].Kernel,
-		Checked:  n.Records[id].Checked,
-		NewRec:   n.Records[id].NewRec,
-		Changed:  n.Records[id].Changed,
+		IP:         n.Records[id].IP,
+		User:       n.Records[id].User,
+		SSHAuth:    n.Records[id].SSHAuth,
+		ProxyJump:  n.Records[id].ProxyJump,
+		Become:     n.Records[id].Become,
+		Options:    n.Records[id].Options,
+		Status:     n.Records[id].Status,
+		StatusTime: n.Records[id].StatusTime,
+		Probe:      n.Records[id].Probe,
+		Latency:    n.Records[id].Latency,
+		Hostname:   n.Records[id].Hostname,
+		Arch:       n.Records[id].Arch,
+		OS:         n.Records[id].OS,
+		Kernel:     n.Records[id].Kernel,
+		Group:      n.Records[id].Group,
+		Tags:       slices.Clone(n.Records[id].Tags),
+		Checked:    n.Records[id].Checked,
+		NewRec:     n.Records[id].NewRec,
+		Changed:    n.Records[id].Changed,
 	}
 }
 
@@ -333,33 +477,119 @@ func (n *NodesState) CheckedRecord(id int, checked bool) {
 func (n *NodesState) ChangeUser(id int, user string) {
 	n.Lock()
 	defer n.Unlock()
-	if n.Records[id].User == user {
-		n.Records[id].Changed = false
-		return
-	}
-	if n.Records[id].rawUser == user {
-		n.Records[id].User = user
-		n.Records[id].Changed = false
-		return
-	}
 	n.Records[id].User = user
-	n.Records[id].Changed = true
+	n.recordChanged(id)
 }
 
 func (n *NodesState) ChangePassword(id int, password string) {
 	n.Lock()
 	defer n.Unlock()
-	if n.Records[id].Password == password {
-		n.Records[id].Changed = false
-		return
+	n.Records[id].Password = password
+	n.recordChanged(id)
+}
+
+func (n *NodesState) ChangeKeyFile(id int, keyFile string) {
+	n.Lock()
+	defer n.Unlock()
+	n.Records[id].KeyFile = keyFile
+	n.recordChanged(id)
+}
+
+func (n *NodesState) ChangeAuth(id int, auth utils.SSHAuth) {
+	n.Lock()
+	defer n.Unlock()
+	n.Records[id].SSHAuth = auth
+	n.recordChanged(id)
+}
+
+// ChangeBecome sets the privilege escalation of the node
+func (n *NodesState) ChangeBecome(id int, become utils.Become) {
+	n.Lock()
+	defer n.Unlock()
+	n.Records[id].Become = become
+	n.recordChanged(id)
+}
+
+// ChangeOptions sets the connection options of the node
+func (n *NodesState) ChangeOptions(id int, options utils.SSHOptions) {
+	n.Lock()
+	defer n.Unlock()
+	n.Records[id].Options = options
+	n.recordChanged(id)
+}
+
+// recordChanged flags the record as changed if any saved field differs
+// from the value loaded from the database, reverting one field keeps the
+// pending edits of the others.
+func (n *NodesState) recordChanged(id int) {
+	rec := &n.Records[id]
+	rec.Changed = rec.User != rec.rawUser ||
+		rec.SSHAuth != rec.rawAuth ||
+		rec.ProxyJump != rec.rawProxyJump ||
+		rec.Become != rec.rawBecome ||
+		!sameOptions(rec.Options, rec.rawOptions) ||
+		rec.Hostname != rec.rawHostname ||
+		rec.OS != rec.rawOS ||
+		rec.Arch != rec.rawArch ||
+		rec.Kernel != rec.rawKernel ||
+		rec.Group != rec.rawGroup ||
+		!sameTags(rec.Tags, rec.rawTags)
+}
+
+func sameOptions(o1, o2 utils.SSHOptions) bool {
+	return o1.Port == o2.Port && o1.ConnectTimeout == o2.ConnectTimeout &&
+		o1.KeepaliveInterval == o2.KeepaliveInterval &&
+		slices.Equal(o1.Ciphers, o2.Ciphers) && slices.Equal(o1.KeyExchanges, o2.KeyExchanges)
+}
+
+// ChangeProxyJump sets the jump host chain of the node
+func (n *NodesState) ChangeProxyJump(id int, proxyJump string) {
+	n.Lock()
+	defer n.Unlock()
+	n.changeProxyJump(id, proxyJump)
+}
+
+// SetCheckedProxyJump sets the jump host chain of all checked nodes,
+// it returns the number of the changed nodes.
+func (n *NodesState) SetCheckedProxyJump(proxyJump string) int {
+	n.Lock()
+	defer n.Unlock()
+	cnt := 0
+	for id, rec := range n.Records {
+		if rec.Checked && rec.ProxyJump != proxyJump {
+			n.changeProxyJump(id, proxyJump)
+			cnt++
+		}
 	}
-	if n.Records[id].rawPwd == password {
-		n.Records[id].Password = password
-		n.Records[id].Changed = false
+	return cnt
+}
+
+func (n *NodesState) changeProxyJump(id int, proxyJump string) {
+	proxyJump = strings.Join(SplitProxyJump(proxyJump), ",")
+	if n.Records[id].ProxyJump == proxyJump {
 		return
 	}
-	n.Records[id].Password = password
-	n.Records[id].Changed = true
+	n.Records[id].ProxyJump = proxyJump
+	n.recordChanged(id)
+}
+
+// Connection returns the connection of the record with its jump host chain
+func (n *NodesState) Connection(id int) (SSHConnection, error) {
+	n.RLock()
+	rec := n.Records[id]
+	n.RUnlock()
+	jumps, err := resolveJumps([]string{rec.ProxyJump})
+	if err != nil {
+		return SSHConnection{}, err
+	}
+	return SSHConnection{
+		IPAddress: rec.IP,
+		User:      rec.User,
+		SSHAuth:   rec.SSHAuth,
+		Become:    rec.Become,
+		Options:   rec.Options,
+		Jumps:     jumps[rec.ProxyJump],
+	}, nil
 }
 
 func (n *NodesState) GetFillColor(id int) color.Color {
@@ -374,6 +604,24 @@ func (n *NodesState) GetFillColor(id int) color.Color {
 	}
 }
 
+// Address returns host:port of the node
+func (nod *Node) Address() string {
+	conf := utils.SSHConfig{Host: nod.IP, Options: nod.Options}
+	return conf.Address()
+}
+
+// StatusText shows the status with the probe and latency which proved it
+func (nod *Node) StatusText() string {
+	if nod.Probe == "" {
+		return nod.Status
+	}
+	latency := nod.Latency.Round(100 * time.Microsecond)
+	if nod.Latency >= time.Second {
+		latency = nod.Latency.Round(10 * time.Millisecond)
+	}
+	return fmt.Sprintf("%s %s %v", nod.Status, nod.Probe, latency)
+}
+
 func (n *NodesState) GetStatusColor(status string) color.Color {
 	switch status {
 	case "online":
@@ -383,92 +631,156 @@ func (n *NodesState) GetStatusColor(status string) color.Color {
 	}
 }
 
-func (n *NodesState) CheckNodesStatus() {
+// CheckProgress reports a checked node
+type CheckProgress struct {
+	Done  int
+	Total int
+	ID    int // index of the record, -1 if it was removed meanwhile
+}
+
+// checkOptions limits the concurrent probes of Check
+type checkOptions struct {
+	probes      []string
+	workers     int
+	hostTimeout time.Duration
+}
+
+// CheckNodesStatus detects the status of the nodes of the target group, onProgress is called
+// after the record of each node was updated. It returns CancelledError if
+// ctx was cancelled before all nodes were checked.
+func (n *NodesState) CheckNodesStatus(ctx context.Context, onProgress func(CheckProgress)) error {
 	ipList := make([]hostnamectlResult, 0, len(n.Records))
+	proxyJumps := make([]string, 0, len(n.Records))
 	n.RLock()
 	for _, rec := range n.Records {
+		if !n.inTarget(&rec) {
+			continue
+		}
 		ipList = append(ipList,
 			hostnamectlResult{
 				ipAddress: rec.IP,
 				user:      rec.User,
-				password:  rec.Password,
+				auth:      rec.SSHAuth,
+				options:   rec.Options,
 			},
 		)
+		proxyJumps = append(proxyJumps, rec.ProxyJump)
 	}
 	n.RUnlock()
-	n.detectStatus(ipList)
+	jumps, err := resolveJumps(proxyJumps)
+	if err != nil {
+		return err
+	}
+	for i := range ipList {
+		ipList[i].jumps = jumps[proxyJumps[i]]
+	}
+	opts := checkOptions{
+		probes:      config.Conf.Reachability.ProbeList(),
+		workers:     config.Conf.Check.WorkerCount(),
+		hostTimeout: config.Conf.Check.Timeout(),
+	}
+	return n.detectStatus(ctx, ipList, opts, onProgress)
 }
 
-func (n *NodesState) detectStatus(ipList []hostnamectlResult) {
+// detectStatus checks the nodes with a bounded number of workers,
+// each node is given opts.hostTimeout.
+func (n *NodesState) detectStatus(ctx context.Context, ipList []hostnamectlResult, opts checkOptions, onProgress func(CheckProgress)) error {
 	if len(ipList) == 0 {
-		return
-	}
-	resultCh := make(chan *hostnamectlResult, len(ipList))
-	defer close(resultCh)
-
-	var wg sync.WaitGroup
-	for _, hnc := range ipList {
-		wg.Add(1)
-		go func(hnc *hostnamectlResult) {
-			defer wg.Done()
-			if pingable, err := utils.Ping(hnc.ipAddress); err == nil {
-				hnc.status = "online"
-				if pingable {
-					if err := hnc.getHostnamectl(); err != nil {
-						logger.Errorf("get hostnamectl command result failed, %v\n", err)
-					}
-					resultCh <- hnc
-				} else {
-					hnc.status = "offline"
-					resultCh <- hnc
-				}
-			} else {
-				logger.Errorf("ping '%s' error, %v\n", hnc.ipAddress, err)
-				hnc.status = "unknown"
-				resultCh <- hnc
-			}
-		}(&hnc)
+		return nil
 	}
-	wg.Wait()
-	cnt := 0
-	statusMap := make(map[string]*hostnamectlResult, len(ipList))
-	for hnc := range resultCh {
-		statusMap[hnc.ipAddress] = hnc
-		if cnt++; cnt == len(ipList) {
-			break
+	exec := n.Executor()
+	ips := make([]string, len(ipList))
+	for i := range ipList {
+		ips[i] = ipList[i].ipAddress
+	}
+	_, err := forEachNode(ctx, ips, opts.workers, func(i int) (struct{}, error) {
+		hnc := &ipList[i]
+		hostCtx, cancel := context.WithTimeout(ctx, opts.hostTimeout)
+		defer cancel()
+		hnc.detect(hostCtx, exec, opts.probes)
+		if hnc.cancelled {
+			return struct{}{}, context.Canceled
 		}
-	}
+		return struct{}{}, nil
+	}, func(i, done int, err error) {
+		hnc := &ipList[i]
+		if hnc.status == "" {
+			// the queued node is not probed after cancelling
+			hnc.status = "unknown"
+			hnc.cancelled = errors.Is(err, context.Canceled)
+		}
+		id := n.applyStatus(hnc)
+		if onProgress != nil {
+			onProgress(CheckProgress{Done: done, Total: len(ipList), ID: id})
+		}
+	})
+	return err
+}
 
+// applyStatus updates the record of the checked node and returns its index
+func (n *NodesState) applyStatus(hnc *hostnamectlResult) int {
 	n.Lock()
 	defer n.Unlock()
 	for idx, rec := range n.Records {
-		if hnc, ok := statusMap[rec.IP]; ok {
-			if n.Records[idx].Status != hnc.status {
-				n.Records[idx].Status = hnc.status
-			}
-			if hnc.hostname != "" && n.Records[idx].Hostname != hnc.hostname {
-				n.Records[idx].Hostname = hnc.hostname
-				n.Records[idx].Changed = true
-			}
-			if hnc.architecture != "" && n.Records[idx].Arch != hnc.architecture {
-				n.Records[idx].Arch = hnc.architecture
-				n.Records[idx].Changed = true
-			}
-			if hnc.operationSystem != "" && n.Records[idx].OS != hnc.operationSystem {
-				n.Records[idx].OS = hnc.operationSystem
-				n.Records[idx].Changed = true
-			}
-			kernel := strings.TrimPrefix(hnc.kernel, "Linux ")
-			if hnc.kernel != "" && n.Records[idx].Kernel != kernel {
-				n.Records[idx].Kernel = kernel
-				n.Records[idx].Changed = true
-			}
+		if rec.IP != hnc.ipAddress {
+			continue
+		}
+		// keep the previous status of the cancelled node
+		if !hnc.cancelled {
+			n.Records[idx].Status = hnc.status
+			n.Records[idx].StatusTime = time.Now().Local()
+			n.Records[idx].Probe = hnc.probe
+			n.Records[idx].Latency = hnc.latency
+		}
+		rec := &n.Records[idx]
+		kernel := strings.TrimPrefix(hnc.kernel, "Linux ")
+		if (hnc.hostname != "" && rec.Hostname != hnc.hostname) ||
+			(hnc.architecture != "" && rec.Arch != hnc.architecture) ||
+			(hnc.operationSystem != "" && rec.OS != hnc.operationSystem) ||
+			(hnc.kernel != "" && rec.Kernel != kernel) {
+			rec.Hostname = cmp.Or(hnc.hostname, rec.Hostname)
+			rec.Arch = cmp.Or(hnc.architecture, rec.Arch)
+			rec.OS = cmp.Or(hnc.operationSystem, rec.OS)
+			rec.Kernel = cmp.Or(kernel, rec.Kernel)
+			n.recordChanged(idx)
+		}
+		return idx
+	}
+	return -1
+}
+
+// detect probes the node and reads its host information
+func (hnc *hostnamectlResult) detect(ctx context.Context, exec utils.Executor, probes []string) {
+	reach, err := exec.Reach(ctx, hnc.config(), probes)
+	if err != nil {
+		logger.Errorf("probe '%s' error, %v\n", hnc.ipAddress, err)
+		hnc.status = "unknown"
+		hnc.cancelled = errors.Is(err, context.Canceled)
+		return
+	}
+	if !reach.Reachable {
+		hnc.status = "offline"
+		return
+	}
+	hnc.status = "online"
+	hnc.probe = reach.Probe
+	hnc.latency = reach.Latency
+	if err := hnc.getHostnamectl(ctx, exec); err != nil {
+		logger.Errorf("get hostnamectl command result failed, %v\n", err)
+		var mismatchErr *utils.HostKeyMismatchError
+		if errors.As(err, &mismatchErr) {
+			hnc.status = StatusHostKeyChanged
 		}
+		hnc.cancelled = errors.Is(err, context.Canceled)
 	}
 }
 
-func (hnc *hostnamectlResult) getHostnamectl() error {
-	data, err := utils.RemoteCmd(hnc.ipAddress, hnc.user, hnc.password, "hostnamectl")
+func (hnc *hostnamectlResult) config() *utils.SSHConfig {
+	return &utils.SSHConfig{Host: hnc.ipAddress, User: hnc.user, SSHAuth: hnc.auth, Options: hnc.options, Jumps: hnc.jumps}
+}
+
+func (hnc *hostnamectlResult) getHostnamectl(ctx context.Context, exec utils.Executor) error {
+	data, err := exec.RemoteCmd(ctx, hnc.config(), "hostnamectl")
 	if err != nil {
 		return err
 	}
@@ -493,10 +805,48 @@ func (hnc *hostnamectlResult) getHostnamectl() error {
 	return nil
 }
 
-func ipToUint32(ip net.IP) uint32 {
-	ip = ip.To4()
-	if ip == nil {
-		return 0
+func sortIPs(ips []string) {
+	sort.Slice(ips, func(i, j int) bool {
+		return ipLess(ips[i], ips[j])
+	})
+}
+
+// ipLess orders the ipv4 addresses before the ipv6 ones, the invalid
+// addresses come first
+func ipLess(ip1, ip2 string) bool {
+	addr1, _ := netip.ParseAddr(ip1)
+	addr2, _ := netip.ParseAddr(ip2)
+	return addr1.Unmap().Less(addr2.Unmap())
+}
+
+func repoNodeAuth(repoNode *repo.Node) utils.SSHAuth {
+	authType := repoNode.AuthType
+	if authType == "" {
+		authType = utils.AuthPassword
+	}
+	return utils.SSHAuth{
+		AuthType:   authType,
+		Password:   repoNode.Password,
+		KeyFile:    repoNode.KeyFile,
+		Passphrase: repoNode.Passphrase,
+		CertFile:   repoNode.CertFile,
+	}
+}
+
+func repoNodeOptions(repoNode *repo.Node) utils.SSHOptions {
+	return utils.SSHOptions{
+		Port:              repoNode.SSHPort,
+		ConnectTimeout:    time.Duration(repoNode.ConnectTimeout) * time.Second,
+		KeepaliveInterval: time.Duration(repoNode.KeepaliveInterval) * time.Second,
+		Ciphers:           utils.SplitAlgorithms(repoNode.Ciphers),
+		KeyExchanges:      utils.SplitAlgorithms(repoNode.KexAlgorithms),
+	}
+}
+
+func repoNodeBecome(repoNode *repo.Node) utils.Become {
+	method := repoNode.BecomeMethod
+	if method == "" {
+		method = utils.BecomeNone
 	}
-	return uint32(ip[0])<<24 | uint32(ip[1])<<16 | uint32(ip[2])<<8 | uint32(ip[3])
+	return utils.Become{Method: method, Password: repoNode.BecomePassword}
 }
@@ -0,0 +1,274 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luo2pei4/ltool/pkg/dblayer"
+	"github.com/luo2pei4/ltool/pkg/dblayer/repo"
+	"github.com/luo2pei4/ltool/pkg/utils"
+	"github.com/luo2pei4/ltool/pkg/utils/sshtest"
+)
+
+const hostnamectlOutput = `   Static hostname: oss01
+         Icon name: computer-vm
+           Chassis: vm
+        Machine ID: 4d1d0c6d3a6d4b3c9a0f6c3d2e1f0a9b
+           Boot ID: 8a7b6c5d4e3f2a1b0c9d8e7f6a5b4c3d
+    Virtualization: vmware
+  Operating System: Rocky Linux 8.10 (Green Obsidian)
+       CPE OS Name: cpe:/o:rocky:rocky:8:GA
+            Kernel: Linux 4.18.0-553.el8_10.x86_64
+      Architecture: x86-64
+`
+
+func TestAddNode(t *testing.T) {
+	useTestDB(t)
+	n := &NodesState{}
+	auth := utils.SSHAuth{AuthType: utils.AuthPassword, Password: "secret"}
+	for _, ip := range []string{"192.168.1.10-12", "192.168.1.9", "10.0.0.1-1", "2001:db8::a", "2001:DB8::9"} {
+		if err := n.AddNode(ip, "root", auth); err != nil {
+			t.Errorf("AddNode(%s) failed, %v", ip, err)
+		}
+	}
+	if err := n.AddNode("192.168.1.11", "admin", auth); err != nil {
+		t.Error(err)
+	}
+	// the invalid specs add nothing and report why
+	for _, ip := range []string{"192.168.1.14-13", "192.168.1.300", "node01"} {
+		if err := n.AddNode(ip, "root", auth); err == nil || !strings.Contains(err.Error(), ip) {
+			t.Errorf("AddNode(%s) error = %v", ip, err)
+		}
+	}
+
+	var ips []string
+	for _, rec := range n.Records {
+		ips = append(ips, rec.IP)
+		if !rec.NewRec || rec.Status != "unknown" {
+			t.Errorf("record %+v is not a new record", rec)
+		}
+	}
+	want := []string{"10.0.0.1", "192.168.1.9", "192.168.1.10", "192.168.1.11", "192.168.1.12",
+		"2001:db8::9", "2001:db8::a"}
+	if !reflect.DeepEqual(ips, want) {
+		t.Errorf("records are %v, want %v", ips, want)
+	}
+	if n.Records[3].User != "root" {
+		t.Errorf("existing record was overwritten, user is %s", n.Records[3].User)
+	}
+
+	// the saved nodes off the page are skipped too
+	if err := dblayer.DB.AddNodes([]repo.Node{{IPAddress: "10.0.0.2", UserName: "root"}}); err != nil {
+		t.Fatal(err)
+	}
+	added, err := n.AddNodes([]NodeAddress{{IP: "10.0.0.2"}, {IP: "10.0.0.3"}}, "root", auth)
+	if err != nil || added != 1 {
+		t.Errorf("added %d nodes, %v", added, err)
+	}
+}
+
+func TestSaveRecordsOffPage(t *testing.T) {
+	useTestDB(t)
+	auth := utils.SSHAuth{AuthType: utils.AuthPassword, Password: "secret"}
+	n := &NodesState{}
+	if err := n.Search(dblayer.NodeQuery{Group: "oss"}); err != nil {
+		t.Fatal(err)
+	}
+	// the new node is not in the group of the search
+	if err := n.AddNode("192.168.1.10", "root", auth); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := n.SaveRecords(); err != nil {
+			t.Fatalf("save %d failed, %v", i+1, err)
+		}
+		if err := n.LoadAllRecords(); err != nil {
+			t.Fatal(err)
+		}
+		if n.HasUnsavedChanges() {
+			t.Errorf("save %d left unsaved records, %+v", i+1, n.Records)
+		}
+	}
+	n.ChangeUser(0, "admin")
+	if err := n.SaveRecords(); err != nil {
+		t.Fatal(err)
+	}
+	if node, err := dblayer.DB.FindNode("192.168.1.10"); err != nil || node.UserName != "admin" {
+		t.Errorf("saved node %+v, %v", node, err)
+	}
+}
+
+func TestChangeRecord(t *testing.T) {
+	auth := utils.SSHAuth{AuthType: utils.AuthPassword, Password: "secret"}
+	n := &NodesState{Records: []Node{{IP: "192.168.1.10", User: "root", rawUser: "root", SSHAuth: auth, rawAuth: auth}}}
+
+	n.ChangeUser(0, "admin")
+	if !n.Records[0].Changed {
+		t.Error("changing user was not marked")
+	}
+	n.ChangeUser(0, "root")
+	if n.Records[0].Changed {
+		t.Error("restoring user was still marked changed")
+	}
+	n.ChangePassword(0, "other")
+	if !n.Records[0].Changed {
+		t.Error("changing password was not marked")
+	}
+	n.ChangePassword(0, "secret")
+	if n.Records[0].Changed {
+		t.Error("restoring password was still marked changed")
+	}
+
+	keyAuth := utils.SSHAuth{AuthType: utils.AuthKey, KeyFile: "~/.ssh/id_ed25519"}
+	n.ChangeAuth(0, keyAuth)
+	if !n.Records[0].Changed || n.Records[0].AuthType != utils.AuthKey {
+		t.Errorf("changing auth was not applied, %+v", n.Records[0])
+	}
+	n.ChangeKeyFile(0, "~/.ssh/id_rsa")
+	if n.Records[0].KeyFile != "~/.ssh/id_rsa" {
+		t.Errorf("key file is %s", n.Records[0].KeyFile)
+	}
+	n.ChangeAuth(0, auth)
+	if n.Records[0].Changed {
+		t.Error("restoring auth was still marked changed")
+	}
+
+	n.ChangeOptions(0, utils.SSHOptions{Port: 2222, Ciphers: []string{"aes256-ctr"}})
+	if !n.Records[0].Changed || n.Records[0].Address() != "192.168.1.10:2222" {
+		t.Errorf("changing options was not applied, %+v", n.Records[0])
+	}
+	conn, err := n.Connection(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf := conn.Config(); conf.Address() != "192.168.1.10:2222" || conf.Options.Ciphers[0] != "aes256-ctr" {
+		t.Errorf("connection options are %+v", conf.Options)
+	}
+	n.ChangeOptions(0, utils.SSHOptions{})
+	if n.Records[0].Changed {
+		t.Error("restoring options was still marked changed")
+	}
+
+	// reverting a field keeps the pending edits of the others
+	n.ChangeOptions(0, utils.SSHOptions{Port: 2222})
+	n.ChangeBecome(0, utils.Become{Method: utils.BecomeSudo})
+	n.ChangeBecome(0, utils.Become{})
+	n.ChangeUser(0, "root")
+	if !n.Records[0].Changed {
+		t.Error("reverting become dropped the changed options")
+	}
+	n.ChangeOptions(0, utils.SSHOptions{})
+	if n.Records[0].Changed {
+		t.Error("reverting all fields was still marked changed")
+	}
+}
+
+func TestCheckNodesStatus(t *testing.T) {
+	srv, exec := newTestServer(t)
+	srv.Handle("hostnamectl", sshtest.Reply{Stdout: hostnamectlOutput})
+	exec.reachable["192.168.1.10"] = true
+
+	auth := utils.SSHAuth{AuthType: utils.AuthPassword, Password: "secret"}
+	n := &NodesState{
+		Exec: exec,
+		Records: []Node{
+			{IP: "192.168.1.10", User: "root", SSHAuth: auth, Status: "unknown"},
+			{IP: "192.168.1.11", User: "root", SSHAuth: auth, Status: "unknown"},
+		},
+	}
+	if err := n.CheckNodesStatus(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	online := n.Records[0]
+	if online.Status != "online" || online.Hostname != "oss01" || online.Arch != "x86-64" ||
+		online.OS != "Rocky Linux 8.10 (Green Obsidian)" || online.Kernel != "4.18.0-553.el8_10.x86_64" {
+		t.Errorf("online node is %+v", online)
+	}
+	if got := online.StatusText(); got != "online tcp 1.2ms" {
+		t.Errorf("status text = %q", got)
+	}
+	if !online.Changed {
+		t.Error("detected host information was not marked changed")
+	}
+	if offline := n.Records[1]; offline.Status != "offline" || offline.Changed {
+		t.Errorf("offline node is %+v", offline)
+	}
+}
+
+func TestCheckNodesStatusCancelled(t *testing.T) {
+	srv, exec := newTestServer(t)
+	srv.Handle("hostnamectl", sshtest.Reply{Stdout: hostnamectlOutput, Delay: time.Minute})
+	exec.reachable["192.168.1.10"] = true
+
+	auth := utils.SSHAuth{AuthType: utils.AuthPassword, Password: "secret"}
+	n := &NodesState{
+		Exec: exec,
+		Records: []Node{
+			{IP: "192.168.1.10", User: "root", SSHAuth: auth, Status: "unknown"},
+			{IP: "192.168.1.11", User: "root", SSHAuth: auth, Status: "online"},
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(300*time.Millisecond, cancel)
+	err := n.CheckNodesStatus(ctx, nil)
+	var cancelledErr *CancelledError
+	if !errors.As(err, &cancelledErr) {
+		t.Fatalf("CheckNodesStatus error = %v, want CancelledError", err)
+	}
+	if !reflect.DeepEqual(cancelledErr.Finished, []string{"192.168.1.11"}) ||
+		!reflect.DeepEqual(cancelledErr.Cancelled, []string{"192.168.1.10"}) {
+		t.Errorf("cancelled error = %+v", cancelledErr)
+	}
+	if n.Records[0].Status != "unknown" || n.Records[0].Hostname != "" {
+		t.Errorf("cancelled node was updated, %+v", n.Records[0])
+	}
+	if n.Records[1].Status != "offline" {
+		t.Errorf("finished node status is %s", n.Records[1].Status)
+	}
+}
+
+func TestDetectStatusBounded(t *testing.T) {
+	exec := &testExecutor{reachable: map[string]bool{}, delay: 50 * time.Millisecond}
+	n := &NodesState{Exec: exec}
+	var ipList []hostnamectlResult
+	for i := 1; i <= 10; i++ {
+		ip := fmt.Sprintf("192.168.1.%d", i)
+		n.Records = append(n.Records, Node{IP: ip, Status: "unknown"})
+		ipList = append(ipList, hostnamectlResult{ipAddress: ip})
+	}
+	var progress []CheckProgress
+	opts := checkOptions{workers: 3, hostTimeout: time.Second}
+	err := n.detectStatus(context.Background(), ipList, opts, func(p CheckProgress) {
+		progress = append(progress, p)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exec.maxActive > 3 {
+		t.Errorf("%d nodes were probed at the same time, want at most 3", exec.maxActive)
+	}
+	if len(progress) != 10 || progress[9].Done != 10 || progress[9].Total != 10 {
+		t.Fatalf("progress = %+v", progress)
+	}
+	for _, p := range progress {
+		if n.Records[p.ID].Status != "offline" {
+			t.Errorf("record %d was reported before it was updated, %+v", p.ID, n.Records[p.ID])
+		}
+	}
+
+	// the slow node runs out of its own time
+	exec.delay = time.Second
+	opts.hostTimeout = 100 * time.Millisecond
+	start := time.Now()
+	if err := n.detectStatus(context.Background(), ipList[:1], opts, nil); err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) > 500*time.Millisecond || n.Records[0].Status != "unknown" {
+		t.Errorf("timed out node is %+v after %v", n.Records[0], time.Since(start))
+	}
+}
@@ -0,0 +1,157 @@
+package state
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	logger "github.com/luo2pei4/ltool/pkg/log"
+	"github.com/luo2pei4/ltool/pkg/utils"
+)
+
+// RunResult the result of an ad-hoc command on a node
+type RunResult struct {
+	IP         string
+	Stdout     string
+	Stderr     string
+	ExitStatus int
+	Duration   time.Duration
+	Err        string // the command could not run, e.g. the node is unreachable
+}
+
+// RunGroup the nodes with identical output, like clush -b
+type RunGroup struct {
+	IPs    []string
+	Result RunResult // the result of the first node
+}
+
+// CheckedConnections returns the connections of the checked records
+func (n *NodesState) CheckedConnections() ([]SSHConnection, error) {
+	var conns []SSHConnection
+	var proxyJumps []string
+	n.RLock()
+	for _, rec := range n.Records {
+		if !rec.Checked {
+			continue
+		}
+		conns = append(conns, SSHConnection{
+			IPAddress: rec.IP,
+			User:      rec.User,
+			SSHAuth:   rec.SSHAuth,
+			Become:    rec.Become,
+			Options:   rec.Options,
+		})
+		proxyJumps = append(proxyJumps, rec.ProxyJump)
+	}
+	n.RUnlock()
+	jumps, err := resolveJumps(proxyJumps)
+	if err != nil {
+		return nil, err
+	}
+	for i := range conns {
+		conns[i].Jumps = jumps[proxyJumps[i]]
+	}
+	return conns, nil
+}
+
+// RunCommand runs cmd on the nodes with at most workers at the same time,
+// onProgress is called after each node finished. The results are sorted by
+// ip address, CancelledError is returned with the results of the finished
+// nodes if ctx was cancelled.
+func RunCommand(ctx context.Context, exec utils.Executor, conns []SSHConnection, cmd string,
+	workers int, onProgress func(done, total int)) ([]RunResult, error) {
+
+	nodes, err := forEachNode(ctx, connIPs(conns), workers, func(i int) (*utils.CmdResult, error) {
+		result, err := exec.RemoteRun(ctx, conns[i].Config(), cmd)
+		if err != nil {
+			logger.Errorf("run '%s' on %s error, %v", cmd, conns[i].IPAddress, err)
+		}
+		return result, err
+	}, progressOf(onProgress, len(conns)))
+	results := make([]RunResult, len(nodes))
+	for i, node := range nodes {
+		results[i].IP = node.IP
+		if node.Err != nil {
+			results[i].Err = node.Err.Error()
+			continue
+		}
+		results[i].Stdout = string(node.Value.Stdout)
+		results[i].Stderr = string(node.Value.Stderr)
+		results[i].ExitStatus = node.Value.ExitStatus
+		results[i].Duration = node.Value.Duration
+	}
+	return results, err
+}
+
+// GroupResults groups the nodes by identical output, exit status and error,
+// the biggest group comes first.
+func GroupResults(results []RunResult) []RunGroup {
+	var groups []RunGroup
+	index := make(map[string]int)
+	for _, result := range results {
+		key := fmt.Sprintf("%d\x00%s\x00%s\x00%s", result.ExitStatus, result.Err, result.Stdout, result.Stderr)
+		if i, ok := index[key]; ok {
+			groups[i].IPs = append(groups[i].IPs, result.IP)
+			continue
+		}
+		index[key] = len(groups)
+		groups = append(groups, RunGroup{IPs: []string{result.IP}, Result: result})
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		return len(groups[i].IPs) > len(groups[j].IPs)
+	})
+	return groups
+}
+
+// FormatResults renders the results per node, or per group of identical output
+func FormatResults(results []RunResult, grouped bool) string {
+	var b strings.Builder
+	if grouped {
+		for _, group := range GroupResults(results) {
+			title := fmt.Sprintf("%s (%d nodes, %s)", strings.Join(group.IPs, ","), len(group.IPs), exitText(&group.Result))
+			writeResult(&b, title, &group.Result)
+		}
+		return b.String()
+	}
+	for _, result := range results {
+		title := fmt.Sprintf("%s (%s, %v)", result.IP, exitText(&result), result.Duration.Round(time.Millisecond))
+		writeResult(&b, title, &result)
+	}
+	return b.String()
+}
+
+// ExportResults writes the command and its formatted results
+func ExportResults(w io.Writer, cmd string, results []RunResult, grouped bool) error {
+	_, err := fmt.Fprintf(w, "# command: %s\n# nodes: %d\n%s", cmd, len(results), FormatResults(results, grouped))
+	return err
+}
+
+func exitText(result *RunResult) string {
+	if result.Err != "" {
+		return "failed"
+	}
+	return fmt.Sprintf("exit %d", result.ExitStatus)
+}
+
+func writeResult(b *strings.Builder, title string, result *RunResult) {
+	separator := strings.Repeat("-", 15)
+	fmt.Fprintf(b, "%s\n%s\n%s\n", separator, title, separator)
+	if result.Err != "" {
+		fmt.Fprintf(b, "error: %s\n", result.Err)
+		return
+	}
+	b.WriteString(result.Stdout)
+	if result.Stdout != "" && !strings.HasSuffix(result.Stdout, "\n") {
+		b.WriteString("\n")
+	}
+	if result.Stderr != "" {
+		b.WriteString("[stderr]\n")
+		b.WriteString(result.Stderr)
+		if !strings.HasSuffix(result.Stderr, "\n") {
+			b.WriteString("\n")
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package state
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/luo2pei4/ltool/pkg/utils/sshtest"
+)
+
+func TestRunCommand(t *testing.T) {
+	srv, exec := newTestServer(t)
+	srv.Handle("uname -r", sshtest.Reply{Stdout: "4.18.0-553.el8_10.x86_64\n"})
+	srv.Handle("cat /etc/lnet.conf", sshtest.Reply{Stderr: "cat: /etc/lnet.conf: No such file or directory\n", ExitStatus: 1})
+
+	var conns []SSHConnection
+	for _, ip := range []string{"192.168.1.12", "192.168.1.10", "192.168.1.11"} {
+		conn := testConn()
+		conn.IPAddress = ip
+		conns = append(conns, conn)
+	}
+	var progress []int
+	results, err := RunCommand(context.Background(), exec, conns, "uname -r", 2, func(done, total int) {
+		if total != 3 {
+			t.Errorf("total = %d", total)
+		}
+		progress = append(progress, done)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(progress, []int{1, 2, 3}) {
+		t.Errorf("progress = %v", progress)
+	}
+	if len(results) != 3 || results[0].IP != "192.168.1.10" || results[2].IP != "192.168.1.12" {
+		t.Fatalf("results = %+v", results)
+	}
+	if results[1].Stdout != "4.18.0-553.el8_10.x86_64\n" || results[1].ExitStatus != 0 {
+		t.Errorf("result = %+v", results[1])
+	}
+
+	results, err = RunCommand(context.Background(), exec, conns[:1], "cat /etc/lnet.conf", 2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].ExitStatus != 1 || results[0].Stdout != "" || !strings.Contains(results[0].Stderr, "No such file") {
+		t.Errorf("result = %+v", results[0])
+	}
+}
+
+func TestGroupResults(t *testing.T) {
+	results := []RunResult{
+		{IP: "192.168.1.10", Stdout: "active\n"},
+		{IP: "192.168.1.11", Stdout: "inactive\n", ExitStatus: 3},
+		{IP: "192.168.1.12", Stdout: "active\n"},
+		{IP: "192.168.1.13", Err: "dail 192.168.1.13:22 failed, i/o timeout"},
+	}
+	groups := GroupResults(results)
+	if len(groups) != 3 {
+		t.Fatalf("groups = %+v", groups)
+	}
+	if !reflect.DeepEqual(groups[0].IPs, []string{"192.168.1.10", "192.168.1.12"}) {
+		t.Errorf("biggest group = %v", groups[0].IPs)
+	}
+
+	want := `---------------
+192.168.1.10,192.168.1.12 (2 nodes, exit 0)
+---------------
+active
+---------------
+192.168.1.11 (1 nodes, exit 3)
+---------------
+inactive
+---------------
+192.168.1.13 (1 nodes, failed)
+---------------
+error: dail 192.168.1.13:22 failed, i/o timeout
+`
+	if got := FormatResults(results, true); got != want {
+		t.Errorf("grouped output:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,214 @@
+package state
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/luo2pei4/ltool/pkg/dblayer"
+)
+
+// PageSize the saved nodes loaded at once
+const PageSize = 200
+
+// SearchHelp describes the syntax of ParseNodeQuery
+const SearchHelp = `ip:10.0.1 host:oss os:rocky kernel:5.14 group:oss tag:lustre status:online updated:>2026-01-10, ` +
+	`a bare word matches the ip or the host name, quote the values with spaces`
+
+// dateLayout the date of the updated term
+const dateLayout = "2006-01-02"
+
+// ParseNodeQuery parses the terms of the search bar into a query, e.g.
+// `host:oss status:offline updated:>2026-01-10`. A bare word matches the
+// ip address if it looks like one, otherwise the host name.
+func ParseNodeQuery(text string) (dblayer.NodeQuery, error) {
+	var q dblayer.NodeQuery
+	terms, err := splitTerms(text)
+	if err != nil {
+		return q, err
+	}
+	for _, term := range terms {
+		key, value, ok := strings.Cut(term, ":")
+		if !ok || looksLikeIP(term) {
+			// a bare word, or an ipv6 address
+			key, value = "", term
+		}
+		value = strings.Trim(value, `"`)
+		switch strings.ToLower(key) {
+		case "":
+			if looksLikeIP(value) {
+				q.IP = value
+			} else {
+				q.Hostname = value
+			}
+		case "ip":
+			q.IP = value
+		case "host", "hostname":
+			q.Hostname = value
+		case "os":
+			q.OS = value
+		case "kernel":
+			q.Kernel = value
+		case "group":
+			q.Group = value
+		case "tag":
+			q.Tag = value
+		case "status":
+			q.Status = strings.ToLower(value)
+		case "updated":
+			if err := parseUpdated(&q, value); err != nil {
+				return q, err
+			}
+		default:
+			return q, fmt.Errorf("unknown search field '%s'", key)
+		}
+	}
+	return q, nil
+}
+
+// splitTerms splits text by the spaces outside of the double quotes
+func splitTerms(text string) ([]string, error) {
+	var terms []string
+	var b strings.Builder
+	quoted := false
+	for _, r := range text {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			b.WriteRune(r)
+		case (r == ' ' || r == '\t') && !quoted:
+			if b.Len() > 0 {
+				terms = append(terms, b.String())
+				b.Reset()
+			}
+		default:
+			b.WriteRune(r)
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("unterminated quote in '%s'", text)
+	}
+	if b.Len() > 0 {
+		terms = append(terms, b.String())
+	}
+	return terms, nil
+}
+
+// parseUpdated parses a date, >date or <date, the date alone means the day
+func parseUpdated(q *dblayer.NodeQuery, value string) error {
+	op := ""
+	if strings.HasPrefix(value, ">") || strings.HasPrefix(value, "<") {
+		op, value = value[:1], value[1:]
+	}
+	day, err := time.ParseInLocation(dateLayout, value, time.Local)
+	if err != nil {
+		return fmt.Errorf("invalid update date '%s', want %s", value, dateLayout)
+	}
+	switch op {
+	case ">":
+		q.UpdatedAfter = day
+	case "<":
+		q.UpdatedBefore = day
+	default:
+		q.UpdatedAfter = day
+		q.UpdatedBefore = day.AddDate(0, 0, 1)
+	}
+	return nil
+}
+
+// looksLikeIP reports if s is a part of an ipv4 address, i.e. digits and
+// dots, or of an ipv6 address, i.e. hex digits with colons
+func looksLikeIP(s string) bool {
+	ipv6 := strings.Contains(s, ":")
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case r >= '0' && r <= '9', r == '.', r == ':':
+		case ipv6 && r >= 'a' && r <= 'f':
+		default:
+			return false
+		}
+	}
+	return s != ""
+}
+
+// Search replaces the records with the first page of the saved nodes
+// matching q, the unsaved changes are dropped.
+func (n *NodesState) Search(q dblayer.NodeQuery) error {
+	q.Offset, q.Limit = 0, PageSize
+	if err := q.Validate(); err != nil {
+		return err
+	}
+	n.Lock()
+	n.Query = q
+	n.Records = nil
+	n.Unlock()
+	return n.LoadAllRecords()
+}
+
+// SetPage loads the page of the current search, the unsaved changes are
+// dropped.
+func (n *NodesState) SetPage(page int) error {
+	n.Lock()
+	if page < 0 || page > 0 && int64(page*PageSize) >= n.Total {
+		n.Unlock()
+		return fmt.Errorf("page %d is out of range", page+1)
+	}
+	n.Query.Offset = page * PageSize
+	n.Records = nil
+	n.Unlock()
+	return n.LoadAllRecords()
+}
+
+// Page returns the current page and the number of the pages, from 0
+func (n *NodesState) Page() (page, pages int) {
+	n.RLock()
+	defer n.RUnlock()
+	pages = int((n.Total + PageSize - 1) / PageSize)
+	return n.Query.Offset / PageSize, max(pages, 1)
+}
+
+// HasUnsavedChanges reports if a record is new or changed
+func (n *NodesState) HasUnsavedChanges() bool {
+	n.RLock()
+	defer n.RUnlock()
+	for _, rec := range n.Records {
+		if rec.NewRec || rec.Changed {
+			return true
+		}
+	}
+	return false
+}
+
+// SaveStatus saves the status of the saved nodes checked since they were
+// loaded, the new records save it with SaveRecords.
+func (n *NodesState) SaveStatus() error {
+	n.Lock()
+	defer n.Unlock()
+	for i, rec := range n.Records {
+		if rec.NewRec || !rec.StatusTime.After(rec.rawStatusTime) {
+			continue
+		}
+		if err := dblayer.DB.UpdateNodeStatus(rec.IP, rec.Status, rec.StatusTime); err != nil {
+			return fmt.Errorf("save status of %s failed, %v", rec.IP, err)
+		}
+		n.Records[i].rawStatusTime = rec.StatusTime
+	}
+	return nil
+}
+
+// LastSearch returns the last search of the nodes in the active cluster
+func LastSearch() string {
+	text, err := dblayer.DB.GetClusterSetting(SettingNodeSearch)
+	if err != nil {
+		return ""
+	}
+	return text
+}
+
+// SaveSearch keeps the search of the nodes for the active cluster
+func SaveSearch(text string) error {
+	if err := dblayer.DB.SetClusterSetting(SettingNodeSearch, strings.TrimSpace(text)); err != nil {
+		return fmt.Errorf("save search failed, %v", err)
+	}
+	return nil
+}
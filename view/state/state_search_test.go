@@ -0,0 +1,38 @@
+package state
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/luo2pei4/ltool/pkg/dblayer"
+)
+
+func TestParseNodeQuery(t *testing.T) {
+	day := time.Date(2026, 1, 10, 0, 0, 0, 0, time.Local)
+	cases := []struct {
+		text string
+		want dblayer.NodeQuery
+	}{
+		{"", dblayer.NodeQuery{}},
+		{"10.0.1", dblayer.NodeQuery{IP: "10.0.1"}},
+		{"fe80::1", dblayer.NodeQuery{IP: "fe80::1"}},
+		{"oss0", dblayer.NodeQuery{Hostname: "oss0"}},
+		{`host:oss os:"Rocky Linux" kernel:5.14 group:oss tag:lustre status:Online`, dblayer.NodeQuery{
+			Hostname: "oss", OS: "Rocky Linux", Kernel: "5.14", Group: "oss", Tag: "lustre", Status: "online"}},
+		{"ip:2001:db8 updated:>2026-01-10", dblayer.NodeQuery{IP: "2001:db8", UpdatedAfter: day}},
+		{"updated:2026-01-10", dblayer.NodeQuery{UpdatedAfter: day, UpdatedBefore: day.AddDate(0, 0, 1)}},
+		{"updated:<2026-01-10", dblayer.NodeQuery{UpdatedBefore: day}},
+	}
+	for _, c := range cases {
+		q, err := ParseNodeQuery(c.text)
+		if err != nil || !reflect.DeepEqual(q, c.want) {
+			t.Errorf("query of %q is %+v, %v, want %+v", c.text, q, err, c.want)
+		}
+	}
+	for _, text := range []string{"password:x", `os:"rocky`, "updated:yesterday", "updated:>"} {
+		if _, err := ParseNodeQuery(text); err == nil {
+			t.Errorf("%q was parsed", text)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package state
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/luo2pei4/ltool/pkg/dblayer"
+	"github.com/luo2pei4/ltool/pkg/secret"
+	"github.com/luo2pei4/ltool/pkg/utils"
+	"github.com/luo2pei4/ltool/pkg/utils/sshtest"
+)
+
+// testExecutor sends every command to the fake ssh server,
+// and answers the probes from the reachable map.
+type testExecutor struct {
+	mu        sync.Mutex
+	addr      string
+	reachable map[string]bool
+	delay     time.Duration // holds each probe
+	active    int
+	maxActive int // the most concurrent probes
+}
+
+func (e *testExecutor) RemoteCmd(ctx context.Context, conf *utils.SSHConfig, cmd string) ([]byte, error) {
+	c := *conf
+	c.Host = e.addr
+	return utils.RemoteCmd(ctx, &c, cmd)
+}
+
+func (e *testExecutor) RemoteRun(ctx context.Context, conf *utils.SSHConfig, cmd string) (*utils.CmdResult, error) {
+	c := *conf
+	c.Host = e.addr
+	return utils.RemoteRun(ctx, &c, cmd)
+}
+
+func (e *testExecutor) OpenShell(ctx context.Context, conf *utils.SSHConfig, cols, rows int) (*utils.Shell, error) {
+	c := *conf
+	c.Host = e.addr
+	return utils.OpenShell(ctx, &c, cols, rows)
+}
+
+func (e *testExecutor) ListDir(ctx context.Context, conf *utils.SSHConfig, dir string) ([]utils.RemoteFile, error) {
+	c := *conf
+	c.Host = e.addr
+	return utils.ListDir(ctx, &c, dir)
+}
+
+func (e *testExecutor) Download(ctx context.Context, conf *utils.SSHConfig, remotePath string, w io.Writer) (*utils.Transfer, error) {
+	c := *conf
+	c.Host = e.addr
+	return utils.Download(ctx, &c, remotePath, w)
+}
+
+func (e *testExecutor) Upload(ctx context.Context, conf *utils.SSHConfig, r io.Reader, remotePath string, perm fs.FileMode) (*utils.Transfer, error) {
+	c := *conf
+	c.Host = e.addr
+	return utils.Upload(ctx, &c, r, remotePath, perm)
+}
+
+func (e *testExecutor) Reach(ctx context.Context, conf *utils.SSHConfig, probes []string) (*utils.Reachability, error) {
+	e.mu.Lock()
+	e.active++
+	e.maxActive = max(e.maxActive, e.active)
+	e.mu.Unlock()
+	defer func() {
+		e.mu.Lock()
+		e.active--
+		e.mu.Unlock()
+	}()
+	if e.delay > 0 {
+		select {
+		case <-time.After(e.delay):
+		case <-ctx.Done():
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.reachable[conf.Host] {
+		return &utils.Reachability{}, nil
+	}
+	return &utils.Reachability{Reachable: true, Probe: utils.ProbeTCP, Latency: 1200 * time.Microsecond}, nil
+}
+
+func newTestServer(t *testing.T) (*sshtest.Server, *testExecutor) {
+	t.Helper()
+	srv, err := sshtest.NewServer("root", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	utils.SetKnownHosts(filepath.Join(t.TempDir(), "known_hosts"), false)
+	t.Cleanup(func() {
+		utils.CloseSSHClients()
+		srv.Close()
+	})
+	return srv, &testExecutor{addr: srv.Addr, reachable: map[string]bool{}}
+}
+
+func testConn() SSHConnection {
+	return SSHConnection{
+		IPAddress: "192.168.1.10",
+		User:      "root",
+		SSHAuth:   utils.SSHAuth{AuthType: utils.AuthPassword, Password: "secret"},
+	}
+}
+
+// useTestDB makes a new sqlite database the global one for the test,
+// the credentials are unlocked
+func useTestDB(t *testing.T) {
+	t.Helper()
+	prevDB, prevCipher := dblayer.DB, secret.Current()
+	t.Cleanup(func() {
+		dblayer.DB = prevDB
+		secret.SetCurrent(prevCipher)
+	})
+	if err := dblayer.Init("sqlite", filepath.Join(t.TempDir(), "ltool.db")); err != nil {
+		t.Fatal(err)
+	}
+	if err := dblayer.SetPassphrase("secret"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,86 @@
+package terminal
+
+import (
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/driver/desktop"
+)
+
+// keySequences the input of the special keys, the arrows and home/end
+// are sent as SS3 sequences in the application cursor mode.
+var keySequences = map[fyne.KeyName]string{
+	fyne.KeyReturn:    "\r",
+	fyne.KeyEnter:     "\r",
+	fyne.KeyBackspace: "\x7f",
+	fyne.KeyTab:       "\t",
+	fyne.KeyEscape:    "\x1b",
+	fyne.KeyUp:        "\x1b[A",
+	fyne.KeyDown:      "\x1b[B",
+	fyne.KeyRight:     "\x1b[C",
+	fyne.KeyLeft:      "\x1b[D",
+	fyne.KeyHome:      "\x1b[H",
+	fyne.KeyEnd:       "\x1b[F",
+	fyne.KeyInsert:    "\x1b[2~",
+	fyne.KeyDelete:    "\x1b[3~",
+	fyne.KeyPageUp:    "\x1b[5~",
+	fyne.KeyPageDown:  "\x1b[6~",
+	fyne.KeyF1:        "\x1bOP",
+	fyne.KeyF2:        "\x1bOQ",
+	fyne.KeyF3:        "\x1bOR",
+	fyne.KeyF4:        "\x1bOS",
+	fyne.KeyF5:        "\x1b[15~",
+	fyne.KeyF6:        "\x1b[17~",
+	fyne.KeyF7:        "\x1b[18~",
+	fyne.KeyF8:        "\x1b[19~",
+	fyne.KeyF9:        "\x1b[20~",
+	fyne.KeyF10:       "\x1b[21~",
+	fyne.KeyF11:       "\x1b[23~",
+	fyne.KeyF12:       "\x1b[24~",
+}
+
+// keySequence returns the input of key, empty if the key is not sent
+func keySequence(key fyne.KeyName, appCursorKeys bool) string {
+	seq := keySequences[key]
+	if appCursorKeys && len(seq) == 3 && seq[1] == '[' {
+		switch key {
+		case fyne.KeyUp, fyne.KeyDown, fyne.KeyRight, fyne.KeyLeft, fyne.KeyHome, fyne.KeyEnd:
+			return "\x1bO" + seq[2:]
+		}
+	}
+	return seq
+}
+
+// controlSequence returns the input of ctrl+key and alt+key,
+// e.g. ctrl+c is 0x03 and alt+b is ESC b.
+func controlSequence(key fyne.KeyName, modifier fyne.KeyModifier) string {
+	switch modifier {
+	case fyne.KeyModifierControl:
+		if len(key) == 1 && key[0] >= 'A' && key[0] <= 'Z' {
+			return string(rune(key[0] - 'A' + 1))
+		}
+		switch key {
+		case fyne.KeySpace, fyne.Key2:
+			return "\x00"
+		case fyne.KeyLeftBracket:
+			return "\x1b"
+		case fyne.KeyBackslash:
+			return "\x1c"
+		case fyne.KeyRightBracket:
+			return "\x1d"
+		case fyne.KeySlash:
+			return "\x1f"
+		}
+	case fyne.KeyModifierAlt:
+		if len(key) == 1 && key[0] >= 'A' && key[0] <= 'Z' {
+			return "\x1b" + string(rune(key[0]-'A'+'a'))
+		}
+		if seq := keySequences[key]; seq != "" {
+			return "\x1b" + seq
+		}
+	}
+	return ""
+}
+
+// isShift reports whether key is one of the shift keys
+func isShift(key fyne.KeyName) bool {
+	return key == desktop.KeyShiftLeft || key == desktop.KeyShiftRight
+}
@@ -0,0 +1,685 @@
+// Package terminal emulates the vt100/xterm subset used by the shells and
+// the full screen tools (top, vi, less) on the nodes, and renders it with
+// a fyne TextGrid.
+package terminal
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultScrollback is the number of lines kept above the screen
+const DefaultScrollback = 5000
+
+// Style the attributes of a cell, nil colors are the theme colors
+type Style struct {
+	FG, BG    color.Color
+	Bold      bool
+	Underline bool
+	Reverse   bool
+}
+
+// Cell a character on the screen
+type Cell struct {
+	Rune  rune
+	Style Style
+}
+
+type line []Cell
+
+type parseState int
+
+const (
+	stateGround parseState = iota
+	stateEscape
+	stateCSI
+	stateOSC
+	stateOSCEscape
+	stateCharset
+	stateString // DCS, SOS, PM and APC are ignored until ST
+	stateStringEscape
+)
+
+type savedCursor struct {
+	row, col int
+	style    Style
+}
+
+// Screen the state of the terminal, it is fed with the output of the shell.
+// Screen is not safe for concurrent use.
+type Screen struct {
+	cols, rows int
+	lines      []line
+	scrollback []line
+	// MaxScrollback limits the scrollback, 0 means DefaultScrollback
+	MaxScrollback int
+	pushed        int // lines pushed to the scrollback since the start
+
+	row, col    int
+	style       Style
+	wrapNext    bool // the cursor is after the last column
+	top, bottom int  // scroll region, inclusive
+	saved       savedCursor
+	main        []line // the normal screen while the alternate one is shown
+	mainSaved   savedCursor
+
+	cursorHidden   bool
+	autowrap       bool
+	appCursorKeys  bool
+	bracketedPaste bool
+	title          string
+
+	// Reply sends the answers of the status requests back to the shell
+	Reply func(b []byte)
+
+	state        parseState
+	private      byte
+	intermediate byte
+	buf          []byte // csi parameters or osc text
+	pending      []byte // incomplete utf-8 sequence
+}
+
+// NewScreen returns a blank screen of cols x rows
+func NewScreen(cols, rows int) *Screen {
+	cols, rows = max(cols, 1), max(rows, 1)
+	s := &Screen{cols: cols, rows: rows, autowrap: true}
+	s.lines = blankLines(cols, rows, Style{})
+	s.bottom = rows - 1
+	return s
+}
+
+// Size returns the columns and rows of the screen
+func (s *Screen) Size() (cols, rows int) {
+	return s.cols, s.rows
+}
+
+// Cursor returns the cursor position and whether it should be drawn
+func (s *Screen) Cursor() (row, col int, visible bool) {
+	return s.row, s.col, !s.cursorHidden
+}
+
+// AppCursorKeys reports whether the arrow keys send the application sequences
+func (s *Screen) AppCursorKeys() bool {
+	return s.appCursorKeys
+}
+
+// BracketedPaste reports whether the pasted text should be bracketed
+func (s *Screen) BracketedPaste() bool {
+	return s.bracketedPaste
+}
+
+// Title returns the window title set by the shell
+func (s *Screen) Title() string {
+	return s.title
+}
+
+// ScrollbackLen returns the number of lines above the screen
+func (s *Screen) ScrollbackLen() int {
+	return len(s.scrollback)
+}
+
+// Pushed returns the number of lines pushed to the scrollback so far,
+// it keeps counting when the oldest lines are dropped.
+func (s *Screen) Pushed() int {
+	return s.pushed
+}
+
+// Line returns the line i counted from the oldest scrollback line,
+// the screen starts at ScrollbackLen(). The line must not be modified.
+func (s *Screen) Line(i int) []Cell {
+	if i < 0 {
+		return nil
+	}
+	if i < len(s.scrollback) {
+		return s.scrollback[i]
+	}
+	i -= len(s.scrollback)
+	if i < len(s.lines) {
+		return s.lines[i]
+	}
+	return nil
+}
+
+// Text returns the text between two positions of Line, both inclusive,
+// the trailing blanks of the lines are trimmed.
+func (s *Screen) Text(fromLine, fromCol, toLine, toCol int) string {
+	if fromLine > toLine || (fromLine == toLine && fromCol > toCol) {
+		fromLine, fromCol, toLine, toCol = toLine, toCol, fromLine, fromCol
+	}
+	var sb strings.Builder
+	for i := fromLine; i <= toLine; i++ {
+		l := s.Line(i)
+		start, end := 0, len(l)-1
+		if i == fromLine {
+			start = fromCol
+		}
+		if i == toLine {
+			end = min(toCol, len(l)-1)
+		}
+		var text []rune
+		for c := max(start, 0); c <= end; c++ {
+			text = append(text, l[c].Rune)
+		}
+		sb.WriteString(strings.TrimRight(string(text), " "))
+		if i != toLine {
+			sb.WriteByte('\n')
+		}
+	}
+	return sb.String()
+}
+
+// ClearScrollback drops the lines above the screen
+func (s *Screen) ClearScrollback() {
+	s.scrollback = nil
+}
+
+// Resize changes the size of the screen, the lines above the cursor are
+// pushed to the scrollback when the screen gets shorter.
+func (s *Screen) Resize(cols, rows int) {
+	cols, rows = max(cols, 1), max(rows, 1)
+	if cols == s.cols && rows == s.rows {
+		return
+	}
+	if s.main != nil {
+		s.main = resizeLines(s.main, cols, rows)
+		s.lines = resizeLines(s.lines, cols, rows)
+	} else {
+		drop := max(s.row-rows+1, 0)
+		s.pushScrollback(s.lines[:drop])
+		s.lines = resizeLines(s.lines[drop:], cols, rows)
+		s.row -= drop
+	}
+	s.cols, s.rows = cols, rows
+	s.top, s.bottom = 0, rows-1
+	s.row, s.col = min(s.row, rows-1), min(s.col, cols-1)
+	s.saved.row, s.saved.col = min(s.saved.row, rows-1), min(s.saved.col, cols-1)
+	s.mainSaved.row, s.mainSaved.col = min(s.mainSaved.row, rows-1), min(s.mainSaved.col, cols-1)
+	s.wrapNext = false
+}
+
+// Write feeds the output of the shell to the screen
+func (s *Screen) Write(p []byte) (int, error) {
+	for _, b := range p {
+		s.feed(b)
+	}
+	return len(p), nil
+}
+
+func (s *Screen) feed(b byte) {
+	switch s.state {
+	case stateGround:
+		s.ground(b)
+	case stateEscape:
+		s.escape(b)
+	case stateCSI:
+		s.csiByte(b)
+	case stateOSC:
+		switch b {
+		case 0x07:
+			s.osc()
+			s.state = stateGround
+		case 0x1b:
+			s.state = stateOSCEscape
+		default:
+			if len(s.buf) < 4096 {
+				s.buf = append(s.buf, b)
+			}
+		}
+	case stateOSCEscape:
+		// ESC \ terminates the string
+		s.osc()
+		s.state = stateGround
+		if b != '\\' {
+			s.escape(b)
+		}
+	case stateCharset:
+		s.state = stateGround
+	case stateString:
+		switch b {
+		case 0x07:
+			s.state = stateGround
+		case 0x1b:
+			s.state = stateStringEscape
+		}
+	case stateStringEscape:
+		s.state = stateGround
+		if b != '\\' {
+			s.escape(b)
+		}
+	}
+}
+
+func (s *Screen) ground(b byte) {
+	if len(s.pending) > 0 || b >= 0x80 {
+		s.pending = append(s.pending, b)
+		if !utf8.FullRune(s.pending) {
+			return
+		}
+		r, _ := utf8.DecodeRune(s.pending)
+		s.pending = s.pending[:0]
+		s.put(r)
+		return
+	}
+	if b < 0x20 || b == 0x7f {
+		s.control(b)
+		return
+	}
+	s.put(rune(b))
+}
+
+func (s *Screen) control(b byte) {
+	switch b {
+	case 0x1b:
+		s.state = stateEscape
+	case '\r':
+		s.col = 0
+		s.wrapNext = false
+	case '\n', 0x0b, 0x0c:
+		s.lineFeed()
+	case '\b':
+		if s.col > 0 {
+			s.col--
+		}
+		s.wrapNext = false
+	case '\t':
+		s.col = min((s.col/8+1)*8, s.cols-1)
+		s.wrapNext = false
+	}
+}
+
+func (s *Screen) escape(b byte) {
+	s.state = stateGround
+	switch b {
+	case '[':
+		s.state = stateCSI
+		s.buf = s.buf[:0]
+		s.private, s.intermediate = 0, 0
+	case ']':
+		s.state = stateOSC
+		s.buf = s.buf[:0]
+	case 'P', 'X', '^', '_':
+		s.state = stateString
+	case '(', ')', '*', '+':
+		s.state = stateCharset
+	case '7':
+		s.saved = savedCursor{s.row, s.col, s.style}
+	case '8':
+		s.restoreCursor(s.saved)
+	case 'D':
+		s.lineFeed()
+	case 'E':
+		s.col = 0
+		s.lineFeed()
+	case 'M':
+		s.reverseIndex()
+	case 'c':
+		s.reset()
+	}
+}
+
+func (s *Screen) csiByte(b byte) {
+	switch {
+	case b >= 0x30 && b <= 0x3f:
+		if len(s.buf) == 0 && s.private == 0 && (b == '?' || b == '>' || b == '<' || b == '=') {
+			s.private = b
+		} else if len(s.buf) < 64 {
+			s.buf = append(s.buf, b)
+		}
+	case b >= 0x20 && b <= 0x2f:
+		s.intermediate = b
+	case b >= 0x40 && b <= 0x7e:
+		s.state = stateGround
+		s.csi(b)
+	case b == 0x1b:
+		s.state = stateEscape
+	default:
+		s.control(b)
+	}
+}
+
+// maxParam clamps the csi parameters like xterm, so that the cursor
+// arithmetic cannot overflow
+const maxParam = 65535
+
+// params returns the csi parameters, the empty ones are 0
+func (s *Screen) params() []int {
+	if len(s.buf) == 0 {
+		return nil
+	}
+	fields := strings.FieldsFunc(string(s.buf), func(r rune) bool {
+		return r == ';' || r == ':'
+	})
+	if strings.HasSuffix(string(s.buf), ";") {
+		fields = append(fields, "")
+	}
+	params := make([]int, len(fields))
+	for i, f := range fields {
+		p, err := strconv.Atoi(f)
+		if err != nil || p > maxParam {
+			// the digits overflowed int
+			p = maxParam
+		}
+		params[i] = p
+	}
+	return params
+}
+
+// param returns the i-th parameter, def if it is missing or 0
+func param(params []int, i, def int) int {
+	if i >= len(params) || params[i] == 0 {
+		return def
+	}
+	return params[i]
+}
+
+func (s *Screen) csi(final byte) {
+	if s.intermediate != 0 {
+		return
+	}
+	params := s.params()
+	if s.private == '?' {
+		if final == 'h' || final == 'l' {
+			for _, p := range params {
+				s.decMode(p, final == 'h')
+			}
+		}
+		return
+	}
+	if s.private != 0 {
+		return
+	}
+	n := param(params, 0, 1)
+	switch final {
+	case 'A':
+		s.moveTo(s.row-n, s.col)
+	case 'B', 'e':
+		s.moveTo(s.row+n, s.col)
+	case 'C', 'a':
+		s.moveTo(s.row, s.col+n)
+	case 'D':
+		s.moveTo(s.row, s.col-n)
+	case 'E':
+		s.moveTo(s.row+n, 0)
+	case 'F':
+		s.moveTo(s.row-n, 0)
+	case 'G', '`':
+		s.moveTo(s.row, n-1)
+	case 'd':
+		s.moveTo(n-1, s.col)
+	case 'H', 'f':
+		s.moveTo(n-1, param(params, 1, 1)-1)
+	case 'J':
+		s.eraseDisplay(param(params, 0, 0))
+	case 'K':
+		s.eraseLine(param(params, 0, 0))
+	case 'L':
+		if s.row >= s.top && s.row <= s.bottom {
+			s.scrollDown(s.row, s.bottom, n)
+		}
+	case 'M':
+		if s.row >= s.top && s.row <= s.bottom {
+			s.scrollUp(s.row, s.bottom, n)
+		}
+	case '@':
+		l := s.lines[s.row]
+		n = min(n, s.cols-s.col)
+		copy(l[s.col+n:], l[s.col:])
+		s.blank(l[s.col : s.col+n])
+	case 'P':
+		l := s.lines[s.row]
+		n = min(n, s.cols-s.col)
+		copy(l[s.col:], l[s.col+n:])
+		s.blank(l[s.cols-n:])
+	case 'X':
+		s.blank(s.lines[s.row][s.col : s.col+min(n, s.cols-s.col)])
+	case 'S':
+		s.scrollUp(s.top, s.bottom, n)
+	case 'T':
+		s.scrollDown(s.top, s.bottom, n)
+	case 'm':
+		s.sgr(params)
+	case 'r':
+		top, bottom := param(params, 0, 1)-1, param(params, 1, s.rows)-1
+		if top < bottom && bottom < s.rows {
+			s.top, s.bottom = top, bottom
+			s.moveTo(0, 0)
+		}
+	case 's':
+		s.saved = savedCursor{s.row, s.col, s.style}
+	case 'u':
+		s.restoreCursor(s.saved)
+	case 'n':
+		switch param(params, 0, 0) {
+		case 5:
+			s.reply("\x1b[0n")
+		case 6:
+			s.reply(fmt.Sprintf("\x1b[%d;%dR", s.row+1, s.col+1))
+		}
+	case 'c':
+		if param(params, 0, 0) == 0 {
+			s.reply("\x1b[?1;2c")
+		}
+	}
+}
+
+func (s *Screen) decMode(mode int, set bool) {
+	switch mode {
+	case 1:
+		s.appCursorKeys = set
+	case 7:
+		s.autowrap = set
+	case 25:
+		s.cursorHidden = !set
+	case 47, 1047, 1049:
+		if set {
+			s.enterAltScreen()
+		} else {
+			s.exitAltScreen()
+		}
+	case 2004:
+		s.bracketedPaste = set
+	}
+}
+
+func (s *Screen) osc() {
+	// OSC 0 and 2 set the window title
+	text := string(s.buf)
+	if strings.HasPrefix(text, "0;") || strings.HasPrefix(text, "2;") {
+		s.title = text[2:]
+	}
+	s.buf = s.buf[:0]
+}
+
+func (s *Screen) reply(answer string) {
+	if s.Reply != nil {
+		s.Reply([]byte(answer))
+	}
+}
+
+func (s *Screen) put(r rune) {
+	if s.wrapNext {
+		s.col = 0
+		s.lineFeed()
+	}
+	s.lines[s.row][s.col] = Cell{Rune: r, Style: s.style}
+	if s.col < s.cols-1 {
+		s.col++
+	} else if s.autowrap {
+		s.wrapNext = true
+	}
+}
+
+func (s *Screen) moveTo(row, col int) {
+	s.row = min(max(row, 0), s.rows-1)
+	s.col = min(max(col, 0), s.cols-1)
+	s.wrapNext = false
+}
+
+func (s *Screen) restoreCursor(c savedCursor) {
+	s.moveTo(c.row, c.col)
+	s.style = c.style
+}
+
+func (s *Screen) lineFeed() {
+	s.wrapNext = false
+	if s.row == s.bottom {
+		s.scrollUp(s.top, s.bottom, 1)
+	} else if s.row < s.rows-1 {
+		s.row++
+	}
+}
+
+func (s *Screen) reverseIndex() {
+	s.wrapNext = false
+	if s.row == s.top {
+		s.scrollDown(s.top, s.bottom, 1)
+	} else if s.row > 0 {
+		s.row--
+	}
+}
+
+// scrollUp moves the lines from..to up by n, the lines leaving the top of
+// the normal screen are kept in the scrollback.
+func (s *Screen) scrollUp(from, to, n int) {
+	n = min(n, to-from+1)
+	if from == 0 && s.main == nil {
+		s.pushScrollback(s.lines[:n])
+	}
+	copy(s.lines[from:], s.lines[from+n:to+1])
+	for i := to - n + 1; i <= to; i++ {
+		s.lines[i] = s.blankLine()
+	}
+}
+
+func (s *Screen) scrollDown(from, to, n int) {
+	n = min(n, to-from+1)
+	copy(s.lines[from+n:to+1], s.lines[from:])
+	for i := from; i < from+n; i++ {
+		s.lines[i] = s.blankLine()
+	}
+}
+
+func (s *Screen) pushScrollback(lines []line) {
+	if len(lines) == 0 {
+		return
+	}
+	limit := s.MaxScrollback
+	if limit <= 0 {
+		limit = DefaultScrollback
+	}
+	s.scrollback = append(s.scrollback, lines...)
+	s.pushed += len(lines)
+	if over := len(s.scrollback) - limit; over > 0 {
+		s.scrollback = append(s.scrollback[:0:0], s.scrollback[over:]...)
+	}
+}
+
+func (s *Screen) eraseDisplay(mode int) {
+	switch mode {
+	case 0:
+		s.eraseLine(0)
+		for i := s.row + 1; i < s.rows; i++ {
+			s.lines[i] = s.blankLine()
+		}
+	case 1:
+		s.eraseLine(1)
+		for i := 0; i < s.row; i++ {
+			s.lines[i] = s.blankLine()
+		}
+	case 2:
+		for i := range s.lines {
+			s.lines[i] = s.blankLine()
+		}
+	case 3:
+		s.ClearScrollback()
+	}
+}
+
+func (s *Screen) eraseLine(mode int) {
+	l := s.lines[s.row]
+	switch mode {
+	case 0:
+		s.blank(l[s.col:])
+	case 1:
+		s.blank(l[:s.col+1])
+	case 2:
+		s.blank(l)
+	}
+}
+
+// blank erases the cells with the current background
+func (s *Screen) blank(cells []Cell) {
+	for i := range cells {
+		cells[i] = Cell{Rune: ' ', Style: Style{BG: s.style.BG}}
+	}
+}
+
+func (s *Screen) blankLine() line {
+	l := make(line, s.cols)
+	s.blank(l)
+	return l
+}
+
+func (s *Screen) enterAltScreen() {
+	if s.main != nil {
+		return
+	}
+	s.mainSaved = savedCursor{s.row, s.col, s.style}
+	s.main = s.lines
+	s.lines = blankLines(s.cols, s.rows, Style{})
+	s.top, s.bottom = 0, s.rows-1
+}
+
+func (s *Screen) exitAltScreen() {
+	if s.main == nil {
+		return
+	}
+	s.lines = s.main
+	s.main = nil
+	s.top, s.bottom = 0, s.rows-1
+	s.restoreCursor(s.mainSaved)
+}
+
+func (s *Screen) reset() {
+	*s = Screen{
+		cols:          s.cols,
+		rows:          s.rows,
+		lines:         blankLines(s.cols, s.rows, Style{}),
+		scrollback:    s.scrollback,
+		MaxScrollback: s.MaxScrollback,
+		pushed:        s.pushed,
+		bottom:        s.rows - 1,
+		autowrap:      true,
+		Reply:         s.Reply,
+	}
+}
+
+func blankLines(cols, rows int, style Style) []line {
+	lines := make([]line, rows)
+	for i := range lines {
+		lines[i] = make(line, cols)
+		for c := range lines[i] {
+			lines[i][c] = Cell{Rune: ' ', Style: style}
+		}
+	}
+	return lines
+}
+
+// resizeLines pads or truncates lines to cols x rows
+func resizeLines(lines []line, cols, rows int) []line {
+	resized := make([]line, rows)
+	for i := range resized {
+		l := make(line, cols)
+		for c := range l {
+			l[c] = Cell{Rune: ' '}
+		}
+		if i < len(lines) {
+			copy(l, lines[i])
+		}
+		resized[i] = l
+	}
+	return resized
+}
@@ -0,0 +1,181 @@
+package terminal
+
+import (
+	"image/color"
+	"strings"
+	"testing"
+)
+
+// screenText returns the lines of the screen with the trailing blanks trimmed
+func screenText(s *Screen) []string {
+	_, rows := s.Size()
+	start := s.ScrollbackLen()
+	lines := make([]string, rows)
+	for i := range lines {
+		lines[i] = s.Text(start+i, 0, start+i, 1<<20)
+	}
+	return lines
+}
+
+func TestScreenWrite(t *testing.T) {
+	s := NewScreen(10, 3)
+	s.Write([]byte("[root@oss01 ~]# ls\r\nab\tc"))
+	want := []string{"[root@oss0", "1 ~]# ls", "ab      c"}
+	if got := screenText(s); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("screen = %q, want %q", got, want)
+	}
+	// the next line feed scrolls the first line to the scrollback
+	s.Write([]byte("\n"))
+	if s.ScrollbackLen() != 1 || s.Text(0, 0, 0, 20) != "[root@oss0" {
+		t.Errorf("scrollback = %d %q", s.ScrollbackLen(), s.Text(0, 0, 0, 20))
+	}
+}
+
+func TestScreenUTF8Split(t *testing.T) {
+	s := NewScreen(10, 2)
+	b := []byte("héllo")
+	s.Write(b[:2])
+	s.Write(b[2:])
+	if got := screenText(s)[0]; got != "héllo" {
+		t.Errorf("line = %q", got)
+	}
+}
+
+func TestScreenCSI(t *testing.T) {
+	s := NewScreen(10, 4)
+	s.Write([]byte("aaaaaaaaaa\r\nbbbbbbbbbb\r\ncccccccccc"))
+	// cursor to row 2 col 3, erase to the end of the line
+	s.Write([]byte("\x1b[2;3H\x1b[K"))
+	// delete 2 chars of the first line, insert a blank line before the third
+	s.Write([]byte("\x1b[1;1H\x1b[2P\x1b[3;1H\x1b[L"))
+	want := []string{"aaaaaaaa", "bb", "", "cccccccccc"}
+	if got := screenText(s); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("screen = %q, want %q", got, want)
+	}
+	if row, col, _ := s.Cursor(); row != 2 || col != 0 {
+		t.Errorf("cursor = %d,%d", row, col)
+	}
+	s.Write([]byte("\x1b[2J"))
+	if got := strings.Join(screenText(s), ""); got != "" {
+		t.Errorf("screen not erased, %q", got)
+	}
+}
+
+func TestScreenHugeParams(t *testing.T) {
+	s := NewScreen(10, 3)
+	s.Write([]byte("aaaaaaaaaa\r\nbbbbbbbbbb\r\ncccccccccc"))
+	// the overflowing parameters are clamped instead of panicking
+	huge := "99999999999999999999"
+	for _, final := range "ABCDEFGd@PXLMST" {
+		s.Write([]byte("\x1b[2;5H\x1b[" + huge + string(final)))
+	}
+	s.Write([]byte("\x1b[" + huge + ";" + huge + "H"))
+	if row, col, _ := s.Cursor(); row != 2 || col != 9 {
+		t.Errorf("cursor = %d,%d, want 2,9", row, col)
+	}
+	s = NewScreen(10, 1)
+	s.Write([]byte("aaaaaaaaaa\x1b[1;5H\x1b[" + huge + "X"))
+	if got := screenText(s)[0]; got != "aaaa" {
+		t.Errorf("line = %q, want %q", got, "aaaa")
+	}
+}
+
+func TestScreenScrollRegion(t *testing.T) {
+	s := NewScreen(5, 4)
+	s.Write([]byte("top\r\n1\r\n2\r\nbot"))
+	// scroll the rows 2-3 only, like the status lines of top
+	s.Write([]byte("\x1b[2;3r\x1b[3;1H\n"))
+	want := []string{"top", "2", "", "bot"}
+	if got := screenText(s); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("screen = %q, want %q", got, want)
+	}
+	if s.ScrollbackLen() != 0 {
+		t.Errorf("the region scrolled into the scrollback")
+	}
+}
+
+func TestScreenAltScreen(t *testing.T) {
+	s := NewScreen(10, 3)
+	s.Write([]byte("$ vi\r\n"))
+	s.Write([]byte("\x1b[?1049h\x1b[?1h\x1b[Hfile"))
+	if got := screenText(s)[0]; got != "file" {
+		t.Errorf("alternate screen = %q", got)
+	}
+	if !s.AppCursorKeys() {
+		t.Errorf("application cursor keys not set")
+	}
+	s.Write([]byte("\x1b[?1049l"))
+	if got := screenText(s)[0]; got != "$ vi" {
+		t.Errorf("normal screen = %q", got)
+	}
+	if row, col, _ := s.Cursor(); row != 1 || col != 0 {
+		t.Errorf("cursor = %d,%d", row, col)
+	}
+}
+
+func TestScreenSGR(t *testing.T) {
+	s := NewScreen(10, 2)
+	s.Write([]byte("\x1b[1;31mE\x1b[0m \x1b[38;5;21mB\x1b[48;2;1;2;3mG\x1b[7mR"))
+	l := s.Line(0)
+	if !l[0].Style.Bold || l[0].Style.FG != ansiColors[1] {
+		t.Errorf("style = %+v", l[0].Style)
+	}
+	if l[1].Style != (Style{}) {
+		t.Errorf("style not reset, %+v", l[1].Style)
+	}
+	if l[2].Style.FG != (color.RGBA{0, 0, 255, 255}) {
+		t.Errorf("256 color = %v", l[2].Style.FG)
+	}
+	if l[3].Style.BG != (color.RGBA{1, 2, 3, 255}) {
+		t.Errorf("true color = %v", l[3].Style.BG)
+	}
+	if !l[4].Style.Reverse {
+		t.Errorf("reverse not set")
+	}
+}
+
+func TestScreenReplyAndTitle(t *testing.T) {
+	s := NewScreen(10, 5)
+	var replies []string
+	s.Reply = func(b []byte) {
+		replies = append(replies, string(b))
+	}
+	s.Write([]byte("\x1b]0;root@oss01:~\x07\x1b[3;4H\x1b[6n"))
+	if s.Title() != "root@oss01:~" {
+		t.Errorf("title = %q", s.Title())
+	}
+	if len(replies) != 1 || replies[0] != "\x1b[3;4R" {
+		t.Errorf("replies = %q", replies)
+	}
+}
+
+func TestScreenResize(t *testing.T) {
+	s := NewScreen(10, 4)
+	s.Write([]byte("1\r\n2\r\n3\r\n4"))
+	s.Resize(5, 2)
+	want := []string{"3", "4"}
+	if got := screenText(s); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("screen = %q, want %q", got, want)
+	}
+	if s.ScrollbackLen() != 2 {
+		t.Errorf("scrollback = %d, want 2", s.ScrollbackLen())
+	}
+	if row, _, _ := s.Cursor(); row != 1 {
+		t.Errorf("cursor row = %d", row)
+	}
+	s.Resize(8, 3)
+	if cols, rows := s.Size(); cols != 8 || rows != 3 {
+		t.Errorf("size = %dx%d", cols, rows)
+	}
+}
+
+func TestScreenScrollbackLimit(t *testing.T) {
+	s := NewScreen(5, 2)
+	s.MaxScrollback = 3
+	for i := 0; i < 10; i++ {
+		s.Write([]byte("x\r\n"))
+	}
+	if s.ScrollbackLen() != 3 || s.Pushed() != 9 {
+		t.Errorf("scrollback = %d, pushed = %d", s.ScrollbackLen(), s.Pushed())
+	}
+}
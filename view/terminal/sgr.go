@@ -0,0 +1,114 @@
+package terminal
+
+import "image/color"
+
+// ansiColors the 16 base colors of xterm
+var ansiColors = [16]color.RGBA{
+	{0, 0, 0, 255},
+	{205, 0, 0, 255},
+	{0, 205, 0, 255},
+	{205, 205, 0, 255},
+	{0, 0, 238, 255},
+	{205, 0, 205, 255},
+	{0, 205, 205, 255},
+	{229, 229, 229, 255},
+	{127, 127, 127, 255},
+	{255, 0, 0, 255},
+	{0, 255, 0, 255},
+	{255, 255, 0, 255},
+	{92, 92, 255, 255},
+	{255, 0, 255, 255},
+	{0, 255, 255, 255},
+	{255, 255, 255, 255},
+}
+
+// paletteColor returns the color i of the xterm 256 color palette
+func paletteColor(i int) color.Color {
+	switch {
+	case i < 16:
+		return ansiColors[max(i, 0)]
+	case i < 232:
+		// 6x6x6 color cube
+		i -= 16
+		level := func(v int) uint8 {
+			if v == 0 {
+				return 0
+			}
+			return uint8(55 + v*40)
+		}
+		return color.RGBA{level(i / 36), level(i / 6 % 6), level(i % 6), 255}
+	default:
+		gray := uint8(8 + (min(i, 255)-232)*10)
+		return color.RGBA{gray, gray, gray, 255}
+	}
+}
+
+// sgr applies the select graphic rendition parameters
+func (s *Screen) sgr(params []int) {
+	if len(params) == 0 {
+		params = []int{0}
+	}
+	for i := 0; i < len(params); i++ {
+		p := params[i]
+		switch {
+		case p == 0:
+			s.style = Style{}
+		case p == 1:
+			s.style.Bold = true
+		case p == 22:
+			s.style.Bold = false
+		case p == 4:
+			s.style.Underline = true
+		case p == 24:
+			s.style.Underline = false
+		case p == 7:
+			s.style.Reverse = true
+		case p == 27:
+			s.style.Reverse = false
+		case p >= 30 && p <= 37:
+			s.style.FG = ansiColors[p-30]
+		case p >= 90 && p <= 97:
+			s.style.FG = ansiColors[p-90+8]
+		case p == 39:
+			s.style.FG = nil
+		case p >= 40 && p <= 47:
+			s.style.BG = ansiColors[p-40]
+		case p >= 100 && p <= 107:
+			s.style.BG = ansiColors[p-100+8]
+		case p == 49:
+			s.style.BG = nil
+		case p == 38 || p == 48:
+			c, used := extendedColor(params[i+1:])
+			i += used
+			if c == nil {
+				continue
+			}
+			if p == 38 {
+				s.style.FG = c
+			} else {
+				s.style.BG = c
+			}
+		}
+	}
+}
+
+// extendedColor parses "5;n" and "2;r;g;b" after 38 or 48,
+// it returns the number of parameters used.
+func extendedColor(params []int) (color.Color, int) {
+	if len(params) == 0 {
+		return nil, 0
+	}
+	switch params[0] {
+	case 5:
+		if len(params) < 2 {
+			return nil, len(params)
+		}
+		return paletteColor(params[1]), 2
+	case 2:
+		if len(params) < 4 {
+			return nil, len(params)
+		}
+		return color.RGBA{uint8(params[1]), uint8(params[2]), uint8(params[3]), 255}, 4
+	}
+	return nil, 1
+}
@@ -0,0 +1,472 @@
+package terminal
+
+import (
+	"image/color"
+	"io"
+	"math"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// position a cell of Screen.Line
+type position struct {
+	line, col int
+}
+
+func (p position) before(o position) bool {
+	return p.line < o.line || (p.line == o.line && p.col < o.col)
+}
+
+// Terminal a widget showing the screen of a shell and sending the typed
+// keys to it. The mouse wheel and shift+page up/down scroll back, dragging
+// selects, ctrl+shift+c and ctrl+shift+v copy and paste.
+type Terminal struct {
+	widget.BaseWidget
+
+	// OnResize is called when the number of columns or rows changed
+	OnResize func(cols, rows int)
+
+	grid *widget.TextGrid
+
+	mu        sync.Mutex
+	screen    *Screen
+	in        io.Writer
+	replies   []byte
+	offset    int // lines scrolled back from the bottom
+	shift     bool
+	selecting bool
+	hasSel    bool
+	selFrom   position
+	selTo     position
+
+	refreshQueued atomic.Bool
+	styles        map[Style]widget.TextGridStyle
+	themeFG       color.Color
+}
+
+// New returns a terminal of 80x24, it is resized with the widget
+func New() *Terminal {
+	t := &Terminal{
+		grid:   widget.NewTextGrid(),
+		screen: NewScreen(80, 24),
+		styles: make(map[Style]widget.TextGridStyle),
+	}
+	t.grid.Scroll = fyne.ScrollNone
+	t.screen.Reply = func(b []byte) {
+		t.replies = append(t.replies, b...)
+	}
+	t.ExtendBaseWidget(t)
+	return t
+}
+
+// GridSize returns the columns and rows of the terminal
+func (t *Terminal) GridSize() (cols, rows int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.screen.Size()
+}
+
+// Run sends the typed keys to in and shows out until it is closed
+func (t *Terminal) Run(in io.Writer, out io.Reader) error {
+	t.mu.Lock()
+	t.in = in
+	t.mu.Unlock()
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := out.Read(buf)
+		if n > 0 {
+			t.Write(buf[:n])
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Write shows p on the terminal, it is safe to call from any goroutine
+func (t *Terminal) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	pushed := t.screen.Pushed()
+	t.screen.Write(p)
+	if t.offset > 0 {
+		// keep showing the same lines while the output scrolls
+		t.offset = min(t.offset+t.screen.Pushed()-pushed, t.screen.ScrollbackLen())
+	}
+	replies, in := t.replies, t.in
+	t.replies = nil
+	t.mu.Unlock()
+	if len(replies) > 0 && in != nil {
+		in.Write(replies)
+	}
+	if t.refreshQueued.CompareAndSwap(false, true) {
+		fyne.Do(func() {
+			t.refreshQueued.Store(false)
+			t.Refresh()
+		})
+	}
+	return len(p), nil
+}
+
+// Copy puts the selected text on the clipboard
+func (t *Terminal) Copy() {
+	t.mu.Lock()
+	if !t.hasSel {
+		t.mu.Unlock()
+		return
+	}
+	text := t.screen.Text(t.selFrom.line, t.selFrom.col, t.selTo.line, t.selTo.col)
+	t.mu.Unlock()
+	fyne.CurrentApp().Clipboard().SetContent(text)
+}
+
+// Paste types the text of the clipboard
+func (t *Terminal) Paste() {
+	text := fyne.CurrentApp().Clipboard().Content()
+	if text == "" {
+		return
+	}
+	text = strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\r"), "\n", "\r")
+	t.mu.Lock()
+	bracketed := t.screen.BracketedPaste()
+	t.mu.Unlock()
+	if bracketed {
+		text = "\x1b[200~" + text + "\x1b[201~"
+	}
+	t.typed(text)
+}
+
+// ClearScrollback drops the lines above the screen
+func (t *Terminal) ClearScrollback() {
+	t.mu.Lock()
+	t.screen.ClearScrollback()
+	t.offset = 0
+	t.hasSel = false
+	t.mu.Unlock()
+	t.Refresh()
+}
+
+// typed sends the input to the shell and scrolls to the bottom
+func (t *Terminal) typed(input string) {
+	if input == "" {
+		return
+	}
+	t.mu.Lock()
+	in := t.in
+	t.offset = 0
+	t.hasSel = false
+	t.mu.Unlock()
+	if in != nil {
+		in.Write([]byte(input))
+	}
+	t.Refresh()
+}
+
+func (t *Terminal) scrollBy(lines int) {
+	t.mu.Lock()
+	t.offset = min(max(t.offset+lines, 0), t.screen.ScrollbackLen())
+	t.mu.Unlock()
+	t.Refresh()
+}
+
+// Resize resizes the screen to the cells fitting in size
+func (t *Terminal) Resize(size fyne.Size) {
+	t.BaseWidget.Resize(size)
+	cell := cellSize()
+	cols, rows := max(int(size.Width/cell.Width), 1), max(int(size.Height/cell.Height), 1)
+	t.mu.Lock()
+	oldCols, oldRows := t.screen.Size()
+	t.screen.Resize(cols, rows)
+	t.offset = min(t.offset, t.screen.ScrollbackLen())
+	t.mu.Unlock()
+	if cols == oldCols && rows == oldRows {
+		return
+	}
+	if t.OnResize != nil {
+		t.OnResize(cols, rows)
+	}
+	t.Refresh()
+}
+
+// FocusGained implements fyne.Focusable
+func (t *Terminal) FocusGained() {
+	t.Refresh()
+}
+
+// FocusLost implements fyne.Focusable
+func (t *Terminal) FocusLost() {
+	t.mu.Lock()
+	t.shift = false
+	t.mu.Unlock()
+}
+
+// TypedRune implements fyne.Focusable
+func (t *Terminal) TypedRune(r rune) {
+	t.typed(string(r))
+}
+
+// TypedKey implements fyne.Focusable
+func (t *Terminal) TypedKey(ev *fyne.KeyEvent) {
+	t.mu.Lock()
+	shift := t.shift
+	_, rows := t.screen.Size()
+	appCursorKeys := t.screen.AppCursorKeys()
+	t.mu.Unlock()
+	if shift && ev.Name == fyne.KeyPageUp {
+		t.scrollBy(rows / 2)
+		return
+	}
+	if shift && ev.Name == fyne.KeyPageDown {
+		t.scrollBy(-rows / 2)
+		return
+	}
+	t.typed(keySequence(ev.Name, appCursorKeys))
+}
+
+// KeyDown implements desktop.Keyable to know the state of shift
+func (t *Terminal) KeyDown(ev *fyne.KeyEvent) {
+	if isShift(ev.Name) {
+		t.mu.Lock()
+		t.shift = true
+		t.mu.Unlock()
+	}
+}
+
+// KeyUp implements desktop.Keyable
+func (t *Terminal) KeyUp(ev *fyne.KeyEvent) {
+	if isShift(ev.Name) {
+		t.mu.Lock()
+		t.shift = false
+		t.mu.Unlock()
+	}
+}
+
+// AcceptsTab implements fyne.Tabbable, tab completes on the shell
+func (t *Terminal) AcceptsTab() bool {
+	return true
+}
+
+// TypedShortcut implements fyne.Shortcutable, the editing shortcuts of
+// fyne are the control characters of the shell except on macOS.
+func (t *Terminal) TypedShortcut(s fyne.Shortcut) {
+	macOS := runtime.GOOS == "darwin"
+	t.mu.Lock()
+	shift := t.shift
+	t.mu.Unlock()
+	switch sc := s.(type) {
+	case *desktop.CustomShortcut:
+		if sc.Modifier == fyne.KeyModifierControl|fyne.KeyModifierShift {
+			switch sc.KeyName {
+			case fyne.KeyC:
+				t.Copy()
+				return
+			case fyne.KeyV:
+				t.Paste()
+				return
+			}
+		}
+		t.typed(controlSequence(sc.KeyName, sc.Modifier))
+	case *fyne.ShortcutCopy:
+		if macOS {
+			t.Copy()
+			return
+		}
+		t.typed("\x03")
+	case *fyne.ShortcutPaste:
+		// shift+insert pastes too
+		if macOS || shift {
+			t.Paste()
+			return
+		}
+		t.typed("\x16")
+	case *fyne.ShortcutCut:
+		t.typed("\x18")
+	case *fyne.ShortcutSelectAll:
+		t.typed("\x01")
+	case *fyne.ShortcutUndo:
+		t.typed("\x1a")
+	case *fyne.ShortcutRedo:
+		t.typed("\x19")
+	}
+}
+
+// Tapped focuses the terminal and clears the selection
+func (t *Terminal) Tapped(*fyne.PointEvent) {
+	if c := fyne.CurrentApp().Driver().CanvasForObject(t); c != nil {
+		c.Focus(t)
+	}
+	t.mu.Lock()
+	t.hasSel = false
+	t.mu.Unlock()
+	t.Refresh()
+}
+
+// Dragged selects the text from the start of the drag
+func (t *Terminal) Dragged(ev *fyne.DragEvent) {
+	t.mu.Lock()
+	if !t.selecting {
+		t.selecting = true
+		t.selFrom = t.positionAt(ev.Position.Subtract(ev.Dragged))
+	}
+	t.selTo = t.positionAt(ev.Position)
+	t.hasSel = true
+	t.mu.Unlock()
+	t.Refresh()
+}
+
+// DragEnd implements fyne.Draggable
+func (t *Terminal) DragEnd() {
+	t.mu.Lock()
+	t.selecting = false
+	t.mu.Unlock()
+}
+
+// Scrolled scrolls back with the mouse wheel
+func (t *Terminal) Scrolled(ev *fyne.ScrollEvent) {
+	lines := int(ev.Scrolled.DY / cellSize().Height)
+	if lines == 0 && ev.Scrolled.DY > 0 {
+		lines = 1
+	} else if lines == 0 && ev.Scrolled.DY < 0 {
+		lines = -1
+	}
+	t.scrollBy(lines)
+}
+
+// Cursor implements desktop.Cursorable
+func (t *Terminal) Cursor() desktop.Cursor {
+	return desktop.TextCursor
+}
+
+// positionAt returns the cell under p, t.mu must be held
+func (t *Terminal) positionAt(p fyne.Position) position {
+	cell := cellSize()
+	cols, rows := t.screen.Size()
+	row := min(max(int(p.Y/cell.Height), 0), rows-1)
+	col := min(max(int(p.X/cell.Width), 0), cols-1)
+	return position{line: t.screen.ScrollbackLen() - t.offset + row, col: col}
+}
+
+// selected reports whether the cell is in the selection, t.mu must be held
+func (t *Terminal) selected(p position) bool {
+	if !t.hasSel {
+		return false
+	}
+	from, to := t.selFrom, t.selTo
+	if to.before(from) {
+		from, to = to, from
+	}
+	return !p.before(from) && !to.before(p)
+}
+
+// CreateRenderer implements fyne.Widget
+func (t *Terminal) CreateRenderer() fyne.WidgetRenderer {
+	return &terminalRenderer{t: t}
+}
+
+// updateGrid copies the visible lines to the grid
+func (t *Terminal) updateGrid() {
+	focused := false
+	if c := fyne.CurrentApp().Driver().CanvasForObject(t); c != nil {
+		focused = c.Focused() == t
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if fg := theme.Color(theme.ColorNameForeground); fg != t.themeFG {
+		t.styles = make(map[Style]widget.TextGridStyle)
+		t.themeFG = fg
+	}
+	cols, rows := t.screen.Size()
+	start := t.screen.ScrollbackLen() - t.offset
+	cursorRow, cursorCol, cursorVisible := t.screen.Cursor()
+	gridRows := make([]widget.TextGridRow, rows)
+	for r := range gridRows {
+		l := t.screen.Line(start + r)
+		cells := make([]widget.TextGridCell, cols)
+		for c := range cells {
+			cell := Cell{Rune: ' '}
+			if c < len(l) {
+				cell = l[c]
+			}
+			if cell.Rune == 0 {
+				cell.Rune = ' '
+			}
+			style := cell.Style
+			if t.selected(position{start + r, c}) {
+				style.Reverse = !style.Reverse
+			}
+			if focused && cursorVisible && t.offset == 0 && r == cursorRow && c == cursorCol {
+				style.Reverse = !style.Reverse
+			}
+			cells[c] = widget.TextGridCell{Rune: cell.Rune, Style: t.gridStyle(style)}
+		}
+		gridRows[r].Cells = cells
+	}
+	t.grid.Rows = gridRows
+}
+
+// gridStyle converts style to the TextGrid style, t.mu must be held
+func (t *Terminal) gridStyle(style Style) widget.TextGridStyle {
+	if style == (Style{}) {
+		return nil
+	}
+	if s, ok := t.styles[style]; ok {
+		return s
+	}
+	fg, bg := style.FG, style.BG
+	if style.Reverse {
+		if fg == nil {
+			fg = theme.Color(theme.ColorNameForeground)
+		}
+		if bg == nil {
+			bg = theme.Color(theme.ColorNameBackground)
+		}
+		fg, bg = bg, fg
+	}
+	s := &widget.CustomTextGridStyle{
+		TextStyle: fyne.TextStyle{Bold: style.Bold, Underline: style.Underline},
+		FGColor:   fg,
+		BGColor:   bg,
+	}
+	t.styles[style] = s
+	return s
+}
+
+// cellSize is the size of a TextGrid cell
+func cellSize() fyne.Size {
+	size := fyne.MeasureText("M", theme.TextSize(), fyne.TextStyle{Monospace: true})
+	return fyne.NewSize(float32(math.Round(float64(size.Width))), float32(math.Round(float64(size.Height))))
+}
+
+type terminalRenderer struct {
+	t *Terminal
+}
+
+func (r *terminalRenderer) Layout(size fyne.Size) {
+	r.t.grid.Resize(size)
+}
+
+// MinSize is small, the screen follows the size of the widget
+func (r *terminalRenderer) MinSize() fyne.Size {
+	cell := cellSize()
+	return fyne.NewSize(cell.Width*20, cell.Height*5)
+}
+
+func (r *terminalRenderer) Refresh() {
+	r.t.updateGrid()
+	r.t.grid.Refresh()
+}
+
+func (r *terminalRenderer) Objects() []fyne.CanvasObject {
+	return []fyne.CanvasObject{r.t.grid}
+}
+
+func (r *terminalRenderer) Destroy() {}
@@ -0,0 +1,49 @@
+package terminal
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/test"
+)
+
+func TestTerminalInput(t *testing.T) {
+	test.NewTempApp(t)
+	term := New()
+	w := test.NewTempWindow(t, term)
+	w.Resize(fyne.NewSize(400, 300))
+
+	var in bytes.Buffer
+	out, outW := io.Pipe()
+	done := make(chan error)
+	go func() {
+		done <- term.Run(&in, out)
+	}()
+	outW.Write([]byte("\x1b[?1h$ "))
+	outW.Close()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	w.Canvas().Focus(term)
+	test.Type(term, "ls")
+	term.TypedKey(&fyne.KeyEvent{Name: fyne.KeyReturn})
+	term.TypedKey(&fyne.KeyEvent{Name: fyne.KeyUp})
+	term.TypedShortcut(&fyne.ShortcutCopy{})
+	term.TypedShortcut(&desktop.CustomShortcut{KeyName: fyne.KeyD, Modifier: fyne.KeyModifierControl})
+	if got, want := in.String(), "ls\r\x1bOA\x03\x04"; got != want {
+		t.Errorf("input = %q, want %q", got, want)
+	}
+
+	cols, rows := term.GridSize()
+	if cols < 20 || rows < 5 {
+		t.Errorf("grid size = %dx%d", cols, rows)
+	}
+	term.Refresh()
+	if got := term.grid.RowText(0); got[:2] != "$ " {
+		t.Errorf("first row = %q", got)
+	}
+}
@@ -1,17 +1,27 @@
 package view
 
 import (
+	"errors"
+	"fmt"
+	"image/color"
+	"strings"
+
 	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/layout"
 	"fyne.io/fyne/v2/widget"
+	"github.com/luo2pei4/ltool/view/state"
 )
 
 type View interface {
 	CreateView(w fyne.Window) fyne.CanvasObject
 }
 
-func showProgressing(win fyne.Window, message string, spinnerWidth float32) *widget.PopUp {
+// showProgressing shows a modal spinner, a Cancel button is added
+// if onCancel is not nil.
+func showProgressing(win fyne.Window, message string, spinnerWidth float32, onCancel func()) *widget.PopUp {
 
 	msg := widget.NewLabel(message)
 	spinner := widget.NewProgressBarInfinite()
@@ -25,10 +35,92 @@ func showProgressing(win fyne.Window, message string, spinnerWidth float32) *wid
 		spinnerLayout,
 		container.New(layout.NewCenterLayout(), msg),
 	)
+	height := float32(80)
+	if onCancel != nil {
+		var cancelBtn *widget.Button
+		cancelBtn = widget.NewButton("Cancel", func() {
+			cancelBtn.Disable()
+			msg.SetText("Cancelling, please wait...")
+			onCancel()
+		})
+		card.Add(container.NewCenter(cancelBtn))
+		height += 40
+	}
 
 	popup := widget.NewModalPopUp(card, win.Canvas())
-	popup.Resize(fyne.NewSize(spinnerWidth+100, 80))
+	popup.Resize(fyne.NewSize(spinnerWidth+100, height))
 	popup.Show()
 
 	return popup
 }
+
+// progressPopup a modal progress bar showing done/total
+type progressPopup struct {
+	popup *widget.PopUp
+	bar   *widget.ProgressBar
+}
+
+// showProgressBar shows a modal progress bar of total items, a Cancel button
+// is added if onCancel is not nil.
+func showProgressBar(win fyne.Window, message string, total int, barWidth float32, onCancel func()) *progressPopup {
+
+	msg := widget.NewLabel(message)
+	bar := widget.NewProgressBar()
+	bar.Max = float64(total)
+	bar.TextFormatter = func() string {
+		return fmt.Sprintf("%d/%d", int(bar.Value), total)
+	}
+	barArea := container.NewGridWrap(fyne.NewSize(barWidth, bar.MinSize().Height), bar)
+
+	card := container.NewVBox(
+		container.NewCenter(msg),
+		container.NewCenter(barArea),
+	)
+	if onCancel != nil {
+		var cancelBtn *widget.Button
+		cancelBtn = widget.NewButton("Cancel", func() {
+			cancelBtn.Disable()
+			msg.SetText("Cancelling, please wait...")
+			onCancel()
+		})
+		card.Add(container.NewCenter(cancelBtn))
+	}
+
+	popup := widget.NewModalPopUp(card, win.Canvas())
+	popup.Resize(fyne.NewSize(barWidth+100, card.MinSize().Height+20))
+	popup.Show()
+
+	return &progressPopup{popup: popup, bar: bar}
+}
+
+// SetDone updates the number of the finished items, it must run on the UI thread
+func (p *progressPopup) SetDone(done int) {
+	p.bar.SetValue(float64(done))
+}
+
+func (p *progressPopup) Hide() {
+	p.popup.Hide()
+}
+
+// showError shows the error of a remote operation,
+// a cancelled operation reports what finished and what was cancelled.
+func showError(win fyne.Window, err error) {
+	var cancelledErr *state.CancelledError
+	if errors.As(err, &cancelledErr) {
+		text := "Finished:\n  " + strings.Join(cancelledErr.Finished, "\n  ") +
+			"\nCancelled:\n  " + strings.Join(cancelledErr.Cancelled, "\n  ")
+		label := widget.NewLabel(text)
+		label.Selectable = true
+		scroll := container.NewVScroll(label)
+		scroll.SetMinSize(fyne.NewSize(400, 200))
+		dialog.ShowCustom("Cancelled", "Close", scroll, win)
+		return
+	}
+	// draw error dialog
+	errLabel := widget.NewLabel(err.Error())
+	errLabel.Wrapping = fyne.TextWrapWord
+	bg := canvas.NewRectangle(color.NRGBA{0, 0, 0, 0})
+	bg.SetMinSize(fyne.NewSize(400, 160))
+	content := container.NewStack(bg, container.NewVBox(errLabel))
+	dialog.ShowCustom("Error", "Close", content, win)
+}
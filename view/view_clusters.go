@@ -0,0 +1,229 @@
+package view
+
+import (
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/luo2pei4/ltool/pkg/dblayer/repo"
+	logger "github.com/luo2pei4/ltool/pkg/log"
+	"github.com/luo2pei4/ltool/pkg/utils"
+	"github.com/luo2pei4/ltool/view/state"
+)
+
+const preferenceCurrentCluster = "currentCluster"
+
+// clusters the clusters shared by the switcher and the views
+var clusters = &state.ClustersState{}
+
+// NewClusterSwitcher creates the select of the active cluster. onSwitch is
+// called after another cluster became active, e.g. to recreate the current
+// view. The returned restore loads the clusters and activates the last used
+// one, the credentials of the clusters are readable after unlocking only.
+func NewClusterSwitcher(w fyne.Window, onSwitch func()) (fyne.CanvasObject, func()) {
+	prefs := fyne.CurrentApp().Preferences()
+	clusterSelect := widget.NewSelect(nil, nil)
+	clusterSelect.PlaceHolder = "locked"
+	clusterSelect.OnChanged = func(name string) {
+		if name == clusters.Active().Name {
+			return
+		}
+		if err := clusters.Switch(name); err != nil {
+			showError(w, err)
+			return
+		}
+		prefs.SetString(preferenceCurrentCluster, name)
+		onSwitch()
+	}
+	refresh := func() {
+		onChanged := clusterSelect.OnChanged
+		clusterSelect.OnChanged = nil
+		clusterSelect.SetOptions(clusters.Names())
+		clusterSelect.SetSelected(clusters.Active().Name)
+		clusterSelect.OnChanged = onChanged
+	}
+	restore := func() {
+		if err := clusters.LoadAllRecords(); err != nil {
+			logger.Errorf("load clusters failed, %v", err)
+			return
+		}
+		if name := prefs.String(preferenceCurrentCluster); name != "" {
+			if err := clusters.Switch(name); err != nil {
+				logger.Warnf("restore cluster failed, %v", err)
+			}
+		}
+		refresh()
+		onSwitch()
+	}
+	manageBtn := widget.NewButtonWithIcon("", theme.SettingsIcon(), func() {
+		showClustersDialog(w, func() {
+			// the active cluster may have been renamed
+			refresh()
+			prefs.SetString(preferenceCurrentCluster, clusters.Active().Name)
+		})
+	})
+	return container.NewBorder(nil, nil, widget.NewLabel("Cluster"), manageBtn, clusterSelect), restore
+}
+
+// showClustersDialog adds, changes and deletes the clusters, onChange is
+// called after the clusters were changed
+func showClustersDialog(w fyne.Window, onChange func()) {
+	if err := clusters.LoadAllRecords(); err != nil {
+		showError(w, err)
+		return
+	}
+
+	var editing repo.Cluster
+	nameEntry := widget.NewEntry()
+	nameEntry.SetPlaceHolder("lustre01")
+	descEntry := widget.NewEntry()
+	userEntry := widget.NewEntry()
+	userEntry.SetPlaceHolder("default user of the new nodes")
+	entries := newAuthEntries(utils.SSHAuth{})
+	formArea := container.NewVBox()
+	resetForm := func(rec repo.Cluster) {
+		editing = rec
+		nameEntry.SetText(rec.Name)
+		descEntry.SetText(rec.Description)
+		userEntry.SetText(rec.UserName)
+		entries = newAuthEntries(utils.SSHAuth{
+			AuthType:   rec.AuthType,
+			Password:   rec.Password,
+			KeyFile:    rec.KeyFile,
+			Passphrase: rec.Passphrase,
+			CertFile:   rec.CertFile,
+		})
+		items := []*widget.FormItem{
+			widget.NewFormItem("Name", nameEntry),
+			widget.NewFormItem("Description", descEntry),
+			widget.NewFormItem("User", userEntry),
+		}
+		formArea.Objects = []fyne.CanvasObject{widget.NewForm(append(items, entries.formItems()...)...)}
+		formArea.Refresh()
+	}
+	resetForm(repo.Cluster{})
+
+	list := widget.NewList(
+		func() int {
+			return len(clusters.Records)
+		},
+		func() fyne.CanvasObject {
+			return widget.NewLabel("")
+		},
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			rec := clusters.Records[id]
+			text := rec.Name
+			if rec.Description != "" {
+				text = fmt.Sprintf("%s  %s", rec.Name, rec.Description)
+			}
+			if rec.ID == clusters.Active().ID {
+				text += "  (active)"
+			}
+			obj.(*widget.Label).SetText(text)
+		},
+	)
+	list.OnSelected = func(id widget.ListItemID) {
+		resetForm(clusters.Records[id])
+	}
+	refresh := func() {
+		list.UnselectAll()
+		list.Refresh()
+		resetForm(repo.Cluster{})
+		onChange()
+	}
+
+	newBtn := widget.NewButton("New", func() {
+		list.UnselectAll()
+		resetForm(repo.Cluster{})
+	})
+	saveBtn := widget.NewButton("Save", func() {
+		auth := entries.auth()
+		rec := editing
+		rec.Name = nameEntry.Text
+		rec.Description = descEntry.Text
+		rec.UserName = userEntry.Text
+		rec.AuthType = auth.AuthType
+		rec.Password = auth.Password
+		rec.KeyFile = auth.KeyFile
+		rec.Passphrase = auth.Passphrase
+		rec.CertFile = auth.CertFile
+		if err := clusters.Save(rec); err != nil {
+			showError(w, err)
+			return
+		}
+		refresh()
+	})
+	deleteBtn := widget.NewButton("Delete", func() {
+		if editing.ID == 0 {
+			return
+		}
+		rec := editing
+		dialog.ShowConfirm(
+			"Delete confirm",
+			fmt.Sprintf("Are you sure you want to delete the cluster %s with all its nodes?", rec.Name),
+			func(confirm bool) {
+				if !confirm {
+					return
+				}
+				if err := clusters.Delete(rec.ID); err != nil {
+					showError(w, err)
+					return
+				}
+				refresh()
+			}, w,
+		)
+	})
+
+	content := container.NewBorder(
+		nil,
+		container.NewVBox(formArea, container.NewHBox(newBtn, saveBtn, deleteBtn)),
+		nil,
+		nil,
+		list,
+	)
+	d := dialog.NewCustom("Clusters", "Close", content, w)
+	d.Resize(fyne.NewSize(520, 600))
+	d.Show()
+}
+
+// showCopyDialog copies the checked saved nodes to another cluster
+func (n *NodesUI) showCopyDialog(w fyne.Window) {
+	if n.state.GetCheckedRecordsCount() == 0 {
+		return
+	}
+	if err := clusters.LoadAllRecords(); err != nil {
+		showError(w, err)
+		return
+	}
+	active := clusters.Active().Name
+	var targets []string
+	for _, name := range clusters.Names() {
+		if name != active {
+			targets = append(targets, name)
+		}
+	}
+	if len(targets) == 0 {
+		dialog.ShowInformation("Copy nodes", "There is no other cluster, add one with the cluster settings", w)
+		return
+	}
+	targetSelect := widget.NewSelect(targets, nil)
+	targetSelect.SetSelectedIndex(0)
+	dialog.ShowCustomConfirm("Copy nodes", "Copy", "Cancel",
+		widget.NewForm(widget.NewFormItem("To cluster", targetSelect)),
+		func(confirm bool) {
+			if !confirm {
+				return
+			}
+			target, _ := clusters.Find(targetSelect.Selected)
+			cnt, err := n.state.CopyCheckedNodes(target.ID)
+			if err != nil {
+				showError(w, err)
+				return
+			}
+			dialog.ShowInformation("Copy nodes", fmt.Sprintf("Copied %d nodes to %s", cnt, target.Name), w)
+		}, w,
+	)
+}
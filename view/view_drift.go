@@ -0,0 +1,210 @@
+package view
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/luo2pei4/ltool/pkg/config"
+	"github.com/luo2pei4/ltool/pkg/utils"
+	"github.com/luo2pei4/ltool/view/state"
+)
+
+// showCompareDialog compares a file across the checked nodes, the chosen
+// version can be pushed to the nodes which drifted from it.
+func (n *NodesUI) showCompareDialog(w fyne.Window) {
+
+	conns, err := n.state.CheckedConnections()
+	if err != nil {
+		showError(w, err)
+		return
+	}
+	if len(conns) < 2 {
+		dialog.ShowInformation("Compare", "Check at least two nodes to compare the file of", w)
+		return
+	}
+	connMap := make(map[string]state.SSHConnection, len(conns))
+	for _, conn := range conns {
+		connMap[conn.IPAddress] = conn
+	}
+
+	var (
+		report *state.DriftReport
+		chosen int
+		cancel context.CancelFunc
+	)
+	exec := n.state.Executor()
+	workers := config.Conf.Check.WorkerCount()
+	pathEntry := widget.NewEntry()
+	pathEntry.SetPlaceHolder("remote file, e.g. /etc/lnet.conf")
+	progress := widget.NewProgressBar()
+	progress.Max = float64(len(conns))
+	progress.TextFormatter = func() string {
+		return fmt.Sprintf("%d/%d", int(progress.Value), len(conns))
+	}
+	failedLabel := widget.NewLabel("")
+	failedLabel.Wrapping = fyne.TextWrapWord
+	diffArea := container.NewStack()
+	versions := widget.NewRadioGroup(nil, nil)
+
+	var compareBtn, pushBtn *widget.Button
+	showReport := func() {
+		var options []string
+		for _, v := range report.Versions {
+			options = append(options, fmt.Sprintf("%s  %d bytes  %s", v.Name(), v.Size, strings.Join(v.IPs, ",")))
+		}
+		versions.OnChanged = nil
+		versions.Options = options
+		versions.Selected = ""
+		if len(options) > 0 {
+			versions.Selected = options[0]
+		}
+		versions.OnChanged = func(selected string) {
+			for i, option := range versions.Options {
+				if option == selected {
+					chosen = i
+				}
+			}
+			diffArea.Objects = []fyne.CanvasObject{newDiffView(report.Diffs(chosen))}
+			diffArea.Refresh()
+			pushBtn.SetText(fmt.Sprintf("Push to %d drifted nodes", len(report.Drifted(chosen))))
+		}
+		versions.Refresh()
+
+		var failed []string
+		for _, result := range report.Failed {
+			failed = append(failed, result.IP+": "+result.Err)
+		}
+		failedLabel.SetText(strings.Join(failed, "\n"))
+		diffArea.Objects = nil
+		pushBtn.SetText("Push")
+		pushBtn.Disable()
+		switch {
+		case len(report.Versions) == 0:
+		case len(report.Versions) == 1 && len(report.Failed) == 0:
+			diffArea.Objects = []fyne.CanvasObject{widget.NewLabel("The file is identical on all nodes")}
+		default:
+			chosen = 0
+			versions.OnChanged(versions.Selected)
+			pushBtn.Enable()
+		}
+		diffArea.Refresh()
+	}
+	compare := func() {
+		path := strings.TrimSpace(pathEntry.Text)
+		if path == "" {
+			w.Canvas().Focus(pathEntry)
+			return
+		}
+		progress.SetValue(0)
+		pushBtn.Disable()
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		compareBtn.SetText("Cancel")
+		go func() {
+			res, err := state.CompareFile(ctx, exec, conns, path, workers, func(done, total int) {
+				fyne.Do(func() {
+					progress.SetValue(float64(done))
+				})
+			})
+			fyne.Do(func() {
+				cancel()
+				cancel = nil
+				compareBtn.SetText("Compare")
+				compareBtn.Enable()
+				report = res
+				showReport()
+				if err != nil {
+					showError(w, err)
+				}
+			})
+		}()
+	}
+	compareBtn = widget.NewButton("Compare", func() {
+		if cancel != nil {
+			compareBtn.Disable()
+			cancel()
+			return
+		}
+		compare()
+	})
+	pathEntry.OnSubmitted = func(string) {
+		if cancel == nil {
+			compare()
+		}
+	}
+	pushBtn = widget.NewButton("Push", func() {
+		drifted := report.Drifted(chosen)
+		version := report.Versions[chosen]
+		message := fmt.Sprintf("Replace %s of %d nodes with %s?\n%s",
+			report.Path, len(drifted), version.Name(), strings.Join(drifted, ","))
+		dialog.ShowConfirm("Push", message, func(ok bool) {
+			if !ok {
+				return
+			}
+			var targets []state.SSHConnection
+			for _, ip := range drifted {
+				targets = append(targets, connMap[ip])
+			}
+			pushVersion(w, exec, targets, report.Path, &version, func() {
+				if cancel == nil {
+					compare()
+				}
+			})
+		}, w)
+	})
+	pushBtn.Disable()
+
+	content := container.NewBorder(
+		container.NewVBox(
+			widget.NewLabel(fmt.Sprintf("Compare a file of %d checked nodes", len(conns))),
+			container.NewBorder(nil, nil, nil, compareBtn, pathEntry),
+			progress,
+			versions,
+			failedLabel,
+		),
+		container.NewHBox(pushBtn),
+		nil,
+		nil,
+		diffArea,
+	)
+	d := dialog.NewCustom("Compare", "Close", content, w)
+	d.SetOnClosed(func() {
+		if cancel != nil {
+			cancel()
+		}
+	})
+	d.Resize(fyne.NewSize(900, 650))
+	d.Show()
+}
+
+// pushVersion writes the version to the nodes and shows the checksum of each node
+func pushVersion(w fyne.Window, exec utils.Executor, conns []state.SSHConnection, remotePath string,
+	version *state.FileVersion, onDone func()) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	progress := showProgressBar(w, "Pushing "+remotePath+", please wait...", len(conns), 300, cancel)
+	workers := config.Conf.Check.WorkerCount()
+	go func() {
+		defer cancel()
+		results, err := state.PushFile(ctx, exec, conns, remotePath, version, workers, func(done, total int) {
+			fyne.Do(func() {
+				progress.SetDone(done)
+			})
+		})
+		fyne.Do(func() {
+			progress.Hide()
+			if len(results) > 0 {
+				showTransfers(w, "Push", results)
+			}
+			if err != nil {
+				showError(w, err)
+			}
+			onDone()
+		})
+	}()
+}
@@ -0,0 +1,252 @@
+package view
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/luo2pei4/ltool/pkg/utils"
+	"github.com/luo2pei4/ltool/view/state"
+)
+
+// showEditor opens the remote text file of the node in an editor window
+func showEditor(w fyne.Window, exec utils.Executor, conn state.SSHConnection, filePath string) {
+	ctx, cancel := context.WithCancel(context.Background())
+	popup := showProgressing(w, "Opening "+filePath+", please wait...", 400, cancel)
+	go func() {
+		defer cancel()
+		file, err := state.OpenRemoteFile(ctx, exec, conn, filePath)
+		fyne.Do(func() {
+			popup.Hide()
+			if err != nil {
+				showError(w, err)
+				return
+			}
+			newEditorWindow(exec, file).Show()
+		})
+	}()
+}
+
+// editorWindow edits a remote text file, the changes are shown as a diff
+// and a backup is saved next to the file before it is replaced.
+type editorWindow struct {
+	win   fyne.Window
+	exec  utils.Executor
+	file  *state.RemoteTextFile
+	entry *widget.Entry
+}
+
+func newEditorWindow(exec utils.Executor, file *state.RemoteTextFile) fyne.Window {
+	e := &editorWindow{
+		win:  fyne.CurrentApp().NewWindow("Edit " + file.Conn.IPAddress + ":" + file.Path),
+		exec: exec,
+		file: file,
+	}
+	e.entry = widget.NewMultiLineEntry()
+	e.entry.TextStyle = fyne.TextStyle{Monospace: true}
+	e.entry.Wrapping = fyne.TextWrapOff
+	e.entry.SetText(file.Content)
+
+	reloadBtn := widget.NewButtonWithIcon("Reload", theme.ViewRefreshIcon(), e.reload)
+	backupsBtn := widget.NewButtonWithIcon("Backups", theme.HistoryIcon(), e.showBackups)
+	saveBtn := widget.NewButtonWithIcon("Save", theme.DocumentSaveIcon(), e.save)
+	saveBtn.Importance = widget.HighImportance
+	pathLabel := widget.NewLabel(file.Conn.IPAddress + ":" + file.Path)
+
+	e.win.SetContent(container.NewBorder(
+		container.NewBorder(nil, nil, nil, container.NewHBox(reloadBtn, backupsBtn, saveBtn), pathLabel),
+		nil, nil, nil,
+		e.entry,
+	))
+	e.win.Resize(fyne.NewSize(900, 600))
+	return e.win
+}
+
+// reload reads the file from the node again, the edits are discarded
+func (e *editorWindow) reload() {
+	load := func() {
+		e.run("Reload", "Reloading", func(ctx context.Context) (string, error) {
+			file, err := state.OpenRemoteFile(ctx, e.exec, e.file.Conn, e.file.Path)
+			if err != nil {
+				return "", err
+			}
+			fyne.Do(func() {
+				e.file = file
+				e.entry.SetText(file.Content)
+			})
+			return "", nil
+		})
+	}
+	if e.entry.Text == e.file.Content {
+		load()
+		return
+	}
+	dialog.ShowConfirm("Reload", "Discard the changes and reload "+e.file.Path+"?", func(ok bool) {
+		if ok {
+			load()
+		}
+	}, e.win)
+}
+
+// save shows the diff against the content on the node and saves it after confirmation
+func (e *editorWindow) save() {
+	content := e.entry.Text
+	diff := e.file.Diff(content)
+	if diff == "" {
+		dialog.ShowInformation("Save", "No changes", e.win)
+		return
+	}
+	showDiff(e.win, "Save "+e.file.Path, "Save", diff, func() {
+		e.run("Save", "Saving", func(ctx context.Context) (string, error) {
+			backup, err := e.file.Save(ctx, e.exec, content, time.Now())
+			if err != nil {
+				return "", err
+			}
+			return "Saved " + e.file.Path + "\nBackup " + backup, nil
+		})
+	})
+}
+
+// showBackups lists the backups of the file, a backup is restored after
+// its diff against the content on the node was confirmed.
+func (e *editorWindow) showBackups() {
+	ctx, cancel := context.WithCancel(context.Background())
+	popup := showProgressing(e.win, "Listing backups, please wait...", 400, cancel)
+	go func() {
+		defer cancel()
+		backups, err := e.file.ListBackups(ctx, e.exec)
+		fyne.Do(func() {
+			popup.Hide()
+			if err != nil {
+				showError(e.win, err)
+				return
+			}
+			if len(backups) == 0 {
+				dialog.ShowInformation("Backups", "No backups of "+e.file.Path, e.win)
+				return
+			}
+			e.showBackupList(backups)
+		})
+	}()
+}
+
+func (e *editorWindow) showBackupList(backups []state.Backup) {
+	var d dialog.Dialog
+	list := widget.NewList(
+		func() int {
+			return len(backups)
+		},
+		func() fyne.CanvasObject {
+			return widget.NewLabel("")
+		},
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			backup := backups[id]
+			text := fmt.Sprintf("%s  %8d  %s", backup.Time.Format("2006-01-02 15:04:05.000"), backup.Size, backup.Path)
+			if !backup.Restored.IsZero() {
+				text += "  (taken by restoring " + backup.Restored.Format("2006-01-02 15:04:05.000") + ")"
+			}
+			obj.(*widget.Label).SetText(text)
+		},
+	)
+	list.OnSelected = func(id widget.ListItemID) {
+		list.UnselectAll()
+		d.Hide()
+		e.confirmRestore(backups[id])
+	}
+	scroll := container.NewVScroll(list)
+	scroll.SetMinSize(fyne.NewSize(700, 250))
+	d = dialog.NewCustom("Backups of "+e.file.Conn.IPAddress+":"+e.file.Path, "Close", scroll, e.win)
+	d.Show()
+}
+
+func (e *editorWindow) confirmRestore(backup state.Backup) {
+	ctx, cancel := context.WithCancel(context.Background())
+	popup := showProgressing(e.win, "Reading "+backup.Path+", please wait...", 400, cancel)
+	go func() {
+		defer cancel()
+		content, err := e.file.ReadBackup(ctx, e.exec, backup.Path)
+		fyne.Do(func() {
+			popup.Hide()
+			if err != nil {
+				showError(e.win, err)
+				return
+			}
+			diff := state.UnifiedDiff(e.file.Conn.IPAddress+":"+e.file.Path, backup.Path, e.file.Content, content)
+			if diff == "" {
+				dialog.ShowInformation("Restore", "The backup equals the file on the node", e.win)
+				return
+			}
+			showDiff(e.win, "Restore "+backup.Path, "Restore", diff, func() {
+				e.run("Restore", "Restoring", func(ctx context.Context) (string, error) {
+					saved, err := e.file.Restore(ctx, e.exec, backup.Path, time.Now())
+					if err != nil {
+						return "", err
+					}
+					fyne.Do(func() {
+						e.entry.SetText(e.file.Content)
+					})
+					return "Restored " + e.file.Path + " from " + backup.Path + "\nBackup " + saved, nil
+				})
+			})
+		})
+	}()
+}
+
+// run runs fn with a spinner and shows its message or error in a dialog titled title
+func (e *editorWindow) run(title, action string, fn func(ctx context.Context) (string, error)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	popup := showProgressing(e.win, action+" "+e.file.Path+", please wait...", 400, cancel)
+	go func() {
+		defer cancel()
+		message, err := fn(ctx)
+		fyne.Do(func() {
+			popup.Hide()
+			if err != nil {
+				showError(e.win, err)
+				return
+			}
+			if message != "" {
+				dialog.ShowInformation(title, message, e.win)
+			}
+		})
+	}()
+}
+
+// newDiffView renders a unified diff with the added and removed lines colored
+func newDiffView(diff string) fyne.CanvasObject {
+	grid := widget.NewTextGridFromString(strings.TrimSuffix(diff, "\n"))
+	grid.Scroll = fyne.ScrollNone
+	added := &widget.CustomTextGridStyle{FGColor: theme.Color(theme.ColorNameSuccess)}
+	removed := &widget.CustomTextGridStyle{FGColor: theme.Color(theme.ColorNameError)}
+	hunk := &widget.CustomTextGridStyle{FGColor: theme.Color(theme.ColorNamePrimary)}
+	for i, line := range strings.Split(strings.TrimSuffix(diff, "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+			grid.SetRowStyle(i, &widget.CustomTextGridStyle{TextStyle: fyne.TextStyle{Bold: true}})
+		case strings.HasPrefix(line, "+"):
+			grid.SetRowStyle(i, added)
+		case strings.HasPrefix(line, "-"):
+			grid.SetRowStyle(i, removed)
+		case strings.HasPrefix(line, "@@"):
+			grid.SetRowStyle(i, hunk)
+		}
+	}
+	scroll := container.NewScroll(grid)
+	scroll.SetMinSize(fyne.NewSize(800, 400))
+	return scroll
+}
+
+// showDiff asks to confirm the diff, onConfirm is called if confirmed
+func showDiff(w fyne.Window, title, confirm, diff string, onConfirm func()) {
+	dialog.ShowCustomConfirm(title, confirm, "Cancel", newDiffView(diff), func(ok bool) {
+		if ok {
+			onConfirm()
+		}
+	}, w)
+}
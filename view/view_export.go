@@ -0,0 +1,67 @@
+package view
+
+import (
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/luo2pei4/ltool/pkg/inventory"
+	"github.com/luo2pei4/ltool/view/state"
+)
+
+// how the secrets of the exported nodes are written
+const (
+	secretsLeaveOut = "Leave out"
+	secretsRedact   = "Redact"
+)
+
+// exportExtensions the file extension of each export format
+var exportExtensions = map[string]string{
+	inventory.FormatCSV:          ".csv",
+	inventory.FormatJSON:         ".json",
+	inventory.FormatYAML:         ".yaml",
+	inventory.FormatAnsibleINI:   ".ini",
+	inventory.FormatClusterShell: ".cfg",
+}
+
+// showExportDialog writes the saved nodes to a file
+func (n *NodesUI) showExportDialog(w fyne.Window) {
+	formatSelect := widget.NewSelect(inventory.ExportFormats, nil)
+	formatSelect.SetSelected(inventory.FormatCSV)
+	secrets := widget.NewRadioGroup([]string{secretsLeaveOut, secretsRedact}, nil)
+	secrets.Horizontal = true
+	secrets.Required = true
+	secrets.SetSelected(secretsLeaveOut)
+	items := []*widget.FormItem{
+		widget.NewFormItem("Format", formatSelect),
+		widget.NewFormItem("Secrets", secrets),
+		widget.NewFormItem("", widget.NewLabel("The saved nodes are exported, save the changes first")),
+	}
+	dialog.ShowForm("Export nodes", "Export", "Cancel", items, func(confirm bool) {
+		if !confirm {
+			return
+		}
+		format, redact := formatSelect.Selected, secrets.Selected == secretsRedact
+		save := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+			if err != nil {
+				showError(w, err)
+				return
+			}
+			if writer == nil {
+				return
+			}
+			count, err := state.ExportNodes(writer, format, redact)
+			if closeErr := writer.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				showError(w, fmt.Errorf("export nodes failed, %v", err))
+				return
+			}
+			dialog.ShowInformation("Export nodes", fmt.Sprintf("Exported %d nodes to %s", count, writer.URI().Path()), w)
+		}, w)
+		save.SetFileName("ltool-nodes" + exportExtensions[format])
+		save.Show()
+	}, w)
+}
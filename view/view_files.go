@@ -0,0 +1,337 @@
+package view
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"path/filepath"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/luo2pei4/ltool/pkg/config"
+	logger "github.com/luo2pei4/ltool/pkg/log"
+	"github.com/luo2pei4/ltool/pkg/utils"
+	"github.com/luo2pei4/ltool/view/state"
+)
+
+type FilesUI struct {
+	state     *state.FilesState
+	nodeList  *widget.SelectEntry // management ip address list
+	rootEntry *widget.Entry
+	tree      *widget.Tree
+	infoLabel *widget.Label
+	conn      state.SSHConnection
+	root      string // the opened directory, the root of the tree
+	selected  string
+}
+
+func NewFilesUI() View {
+	return &FilesUI{
+		state: &state.FilesState{},
+	}
+}
+
+func (v *FilesUI) CreateView(w fyne.Window) fyne.CanvasObject {
+	v.nodeList = widget.NewSelectEntry([]string{})
+	if err := v.state.LoadNodeList(); err == nil {
+		v.nodeList.SetOptions(v.state.NodeList)
+	} else {
+		logger.Errorf("load node list failed, %v\n", err)
+	}
+	v.rootEntry = widget.NewEntry()
+	v.rootEntry.SetText("/etc")
+	v.infoLabel = widget.NewLabel("")
+	v.infoLabel.Selectable = true
+
+	v.tree = widget.NewTree(
+		func(uid widget.TreeNodeID) []widget.TreeNodeID {
+			if uid == "" {
+				if v.root == "" {
+					return nil
+				}
+				return []string{v.root}
+			}
+			paths, _ := v.state.Children(uid)
+			return paths
+		},
+		func(uid widget.TreeNodeID) bool {
+			if uid == "" || uid == v.root {
+				return true
+			}
+			file, _ := v.state.File(uid)
+			return file.IsDir
+		},
+		func(branch bool) fyne.CanvasObject {
+			return container.NewBorder(nil, nil, widget.NewIcon(nil), widget.NewLabel(""), widget.NewLabel(""))
+		},
+		func(uid widget.TreeNodeID, branch bool, obj fyne.CanvasObject) {
+			row := obj.(*fyne.Container)
+			nameLabel := row.Objects[0].(*widget.Label)
+			icon := row.Objects[1].(*widget.Icon)
+			detailLabel := row.Objects[2].(*widget.Label)
+			if branch {
+				icon.SetResource(theme.FolderIcon())
+			} else {
+				icon.SetResource(theme.FileIcon())
+			}
+			if uid == v.root {
+				nameLabel.SetText(uid)
+				detailLabel.SetText("")
+				return
+			}
+			nameLabel.SetText(path.Base(uid))
+			file, _ := v.state.File(uid)
+			detailLabel.SetText(fmt.Sprintf("%s  %8d  %s", file.Mode, file.Size, file.ModTime.Format("2006-01-02 15:04")))
+		},
+	)
+	v.tree.OnBranchOpened = func(uid widget.TreeNodeID) {
+		if _, loaded := v.state.Children(uid); !loaded {
+			v.loadDir(w, uid)
+		}
+	}
+	v.tree.OnSelected = func(uid widget.TreeNodeID) {
+		v.selected = uid
+		v.infoLabel.SetText(v.conn.IPAddress + ":" + uid)
+	}
+
+	openBtn := widget.NewButtonWithIcon("Open", theme.FolderOpenIcon(), func() {
+		conn, ok := v.state.SSHCon[v.nodeList.Text]
+		if !ok {
+			dialog.ShowInformation("Files", "Select a management ip address", w)
+			return
+		}
+		v.conn = conn
+		v.root = path.Clean("/" + v.rootEntry.Text)
+		v.selected = ""
+		v.infoLabel.SetText("")
+		v.state.Reset()
+		v.tree.UnselectAll()
+		v.tree.CloseAllBranches()
+		v.tree.Refresh()
+		v.tree.OpenBranch(v.root)
+	})
+	v.rootEntry.OnSubmitted = func(string) {
+		openBtn.OnTapped()
+	}
+	refreshBtn := widget.NewButtonWithIcon("Refresh", theme.ViewRefreshIcon(), func() {
+		if v.root != "" {
+			v.loadDir(w, v.selectedDir())
+		}
+	})
+	downloadBtn := widget.NewButtonWithIcon("Download", theme.DownloadIcon(), func() {
+		v.download(w)
+	})
+	editBtn := widget.NewButtonWithIcon("Edit", theme.DocumentCreateIcon(), func() {
+		file, ok := v.state.File(v.selected)
+		if !ok || file.IsDir {
+			dialog.ShowInformation("Edit", "Select a file to edit", w)
+			return
+		}
+		showEditor(w, v.state.Executor(), v.conn, file.Path)
+	})
+	uploadBtn := widget.NewButtonWithIcon("Upload", theme.UploadIcon(), func() {
+		if v.root == "" {
+			dialog.ShowInformation("Upload", "Open a directory of a node first", w)
+			return
+		}
+		dir := v.selectedDir()
+		dialog.ShowFileOpen(func(reader fyne.URIReadCloser, err error) {
+			if err != nil {
+				showError(w, err)
+				return
+			}
+			if reader == nil {
+				return
+			}
+			reader.Close()
+			localPath := reader.URI().Path()
+			showUploadDialog(w, v.state.Executor(), v.state.NodeList, v.state.SSHCon, []string{v.conn.IPAddress},
+				localPath, path.Join(dir, filepath.Base(localPath)), func() {
+					v.loadDir(w, dir)
+				})
+		}, w)
+	})
+
+	inputArea := container.NewBorder(nil, nil, nil, openBtn,
+		container.NewGridWithColumns(2, v.nodeList, v.rootEntry))
+	return container.NewBorder(
+		container.NewVBox(inputArea, widget.NewSeparator()),
+		container.NewBorder(nil, nil, nil, container.NewHBox(refreshBtn, editBtn, downloadBtn, uploadBtn), v.infoLabel),
+		nil,
+		nil,
+		v.tree,
+	)
+}
+
+// selectedDir returns the selected directory, or the directory of the selected file
+func (v *FilesUI) selectedDir() string {
+	if v.selected == "" {
+		return v.root
+	}
+	if file, ok := v.state.File(v.selected); ok && !file.IsDir {
+		return path.Dir(v.selected)
+	}
+	return v.selected
+}
+
+// loadDir lists dir of the opened node and refreshes the tree
+func (v *FilesUI) loadDir(w fyne.Window, dir string) {
+	ctx, cancel := context.WithCancel(context.Background())
+	popup := showProgressing(w, "Listing "+dir+", please wait...", 400, cancel)
+	conn := v.conn
+	go func() {
+		defer cancel()
+		err := v.state.LoadDir(ctx, conn, dir)
+		fyne.Do(func() {
+			popup.Hide()
+			if err != nil {
+				showError(w, err)
+				return
+			}
+			v.tree.Refresh()
+		})
+	}()
+}
+
+func (v *FilesUI) download(w fyne.Window) {
+	file, ok := v.state.File(v.selected)
+	if !ok || file.IsDir {
+		dialog.ShowInformation("Download", "Select a file to download", w)
+		return
+	}
+	conn := v.conn
+	save := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil {
+			showError(w, err)
+			return
+		}
+		if writer == nil {
+			return
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		popup := showProgressing(w, "Downloading "+file.Path+", please wait...", 400, cancel)
+		go func() {
+			defer cancel()
+			result, err := state.DownloadFile(ctx, v.state.Executor(), conn, file.Path, writer)
+			if closeErr := writer.Close(); err == nil && closeErr != nil {
+				err = fmt.Errorf("save %s failed, %v", writer.URI().Path(), closeErr)
+			}
+			fyne.Do(func() {
+				popup.Hide()
+				if err != nil {
+					showError(w, err)
+					return
+				}
+				showTransfers(w, "Download", []state.TransferResult{result})
+			})
+		}()
+	}, w)
+	save.SetFileName(path.Base(file.Path))
+	save.Show()
+}
+
+// showUploadDialog uploads a local file to the checked nodes
+func (n *NodesUI) showUploadDialog(w fyne.Window) {
+	conns, err := n.state.CheckedConnections()
+	if err != nil {
+		showError(w, err)
+		return
+	}
+	if len(conns) == 0 {
+		dialog.ShowInformation("Upload", "Check the nodes to upload the file to", w)
+		return
+	}
+	ips := make([]string, 0, len(conns))
+	connMap := make(map[string]state.SSHConnection, len(conns))
+	for _, conn := range conns {
+		ips = append(ips, conn.IPAddress)
+		connMap[conn.IPAddress] = conn
+	}
+	dialog.ShowFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if err != nil {
+			showError(w, err)
+			return
+		}
+		if reader == nil {
+			return
+		}
+		reader.Close()
+		localPath := reader.URI().Path()
+		showUploadDialog(w, n.state.Executor(), ips, connMap, ips, localPath, path.Join("/tmp", filepath.Base(localPath)), nil)
+	}, w)
+}
+
+// showUploadDialog asks for the remote path and the target nodes of
+// localPath, checked are the nodes selected at first.
+func showUploadDialog(w fyne.Window, exec utils.Executor, nodes []string, conns map[string]state.SSHConnection,
+	checked []string, localPath, remotePath string, onDone func()) {
+
+	remoteEntry := widget.NewEntry()
+	remoteEntry.SetText(remotePath)
+	targets := widget.NewCheckGroup(nodes, nil)
+	targets.SetSelected(checked)
+	targetScroll := container.NewVScroll(targets)
+	targetScroll.SetMinSize(fyne.NewSize(300, 150))
+	items := []*widget.FormItem{
+		widget.NewFormItem("Local file", widget.NewLabel(localPath)),
+		widget.NewFormItem("Remote path", remoteEntry),
+		widget.NewFormItem("Nodes", targetScroll),
+	}
+	form := dialog.NewForm("Upload", "Upload", "Cancel", items, func(confirm bool) {
+		if !confirm {
+			return
+		}
+		var targetConns []state.SSHConnection
+		for _, ip := range targets.Selected {
+			targetConns = append(targetConns, conns[ip])
+		}
+		if len(targetConns) == 0 || remoteEntry.Text == "" {
+			return
+		}
+		uploadToNodes(w, exec, targetConns, localPath, remoteEntry.Text, onDone)
+	}, w)
+	form.Resize(fyne.NewSize(600, 400))
+	form.Show()
+}
+
+// uploadToNodes uploads localPath to the nodes and shows the checksum of each node
+func uploadToNodes(w fyne.Window, exec utils.Executor, conns []state.SSHConnection, localPath, remotePath string,
+	onDone func()) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	progress := showProgressBar(w, "Uploading "+filepath.Base(localPath)+", please wait...", len(conns), 300, cancel)
+	workers := config.Conf.Check.WorkerCount()
+	go func() {
+		defer cancel()
+		results, err := state.UploadFile(ctx, exec, conns, localPath, remotePath, workers, func(done, total int) {
+			fyne.Do(func() {
+				progress.SetDone(done)
+			})
+		})
+		fyne.Do(func() {
+			progress.Hide()
+			if len(results) > 0 {
+				showTransfers(w, "Upload", results)
+			}
+			if err != nil {
+				showError(w, err)
+			}
+			if onDone != nil {
+				onDone()
+			}
+		})
+	}()
+}
+
+// showTransfers shows the verified checksum or the error of each node
+func showTransfers(w fyne.Window, title string, results []state.TransferResult) {
+	report := widget.NewLabel(state.FormatTransfers(results))
+	report.TextStyle = fyne.TextStyle{Monospace: true}
+	report.Selectable = true
+	scroll := container.NewScroll(report)
+	scroll.SetMinSize(fyne.NewSize(700, 200))
+	dialog.ShowCustom(title, "Close", scroll, w)
+}
@@ -0,0 +1,159 @@
+package view
+
+import (
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	logger "github.com/luo2pei4/ltool/pkg/log"
+	"github.com/luo2pei4/ltool/view/state"
+)
+
+// targetAll the target option of the actions on all nodes
+const targetAll = "all nodes"
+
+// createChipsBar creates the filter chips of the groups and the tags
+func (n *NodesUI) createChipsBar(w fyne.Window) fyne.CanvasObject {
+	n.chipsBox = container.NewHBox()
+	n.refreshChips(w)
+	return container.NewHScroll(n.chipsBox)
+}
+
+// refreshChips recreates the chips of the saved groups and tags
+func (n *NodesUI) refreshChips(w fyne.Window) {
+	groups, tags, err := state.LoadFilters()
+	if err != nil {
+		logger.Errorf("load groups and tags failed, %v", err)
+		return
+	}
+	n.groups = groups
+	chip := func(name string, active *string) *widget.Button {
+		btn := widget.NewButton(name, func() {
+			if *active == name {
+				*active = ""
+			} else {
+				*active = name
+			}
+			n.search(w)
+		})
+		if *active == name {
+			btn.Importance = widget.HighImportance
+		}
+		return btn
+	}
+	objects := []fyne.CanvasObject{widget.NewLabel("Groups:")}
+	for _, group := range groups {
+		objects = append(objects, chip(group, &n.groupFilter))
+	}
+	if len(tags) > 0 {
+		objects = append(objects, widget.NewLabel("Tags:"))
+	}
+	for _, tag := range tags {
+		objects = append(objects, chip(tag, &n.tagFilter))
+	}
+	n.chipsBox.Objects = objects
+	n.chipsBox.Refresh()
+	if n.targetSelect != nil {
+		n.targetSelect.SetOptions(append([]string{targetAll}, groups...))
+	}
+}
+
+// createTargetSelect creates the group the Check and Delete buttons are
+// aimed at, choosing a group checks its nodes
+func (n *NodesUI) createTargetSelect() *widget.Select {
+	n.targetSelect = widget.NewSelect(append([]string{targetAll}, n.groups...), func(target string) {
+		if target == targetAll {
+			n.state.SetTargetGroup("")
+			return
+		}
+		n.state.SetTargetGroup(target)
+		n.state.SelectGroup(target)
+		n.records.Refresh()
+		n.updateStatsMsg()
+	})
+	n.targetSelect.SetSelected(targetAll)
+	return n.targetSelect
+}
+
+// deleteTargetGroup deletes all saved nodes of the target group
+func (n *NodesUI) deleteTargetGroup(w fyne.Window) {
+	group := n.state.TargetGroup()
+	dialog.ShowConfirm(
+		"Delete confirm",
+		fmt.Sprintf("Are you sure you want to delete all nodes of the group %s?", group),
+		func(confirm bool) {
+			if !confirm {
+				return
+			}
+			cnt, err := n.state.DeleteGroup(group)
+			if err != nil {
+				showError(w, err)
+				return
+			}
+			logger.Infof("deleted %d nodes of the group %s", cnt, group)
+			if err := n.state.LoadAllRecords(); err != nil {
+				dialog.ShowCustom("Error", "Close", widget.NewLabel(fmt.Sprintf("reload nodes failed, %v", err)), w)
+			}
+			n.targetSelect.SetSelected(targetAll)
+			n.refreshChips(w)
+			n.records.Refresh()
+			n.updateStatsMsg()
+		}, w,
+	)
+}
+
+// showAssignDialog sets the group and the tags of the checked nodes
+func (n *NodesUI) showAssignDialog(w fyne.Window) {
+	groupEntry := widget.NewSelectEntry(n.groups)
+	groupEntry.SetPlaceHolder("unchanged")
+	tagEntry := widget.NewEntry()
+	tagEntry.SetPlaceHolder("rack1,lustre")
+	untagEntry := widget.NewEntry()
+	untagEntry.SetPlaceHolder("rack2")
+	clearGroup := widget.NewCheck("Clear the group", func(checked bool) {
+		if checked {
+			groupEntry.Disable()
+		} else {
+			groupEntry.Enable()
+		}
+	})
+	msgLabel := widget.NewLabel("")
+	form := widget.NewForm(
+		widget.NewFormItem("Group", groupEntry),
+		widget.NewFormItem("", clearGroup),
+		widget.NewFormItem("Add tags", tagEntry),
+		widget.NewFormItem("Remove tags", untagEntry),
+	)
+	applyBtn := widget.NewButton("Apply to checked nodes", func() {
+		if n.state.GetCheckedRecordsCount() == 0 {
+			msgLabel.SetText("no node is checked")
+			return
+		}
+		changed := 0
+		switch group := strings.TrimSpace(groupEntry.Text); {
+		case clearGroup.Checked:
+			changed = n.state.SetCheckedGroup("")
+		case group != "":
+			changed = n.state.SetCheckedGroup(group)
+		}
+		tagged := n.state.TagChecked(state.SplitTags(tagEntry.Text), state.SplitTags(untagEntry.Text))
+		msgLabel.SetText(fmt.Sprintf("%d groups and %d tags changed, save them with the Save button", changed, tagged))
+		n.records.Refresh()
+		n.updateStatsMsg()
+	})
+	d := dialog.NewCustom("Groups and tags", "Close", container.NewVBox(form, applyBtn, msgLabel), w)
+	d.Resize(fyne.NewSize(420, 320))
+	d.Show()
+}
+
+// groupText shows the group and the tags of the node
+func groupText(node *state.Node) string {
+	text := node.Group
+	if len(node.Tags) > 0 {
+		text = strings.TrimSpace(text + " #" + strings.Join(node.Tags, " #"))
+	}
+	return text
+}
@@ -0,0 +1,225 @@
+package view
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/luo2pei4/ltool/pkg/inventory"
+	"github.com/luo2pei4/ltool/view/state"
+)
+
+// formatAuto detects the format of the imported file
+const formatAuto = "auto"
+
+// importColumns the columns of the import preview
+var importColumns = []string{"Line", "Host", "IP address", "User", "Auth", "Hostname", "Group", "Status"}
+
+// showImportDialog previews the nodes of a csv, hosts, ClusterShell or
+// Ansible file and adds the valid ones. The user and credentials of the
+// input row are used if the file has none.
+func (n *NodesUI) showImportDialog(w fyne.Window) {
+
+	var (
+		name string
+		data []byte
+		rows []state.ImportRow
+	)
+	fileLabel := widget.NewLabel("No file chosen")
+	formatSelect := widget.NewSelect(append([]string{formatAuto}, inventory.ImportFormats...), nil)
+	formatSelect.SetSelected(formatAuto)
+	summaryLabel := widget.NewLabel("")
+
+	table := widget.NewTable(
+		func() (int, int) {
+			return len(rows) + 1, len(importColumns)
+		},
+		func() fyne.CanvasObject {
+			return widget.NewLabel("")
+		},
+		func(id widget.TableCellID, obj fyne.CanvasObject) {
+			label := obj.(*widget.Label)
+			label.Importance = widget.MediumImportance
+			label.TextStyle = fyne.TextStyle{}
+			if id.Row == 0 {
+				label.TextStyle = fyne.TextStyle{Bold: true}
+				label.SetText(importColumns[id.Col])
+				return
+			}
+			label.SetText(importCell(&rows[id.Row-1], id.Col))
+			if id.Col == len(importColumns)-1 {
+				switch row := &rows[id.Row-1]; {
+				case row.Duplicate:
+					label.Importance = widget.WarningImportance
+				case row.Problem != "":
+					label.Importance = widget.DangerImportance
+				default:
+					label.Importance = widget.SuccessImportance
+				}
+				label.Refresh()
+			}
+		},
+	)
+	for i, width := range []float32{50, 140, 130, 80, 80, 140, 100, 260} {
+		table.SetColumnWidth(i, width)
+	}
+
+	var importBtn *widget.Button
+	preview := func() {
+		if data == nil {
+			return
+		}
+		format := formatSelect.Selected
+		if format == formatAuto {
+			format = inventory.Detect(name, data)
+		}
+		entries, err := inventory.Parse(format, data)
+		if err != nil {
+			rows = nil
+			table.Refresh()
+			importBtn.Disable()
+			showError(w, fmt.Errorf("parse %s as %s failed, %v", name, format, err))
+			return
+		}
+		user, auth := n.userEntry.Text, n.inputAuth()
+		ctx, cancel := context.WithCancel(context.Background())
+		popup := showProgressing(w, "Resolving the host names, please wait...", 400, cancel)
+		go func() {
+			defer cancel()
+			previewRows, err := n.state.PreviewImport(ctx, entries, user, auth, net.DefaultResolver.LookupHost)
+			fyne.Do(func() {
+				popup.Hide()
+				if err != nil {
+					showError(w, err)
+					return
+				}
+				rows = previewRows
+				fileLabel.SetText(name + " (" + format + ")")
+				summaryLabel.SetText(importSummary(rows))
+				table.Refresh()
+				table.ScrollToTop()
+				importBtn.Enable()
+			})
+		}()
+	}
+	formatSelect.OnChanged = func(string) {
+		preview()
+	}
+	chooseBtn := widget.NewButtonWithIcon("Choose file", theme.FolderOpenIcon(), func() {
+		dialog.ShowFileOpen(func(reader fyne.URIReadCloser, err error) {
+			if err != nil {
+				showError(w, err)
+				return
+			}
+			if reader == nil {
+				return
+			}
+			defer reader.Close()
+			content, err := io.ReadAll(reader)
+			if err != nil {
+				showError(w, fmt.Errorf("read %s failed, %v", reader.URI().Path(), err))
+				return
+			}
+			name, data = reader.URI().Name(), content
+			preview()
+		}, w)
+	})
+
+	var d dialog.Dialog
+	importBtn = widget.NewButtonWithIcon("Import", theme.ContentAddIcon(), func() {
+		added, err := n.state.ImportNodes(rows)
+		if err != nil {
+			n.records.Refresh()
+			n.updateStatsMsg()
+			showError(w, err)
+			return
+		}
+		if added == 0 {
+			dialog.ShowInformation("Import", "No nodes to import", w)
+			return
+		}
+		d.Hide()
+		n.records.Refresh()
+		n.updateStatsMsg()
+		if err := n.state.SaveRecords(); err != nil {
+			showError(w, fmt.Errorf("save the imported nodes failed, %v", err))
+			return
+		}
+		if err := n.state.LoadAllRecords(); err != nil {
+			showError(w, fmt.Errorf("reload nodes failed, %v", err))
+			return
+		}
+		n.records.Refresh()
+		n.updateStatsMsg()
+		dialog.ShowInformation("Import", fmt.Sprintf("Imported %d nodes", added), w)
+	})
+	importBtn.Importance = widget.HighImportance
+	importBtn.Disable()
+
+	content := container.NewBorder(
+		container.NewVBox(
+			container.NewBorder(nil, nil, chooseBtn, formatSelect, fileLabel),
+			widget.NewLabel("The user and credentials of the input row apply to the nodes without them"),
+		),
+		container.NewBorder(nil, nil, nil, importBtn, summaryLabel),
+		nil,
+		nil,
+		table,
+	)
+	d = dialog.NewCustom("Import nodes", "Close", content, w)
+	d.Resize(fyne.NewSize(1050, 600))
+	d.Show()
+}
+
+func importCell(row *state.ImportRow, col int) string {
+	switch col {
+	case 0:
+		if row.Line == 0 {
+			return ""
+		}
+		return strconv.Itoa(row.Line)
+	case 1:
+		return row.Host
+	case 2:
+		return row.IP
+	case 3:
+		return row.User
+	case 4:
+		if row.Problem != "" {
+			return ""
+		}
+		return row.SSHAuth.AuthType
+	case 5:
+		return row.Hostname
+	case 6:
+		return row.Group
+	default:
+		if row.Problem != "" {
+			return row.Problem
+		}
+		return "new"
+	}
+}
+
+// importSummary counts the new, duplicate and invalid rows
+func importSummary(rows []state.ImportRow) string {
+	var added, duplicates, invalid int
+	for _, row := range rows {
+		switch {
+		case row.Duplicate:
+			duplicates++
+		case row.Problem != "":
+			invalid++
+		default:
+			added++
+		}
+	}
+	return fmt.Sprintf("New: %d, Duplicate: %d, Invalid: %d", added, duplicates, invalid)
+}
@@ -0,0 +1,159 @@
+package view
+
+import (
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/luo2pei4/ltool/pkg/dblayer/repo"
+	"github.com/luo2pei4/ltool/pkg/utils"
+)
+
+// showJumpHostsDialog edits the stored jump hosts and sets the jump host
+// chain of the checked nodes
+func (n *NodesUI) showJumpHostsDialog(w fyne.Window) {
+
+	if err := n.jumpHosts.LoadAllRecords(); err != nil {
+		showError(w, err)
+		return
+	}
+
+	nameEntry := widget.NewEntry()
+	nameEntry.SetPlaceHolder("bastion")
+	addressEntry := widget.NewEntry()
+	addressEntry.SetPlaceHolder("host[:port]")
+	userEntry := widget.NewEntry()
+	userEntry.SetPlaceHolder("user name")
+	entries := newAuthEntries(utils.SSHAuth{})
+	formArea := container.NewVBox()
+	resetForm := func(rec repo.JumpHost) {
+		nameEntry.SetText(rec.Name)
+		addressEntry.SetText(rec.Address)
+		userEntry.SetText(rec.UserName)
+		entries = newAuthEntries(utils.SSHAuth{
+			AuthType:   rec.AuthType,
+			Password:   rec.Password,
+			KeyFile:    rec.KeyFile,
+			Passphrase: rec.Passphrase,
+			CertFile:   rec.CertFile,
+		})
+		items := []*widget.FormItem{
+			widget.NewFormItem("Name", nameEntry),
+			widget.NewFormItem("Address", addressEntry),
+			widget.NewFormItem("User", userEntry),
+		}
+		formArea.Objects = []fyne.CanvasObject{widget.NewForm(append(items, entries.formItems()...)...)}
+		formArea.Refresh()
+	}
+	resetForm(repo.JumpHost{})
+
+	chainEntry := widget.NewSelectEntry(n.jumpHosts.Names())
+	chainEntry.SetPlaceHolder("bastion1,bastion2")
+
+	selected := ""
+	list := widget.NewList(
+		func() int {
+			return len(n.jumpHosts.Records)
+		},
+		func() fyne.CanvasObject {
+			return widget.NewLabel("")
+		},
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			rec := n.jumpHosts.Records[id]
+			obj.(*widget.Label).SetText(fmt.Sprintf("%s  %s@%s", rec.Name, rec.UserName, rec.Address))
+		},
+	)
+	list.OnSelected = func(id widget.ListItemID) {
+		rec := n.jumpHosts.Records[id]
+		selected = rec.Name
+		resetForm(rec)
+	}
+	refresh := func() {
+		selected = ""
+		list.UnselectAll()
+		list.Refresh()
+		chainEntry.SetOptions(n.jumpHosts.Names())
+	}
+
+	newBtn := widget.NewButton("New", func() {
+		list.UnselectAll()
+		selected = ""
+		resetForm(repo.JumpHost{})
+	})
+	saveBtn := widget.NewButton("Save", func() {
+		auth := entries.auth()
+		rec := repo.JumpHost{
+			Name:       nameEntry.Text,
+			Address:    addressEntry.Text,
+			UserName:   userEntry.Text,
+			AuthType:   auth.AuthType,
+			Password:   auth.Password,
+			KeyFile:    auth.KeyFile,
+			Passphrase: auth.Passphrase,
+			CertFile:   auth.CertFile,
+		}
+		if err := n.jumpHosts.Save(rec); err != nil {
+			showError(w, err)
+			return
+		}
+		refresh()
+		resetForm(repo.JumpHost{})
+	})
+	deleteBtn := widget.NewButton("Delete", func() {
+		if selected == "" {
+			return
+		}
+		name := selected
+		dialog.ShowConfirm(
+			"Delete confirm",
+			fmt.Sprintf("Are you sure you want to delete the jump host %s?", name),
+			func(confirm bool) {
+				if !confirm {
+					return
+				}
+				if err := n.jumpHosts.Delete(name); err != nil {
+					showError(w, err)
+					return
+				}
+				refresh()
+				resetForm(repo.JumpHost{})
+			}, w,
+		)
+	})
+
+	applyLabel := widget.NewLabel("")
+	applyBtn := widget.NewButton("Apply to checked nodes", func() {
+		if n.state.GetCheckedRecordsCount() == 0 {
+			applyLabel.SetText("no node is checked")
+			return
+		}
+		if err := n.jumpHosts.ValidateProxyJump(chainEntry.Text); err != nil {
+			applyLabel.SetText(err.Error())
+			return
+		}
+		cnt := n.state.SetCheckedProxyJump(chainEntry.Text)
+		applyLabel.SetText(fmt.Sprintf("%d nodes changed, save them on the Node page", cnt))
+		n.records.Refresh()
+		n.updateStatsMsg()
+	})
+
+	content := container.NewBorder(
+		nil,
+		container.NewVBox(
+			widget.NewSeparator(),
+			widget.NewForm(widget.NewFormItem("Chain", chainEntry)),
+			container.NewHBox(applyBtn, applyLabel),
+		),
+		nil,
+		nil,
+		container.NewHSplit(
+			list,
+			container.NewBorder(nil, container.NewHBox(newBtn, saveBtn, deleteBtn), nil, nil, formArea),
+		),
+	)
+	d := dialog.NewCustom("Jump Hosts", "Close", content, w)
+	d.Resize(fyne.NewSize(760, 520))
+	d.Show()
+}
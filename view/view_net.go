@@ -1,12 +1,14 @@
 package view
 
 import (
-	"image/color"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
 	"strconv"
 	"strings"
 
 	"fyne.io/fyne/v2"
-	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/theme"
@@ -19,7 +21,9 @@ import (
 type NetMainUI struct {
 	state     *state.NetState
 	nodeList  *widget.SelectEntry // management ip address list
+	groupList *widget.Select      // narrows nodeList to a group
 	searchBtn *widget.Button
+	termBtn   *widget.Button
 	header    *fyne.Container
 	records   *widget.List
 }
@@ -37,6 +41,17 @@ func (v *NetMainUI) CreateView(w fyne.Window) fyne.CanvasObject {
 	} else {
 		logger.Errorf("load node list failed, %v\n", err)
 	}
+	v.groupList = widget.NewSelect(append([]string{targetAll}, v.state.Groups()...), func(group string) {
+		if group == targetAll {
+			group = ""
+		}
+		nodes := v.state.NodesOf(group)
+		v.nodeList.SetOptions(nodes)
+		if !slices.Contains(nodes, v.nodeList.Text) {
+			v.nodeList.SetText("")
+		}
+	})
+	v.groupList.SetSelected(targetAll)
 	v.header = container.New(
 		&layout.NetRecordsGrid{},
 		widget.NewLabel("Interface"),
@@ -59,6 +74,7 @@ func (v *NetMainUI) CreateView(w fyne.Window) fyne.CanvasObject {
 
 			ipLabel := widget.NewLabel("")
 			ipLabel.Selectable = true
+			ipLabel.Truncation = fyne.TextTruncateEllipsis // long ipv6 addresses
 
 			macLabel := widget.NewLabel("")
 			macLabel.Selectable = true
@@ -98,7 +114,7 @@ func (v *NetMainUI) CreateView(w fyne.Window) fyne.CanvasObject {
 			lnetLabel := recordArea.Objects[5].(*widget.Label)
 
 			adapterLabel.SetText(detail.Name)
-			ipLabel.SetText(detail.IPv4)
+			ipLabel.SetText(netAddresses(detail))
 			macLabel.SetText(detail.MAC)
 			linkTypeLabel.SetText(detail.LinkType)
 			stateLabel.SetText(detail.State)
@@ -111,30 +127,39 @@ func (v *NetMainUI) CreateView(w fyne.Window) fyne.CanvasObject {
 		},
 	)
 	v.searchBtn = widget.NewButtonWithIcon("", theme.SearchIcon(), func() {
-		popup := showProgressing(w, "Searching, please wait...", 400)
+		ctx, cancel := context.WithCancel(context.Background())
+		popup := showProgressing(w, "Searching, please wait...", 400, cancel)
 		go func() {
+			defer cancel()
 			conn := v.state.SSHCon[v.nodeList.Text]
-			err := v.state.LoadInterfaceDetail(conn.IPAddress, conn.User, conn.Password)
+			err := v.state.LoadInterfaceDetail(ctx, conn)
 			fyne.Do(func() {
 				if popup != nil {
 					popup.Hide()
 				}
 				if err != nil {
-					// draw error dialog
-					errLabel := widget.NewLabel(err.Error())
-					errLabel.Wrapping = fyne.TextWrapWord
-					bg := canvas.NewRectangle(color.NRGBA{0, 0, 0, 0})
-					bg.SetMinSize(fyne.NewSize(400, 160))
-					content := container.NewStack(bg, container.NewVBox(errLabel))
-					dialog.ShowCustom("Error", "Close", content, w)
-					return
+					showError(w, err)
+					if !errors.Is(err, context.Canceled) {
+						return
+					}
 				}
 				v.header.Show()
 				v.records.Refresh()
 			})
 		}()
 	})
-	inputArea := container.NewGridWithColumns(2, v.nodeList, v.searchBtn)
+	v.termBtn = widget.NewButtonWithIcon("Terminal", theme.ComputerIcon(), func() {
+		conn, ok := v.state.SSHCon[v.nodeList.Text]
+		if !ok {
+			dialog.ShowInformation("Terminal", "Select a management ip address", w)
+			return
+		}
+		showTerminal(v.state.Executor(), conn)
+	})
+	inputArea := container.NewGridWithColumns(2,
+		container.NewBorder(nil, nil, v.groupList, nil, v.nodeList),
+		container.NewGridWithColumns(2, v.searchBtn, v.termBtn),
+	)
 	content := container.NewBorder(
 		container.NewVBox(
 			inputArea,
@@ -160,13 +185,22 @@ func (v *NetMainUI) showDetailDialog(w fyne.Window, id int) {
 
 	ipEntry := &widget.Entry{Text: detail.IPv4, MultiLine: false}
 	maskSelect := widget.NewSelectEntry(state.IPv4MaskCIDRList)
-	if detail.IPv4 == managementIP {
-		items = append(items, widget.NewFormItem("IPv4", widget.NewLabel(managementIP+"/"+strconv.Itoa(detail.Mask))))
+	if detail.HasAddress(managementIP) {
+		ipv4 := ""
+		if detail.IPv4 != "" {
+			ipv4 = detail.IPv4 + "/" + strconv.Itoa(detail.Mask)
+		}
+		items = append(items, widget.NewFormItem("IPv4", widget.NewLabel(ipv4)))
 	} else {
 		maskSelect.Text = strconv.Itoa(detail.Mask)
 		ipArea := container.New(&layout.IPAddressAreaGrid{}, ipEntry, maskSelect)
 		items = append(items, widget.NewFormItem("IPv4", ipArea))
 	}
+	if len(detail.IPv6) > 0 {
+		ipv6Label := widget.NewLabel(strings.Join(detail.IPv6, "\n"))
+		ipv6Label.Selectable = true
+		items = append(items, widget.NewFormItem("IPv6", ipv6Label))
+	}
 	gwEntry := &widget.Entry{Text: detail.Gateway, MultiLine: false}
 	items = append(items, widget.NewFormItem("Gateway", gwEntry))
 	items = append(items, widget.NewFormItem("Mac", widget.NewLabel(detail.MAC)))
@@ -187,32 +221,30 @@ func (v *NetMainUI) showDetailDialog(w fyne.Window, id int) {
 		items,
 		func(ok bool) {
 			if ok {
-				isManagementInterface := detail.IPv4 == managementIP
+				isManagementInterface := detail.HasAddress(managementIP)
 				if isManagementInterface {
 					return
 				}
 				if strings.TrimSpace(ipEntry.Text) == "" && detail.IPv4 != "" {
 					// delete ipv4 address
-					popup := showProgressing(w, "Saving, please wait...", 400)
+					ctx, cancel := context.WithCancel(context.Background())
+					popup := showProgressing(w, "Saving, please wait...", 400, cancel)
 					go func() {
+						defer cancel()
 						conn := v.state.SSHCon[managementIP]
-						err := detail.DeleteIPv4(conn.IPAddress, conn.User, conn.Password)
+						err := detail.DeleteIPv4(ctx, v.state.Executor(), conn)
 						if err == nil {
-							err = v.state.LoadInterfaceDetail(conn.IPAddress, conn.User, conn.Password)
+							err = v.state.LoadInterfaceDetail(ctx, conn)
 						}
 						fyne.Do(func() {
 							if popup != nil {
 								popup.Hide()
 							}
 							if err != nil {
-								// draw error dialog
-								errLabel := widget.NewLabel(err.Error())
-								errLabel.Wrapping = fyne.TextWrapWord
-								bg := canvas.NewRectangle(color.NRGBA{0, 0, 0, 0})
-								bg.SetMinSize(fyne.NewSize(400, 160))
-								content := container.NewStack(bg, container.NewVBox(errLabel))
-								dialog.ShowCustom("Error", "Close", content, w)
-								return
+								showError(w, err)
+								if !errors.Is(err, context.Canceled) {
+									return
+								}
 							}
 							v.header.Show()
 							v.records.Refresh()
@@ -225,26 +257,24 @@ func (v *NetMainUI) showDetailDialog(w fyne.Window, id int) {
 					detail.Gateway = gwEntry.Text
 				}
 
-				popup := showProgressing(w, "Saving, please wait...", 400)
+				ctx, cancel := context.WithCancel(context.Background())
+				popup := showProgressing(w, "Saving, please wait...", 400, cancel)
 				go func() {
+					defer cancel()
 					conn := v.state.SSHCon[managementIP]
-					err := detail.SetIPv4(conn.IPAddress, conn.User, conn.Password)
+					err := detail.SetIPv4(ctx, v.state.Executor(), conn)
 					if err == nil {
-						err = v.state.LoadInterfaceDetail(conn.IPAddress, conn.User, conn.Password)
+						err = v.state.LoadInterfaceDetail(ctx, conn)
 					}
 					fyne.Do(func() {
 						if popup != nil {
 							popup.Hide()
 						}
 						if err != nil {
-							// draw error dialog
-							errLabel := widget.NewLabel(err.Error())
-							errLabel.Wrapping = fyne.TextWrapWord
-							bg := canvas.NewRectangle(color.NRGBA{0, 0, 0, 0})
-							bg.SetMinSize(fyne.NewSize(400, 160))
-							content := container.NewStack(bg, container.NewVBox(errLabel))
-							dialog.ShowCustom("Error", "Close", content, w)
-							return
+							showError(w, err)
+							if !errors.Is(err, context.Canceled) {
+								return
+							}
 						}
 						v.header.Show()
 						v.records.Refresh()
@@ -256,3 +286,18 @@ func (v *NetMainUI) showDetailDialog(w fyne.Window, id int) {
 	f.Resize(fyne.NewSize(350, 500))
 	f.Show()
 }
+
+// netAddresses is the ipv4 address of the interface, or its first ipv6
+// address and the number of the others
+func netAddresses(detail *state.NetDetail) string {
+	switch {
+	case detail.IPv4 != "":
+		return detail.IPv4
+	case len(detail.IPv6) == 0:
+		return ""
+	case len(detail.IPv6) == 1:
+		return detail.IPv6[0]
+	default:
+		return fmt.Sprintf("%s (+%d)", detail.IPv6[0], len(detail.IPv6)-1)
+	}
+}
@@ -1,54 +1,96 @@
 package view
 
 import (
+	"context"
 	"fmt"
 	"image/color"
+	"net"
+	"strconv"
+	"strings"
+	"time"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 	logger "github.com/luo2pei4/ltool/pkg/log"
 	"github.com/luo2pei4/ltool/pkg/utils"
 	"github.com/luo2pei4/ltool/view/layout"
 	"github.com/luo2pei4/ltool/view/state"
+	"golang.org/x/crypto/ssh"
 )
 
 type NodesUI struct {
 	state          *state.NodesState
+	jumpHosts      *state.JumpHostsState
 	records        *widget.List
 	ipEntry        *widget.Entry
+	expandLabel    *widget.Label // the number of the nodes the ip entry expands to
 	userEntry      *widget.Entry
-	passEntry      *widget.Entry
+	authSelect     *widget.Select
+	passEntry      *widget.Entry // password or private key file
+	extraEntry     *widget.Entry // key passphrase
 	addBtn         *widget.Button
 	selectAllBtn   *widget.Button
 	unselectAllBtn *widget.Button
 	deleteBtn      *widget.Button
 	statusBtn      *widget.Button
+	runBtn         *widget.Button
+	uploadBtn      *widget.Button
+	compareBtn     *widget.Button
+	importBtn      *widget.Button
+	exportBtn      *widget.Button
+	jumpBtn        *widget.Button
 	saveBtn        *widget.Button
 	statsLabel     *widget.Label
+	revealCheck    *widget.Check // show the passwords of the records
+	searchEntry    *widget.Entry
+	sortSelect     *widget.Select
+	descCheck      *widget.Check
+	prevBtn        *widget.Button
+	nextBtn        *widget.Button
+	pageLabel      *widget.Label
+	chipsBox       *fyne.Container // the filter chips of the groups and tags
+	groups         []string        // the saved groups and the roles
+	groupFilter    string          // the active group chip
+	tagFilter      string          // the active tag chip
+	targetSelect   *widget.Select  // the group Check and Delete are aimed at
+	assignBtn      *widget.Button
+	copyBtn        *widget.Button
+	defaultAuth    utils.SSHAuth // the default credentials of the cluster
 }
 
 func NewNodesUI() View {
 	return &NodesUI{
-		state: &state.NodesState{},
+		state:     &state.NodesState{},
+		jumpHosts: &state.JumpHostsState{},
 	}
 }
 
 func (n *NodesUI) CreateView(w fyne.Window) fyne.CanvasObject {
 
 	n.ipEntry = widget.NewEntry()
-	n.ipEntry.SetPlaceHolder("ip adress")
+	n.ipEntry.SetPlaceHolder("ip, range, cidr or oss[01-16]")
+	n.expandLabel = widget.NewLabel("")
+	n.expandLabel.Hide()
+	n.ipEntry.OnChanged = func(spec string) {
+		n.updateExpandMsg(spec)
+	}
 	n.userEntry = widget.NewEntry()
 	n.userEntry.SetPlaceHolder("user name")
 	n.passEntry = widget.NewPasswordEntry()
-	n.passEntry.SetPlaceHolder("user password")
-	n.passEntry.Password = false
+	n.extraEntry = widget.NewPasswordEntry()
+	n.authSelect = widget.NewSelect(utils.AuthTypes, func(authType string) {
+		n.updateAuthEntries(authType)
+	})
+	n.authSelect.SetSelected(utils.AuthPassword)
+	n.setDefaultAuth()
 	n.addBtn = widget.NewButton("+", func() {
 		ip := n.ipEntry.Text
 		user := n.userEntry.Text
-		pass := n.passEntry.Text
+		auth := n.inputAuth()
 		switch {
 		case ip == "":
 			w.Canvas().Focus(n.ipEntry)
@@ -56,25 +98,44 @@ func (n *NodesUI) CreateView(w fyne.Window) fyne.CanvasObject {
 		case user == "":
 			w.Canvas().Focus(n.userEntry)
 			return
-		case pass == "":
+		default:
+		}
+		if err := utils.ValidateAuth(&auth); err != nil {
 			w.Canvas().Focus(n.passEntry)
 			return
-		default:
 		}
-		if err := utils.ValidateIPv4(ip); err != nil {
+		if _, err := state.CountAddresses(ip); err != nil {
 			dialog.ShowCustom("Warning", "Close", widget.NewLabel(err.Error()), w)
 			// set focus on ip entry
 			w.Canvas().Focus(n.ipEntry)
 			return
 		}
-		// add node
-		n.state.AddNode(ip, user, pass)
-		// refresh records list
-		n.records.Refresh()
-		// set focus on ip entry
-		w.Canvas().Focus(n.ipEntry)
+		ctx, cancel := context.WithCancel(context.Background())
+		popup := showProgressing(w, "Resolving the host names, please wait...", 400, cancel)
+		go func() {
+			defer cancel()
+			addrs, err := state.ExpandAddresses(ctx, ip, net.DefaultResolver.LookupHost)
+			fyne.Do(func() {
+				popup.Hide()
+				if err != nil {
+					dialog.ShowCustom("Warning", "Close", widget.NewLabel(err.Error()), w)
+					w.Canvas().Focus(n.ipEntry)
+					return
+				}
+				if len(addrs) <= state.LargeExpansion {
+					n.addNodes(w, addrs, user, auth)
+					return
+				}
+				msg := fmt.Sprintf("Add %d nodes, from %s to %s?", len(addrs), addrs[0].IP, addrs[len(addrs)-1].IP)
+				dialog.ShowConfirm("Add confirm", msg, func(confirm bool) {
+					if confirm {
+						n.addNodes(w, addrs, user, auth)
+					}
+				}, w)
+			})
+		}()
 	})
-	inputArea := container.NewGridWithColumns(4, n.ipEntry, n.userEntry, n.passEntry, n.addBtn)
+	inputArea := container.NewGridWithColumns(6, n.ipEntry, n.userEntry, n.authSelect, n.passEntry, n.extraEntry, n.addBtn)
 
 	n.selectAllBtn = widget.NewButton("Select All", func() {
 		n.state.SelectAllRecords()
@@ -87,6 +148,10 @@ func (n *NodesUI) CreateView(w fyne.Window) fyne.CanvasObject {
 		n.updateStatsMsg()
 	})
 	n.deleteBtn = widget.NewButton("Delete", func() {
+		if n.state.TargetGroup() != "" {
+			n.deleteTargetGroup(w)
+			return
+		}
 		if cnt := n.state.GetCheckedRecordsCount(); cnt == 0 {
 			return
 		}
@@ -106,18 +171,37 @@ func (n *NodesUI) CreateView(w fyne.Window) fyne.CanvasObject {
 					dialog.ShowCustom("Error", "Close", widget.NewLabel(fmt.Sprintf("reload nodes failed, %v", err)), w)
 					return
 				}
+				n.refreshChips(w)
 				n.updateStatsMsg()
 				n.records.Refresh()
 			}, w,
 		)
 	})
 	n.statusBtn = widget.NewButton("Check", func() {
-		popup := showProgressing(w, "Checking, please wait...", 400)
+		total := len(n.state.Records)
+		if total == 0 {
+			return
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		progress := showProgressBar(w, "Checking, please wait...", total, 400, cancel)
 		go func() {
-			n.state.CheckNodesStatus()
+			defer cancel()
+			err := n.state.CheckNodesStatus(ctx, func(p state.CheckProgress) {
+				fyne.Do(func() {
+					progress.SetDone(p.Done)
+					if p.ID >= 0 {
+						n.records.RefreshItem(p.ID)
+					}
+					n.updateStatsMsg()
+				})
+			})
 			fyne.Do(func() {
-				if popup != nil {
-					popup.Hide()
+				progress.Hide()
+				if err != nil {
+					showError(w, err)
+				}
+				if err := n.state.SaveStatus(); err != nil {
+					logger.Errorf("%v", err)
 				}
 				n.records.Refresh()
 			})
@@ -132,15 +216,46 @@ func (n *NodesUI) CreateView(w fyne.Window) fyne.CanvasObject {
 			dialog.ShowCustom("Error", "Close", widget.NewLabel(fmt.Sprintf("reload nodes failed, %v", err)), w)
 			return
 		}
+		n.refreshChips(w)
 		n.updateStatsMsg()
 		n.records.Refresh()
 	})
+	n.runBtn = widget.NewButton("Run", func() {
+		n.showRunDialog(w)
+	})
+	n.uploadBtn = widget.NewButton("Upload", func() {
+		n.showUploadDialog(w)
+	})
+	n.compareBtn = widget.NewButton("Compare", func() {
+		n.showCompareDialog(w)
+	})
+	n.importBtn = widget.NewButton("Import", func() {
+		n.showImportDialog(w)
+	})
+	n.exportBtn = widget.NewButton("Export", func() {
+		n.showExportDialog(w)
+	})
+	n.jumpBtn = widget.NewButton("Jump Hosts", func() {
+		n.showJumpHostsDialog(w)
+	})
+	n.assignBtn = widget.NewButton("Groups", func() {
+		n.showAssignDialog(w)
+	})
+	n.copyBtn = widget.NewButton("Copy To", func() {
+		n.showCopyDialog(w)
+	})
+	n.revealCheck = widget.NewCheck("Reveal", func(bool) {
+		n.records.Refresh()
+	})
 	n.statsLabel = widget.NewLabel("")
+	chipsBar := n.createChipsBar(w)
+	n.createTargetSelect()
 	btnBar := container.NewBorder(
 		nil,
 		nil,
-		container.NewHBox(n.selectAllBtn, n.unselectAllBtn, n.deleteBtn),
-		container.NewHBox(n.statusBtn, n.saveBtn),
+		container.NewHBox(n.selectAllBtn, n.unselectAllBtn, n.deleteBtn, n.importBtn, n.exportBtn, n.jumpBtn, n.assignBtn,
+			n.copyBtn, n.revealCheck),
+		container.NewHBox(n.targetSelect, n.runBtn, n.uploadBtn, n.compareBtn, n.statusBtn, n.saveBtn),
 		container.NewCenter(n.statsLabel),
 	)
 
@@ -153,28 +268,40 @@ func (n *NodesUI) CreateView(w fyne.Window) fyne.CanvasObject {
 			bg := canvas.NewRectangle(color.Transparent)
 			checkbox := widget.NewCheck("", nil)
 			ipLabel := widget.NewLabel("")
+			ipLabel.Truncation = fyne.TextTruncateEllipsis // long ipv6 addresses
 			userInput := widget.NewEntry()
+			authSelect := widget.NewSelect(utils.AuthTypes, nil)
 			passInput := widget.NewPasswordEntry()
-			passInput.Password = false
 			passInput.Resize(fyne.NewSize(150, 25))
 			statuscc := container.NewCenter(canvas.NewText("", color.Black))
 			hostnamecc := container.NewCenter(widget.NewLabel(""))
+			groupLabel := widget.NewLabel("")
+			groupLabel.Truncation = fyne.TextTruncateEllipsis
 			kernelcc := container.NewCenter(widget.NewLabel(""))
 			inputArea := container.New(
 				&layout.NodeRecordsGrid{},
 				container.NewStack(bg, ipLabel),
 				userInput,
+				authSelect,
 				passInput,
 				statuscc,
 				hostnamecc,
+				groupLabel,
 				kernelcc,
 			)
-			return container.NewBorder(nil, nil, checkbox, nil, inputArea)
+			authBtn := widget.NewButtonWithIcon("", theme.AccountIcon(), nil)
+			hostKeyBtn := widget.NewButtonWithIcon("", theme.VisibilityIcon(), nil)
+			terminalBtn := widget.NewButtonWithIcon("", theme.ComputerIcon(), nil)
+			return container.NewBorder(nil, nil, checkbox, container.NewHBox(authBtn, hostKeyBtn, terminalBtn), inputArea)
 		},
 		func(id widget.ListItemID, obj fyne.CanvasObject) {
 
 			row := obj.(*fyne.Container)
 			checkbox := row.Objects[1].(*widget.Check)
+			btnArea := row.Objects[2].(*fyne.Container)
+			authBtn := btnArea.Objects[0].(*widget.Button)
+			hostKeyBtn := btnArea.Objects[1].(*widget.Button)
+			terminalBtn := btnArea.Objects[2].(*widget.Button)
 			inputArea := row.Objects[0].(*fyne.Container)
 
 			stack := inputArea.Objects[0].(*fyne.Container)
@@ -182,14 +309,15 @@ func (n *NodesUI) CreateView(w fyne.Window) fyne.CanvasObject {
 			ipLabel := stack.Objects[1].(*widget.Label)
 
 			userInput := inputArea.Objects[1].(*widget.Entry)
-			passInput := inputArea.Objects[2].(*widget.Entry)
-			passInput.Password = false
+			authSelect := inputArea.Objects[2].(*widget.Select)
+			passInput := inputArea.Objects[3].(*widget.Entry)
 
-			statuscc := inputArea.Objects[3].(*fyne.Container)
+			statuscc := inputArea.Objects[4].(*fyne.Container)
 			statustext := statuscc.Objects[0].(*canvas.Text)
-			hostnamecc := inputArea.Objects[4].(*fyne.Container)
+			hostnamecc := inputArea.Objects[5].(*fyne.Container)
 			hostnameLabel := hostnamecc.Objects[0].(*widget.Label)
-			kernelcc := inputArea.Objects[5].(*fyne.Container)
+			groupLabel := inputArea.Objects[6].(*widget.Label)
+			kernelcc := inputArea.Objects[7].(*fyne.Container)
 			kernelLabel := kernelcc.Objects[0].(*widget.Label)
 
 			node := n.state.GetNodeRecord(id)
@@ -202,7 +330,11 @@ func (n *NodesUI) CreateView(w fyne.Window) fyne.CanvasObject {
 			checkbox.SetChecked(node.Checked)
 
 			// display ip address
-			ipLabel.SetText(node.IP)
+			if node.Options.Port > 0 {
+				ipLabel.SetText(node.Address())
+			} else {
+				ipLabel.SetText(node.IP)
+			}
 			// set background color
 			bg.FillColor = n.state.GetFillColor(id)
 
@@ -214,32 +346,90 @@ func (n *NodesUI) CreateView(w fyne.Window) fyne.CanvasObject {
 			}
 			userInput.SetText(node.User)
 
-			// change password
-			passInput.OnChanged = func(pass string) {
-				n.state.ChangePassword(id, pass)
-				bg.FillColor = n.state.GetFillColor(id)
+			// change authentication method
+			authSelect.OnChanged = nil
+			authSelect.SetSelected(node.AuthType)
+			authSelect.OnChanged = func(authType string) {
+				auth := n.state.GetNodeRecord(id).SSHAuth
+				auth.AuthType = authType
+				n.state.ChangeAuth(id, auth)
+				n.records.RefreshItem(id)
 				n.updateStatsMsg()
 			}
-			passInput.SetText(node.Password)
 
-			statustext.Text = node.Status
+			// change password or private key file
+			passInput.OnChanged = nil
+			switch node.AuthType {
+			case utils.AuthKey, utils.AuthCert:
+				passInput.Password = false
+				passInput.Enable()
+				passInput.SetPlaceHolder("private key file")
+				passInput.SetText(node.KeyFile)
+				passInput.OnChanged = func(keyFile string) {
+					n.state.ChangeKeyFile(id, keyFile)
+					bg.FillColor = n.state.GetFillColor(id)
+					n.updateStatsMsg()
+				}
+			case utils.AuthAgent:
+				passInput.SetPlaceHolder("ssh-agent")
+				passInput.SetText("")
+				passInput.Disable()
+			default:
+				// passwords are masked unless revealed
+				passInput.Password = !n.revealCheck.Checked
+				passInput.Enable()
+				passInput.SetPlaceHolder("user password")
+				passInput.SetText(node.Password)
+				passInput.OnChanged = func(pass string) {
+					n.state.ChangePassword(id, pass)
+					bg.FillColor = n.state.GetFillColor(id)
+					n.updateStatsMsg()
+				}
+			}
+
+			authBtn.OnTapped = func() {
+				n.showAuthDialog(w, id)
+			}
+			hostKeyBtn.OnTapped = func() {
+				n.showHostKeyDialog(w, id)
+			}
+			terminalBtn.OnTapped = func() {
+				conn, err := n.state.Connection(id)
+				if err != nil {
+					showError(w, err)
+					return
+				}
+				showTerminal(n.state.Executor(), conn)
+			}
+
+			statustext.Text = node.StatusText()
 			statustext.Color = n.state.GetStatusColor(node.Status)
 			hostnameLabel.SetText(node.Hostname)
+			groupLabel.SetText(groupText(&node))
 			kernelLabel.SetText(node.Kernel)
 		},
 	)
 
-	if err := n.state.LoadAllRecords(); err != nil {
+	searchBar := n.createSearchBar(w)
+	// the last search of the active cluster is restored
+	if text := state.LastSearch(); text != "" {
+		n.searchEntry.SetText(text)
+		n.search(w)
+	} else if err := n.state.LoadAllRecords(); err != nil {
 		logger.Errorf("loading all records failed, %v\n", err)
 	} else if len(n.state.Records) > 0 {
 		n.records.Refresh()
 		n.updateStatsMsg()
 	}
+	n.updatePageMsg()
 
 	content := container.NewBorder(
 		container.NewVBox(
 			inputArea,
+			n.expandLabel,
 			widget.NewSeparator(),
+			searchBar,
+			chipsBar,
 		),
 		btnBar,    // bottom
 		nil,       // left
@@ -251,4 +441,448 @@ func (n *NodesUI) CreateView(w fyne.Window) fyne.CanvasObject {
 
 func (n *NodesUI) updateStatsMsg() {
 	n.statsLabel.SetText(n.state.MakeStatsMsg())
+	n.updatePageMsg()
+}
+
+// addNodes adds the expanded addresses of the ip entry
+func (n *NodesUI) addNodes(w fyne.Window, addrs []state.NodeAddress, user string, auth utils.SSHAuth) {
+	added, err := n.state.AddNodes(addrs, user, auth)
+	if err != nil {
+		showError(w, err)
+		return
+	}
+	// refresh records list
+	n.records.Refresh()
+	n.updateStatsMsg()
+	if skipped := len(addrs) - added; skipped > 0 {
+		dialog.ShowInformation("Add nodes", fmt.Sprintf("Added %d nodes, %d nodes exist", added, skipped), w)
+		return
+	}
+	// set focus on ip entry
+	w.Canvas().Focus(n.ipEntry)
+}
+
+// updateExpandMsg shows the number of the nodes spec expands to
+func (n *NodesUI) updateExpandMsg(spec string) {
+	if strings.TrimSpace(spec) == "" {
+		n.expandLabel.Hide()
+		return
+	}
+	count, err := state.CountAddresses(spec)
+	switch {
+	case err != nil:
+		n.expandLabel.Importance = widget.DangerImportance
+		n.expandLabel.SetText(err.Error())
+	case count > state.LargeExpansion:
+		n.expandLabel.Importance = widget.WarningImportance
+		n.expandLabel.SetText(fmt.Sprintf("%d nodes", count))
+	default:
+		n.expandLabel.Importance = widget.MediumImportance
+		n.expandLabel.SetText(fmt.Sprintf("%d nodes", count))
+	}
+	n.expandLabel.Show()
+}
+
+// inputAuth collects the credentials of the add row
+func (n *NodesUI) inputAuth() utils.SSHAuth {
+	auth := utils.SSHAuth{AuthType: n.authSelect.Selected}
+	switch auth.AuthType {
+	case utils.AuthKey, utils.AuthCert:
+		auth.KeyFile = n.passEntry.Text
+		auth.Passphrase = n.extraEntry.Text
+		if auth.AuthType == utils.AuthCert && auth.KeyFile == n.defaultAuth.KeyFile {
+			// the add row has no certificate entry
+			auth.CertFile = n.defaultAuth.CertFile
+		}
+	case utils.AuthAgent:
+	default:
+		auth.Password = n.passEntry.Text
+	}
+	return auth
+}
+
+// setDefaultAuth fills the add row with the default credentials of the cluster
+func (n *NodesUI) setDefaultAuth() {
+	user, auth := clusters.Defaults()
+	if user == "" {
+		return
+	}
+	n.defaultAuth = auth
+	n.userEntry.SetText(user)
+	n.authSelect.SetSelected(auth.AuthType)
+	switch auth.AuthType {
+	case utils.AuthKey, utils.AuthCert:
+		n.passEntry.SetText(auth.KeyFile)
+		n.extraEntry.SetText(auth.Passphrase)
+	case utils.AuthAgent:
+	default:
+		n.passEntry.SetText(auth.Password)
+	}
+}
+
+// updateAuthEntries switches the add row entries by authentication method
+func (n *NodesUI) updateAuthEntries(authType string) {
+	switch authType {
+	case utils.AuthKey, utils.AuthCert:
+		n.passEntry.Password = false
+		n.passEntry.Enable()
+		n.passEntry.SetPlaceHolder("private key file")
+		n.extraEntry.Enable()
+		n.extraEntry.SetPlaceHolder("key passphrase")
+	case utils.AuthAgent:
+		n.passEntry.SetPlaceHolder("ssh-agent")
+		n.passEntry.Disable()
+		n.extraEntry.SetPlaceHolder("")
+		n.extraEntry.Disable()
+	default:
+		n.passEntry.Password = true
+		n.passEntry.Enable()
+		n.passEntry.SetPlaceHolder("user password")
+		n.extraEntry.SetPlaceHolder("")
+		n.extraEntry.Disable()
+	}
+}
+
+func (n *NodesUI) showAuthDialog(w fyne.Window, id int) {
+
+	node := n.state.GetNodeRecord(id)
+	if err := n.jumpHosts.LoadAllRecords(); err != nil {
+		showError(w, err)
+		return
+	}
+	entries := newAuthEntries(node.SSHAuth)
+	becomePassEntry := widget.NewPasswordEntry()
+	becomePassEntry.SetText(node.Become.Password)
+	becomePassEntry.SetPlaceHolder("empty for NOPASSWD sudo")
+	becomeSelect := widget.NewSelect(utils.BecomeMethods, func(method string) {
+		if method == utils.BecomeNone {
+			becomePassEntry.Disable()
+			return
+		}
+		becomePassEntry.Enable()
+	})
+	becomeSelect.SetSelected(node.Become.Method)
+	if becomeSelect.Selected == "" {
+		becomeSelect.SetSelected(utils.BecomeNone)
+	}
+	jumpEntry := widget.NewSelectEntry(n.jumpHosts.Names())
+	jumpEntry.SetText(node.ProxyJump)
+	jumpEntry.SetPlaceHolder("bastion1,bastion2")
+	options := newOptionsEntries(node.Options)
+
+	items := []*widget.FormItem{widget.NewFormItem("Node", widget.NewLabel(node.IP))}
+	items = append(items, entries.formItems()...)
+	items = append(items,
+		widget.NewFormItem("Become", becomeSelect),
+		widget.NewFormItem("Become password", becomePassEntry),
+		widget.NewFormItem("Jump hosts", jumpEntry),
+	)
+	items = append(items, options.formItems()...)
+	f := dialog.NewForm(
+		"Connection",
+		"OK", "Cancel",
+		items,
+		func(ok bool) {
+			if !ok {
+				return
+			}
+			auth := entries.auth()
+			if err := utils.ValidateAuth(&auth); err != nil {
+				dialog.ShowCustom("Warning", "Close", widget.NewLabel(err.Error()), w)
+				return
+			}
+			become := utils.Become{Method: becomeSelect.Selected}
+			if become.Method != utils.BecomeNone {
+				become.Password = becomePassEntry.Text
+			}
+			if err := utils.ValidateBecome(&become); err != nil {
+				dialog.ShowCustom("Warning", "Close", widget.NewLabel(err.Error()), w)
+				return
+			}
+			if err := n.jumpHosts.ValidateProxyJump(jumpEntry.Text); err != nil {
+				dialog.ShowCustom("Warning", "Close", widget.NewLabel(err.Error()), w)
+				return
+			}
+			opts, err := options.options()
+			if err != nil {
+				dialog.ShowCustom("Warning", "Close", widget.NewLabel(err.Error()), w)
+				return
+			}
+			n.state.ChangeAuth(id, auth)
+			n.state.ChangeBecome(id, become)
+			n.state.ChangeProxyJump(id, jumpEntry.Text)
+			n.state.ChangeOptions(id, opts)
+			n.records.RefreshItem(id)
+			n.updateStatsMsg()
+		}, w,
+	)
+	f.Resize(fyne.NewSize(480, 680))
+	f.Show()
+}
+
+// authEntries edits the credentials of a node or a jump host
+type authEntries struct {
+	authSelect      *widget.Select
+	passEntry       *widget.Entry
+	keyEntry        *widget.Entry
+	passphraseEntry *widget.Entry
+	certEntry       *widget.Entry
+}
+
+func newAuthEntries(auth utils.SSHAuth) *authEntries {
+	e := &authEntries{
+		passEntry:       widget.NewPasswordEntry(),
+		keyEntry:        widget.NewEntry(),
+		passphraseEntry: widget.NewPasswordEntry(),
+		certEntry:       widget.NewEntry(),
+	}
+	e.passEntry.SetText(auth.Password)
+	e.keyEntry.SetText(auth.KeyFile)
+	e.keyEntry.SetPlaceHolder("~/.ssh/id_ed25519")
+	e.passphraseEntry.SetText(auth.Passphrase)
+	e.certEntry.SetText(auth.CertFile)
+	e.certEntry.SetPlaceHolder("<private key file>-cert.pub")
+
+	e.authSelect = widget.NewSelect(utils.AuthTypes, func(authType string) {
+		for _, entry := range []*widget.Entry{e.passEntry, e.keyEntry, e.passphraseEntry, e.certEntry} {
+			entry.Disable()
+		}
+		switch authType {
+		case utils.AuthKey:
+			e.keyEntry.Enable()
+			e.passphraseEntry.Enable()
+		case utils.AuthCert:
+			e.keyEntry.Enable()
+			e.passphraseEntry.Enable()
+			e.certEntry.Enable()
+		case utils.AuthAgent:
+		default:
+			e.passEntry.Enable()
+		}
+	})
+	authType := auth.AuthType
+	if authType == "" {
+		authType = utils.AuthPassword
+	}
+	e.authSelect.SetSelected(authType)
+	return e
+}
+
+func (e *authEntries) formItems() []*widget.FormItem {
+	return []*widget.FormItem{
+		widget.NewFormItem("Auth", e.authSelect),
+		widget.NewFormItem("Password", e.passEntry),
+		widget.NewFormItem("Private key", e.keyEntry),
+		widget.NewFormItem("Passphrase", e.passphraseEntry),
+		widget.NewFormItem("Certificate", e.certEntry),
+	}
+}
+
+// auth collects the credentials of the selected authentication method
+func (e *authEntries) auth() utils.SSHAuth {
+	auth := utils.SSHAuth{AuthType: e.authSelect.Selected}
+	switch auth.AuthType {
+	case utils.AuthKey:
+		auth.KeyFile = e.keyEntry.Text
+		auth.Passphrase = e.passphraseEntry.Text
+	case utils.AuthCert:
+		auth.KeyFile = e.keyEntry.Text
+		auth.Passphrase = e.passphraseEntry.Text
+		auth.CertFile = e.certEntry.Text
+	case utils.AuthAgent:
+	default:
+		auth.Password = e.passEntry.Text
+	}
+	return auth
+}
+
+// optionsEntries edits the connection options of a node
+type optionsEntries struct {
+	portEntry      *widget.Entry
+	timeoutEntry   *widget.Entry
+	keepaliveEntry *widget.Entry
+	cipherEntry    *widget.SelectEntry
+	kexEntry       *widget.SelectEntry
+}
+
+func newOptionsEntries(opts utils.SSHOptions) *optionsEntries {
+	e := &optionsEntries{
+		portEntry:      widget.NewEntry(),
+		timeoutEntry:   widget.NewEntry(),
+		keepaliveEntry: widget.NewEntry(),
+		cipherEntry:    widget.NewSelectEntry(utils.SupportedCiphers),
+		kexEntry:       widget.NewSelectEntry(utils.SupportedKeyExchanges),
+	}
+	e.portEntry.SetPlaceHolder("22")
+	e.timeoutEntry.SetPlaceHolder(fmt.Sprintf("%d seconds", int(utils.DialTimeout/time.Second)))
+	e.keepaliveEntry.SetPlaceHolder("seconds, empty for none")
+	e.cipherEntry.SetPlaceHolder("default, separated by comma")
+	e.kexEntry.SetPlaceHolder("default, separated by comma")
+	if opts.Port > 0 {
+		e.portEntry.SetText(strconv.Itoa(opts.Port))
+	}
+	if opts.ConnectTimeout > 0 {
+		e.timeoutEntry.SetText(strconv.Itoa(int(opts.ConnectTimeout / time.Second)))
+	}
+	if opts.KeepaliveInterval > 0 {
+		e.keepaliveEntry.SetText(strconv.Itoa(int(opts.KeepaliveInterval / time.Second)))
+	}
+	e.cipherEntry.SetText(strings.Join(opts.Ciphers, ","))
+	e.kexEntry.SetText(strings.Join(opts.KeyExchanges, ","))
+	return e
+}
+
+func (e *optionsEntries) formItems() []*widget.FormItem {
+	return []*widget.FormItem{
+		widget.NewFormItem("Port", e.portEntry),
+		widget.NewFormItem("Connect timeout", e.timeoutEntry),
+		widget.NewFormItem("Keepalive", e.keepaliveEntry),
+		widget.NewFormItem("Ciphers", e.cipherEntry),
+		widget.NewFormItem("Key exchanges", e.kexEntry),
+	}
+}
+
+// options collects and validates the connection options, the empty
+// entries use the defaults
+func (e *optionsEntries) options() (utils.SSHOptions, error) {
+	var opts utils.SSHOptions
+	port, err := entryNumber(e.portEntry, "port")
+	if err != nil {
+		return opts, err
+	}
+	timeout, err := entryNumber(e.timeoutEntry, "connect timeout")
+	if err != nil {
+		return opts, err
+	}
+	keepalive, err := entryNumber(e.keepaliveEntry, "keepalive")
+	if err != nil {
+		return opts, err
+	}
+	opts = utils.SSHOptions{
+		Port:              port,
+		ConnectTimeout:    time.Duration(timeout) * time.Second,
+		KeepaliveInterval: time.Duration(keepalive) * time.Second,
+		Ciphers:           utils.SplitAlgorithms(e.cipherEntry.Text),
+		KeyExchanges:      utils.SplitAlgorithms(e.kexEntry.Text),
+	}
+	return opts, utils.ValidateOptions(&opts)
+}
+
+// entryNumber parses the number of the entry, empty means 0
+func entryNumber(entry *widget.Entry, name string) (int, error) {
+	text := strings.TrimSpace(entry.Text)
+	if text == "" {
+		return 0, nil
+	}
+	number, err := strconv.Atoi(text)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s '%s'", name, text)
+	}
+	return number, nil
+}
+
+// showHostKeyDialog views, accepts or revokes the trusted host key of a node
+func (n *NodesUI) showHostKeyDialog(w fyne.Window, id int) {
+
+	node := n.state.GetNodeRecord(id)
+	ip := node.IP
+	// the trusted keys are per host and port
+	addr := node.Address()
+
+	knownLabel := widget.NewLabel("")
+	knownLabel.Selectable = true
+	offeredLabel := widget.NewLabel("")
+	offeredLabel.Selectable = true
+	known := map[string]struct{}{}
+
+	refreshKnown := func() {
+		keys, err := utils.KnownHostKeys(addr)
+		if err != nil {
+			knownLabel.SetText(err.Error())
+			return
+		}
+		known = make(map[string]struct{}, len(keys))
+		lines := make([]string, 0, len(keys))
+		for _, key := range keys {
+			known[key.Fingerprint] = struct{}{}
+			lines = append(lines, fmt.Sprintf("%s %s (%s:%d)", key.Type, key.Fingerprint, key.File, key.Line))
+		}
+		if len(lines) == 0 {
+			knownLabel.SetText("not trusted yet")
+			return
+		}
+		knownLabel.SetText(strings.Join(lines, "\n"))
+	}
+	refreshKnown()
+
+	var offered ssh.PublicKey
+	var acceptBtn *widget.Button
+	fetchBtn := widget.NewButton("Fetch", func() {
+		offeredLabel.SetText("fetching...")
+		go func() {
+			conn, err := n.state.Connection(id)
+			if err != nil {
+				fyne.Do(func() {
+					offeredLabel.SetText(err.Error())
+				})
+				return
+			}
+			key, err := utils.FetchHostKey(conn.Config())
+			fyne.Do(func() {
+				if err != nil {
+					offeredLabel.SetText(err.Error())
+					return
+				}
+				offered = key
+				fingerprint := ssh.FingerprintSHA256(key)
+				if _, ok := known[fingerprint]; ok {
+					offeredLabel.SetText(fmt.Sprintf("%s %s (trusted)", key.Type(), fingerprint))
+					return
+				}
+				offeredLabel.SetText(fmt.Sprintf("%s %s (not trusted)", key.Type(), fingerprint))
+				acceptBtn.Enable()
+			})
+		}()
+	})
+	acceptBtn = widget.NewButton("Accept", func() {
+		if offered == nil {
+			return
+		}
+		if err := utils.AcceptHostKey(addr, offered); err != nil {
+			dialog.ShowCustom("Error", "Close", widget.NewLabel(err.Error()), w)
+			return
+		}
+		acceptBtn.Disable()
+		refreshKnown()
+		offeredLabel.SetText(fmt.Sprintf("%s %s (trusted)", offered.Type(), ssh.FingerprintSHA256(offered)))
+	})
+	acceptBtn.Disable()
+	revokeBtn := widget.NewButton("Revoke", func() {
+		dialog.ShowConfirm(
+			"Revoke confirm",
+			"The next connection will trust the key offered by "+ip+", continue?",
+			func(confirm bool) {
+				if !confirm {
+					return
+				}
+				if err := utils.RevokeHostKey(addr); err != nil {
+					dialog.ShowCustom("Error", "Close", widget.NewLabel(err.Error()), w)
+					return
+				}
+				refreshKnown()
+			}, w,
+		)
+	})
+
+	content := container.NewVBox(
+		widget.NewForm(
+			widget.NewFormItem("Node", widget.NewLabel(ip)),
+			widget.NewFormItem("Trusted", knownLabel),
+			widget.NewFormItem("Offered", offeredLabel),
+		),
+		container.NewHBox(fetchBtn, acceptBtn, revokeBtn),
+	)
+	d := dialog.NewCustom("Host Key", "Close", content, w)
+	d.Resize(fyne.NewSize(600, 260))
+	d.Show()
 }
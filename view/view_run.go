@@ -0,0 +1,129 @@
+package view
+
+import (
+	"context"
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/luo2pei4/ltool/pkg/config"
+	"github.com/luo2pei4/ltool/view/state"
+)
+
+// showRunDialog runs an ad-hoc command on the checked nodes
+func (n *NodesUI) showRunDialog(w fyne.Window) {
+
+	conns, err := n.state.CheckedConnections()
+	if err != nil {
+		showError(w, err)
+		return
+	}
+	if len(conns) == 0 {
+		dialog.ShowInformation("Run", "Check the nodes to run the command on", w)
+		return
+	}
+
+	var (
+		command string
+		results []state.RunResult
+		cancel  context.CancelFunc
+	)
+	cmdEntry := widget.NewEntry()
+	cmdEntry.SetPlaceHolder("shell command, e.g. lctl get_param version")
+	output := widget.NewLabel("")
+	output.TextStyle = fyne.TextStyle{Monospace: true}
+	output.Selectable = true
+	progress := widget.NewProgressBar()
+	progress.Max = float64(len(conns))
+	progress.TextFormatter = func() string {
+		return fmt.Sprintf("%d/%d", int(progress.Value), len(conns))
+	}
+	groupCheck := widget.NewCheck("Group identical output", func(grouped bool) {
+		output.SetText(state.FormatResults(results, grouped))
+	})
+
+	var runBtn, exportBtn *widget.Button
+	runBtn = widget.NewButton("Run", func() {
+		if cancel != nil {
+			runBtn.Disable()
+			cancel()
+			return
+		}
+		if cmdEntry.Text == "" {
+			w.Canvas().Focus(cmdEntry)
+			return
+		}
+		command = cmdEntry.Text
+		results = nil
+		output.SetText("")
+		progress.SetValue(0)
+		exportBtn.Disable()
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		runBtn.SetText("Cancel")
+		exec := n.state.Executor()
+		workers := config.Conf.Check.WorkerCount()
+		go func() {
+			res, err := state.RunCommand(ctx, exec, conns, command, workers, func(done, total int) {
+				fyne.Do(func() {
+					progress.SetValue(float64(done))
+				})
+			})
+			fyne.Do(func() {
+				cancel()
+				cancel = nil
+				results = res
+				output.SetText(state.FormatResults(results, groupCheck.Checked))
+				runBtn.SetText("Run")
+				runBtn.Enable()
+				exportBtn.Enable()
+				if err != nil {
+					showError(w, err)
+				}
+			})
+		}()
+	})
+	cmdEntry.OnSubmitted = func(string) {
+		if cancel == nil {
+			runBtn.OnTapped()
+		}
+	}
+	exportBtn = widget.NewButton("Export", func() {
+		dialog.ShowFileSave(func(writer fyne.URIWriteCloser, err error) {
+			if err != nil {
+				showError(w, err)
+				return
+			}
+			if writer == nil {
+				return
+			}
+			defer writer.Close()
+			if err := state.ExportResults(writer, command, results, groupCheck.Checked); err != nil {
+				showError(w, fmt.Errorf("export results failed, %v", err))
+			}
+		}, w)
+	})
+	exportBtn.Disable()
+
+	content := container.NewBorder(
+		container.NewVBox(
+			widget.NewLabel(fmt.Sprintf("Run on %d checked nodes", len(conns))),
+			container.NewBorder(nil, nil, nil, runBtn, cmdEntry),
+			progress,
+		),
+		container.NewHBox(groupCheck, exportBtn),
+		nil,
+		nil,
+		container.NewScroll(output),
+	)
+	d := dialog.NewCustom("Run", "Close", content, w)
+	d.SetOnClosed(func() {
+		if cancel != nil {
+			cancel()
+		}
+	})
+	d.Resize(fyne.NewSize(900, 600))
+	d.Show()
+}
@@ -0,0 +1,137 @@
+package view
+
+import (
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/luo2pei4/ltool/pkg/dblayer"
+	logger "github.com/luo2pei4/ltool/pkg/log"
+	"github.com/luo2pei4/ltool/view/state"
+)
+
+// sortNone the sort option of the order the nodes were added in
+const sortNone = "added"
+
+// createSearchBar creates the search entry, the sort options and the page
+// buttons above the node records
+func (n *NodesUI) createSearchBar(w fyne.Window) fyne.CanvasObject {
+	n.searchEntry = widget.NewEntry()
+	n.searchEntry.SetPlaceHolder("search, e.g. host:oss status:offline updated:>2026-01-10")
+	n.searchEntry.OnSubmitted = func(string) {
+		n.search(w)
+	}
+	n.sortSelect = widget.NewSelect(append([]string{sortNone}, dblayer.SortFields...), func(string) {
+		n.search(w)
+	})
+	n.sortSelect.SetSelectedIndex(0)
+	n.descCheck = widget.NewCheck("Desc", func(bool) {
+		n.search(w)
+	})
+	searchBtn := widget.NewButtonWithIcon("", theme.SearchIcon(), func() {
+		n.search(w)
+	})
+	helpBtn := widget.NewButtonWithIcon("", theme.HelpIcon(), func() {
+		label := widget.NewLabel(state.SearchHelp)
+		label.Wrapping = fyne.TextWrapWord
+		d := dialog.NewCustom("Search", "Close", label, w)
+		d.Resize(fyne.NewSize(480, 200))
+		d.Show()
+	})
+	n.prevBtn = widget.NewButtonWithIcon("", theme.NavigateBackIcon(), func() {
+		page, _ := n.state.Page()
+		n.turnPage(w, page-1)
+	})
+	n.nextBtn = widget.NewButtonWithIcon("", theme.NavigateNextIcon(), func() {
+		page, _ := n.state.Page()
+		n.turnPage(w, page+1)
+	})
+	n.pageLabel = widget.NewLabel("")
+	return container.NewBorder(nil, nil, nil,
+		container.NewHBox(searchBtn, helpBtn, n.sortSelect, n.descCheck, n.prevBtn, n.pageLabel, n.nextBtn),
+		n.searchEntry,
+	)
+}
+
+// search loads the first page of the nodes matching the search entry
+func (n *NodesUI) search(w fyne.Window) {
+	// the widgets are being created
+	if n.records == nil || n.descCheck == nil {
+		return
+	}
+	q, err := state.ParseNodeQuery(n.searchEntry.Text)
+	if err != nil {
+		dialog.ShowCustom("Warning", "Close", widget.NewLabel(err.Error()), w)
+		w.Canvas().Focus(n.searchEntry)
+		return
+	}
+	if err := state.SaveSearch(n.searchEntry.Text); err != nil {
+		logger.Warnf("%v", err)
+	}
+	// the chips apply unless the text searches the group or the tag
+	if q.Group == "" {
+		q.Group = n.groupFilter
+	}
+	if q.Tag == "" {
+		q.Tag = n.tagFilter
+	}
+	q.SortBy = n.sortSelect.Selected
+	if q.SortBy == sortNone {
+		q.SortBy = dblayer.SortByID
+	}
+	q.Desc = n.descCheck.Checked
+	n.reloadRecords(w, func() error {
+		return n.state.Search(q)
+	})
+}
+
+// turnPage loads the page of the current search
+func (n *NodesUI) turnPage(w fyne.Window, page int) {
+	n.reloadRecords(w, func() error {
+		return n.state.SetPage(page)
+	})
+}
+
+// reloadRecords replaces the records by load, the unsaved changes are
+// dropped after confirming.
+func (n *NodesUI) reloadRecords(w fyne.Window, load func() error) {
+	reload := func() {
+		if err := load(); err != nil {
+			dialog.ShowCustom("Error", "Close", widget.NewLabel(fmt.Sprintf("search nodes failed, %v", err)), w)
+		}
+		n.refreshChips(w)
+		n.records.Refresh()
+		n.updateStatsMsg()
+	}
+	if !n.state.HasUnsavedChanges() {
+		reload()
+		return
+	}
+	dialog.ShowConfirm("Search confirm", "Discard the unsaved nodes and changes?", func(confirm bool) {
+		if confirm {
+			reload()
+		}
+	}, w)
+}
+
+// updatePageMsg shows the page of the saved nodes
+func (n *NodesUI) updatePageMsg() {
+	if n.pageLabel == nil {
+		return
+	}
+	page, pages := n.state.Page()
+	n.pageLabel.SetText(fmt.Sprintf("%d/%d of %d", page+1, pages, n.state.Total))
+	if page > 0 {
+		n.prevBtn.Enable()
+	} else {
+		n.prevBtn.Disable()
+	}
+	if page+1 < pages {
+		n.nextBtn.Enable()
+	} else {
+		n.nextBtn.Disable()
+	}
+}
@@ -0,0 +1,102 @@
+package view
+
+import (
+	"errors"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/luo2pei4/ltool/pkg/dblayer"
+	logger "github.com/luo2pei4/ltool/pkg/log"
+)
+
+// ShowUnlockDialog asks for the master passphrase which encrypts the node
+// credentials, a new passphrase is set on the first run. onUnlocked is
+// called once the credentials are readable.
+func ShowUnlockDialog(w fyne.Window, onUnlocked func()) {
+	initialized, err := dblayer.HasPassphrase()
+	if err != nil {
+		logger.Errorf("load master passphrase settings failed, %v\n", err)
+		dialog.ShowCustomConfirm("Error", "Retry", "Quit", widget.NewLabel(err.Error()), func(retry bool) {
+			if !retry {
+				fyne.CurrentApp().Quit()
+				return
+			}
+			ShowUnlockDialog(w, onUnlocked)
+		}, w)
+		return
+	}
+
+	passEntry := widget.NewPasswordEntry()
+	confirmEntry := widget.NewPasswordEntry()
+	items := []*widget.FormItem{widget.NewFormItem("Passphrase", passEntry)}
+	title := "Unlock"
+	if !initialized {
+		title = "Set Master Passphrase"
+		items = append(items, widget.NewFormItem("Confirm", confirmEntry))
+	}
+
+	f := dialog.NewForm(title, "OK", "Quit", items, func(ok bool) {
+		if !ok {
+			fyne.CurrentApp().Quit()
+			return
+		}
+		var err error
+		switch {
+		case passEntry.Text == "":
+			err = errors.New("master passphrase is required")
+		case !initialized && passEntry.Text != confirmEntry.Text:
+			err = errors.New("the passphrases do not match")
+		case !initialized:
+			err = dblayer.SetPassphrase(passEntry.Text)
+		default:
+			err = dblayer.Unlock(passEntry.Text)
+		}
+		if err != nil {
+			d := dialog.NewCustom("Error", "Close", widget.NewLabel(err.Error()), w)
+			d.SetOnClosed(func() {
+				ShowUnlockDialog(w, onUnlocked)
+			})
+			d.Show()
+			return
+		}
+		onUnlocked()
+	}, w)
+	f.Resize(fyne.NewSize(400, 200))
+	f.Show()
+	w.Canvas().Focus(passEntry)
+}
+
+// ShowChangePassphraseDialog re-encrypts the node credentials with a new
+// master passphrase
+func ShowChangePassphraseDialog(w fyne.Window) {
+	oldEntry := widget.NewPasswordEntry()
+	newEntry := widget.NewPasswordEntry()
+	confirmEntry := widget.NewPasswordEntry()
+	items := []*widget.FormItem{
+		widget.NewFormItem("Current", oldEntry),
+		widget.NewFormItem("New", newEntry),
+		widget.NewFormItem("Confirm", confirmEntry),
+	}
+	f := dialog.NewForm("Change Master Passphrase", "Save", "Cancel", items, func(ok bool) {
+		if !ok {
+			return
+		}
+		var err error
+		switch {
+		case newEntry.Text == "":
+			err = errors.New("new passphrase is required")
+		case newEntry.Text != confirmEntry.Text:
+			err = errors.New("the passphrases do not match")
+		default:
+			err = dblayer.ChangePassphrase(oldEntry.Text, newEntry.Text)
+		}
+		if err != nil {
+			dialog.ShowCustom("Error", "Close", widget.NewLabel(err.Error()), w)
+			return
+		}
+		dialog.ShowInformation("Change Master Passphrase", "The credentials were encrypted with the new passphrase.", w)
+	}, w)
+	f.Resize(fyne.NewSize(400, 240))
+	f.Show()
+}
@@ -0,0 +1,133 @@
+package view
+
+import (
+	"context"
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	logger "github.com/luo2pei4/ltool/pkg/log"
+	"github.com/luo2pei4/ltool/pkg/utils"
+	"github.com/luo2pei4/ltool/view/state"
+	"github.com/luo2pei4/ltool/view/terminal"
+)
+
+// terminalWindow holds a tab per opened shell
+type terminalWindow struct {
+	win     fyne.Window
+	tabs    *container.DocTabs
+	closers map[*container.TabItem]func()
+	terms   map[*container.TabItem]*terminal.Terminal
+}
+
+// terminals is the terminal window, nil until a shell is opened
+var terminals *terminalWindow
+
+// showTerminal opens an interactive shell on the node in a new tab
+func showTerminal(exec utils.Executor, conn state.SSHConnection) {
+	if terminals == nil {
+		terminals = newTerminalWindow()
+	}
+	terminals.open(exec, conn)
+}
+
+func newTerminalWindow() *terminalWindow {
+	tw := &terminalWindow{
+		win:     fyne.CurrentApp().NewWindow("Terminal"),
+		tabs:    container.NewDocTabs(),
+		closers: make(map[*container.TabItem]func()),
+		terms:   make(map[*container.TabItem]*terminal.Terminal),
+	}
+	tw.tabs.OnSelected = func(item *container.TabItem) {
+		if term, ok := tw.terms[item]; ok {
+			tw.win.Canvas().Focus(term)
+		}
+	}
+	tw.tabs.OnClosed = func(item *container.TabItem) {
+		tw.closers[item]()
+		delete(tw.closers, item)
+		delete(tw.terms, item)
+		if len(tw.tabs.Items) == 0 {
+			tw.win.Close()
+		}
+	}
+	tw.win.SetContent(tw.tabs)
+	tw.win.SetOnClosed(func() {
+		for _, closeShell := range tw.closers {
+			closeShell()
+		}
+		terminals = nil
+	})
+	tw.win.Resize(fyne.NewSize(900, 600))
+	return tw
+}
+
+func (tw *terminalWindow) open(exec utils.Executor, conn state.SSHConnection) {
+
+	term := terminal.New()
+	focus := func() {
+		tw.win.Canvas().Focus(term)
+	}
+	copyBtn := widget.NewButtonWithIcon("Copy", theme.ContentCopyIcon(), func() {
+		term.Copy()
+		focus()
+	})
+	pasteBtn := widget.NewButtonWithIcon("Paste", theme.ContentPasteIcon(), func() {
+		term.Paste()
+		focus()
+	})
+	clearBtn := widget.NewButtonWithIcon("Clear scrollback", theme.DeleteIcon(), func() {
+		term.ClearScrollback()
+		focus()
+	})
+	content := container.NewBorder(container.NewHBox(copyBtn, pasteBtn, clearBtn), nil, nil, nil, term)
+	item := container.NewTabItem(conn.User+"@"+conn.IPAddress, content)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var shell *utils.Shell
+	tw.closers[item] = func() {
+		cancel()
+		if shell != nil {
+			shell.Close()
+		}
+	}
+	tw.terms[item] = term
+	tw.tabs.Append(item)
+	tw.tabs.Select(item)
+	tw.win.Show()
+	focus()
+
+	cols, rows := term.GridSize()
+	fmt.Fprintf(term, "Connecting to %s@%s ...\r\n", conn.User, conn.IPAddress)
+	go func() {
+		sh, err := exec.OpenShell(ctx, conn.Config(), cols, rows)
+		if err != nil {
+			logger.Errorf("open shell on %s failed, %v", conn.IPAddress, err)
+			fmt.Fprintf(term, "open shell failed, %v\r\n", err)
+			return
+		}
+		fyne.Do(func() {
+			shell = sh
+			if ctx.Err() != nil {
+				sh.Close()
+				return
+			}
+			term.OnResize = func(cols, rows int) {
+				sh.Resize(cols, rows)
+			}
+			// the window may have been resized while connecting
+			if c, r := term.GridSize(); c != cols || r != rows {
+				sh.Resize(c, r)
+			}
+		})
+		if err := term.Run(sh, sh); err != nil && ctx.Err() == nil {
+			logger.Warnf("read shell of %s failed, %v", conn.IPAddress, err)
+		}
+		if err := sh.Wait(); err != nil && ctx.Err() == nil {
+			logger.Warnf("shell of %s exited, %v", conn.IPAddress, err)
+		}
+		fmt.Fprint(term, "\r\n[connection closed]\r\n")
+	}()
+}